@@ -5,21 +5,29 @@ import (
 	"flag"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/zmlAEQ/Aequa-network/internal/api"
 	"github.com/zmlAEQ/Aequa-network/internal/consensus"
+	"github.com/zmlAEQ/Aequa-network/internal/consensus/hotstuff"
 	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
 	"github.com/zmlAEQ/Aequa-network/internal/monitoring"
 	"github.com/zmlAEQ/Aequa-network/internal/p2p"
 	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
 	payload "github.com/zmlAEQ/Aequa-network/internal/payload"
-	auction_v1 "github.com/zmlAEQ/Aequa-network/internal/payload/auction_bid_v1"
-	plaintext_v1 "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+	// Payload types register themselves (payload.Kind); import new ones here.
+	_ "github.com/zmlAEQ/Aequa-network/internal/payload/auction_bid_v1"
+	_ "github.com/zmlAEQ/Aequa-network/internal/payload/bundle_v1"
+	_ "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
 	private_v1 "github.com/zmlAEQ/Aequa-network/internal/payload/private_v1"
+	"github.com/zmlAEQ/Aequa-network/internal/payload/reconfig_v1"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
 	"github.com/zmlAEQ/Aequa-network/internal/tss"
 	"github.com/zmlAEQ/Aequa-network/pkg/bus"
+	"github.com/zmlAEQ/Aequa-network/pkg/config"
 	"github.com/zmlAEQ/Aequa-network/pkg/lifecycle"
 	"github.com/zmlAEQ/Aequa-network/pkg/logger"
 	"github.com/zmlAEQ/Aequa-network/pkg/trace"
@@ -48,6 +56,22 @@ func main() {
 		builderUseDFBA bool
 		beastThreshold bool
 		beastDKGConf   string
+		clusterLock    string
+		nodeID         string
+		nodeKey        string
+		roundTimeoutMs int
+		qbftVote       bool
+		verifyWorkers  int
+		stageQueue     int
+		dataDir        string
+		evPenalty      int64
+		engine         string
+		blsKey         string
+		poolLimits     payload.Limits
+		poolJournal    string
+		genesisPath    string
+		feeRecipient   string
+		chainID        string
 	)
 	flag.StringVar(&apiAddr, "validator-api", "127.0.0.1:4600", "Validator API listen address")
 	flag.StringVar(&monAddr, "monitoring", "127.0.0.1:4620", "Monitoring listen address")
@@ -69,6 +93,28 @@ func main() {
 	flag.Uint64Var(&builderMinFee, "builder.min-fee", 0, "Optional minimum fee for plaintext_v1 (0 keeps default)")
 	flag.IntVar(&builderTicksMs, "builder.batch-ticks-ms", 0, "Optional batch window in milliseconds for DFBA selection (0 disables windowing)")
 	flag.BoolVar(&builderUseDFBA, "builder.use-dfba", false, "Route builder selection through DFBA solver (experimental, behind flag)")
+	flag.StringVar(&clusterLock, "cluster.lock", "", "Path to cluster-lock JSON; sizes QBFT quorums and restricts votes to its operators (optional)")
+	flag.StringVar(&nodeID, "node.id", "", "Local operator id (cluster-lock peer_id) used as sender of locally generated QBFT messages")
+	flag.StringVar(&nodeKey, "node.key", "", "Path to hex ed25519 seed used to sign QBFT messages (requires --node.id matching a cluster-lock pubkey)")
+	flag.IntVar(&roundTimeoutMs, "qbft.round-timeout-ms", 0, "Base QBFT round timeout in milliseconds; doubles per round (0 keeps default 2000)")
+	flag.BoolVar(&qbftVote, "qbft.vote", false, "Actively participate in QBFT: propose when leading and cast own prepare/commit votes (requires --cluster.lock and --node.id)")
+	flag.IntVar(&verifyWorkers, "consensus.verify-workers", 0, "Parallel QBFT message verifiers (0 keeps default min(GOMAXPROCS,4))")
+	flag.IntVar(&stageQueue, "consensus.queue", 0, "Capacity of each consensus pipeline stage queue (0 keeps default 256)")
+	flag.StringVar(&dataDir, "data.dir", "", "Directory for durable node state (last state, committed blocks); empty keeps in-memory stores")
+	flag.Int64Var(&evPenalty, "evidence.penalty", 0, "P2P score penalty applied to an operator per detected equivocation (0 disables; needs P2P score gating)")
+	flag.StringVar(&engine, "consensus.engine", "qbft", "Consensus engine: qbft or hotstuff (chained HotStuff with linear view change; requires --cluster.lock)")
+	flag.StringVar(&blsKey, "hotstuff.bls-key", "", "Path to hex BLS12-381 secret key; aggregates HotStuff QCs with the cluster-lock bls_pubkey entries (requires -tags blst; default certifies with signed vote sets)")
+	flag.IntVar(&poolLimits.MaxPending, "mempool.max-pending", 0, "Ready txs kept per nonce-ordered pool; when full the lowest fee/bid is evicted (0 keeps default 8192)")
+	flag.IntVar(&poolLimits.MaxFuture, "mempool.max-future", 0, "Nonce-gapped txs kept per nonce-ordered pool (0 keeps default 2048)")
+	flag.IntVar(&poolLimits.MaxPerSender, "mempool.max-per-sender", 0, "Pending plus future txs kept per sender (0 keeps default 64)")
+	flag.Uint64Var(&poolLimits.MaxNonceGap, "mempool.max-nonce-gap", 0, "How far past its expected nonce a sender's future tx may be (0 keeps default 64)")
+	flag.DurationVar(&poolLimits.TTL, "mempool.ttl", 0, "Age at which pooled txs expire (0 keeps default 1h; negative disables)")
+	flag.Uint64Var(&poolLimits.TTLBlocks, "mempool.ttl-blocks", 0, "Committed blocks after which pooled txs expire (0 disables)")
+	flag.Uint64Var(&poolLimits.MinBumpPct, "mempool.min-bump-pct", 0, "Fee/bid increase in percent a tx must pay to replace a pooled one with the same sender and nonce (0 keeps default 10)")
+	flag.StringVar(&poolJournal, "mempool.journal", "local", "Mempool journal under --data.dir replayed on restart: local (txs submitted to this node), all (also gossiped txs) or off")
+	flag.StringVar(&genesisPath, "genesis", "", "Path to genesis JSON ({\"alloc\": {address: balance}}) the account state starts from (empty starts with no balances)")
+	flag.StringVar(&feeRecipient, "fee-recipient", "", "Address credited with the fees of blocks this node proposes (empty burns them)")
+	flag.StringVar(&chainID, "chain-id", payload.DefaultChainID, "Chain id plaintext_v1/auction_bid_v1 signatures must be made for; txs signed for another chain are rejected")
 	flag.Parse()
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
@@ -83,10 +129,11 @@ func main() {
 
 	m := lifecycle.New()
 	apis := api.New(apiAddr, publish, upstream)
+	apis.SetChainID(chainID)
 	// Publish validated txs to bus (KindTx) for local mempool ingest.
 	apis.SetTxPublisher(func(ctx context.Context, pl payload.Payload) {
 		tid, _ := trace.FromContext(ctx)
-		b.Publish(ctx, bus.Event{Kind: bus.KindTx, Body: pl, TraceID: tid})
+		b.Publish(ctx, bus.Event{Kind: bus.KindTx, Body: pl, TraceID: tid, Local: true})
 	})
 	m.Add(apis)
 	m.Add(monitoring.New(monAddr))
@@ -96,6 +143,135 @@ func main() {
 		m.Add(tss.New(p2ps))
 	}
 	cons := consensus.NewWithSub(b.Subscribe())
+	// Txs use the bus's separate lane so a flood cannot delay QBFT messages.
+	cons.SetTxSub(b.SubscribeTx())
+	cons.SetPipeline(verifyWorkers, stageQueue)
+	// Without a cluster lock the single legacy state is used; with one, a
+	// per-height manager buffers early messages and replays them in order.
+	var proc qbft.Processor = &qbft.State{Self: nodeID}
+	var vs *qbft.ValidatorSet
+	var epochs *qbft.Epochs
+	// Optional validator set from the cluster lock: 2f+1 quorums, proposer rotation and a member-only verifier.
+	if clusterLock != "" {
+		lock, err := config.LoadClusterLock(clusterLock)
+		if err != nil {
+			logger.ErrorJ("cluster_lock", map[string]any{"result": "error", "path": clusterLock, "err": err.Error()})
+			os.Exit(1)
+		}
+		vs, err = qbft.ValidatorSetFromLock(lock)
+		if err != nil {
+			logger.ErrorJ("cluster_lock", map[string]any{"result": "error", "path": clusterLock, "err": err.Error()})
+			os.Exit(1)
+		}
+		proc = qbft.NewManager(vs, nodeID)
+		if engine == "hotstuff" {
+			var scheme hotstuff.Scheme
+			if blsKey != "" {
+				if scheme, err = hotstuff.BLSFromLock(blsKey, lock); err != nil {
+					logger.ErrorJ("hotstuff", map[string]any{"result": "error", "path": blsKey, "err": err.Error()})
+					os.Exit(1)
+				}
+			}
+			proc = hotstuff.New(vs, nodeID, scheme)
+		}
+		cons.SetVerifier(qbft.NewBasicVerifierWithPolicy(qbft.Policy{Validators: vs}))
+		// Optional epochs: committed reconfig_v1 endorsements change the set at epoch boundaries.
+		if epochs, err = qbft.EpochsFromLock(lock, vs); err != nil {
+			logger.ErrorJ("cluster_lock", map[string]any{"result": "error", "path": clusterLock, "err": err.Error()})
+			os.Exit(1)
+		}
+		logger.InfoJ("cluster_lock", map[string]any{"result": "loaded", "name": lock.Name, "n": vs.Size(), "f": vs.F(), "quorum": vs.Quorum(), "signed": vs.HasKeys()})
+	}
+	switch {
+	case engine != "qbft" && engine != "hotstuff":
+		logger.ErrorJ("consensus_engine", map[string]any{"result": "error", "engine": engine, "err": "unknown engine"})
+		os.Exit(1)
+	case engine == "hotstuff" && vs == nil:
+		logger.ErrorJ("consensus_engine", map[string]any{"result": "error", "engine": engine, "err": "missing --cluster.lock"})
+		os.Exit(1)
+	case engine == "hotstuff" && epochs != nil:
+		logger.ErrorJ("consensus_engine", map[string]any{"result": "error", "engine": engine, "err": "epoch_length is not supported by hotstuff"})
+		os.Exit(1)
+	}
+	if epochs != nil {
+		cons.SetEpochs(epochs)
+		// Peers outside the set in force are refused once it changes.
+		epochs.OnActivate(func(from uint64, set *qbft.ValidatorSet) {
+			p2ps.SetAllowList(set.IDs()...)
+			logger.InfoJ("consensus_epoch", map[string]any{"result": "active", "from": from, "n": set.Size(), "quorum": set.Quorum()})
+		})
+	}
+	logger.InfoJ("consensus_engine", map[string]any{"result": "selected", "engine": engine})
+	if nodeKey != "" {
+		key, err := qbft.LoadNodeKey(nodeKey)
+		if err != nil || nodeID == "" {
+			reason := "missing --node.id"
+			if err != nil {
+				reason = err.Error()
+			}
+			logger.ErrorJ("node_key", map[string]any{"result": "error", "path": nodeKey, "err": reason})
+			os.Exit(1)
+		}
+		cons.SetMessageSigner(qbft.NewEd25519Signer(nodeID, key))
+	}
+	cons.SetProcessor(proc)
+	// Account state machine: committed blocks execute on the genesis balances.
+	if genesisPath != "" {
+		gen, err := config.LoadGenesis(genesisPath)
+		if err != nil {
+			logger.ErrorJ("genesis", map[string]any{"result": "error", "path": genesisPath, "err": err.Error()})
+			os.Exit(1)
+		}
+		cons.SetGenesis(gen.Alloc)
+		logger.InfoJ("genesis", map[string]any{"result": "loaded", "accounts": len(gen.Alloc)})
+	}
+	cons.SetFeeRecipient(feeRecipient)
+	cons.SetChainID(chainID)
+	if qbftVote && vs != nil && nodeID != "" {
+		cons.SetVoting(true)
+	}
+	// Optional durable state: last consensus coordinates, finalized blocks with commit seals and the vote WAL.
+	if dataDir != "" {
+		blocks, err := state.OpenFileBlockStore(filepath.Join(dataDir, "blocks"))
+		if err != nil {
+			logger.ErrorJ("block_store", map[string]any{"result": "error", "path": dataDir, "err": err.Error()})
+			os.Exit(1)
+		}
+		cons.SetStore(state.NewFileStore(filepath.Join(dataDir, "laststate.dat")))
+		cons.SetBlockStore(blocks)
+		wal := qbft.NewWAL(filepath.Join(dataDir, "wal"))
+		if err := wal.Open(); err != nil {
+			logger.ErrorJ("qbft_wal", map[string]any{"result": "error", "path": dataDir, "err": err.Error()})
+			os.Exit(1)
+		}
+		cons.SetWAL(wal)
+		switch poolJournal {
+		case "local", "all":
+			cons.SetMempoolJournal(state.NewTxJournal(filepath.Join(dataDir, "mempool.journal")), poolJournal == "all")
+		case "off":
+		default:
+			logger.ErrorJ("mempool_journal", map[string]any{"result": "error", "mode": poolJournal, "err": "want local, all or off"})
+			os.Exit(1)
+		}
+	}
+	// Equivocation evidence: detected on verified messages, persisted with --data.dir, served on GET /v1/evidence.
+	evPath := ""
+	if dataDir != "" {
+		evPath = filepath.Join(dataDir, "evidence.jsonl")
+	}
+	evidence, err := qbft.NewEvidencePool(evPath)
+	if err != nil {
+		logger.ErrorJ("qbft_evidence", map[string]any{"result": "error", "path": evPath, "err": err.Error()})
+		os.Exit(1)
+	}
+	cons.SetEvidencePool(evidence)
+	apis.SetEvidenceSource(evidence)
+	if evPenalty > 0 {
+		cons.SetPeerPenalizer(p2ps, evPenalty)
+	}
+	if roundTimeoutMs > 0 {
+		cons.SetRoundTimeout(time.Duration(roundTimeoutMs)*time.Millisecond, 0)
+	}
 	// Optional deterministic builder/DFBA configuration (behind flag).
 	if enableBuilder {
 		os.Setenv("AEQUA_ENABLE_BUILDER", "1")
@@ -115,11 +291,17 @@ func main() {
 	}
 	// Wire a minimal mempool container for plaintext_v1 (used by tx gossip).
 	{
-		pools := map[string]payload.TypedMempool{}
-		pools["auction_bid_v1"] = auction_v1.New()
-		pools["plaintext_v1"] = plaintext_v1.New()
+		// Every registered type with a pool constructor gets a pool;
+		// private_v1 only with BEAST enabled.
+		skip := []string{"private_v1"}
+		if enableBeast {
+			skip = nil
+		}
+		pools := payload.NewPools(poolLimits, skip...)
+		if epochs != nil {
+			pools[reconfig_v1.Type] = reconfig_v1.New(epochs.Current)
+		}
 		if enableBeast {
-			pools["private_v1"] = private_v1.New()
 			os.Setenv("AEQUA_ENABLE_BEAST", "1")
 			if enableJSON {
 				private_v1.EnableLocalJSONDecrypt()
@@ -150,7 +332,7 @@ func main() {
 
 	// Start P2P transport (behind build tag); safe no-op without 'p2p' tag or when disabled.
 	if p2pEnable {
-		cfg := p2p.NetConfig{Enable: true, NAT: p2pNAT, EnableBeast: enableBeast, EnableTSSDKG: beastDKGConf != ""}
+		cfg := p2p.NetConfig{Enable: true, NAT: p2pNAT, EnableBeast: enableBeast, EnableTSSDKG: beastDKGConf != "", ChainID: chainID}
 		if p2pListen != "" {
 			cfg.Listen = []string{p2pListen}
 		}
@@ -177,6 +359,9 @@ func main() {
 			}
 		}
 		if t, _ := p2p.StartTransportIfEnabled(ctx, cfg); t != nil {
+			// Local votes and round-change timeouts go out over the consensus topic.
+			cons.SetBroadcaster(t)
+			cons.SetTxBroadcaster(t)
 			t.OnQBFT(func(m qbft.Message) {
 				b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Height: m.Height, Round: m.Round, Body: m, TraceID: m.TraceID})
 			})
@@ -197,6 +382,19 @@ func main() {
 					})
 				}
 			}
+			// Serve committed blocks to lagging peers; with a cluster lock to
+			// check commit seals against, catch up from them as well.
+			if st, ok := t.(p2p.SyncTransport); ok {
+				st.OnSyncRequest(cons.ServeBlocks)
+				if vs != nil {
+					// HotStuff commits a few heights behind its proposals.
+					lag := uint64(0)
+					if engine == "hotstuff" {
+						lag = hotstuff.PipelineDepth + 2
+					}
+					cons.SetBlockSync(st, vs, lag)
+				}
+			}
 			// ensure graceful stop with lifecycle: wrap and add
 			m.Add(p2p.NewNetService(t))
 			// Optionally allow API to broadcast tx when enabled.
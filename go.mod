@@ -2,4 +2,4 @@ module github.com/zmlAEQ/Aequa-network
 
 go 1.24
 
-require github.com/supranational/blst v0.3.16 // indirect
+require github.com/supranational/blst v0.3.16
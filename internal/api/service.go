@@ -8,8 +8,10 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"time"
 
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
 	wire "github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
 	payload "github.com/zmlAEQ/Aequa-network/internal/payload"
 	"github.com/zmlAEQ/Aequa-network/pkg/lifecycle"
@@ -23,6 +25,11 @@ type txBroadcaster interface {
 	BroadcastTx(ctx context.Context, tx payload.Payload) error
 }
 
+// evidenceSource lists recorded equivocation evidence (qbft.EvidencePool).
+type evidenceSource interface {
+	List(from string, minHeight uint64) []qbft.Evidence
+}
+
 type Service struct {
 	addr        string
 	srv         *http.Server
@@ -30,6 +37,8 @@ type Service struct {
 	upstream    string
 	txb         txBroadcaster
 	onPublishTx func(ctx context.Context, pl payload.Payload)
+	evidence    evidenceSource
+	chainID     string
 }
 
 func New(addr string, onPublish func(ctx context.Context, payload []byte) error, upstream string) *Service {
@@ -46,6 +55,9 @@ func (s *Service) Start(ctx context.Context) error {
 	if os.Getenv("AEQUA_ENABLE_TX_API") == "1" {
 		mux.HandleFunc("/v1/tx/plain", s.handleTxPlain)
 	}
+	if s.evidence != nil {
+		mux.HandleFunc("/v1/evidence", s.handleEvidence)
+	}
 	mux.HandleFunc("/", s.proxy)
 	s.srv = &http.Server{Addr: s.addr, Handler: mux}
 	go func() {
@@ -249,7 +261,9 @@ func (s *Service) handleHealth(w http.ResponseWriter, r *http.Request) {
 // SetTxBroadcaster injects an optional P2P broadcaster for tx gossip (behind flag).
 func (s *Service) SetTxBroadcaster(b txBroadcaster) { s.txb = b }
 
-// handleTxPlain accepts a plaintext_v1 transaction and optionally gossips it.
+// handleTxPlain accepts a transaction as wire JSON, or in the canonical binary
+// encoding with Content-Type application/octet-stream, and optionally gossips
+// it.
 func (s *Service) handleTxPlain(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	tid := traceID(r)
@@ -267,8 +281,14 @@ func (s *Service) handleTxPlain(w http.ResponseWriter, r *http.Request) {
 		s.logAPI(w, route, http.StatusBadRequest, start, tid, "error", "read error")
 		return
 	}
-	pl, err := wire.ParseTx(b)
-	if err != nil {
+	var pl payload.Payload
+	if r.Header.Get("Content-Type") == "application/octet-stream" {
+		// Canonical binary encoding (wire.MarshalTx).
+		if pl, err = wire.UnmarshalTx(b); err != nil {
+			s.logAPI(w, route, http.StatusBadRequest, start, tid, "error", "invalid encoding")
+			return
+		}
+	} else if pl, err = wire.ParseTx(b); err != nil {
 		s.logAPI(w, route, http.StatusBadRequest, start, tid, "error", "invalid json")
 		return
 	}
@@ -281,6 +301,11 @@ func (s *Service) handleTxPlain(w http.ResponseWriter, r *http.Request) {
 		s.logAPI(w, route, http.StatusBadRequest, start, tid, "error", "invalid tx")
 		return
 	}
+	if payload.VerifySig(pl, s.chainID) != nil {
+		metrics.Inc("mempool_in_total", map[string]string{"result": "bad_sig"})
+		s.logAPI(w, route, http.StatusBadRequest, start, tid, "error", "bad signature")
+		return
+	}
 	// Publish to bus for local mempool ingest (structured payload)
 	if s.onPublishTx != nil {
 		s.onPublishTx(trace.WithTraceID(r.Context(), tid), pl)
@@ -300,3 +325,44 @@ func (s *Service) handleTxPlain(w http.ResponseWriter, r *http.Request) {
 func (s *Service) SetTxPublisher(fn func(ctx context.Context, pl payload.Payload)) {
 	s.onPublishTx = fn
 }
+
+// SetChainID sets the chain id tx signatures are checked against (empty
+// keeps payload.DefaultChainID).
+func (s *Service) SetChainID(id string) { s.chainID = id }
+
+// SetEvidenceSource exposes recorded equivocation evidence on GET /v1/evidence.
+func (s *Service) SetEvidenceSource(src evidenceSource) { s.evidence = src }
+
+// handleEvidence lists equivocation evidence, optionally filtered by
+// ?from=<operator id> and ?min_height=<height>.
+func (s *Service) handleEvidence(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	tid := traceID(r)
+	route := "/v1/evidence"
+	if r.Method != http.MethodGet {
+		s.logAPI(w, route, http.StatusMethodNotAllowed, start, tid, "error", "method not allowed")
+		return
+	}
+	var minHeight uint64
+	if v := r.URL.Query().Get("min_height"); v != "" {
+		h, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			s.logAPI(w, route, http.StatusBadRequest, start, tid, "error", "invalid min_height")
+			return
+		}
+		minHeight = h
+	}
+	list := s.evidence.List(r.URL.Query().Get("from"), minHeight)
+	b, err := json.Marshal(map[string]any{"evidence": list})
+	if err != nil {
+		s.logAPI(w, route, http.StatusInternalServerError, start, tid, "error", "encode error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(b)
+	dur := time.Since(start)
+	metrics.Inc("api_requests_total", map[string]string{"route": route, "code": "200"})
+	metrics.ObserveSummary("api_latency_ms", map[string]string{"route": route}, float64(dur.Milliseconds()))
+	logger.InfoJ("api_request", map[string]any{"route": route, "code": 200, "items": len(list), "latency_ms": dur.Milliseconds(), "result": "ok", "trace_id": tid})
+}
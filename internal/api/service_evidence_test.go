@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+)
+
+func TestHandleEvidence_ListsAndFilters(t *testing.T) {
+	pool, _ := qbft.NewEvidencePool("")
+	_, _ = pool.Observe(qbft.Message{Type: qbft.MsgCommit, From: "n1", Height: 3, ID: "a"})
+	_, _ = pool.Observe(qbft.Message{Type: qbft.MsgCommit, From: "n1", Height: 3, ID: "b"})
+	s := &Service{addr: ":0"}
+	s.SetEvidenceSource(pool)
+
+	rr := httptest.NewRecorder()
+	s.handleEvidence(rr, httptest.NewRequest(http.MethodGet, "/v1/evidence?from=n1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d", rr.Code)
+	}
+	var body struct {
+		Evidence []qbft.Evidence `json:"evidence"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || len(body.Evidence) != 1 || body.Evidence[0].Kind != qbft.EvidenceDoubleCommit {
+		t.Fatalf("unexpected body %s err=%v", rr.Body.String(), err)
+	}
+
+	rr = httptest.NewRecorder()
+	s.handleEvidence(rr, httptest.NewRequest(http.MethodGet, "/v1/evidence?min_height=4", nil))
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || len(body.Evidence) != 0 {
+		t.Fatalf("min_height filter: %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	s.handleEvidence(rr, httptest.NewRequest(http.MethodGet, "/v1/evidence?min_height=x", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("want 400 for bad min_height, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	s.handleEvidence(rr, httptest.NewRequest(http.MethodPost, "/v1/evidence", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("want 405, got %d", rr.Code)
+	}
+}
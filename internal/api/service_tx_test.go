@@ -3,15 +3,30 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	wire "github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
 	payload "github.com/zmlAEQ/Aequa-network/internal/payload"
+	plaintext "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
 )
 
+// signedTx returns a plaintext_v1 tx signed for chainID in wire JSON.
+func signedTx(t *testing.T, chainID string) []byte {
+	t.Helper()
+	_, key, _ := ed25519.GenerateKey(nil)
+	tx := &plaintext.PlaintextTx{Nonce: 0, Gas: 21000, Fee: 100}
+	tx.Sign(chainID, key)
+	env, _ := wire.TxFromInternal(tx)
+	b, _ := json.Marshal(env)
+	return b
+}
+
 // stubBroadcaster implements txBroadcaster for tests.
 type stubBroadcaster struct {
 	calls int
@@ -35,8 +50,7 @@ func TestHandleTxPlain_OK_PublishAndBroadcast(t *testing.T) {
 	sb := &stubBroadcaster{}
 	s.SetTxBroadcaster(sb)
 
-	wtx := wire.TxEnvelope{Type: wire.TypePlaintextV1, From: "A", Nonce: 0, Gas: 21000, Fee: 100, Sig: bytes.Repeat([]byte{1}, 32)}
-	b, _ := json.Marshal(wtx)
+	b := signedTx(t, payload.DefaultChainID)
 	req := httptest.NewRequest(http.MethodPost, "/v1/tx/plain", bytes.NewReader(b))
 	rr := httptest.NewRecorder()
 
@@ -84,3 +98,50 @@ func TestHandleTxPlain_InvalidTx(t *testing.T) {
 		t.Fatalf("expected 400, got %d", rr.Code)
 	}
 }
+
+func TestHandleTxPlain_BadSignature(t *testing.T) {
+	metrics.Reset()
+	s := &Service{addr: ":0"}
+	s.SetChainID("chain-a")
+	published := 0
+	s.SetTxPublisher(func(context.Context, payload.Payload) { published++ })
+	forged := wire.TxEnvelope{Type: wire.TypePlaintextV1, From: "A", Gas: 21000, Fee: 100, Sig: bytes.Repeat([]byte{1}, 64)}
+	fb, _ := json.Marshal(forged)
+	for _, b := range [][]byte{fb, signedTx(t, "chain-b")} {
+		rr := httptest.NewRecorder()
+		s.handleTxPlain(rr, httptest.NewRequest(http.MethodPost, "/v1/tx/plain", bytes.NewReader(b)))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", rr.Code)
+		}
+	}
+	if published != 0 {
+		t.Fatalf("unauthenticated tx published")
+	}
+	if !strings.Contains(metrics.DumpProm(), `mempool_in_total{result="bad_sig"} 2`) {
+		t.Fatalf("bad_sig not counted")
+	}
+}
+
+func TestHandleTxPlain_BinaryEncoding(t *testing.T) {
+	s := &Service{addr: ":0"}
+	var pub payload.Payload
+	s.SetTxPublisher(func(_ context.Context, pl payload.Payload) { pub = pl })
+	var env wire.TxEnvelope
+	_ = json.Unmarshal(signedTx(t, payload.DefaultChainID), &env)
+	enc, _ := wire.MarshalTx(env.ToInternal())
+	for _, tc := range []struct {
+		body []byte
+		code int
+	}{{enc, http.StatusAccepted}, {enc[:len(enc)-1], http.StatusBadRequest}} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/tx/plain", bytes.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		rr := httptest.NewRecorder()
+		s.handleTxPlain(rr, req)
+		if rr.Code != tc.code {
+			t.Fatalf("expected %d, got %d", tc.code, rr.Code)
+		}
+	}
+	if pub == nil || !bytes.Equal(pub.Hash(), env.ToInternal().Hash()) {
+		t.Fatalf("binary tx not published")
+	}
+}
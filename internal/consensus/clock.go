@@ -0,0 +1,28 @@
+package consensus
+
+import "time"
+
+// Clock creates the timers the consensus loop waits on (round timeouts).
+// The default is wall-clock time; simulations inject a virtual clock.
+type Clock interface {
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a stoppable one-shot timer, mirroring *time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type realClock struct{}
+
+type realTimer struct{ t *time.Timer }
+
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+func (t realTimer) Stop() bool          { return t.t.Stop() }
+
+// SetClock replaces the clock used for round timers (nil keeps wall-clock
+// time). It must be called before Start.
+func (s *Service) SetClock(c Clock) { s.clock = c }
@@ -0,0 +1,123 @@
+package consensus
+
+import (
+	"context"
+	"encoding/hex"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/pkg/logger"
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// sealer is implemented by processors that expose the committed value and
+// its commit seal once the current height committed (qbft.State and
+// qbft.Manager do).
+type sealer interface {
+	CommitSeal() (round uint64, id string, payload []byte, seal []qbft.Message, ok bool)
+}
+
+// aggSealer is implemented by processors whose commit seal may be one
+// aggregated signature rather than signed votes (hotstuff.Engine with the
+// BLS scheme): CommitAggSeal returns it for the block CommitSeal returns.
+type aggSealer interface {
+	CommitAggSeal() (signers []string, agg []byte, ok bool)
+}
+
+// heightAdvancer is implemented by processors that run one instance per
+// height (qbft.Manager does): Advance starts the next height, Restore resumes
+// at a given height after restart.
+type heightAdvancer interface {
+	Advance()
+	Restore(height, round uint64)
+}
+
+// SetBlockStore injects the store for finalized blocks. If nil, a
+// MemoryBlockStore is instantiated on start.
+func (s *Service) SetBlockStore(bs state.BlockStore) { s.blocks = bs }
+
+// onCommit executes and persists the block finalized at height h together
+// with its commit seal, state root and receipts, compacts the WAL below h,
+// prunes and checkpoints the anti-replay cache, then prunes built blocks of
+// earlier heights from memory. The entry of
+// h itself is kept until the next commit so the commit-path accounting (value
+// metrics, TSS sign) can still read it.
+func (s *Service) onCommit(ctx context.Context, h uint64) {
+	defer s.pruneBlocks(h)
+	// Votes below the committed height can no longer matter for safety.
+	if err := s.wal.Compact(h); err != nil {
+		logger.ErrorJ("qbft_wal", map[string]any{"op": "compact", "result": "error", "below": h, "err": err.Error()})
+	}
+	s.checkpointReplay(ctx, h)
+	sl, ok := s.st.(sealer)
+	if !ok || s.blocks == nil {
+		return
+	}
+	round, id, value, seal, ok := sl.CommitSeal()
+	if !ok {
+		return
+	}
+	blk, ok := s.lastBlock[h][round]
+	if !ok && len(value) > 0 {
+		// The committed value carries the agreed block.
+		if dec, err := wire.DecodeBlock(value); err == nil {
+			blk, ok = dec, true
+		}
+	}
+	if !ok {
+		// Builder disabled or no block built for this round: record the
+		// finalized coordinates and seal with an empty body.
+		blk = pl.StandardBlock{Header: pl.BlockHeader{Height: h, Round: round}}
+	}
+	raw, err := wire.EncodeBlock(blk)
+	if err != nil {
+		metrics.Inc("consensus_block_commit_total", map[string]string{"result": "encode_error"})
+		logger.ErrorJ("consensus_block", map[string]any{"op": "commit", "result": "encode_error", "height": h, "round": round, "err": err.Error()})
+		return
+	}
+	rec := state.BlockRecord{Height: h, Round: round, ID: id, Hash: blk.Hash(), Block: raw}
+	for _, m := range seal {
+		rec.Seal = append(rec.Seal, state.CommitSig{From: m.From, Sig: m.Sig})
+	}
+	if as, ok := s.st.(aggSealer); ok {
+		if signers, agg, ok := as.CommitAggSeal(); ok {
+			rec.AggSeal = &state.AggSeal{Signers: signers, Sig: agg}
+		}
+	}
+	if err := s.saveExecuted(ctx, &rec, blk); err != nil {
+		metrics.Inc("consensus_block_commit_total", map[string]string{"result": "error"})
+		logger.ErrorJ("consensus_block", map[string]any{"op": "commit", "result": "error", "height": h, "round": round, "err": err.Error()})
+		return
+	}
+	metrics.Inc("consensus_block_commit_total", map[string]string{"result": "ok"})
+	s.applyReconfig(ctx, h)
+	s.pruneMempool(blk, rec.Receipts)
+	logger.InfoJ("consensus_block", map[string]any{"op": "commit", "result": "ok", "height": h, "round": round, "id": id, "items": len(blk.Items), "seal": len(rec.Seal), "agg_seal": rec.AggSeal != nil, "state_root": hex.EncodeToString(rec.StateRoot)})
+}
+
+// restoreHeight resumes a per-height processor right after the last
+// finalized block, so a restarted node neither re-runs finished heights nor
+// lets the first gossiped message pick its starting height.
+func (s *Service) restoreHeight(ctx context.Context) {
+	adv, ok := s.st.(heightAdvancer)
+	if !ok {
+		return
+	}
+	last, err := s.blocks.LastBlock(ctx)
+	if err != nil {
+		return
+	}
+	adv.Restore(last.Height+1, 0)
+	logger.InfoJ("consensus_state", map[string]any{"op": "restore", "result": "ok", "height": last.Height + 1, "trace_id": ""})
+}
+
+// pruneBlocks drops built blocks for heights below h.
+func (s *Service) pruneBlocks(h uint64) {
+	for bh := range s.lastBlock {
+		if bh < h {
+			delete(s.lastBlock, bh)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package consensus
+
+import (
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/pkg/logger"
+)
+
+// PeerPenalizer lowers the P2P score of an operator caught misbehaving
+// (p2p.Service implements it on top of its score gate).
+type PeerPenalizer interface {
+	Penalize(id string, delta int64, reason string)
+}
+
+// SetEvidencePool injects the equivocation detector/evidence store. When nil,
+// verified messages are not checked for equivocation.
+func (s *Service) SetEvidencePool(p *qbft.EvidencePool) { s.evidence = p }
+
+// SetPeerPenalizer optionally feeds detected equivocations into P2P scoring:
+// each new piece of evidence lowers the offender's score by delta.
+func (s *Service) SetPeerPenalizer(pp PeerPenalizer, delta int64) {
+	s.penalizer = pp
+	s.penalty = delta
+}
+
+// observeEvidence runs equivocation detection on a verified message and
+// reports whether msg conflicts with its sender's first message at the same
+// coordinates.
+func (s *Service) observeEvidence(msg qbft.Message) bool {
+	if s.evidence == nil {
+		return false
+	}
+	ev, ok := s.evidence.Observe(msg)
+	if !ok {
+		return s.evidence.Conflicts(msg)
+	}
+	if s.penalizer != nil && s.penalty > 0 {
+		s.penalizer.Penalize(ev.From, s.penalty, ev.Kind)
+		logger.InfoJ("consensus_evidence", map[string]any{"result": "penalized", "kind": ev.Kind, "from": ev.From, "height": ev.Height, "round": ev.Round, "delta": s.penalty})
+	}
+	return true
+}
@@ -0,0 +1,575 @@
+// Package hotstuff is a chained HotStuff consensus engine that plugs into
+// consensus.Service in place of the QBFT manager. It speaks the shared
+// qbft.Message envelope (types hs_proposal, hs_vote, hs_new_view), so the
+// same verifier, vote WAL, evidence pool and block store serve both engines.
+//
+// Every view has one leader (round-robin over the validator set). The leader
+// proposes a block extending the highest QC it knows; replicas vote for it
+// and send the vote to the next leader only, which aggregates 2f+1 votes
+// into the QC its own proposal carries. A replica that times out sends its
+// highest QC to the leader of the next view (linear view change); that
+// leader proposes once 2f+1 such new-views arrived. Votes follow the
+// locking rule (the justify QC is at least as recent as the locked QC) and
+// are only cast by replicas holding the block's whole uncommitted ancestry.
+// A block commits, with its uncommitted ancestors, once it heads a chain of
+// three certified blocks in consecutive views.
+package hotstuff
+
+import (
+	"fmt"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/pkg/logger"
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// PipelineDepth is how many heights proposals run ahead of the last
+// committed one in steady state. Block sync must tolerate that lag.
+const PipelineDepth = 3
+
+// block is a proposed block as seen by this replica.
+type block struct {
+	id      string
+	parent  string // justify.ID: proposals always extend their QC
+	height  uint64
+	view    uint64
+	payload []byte
+	justify QC
+	msg     qbft.Message // signed proposal without its own parent
+}
+
+type voteKey struct {
+	view, height uint64
+	id           string
+}
+
+// Engine is one replica. It is driven from the consensus loop and is not
+// safe for concurrent use.
+type Engine struct {
+	vs       *qbft.ValidatorSet
+	self     string
+	scheme   Scheme
+	validate func(qbft.Message) error
+
+	view     uint64 // proposals for earlier views are ignored
+	failed   uint64 // consecutive timeouts in view
+	lastVote uint64 // highest view voted in
+	proposed uint64 // highest view proposed in
+	highQC   QC
+	lockedQC QC
+	owed     []qbft.Message // own votes not handed out yet
+	myVote   qbft.Message   // last own vote as signed and sent
+
+	blocks   map[string]*block
+	certs    map[string]QC // by certified block id
+	votes    map[voteKey]map[string]qbft.Message
+	formed   map[voteKey]bool
+	newViews map[uint64]map[string]struct{} // senders by target view
+
+	root   uint64   // height of a block extending the genesis QC
+	base   uint64   // lowest uncommitted height
+	ready  []*block // committed blocks from base upwards, not advanced yet
+	lastID string   // id of the last committed block ("" unknown)
+}
+
+// New builds a replica for self over vs certifying votes with scheme (nil
+// keeps VoteSet). An empty self observes without proposing or voting.
+func New(vs *qbft.ValidatorSet, self string, scheme Scheme) *Engine {
+	if scheme == nil {
+		scheme = VoteSet{}
+	}
+	return &Engine{
+		vs: vs, self: self, scheme: scheme,
+		view: 1, highQC: genesisQC(), lockedQC: genesisQC(),
+		blocks:   map[string]*block{},
+		certs:    map[string]QC{},
+		votes:    map[voteKey]map[string]qbft.Message{},
+		formed:   map[voteKey]bool{},
+		newViews: map[uint64]map[string]struct{}{},
+	}
+}
+
+// SetProposalValidator installs a check of proposed blocks; a replica does
+// not vote for a block it rejects. The validator sees the proposal with the
+// encoded block as Payload and the view as Round.
+func (e *Engine) SetProposalValidator(fn func(qbft.Message) error) { e.validate = fn }
+
+// Leader returns the proposer of view v.
+func (e *Engine) Leader(v uint64) string { return e.vs.Proposer(0, v) }
+
+// Recipient names the single addressee of msg: votes go to the leader of
+// the next view, new-views to the leader of the view they ask for.
+// Proposals are broadcast (false).
+func (e *Engine) Recipient(msg qbft.Message) (string, bool) {
+	switch msg.Type {
+	case qbft.MsgHSVote:
+		return e.Leader(msg.Round + 1), true
+	case qbft.MsgHSNewView:
+		return e.Leader(msg.Round), true
+	}
+	return "", false
+}
+
+// SealType is the vote type of commit seals: a block is sealed by the QC
+// that certified it.
+func (e *Engine) SealType() qbft.Type { return qbft.MsgHSVote }
+
+// View reports the lowest uncommitted height, the number of consecutive
+// timeouts (the round-timer backoff) and a phase that changes with every
+// view, or "commit" while a committed block waits to be advanced past.
+func (e *Engine) View() (height, round uint64, phase string) {
+	if len(e.ready) > 0 {
+		return e.base, e.failed, "commit"
+	}
+	return e.base, e.failed, fmt.Sprintf("view %d", e.view)
+}
+
+// Process applies a verified message.
+func (e *Engine) Process(msg qbft.Message) error {
+	var err error
+	switch msg.Type {
+	case qbft.MsgHSProposal:
+		err = e.onProposal(msg)
+	case qbft.MsgHSVote:
+		err = e.onVote(msg)
+	case qbft.MsgHSNewView:
+		err = e.onNewView(msg)
+	default:
+		err = fmt.Errorf("unexpected %s", msg.Type)
+	}
+	result := "ok"
+	if err != nil {
+		result = "reject"
+		logger.InfoJ("hotstuff", map[string]any{"result": "reject", "type": string(msg.Type), "from": msg.From, "height": msg.Height, "view": msg.Round, "err": err.Error()})
+	}
+	metrics.Inc("hotstuff_msg_total", map[string]string{"type": string(msg.Type), "result": result})
+	return err
+}
+
+func (e *Engine) onProposal(msg qbft.Message) error {
+	v := msg.Round
+	if want := e.Leader(v); msg.From != want {
+		return fmt.Errorf("proposal from %s, leader is %s", msg.From, want)
+	}
+	if v < e.view || v <= e.lastVote {
+		return fmt.Errorf("stale view %d", v)
+	}
+	c, err := extract(msg)
+	if err != nil {
+		return err
+	}
+	if c.vote != nil {
+		return fmt.Errorf("proposal carries a vote")
+	}
+	b, err := e.check(msg, c)
+	if err != nil {
+		return err
+	}
+	if c.parent != nil && e.blocks[c.qc.ID] == nil {
+		if err := e.adopt(*c.parent, c.qc); err != nil {
+			logger.InfoJ("hotstuff", map[string]any{"result": "parent_reject", "view": v, "id": c.qc.ID, "err": err.Error()})
+		}
+	}
+	e.observe(c.qc)
+	e.store(b)
+	// Locking rule: never vote against the locked chain unless the proposal
+	// carries a QC at least as recent.
+	if c.qc.View < e.lockedQC.View {
+		return fmt.Errorf("qc view %d below locked %d", c.qc.View, e.lockedQC.View)
+	}
+	// Only vote for a chain this replica can commit: every certified block
+	// then has a quorum able to link it down to the committed prefix.
+	if !e.linked(b) {
+		return fmt.Errorf("ancestry of height %d unknown", b.height)
+	}
+	e.enter(v + 1)
+	if e.self == "" {
+		return nil
+	}
+	vote := qbft.Message{Type: qbft.MsgHSVote, From: e.self, Height: msg.Height, Round: v, ID: msg.ID, TraceID: msg.TraceID}
+	share, err := e.scheme.Share(vote)
+	if err != nil {
+		logger.ErrorJ("hotstuff", map[string]any{"result": "share_error", "scheme": e.scheme.Name(), "view": v, "err": err.Error()})
+		return nil
+	}
+	vote.Share = share
+	e.lastVote = v
+	e.owed = append(e.owed, vote)
+	return nil
+}
+
+// check validates a proposal's QC, height and block and returns the block.
+func (e *Engine) check(msg qbft.Message, c carried) (*block, error) {
+	if err := e.verifyQC(c.qc); err != nil {
+		return nil, err
+	}
+	if c.qc.View >= msg.Round {
+		return nil, fmt.Errorf("qc view %d not below proposal view %d", c.qc.View, msg.Round)
+	}
+	want := e.root
+	if !c.qc.genesis() {
+		want = c.qc.Height + 1
+	}
+	if msg.Height != want || msg.Height < e.base {
+		return nil, fmt.Errorf("height %d does not extend qc (want %d, base %d)", msg.Height, want, e.base)
+	}
+	if e.validate != nil {
+		m := msg
+		m.Payload, m.Justification = c.block, nil
+		if err := e.validate(m); err != nil {
+			return nil, fmt.Errorf("invalid proposal: %w", err)
+		}
+	}
+	return &block{id: msg.ID, parent: c.qc.ID, height: msg.Height, view: msg.Round, payload: c.block, justify: c.qc, msg: trim(msg)}, nil
+}
+
+// adopt records the parent proposal carried by a child whose QC certifies
+// it; no vote is cast for it.
+func (e *Engine) adopt(parent qbft.Message, qc QC) error {
+	if parent.From != e.Leader(parent.Round) || parent.Round != qc.View || parent.Height != qc.Height {
+		return fmt.Errorf("parent does not match qc")
+	}
+	c, err := extract(parent)
+	if err != nil {
+		return err
+	}
+	if c.parent != nil || c.vote != nil {
+		return fmt.Errorf("parent carries extra entries")
+	}
+	b, err := e.check(parent, c)
+	if err != nil {
+		return err
+	}
+	e.store(b)
+	metrics.Inc("hotstuff_parent_adopted_total", nil)
+	return nil
+}
+
+func (e *Engine) onVote(msg qbft.Message) error {
+	if msg.From == e.self {
+		e.myVote = msg
+	}
+	if e.Leader(msg.Round+1) != e.self {
+		return nil // not addressed to this replica (gossip fallback)
+	}
+	return e.collect(msg)
+}
+
+// collect counts a vote; 2f+1 votes for one block form its QC.
+func (e *Engine) collect(msg qbft.Message) error {
+	if msg.Round < e.highQC.View && !e.highQC.genesis() {
+		return nil
+	}
+	if err := e.scheme.VerifyShare(e.vs, msg); err != nil {
+		return err
+	}
+	k := voteKey{view: msg.Round, height: msg.Height, id: msg.ID}
+	if e.formed[k] {
+		return nil
+	}
+	if e.votes[k] == nil {
+		e.votes[k] = map[string]qbft.Message{}
+	}
+	e.votes[k][msg.From] = msg
+	if len(e.votes[k]) < e.vs.Quorum() {
+		return nil
+	}
+	votes := make([]qbft.Message, 0, len(e.votes[k]))
+	for _, v := range e.votes[k] {
+		votes = append(votes, v)
+	}
+	qc, err := e.scheme.Aggregate(e.vs, votes)
+	if err != nil {
+		return err
+	}
+	e.formed[k] = true
+	delete(e.votes, k)
+	metrics.Inc("hotstuff_qc_total", map[string]string{"scheme": e.scheme.Name()})
+	logger.InfoJ("hotstuff", map[string]any{"result": "qc", "view": qc.View, "height": qc.Height, "id": qc.ID})
+	e.observe(qc)
+	return nil
+}
+
+func (e *Engine) onNewView(msg qbft.Message) error {
+	target := msg.Round
+	if e.Leader(target) != e.self || target < e.view {
+		return nil
+	}
+	c, err := extract(msg)
+	if err != nil {
+		return err
+	}
+	if c.parent != nil {
+		return fmt.Errorf("new-view carries a proposal")
+	}
+	if err := e.verifyQC(c.qc); err != nil {
+		return err
+	}
+	e.observe(c.qc)
+	if c.vote != nil {
+		if err := e.collect(*c.vote); err != nil {
+			return err
+		}
+	}
+	if e.newViews[target] == nil {
+		e.newViews[target] = map[string]struct{}{}
+	}
+	e.newViews[target][msg.From] = struct{}{}
+	if len(e.newViews[target]) >= e.vs.Quorum() {
+		e.enter(target)
+	}
+	return nil
+}
+
+// store records a checked block; its QC is the certificate of its parent.
+func (e *Engine) store(b *block) {
+	if _, ok := e.blocks[b.id]; ok {
+		return
+	}
+	e.blocks[b.id] = b
+	if _, ok := e.certs[b.justify.ID]; !ok && !b.justify.genesis() {
+		e.certs[b.justify.ID] = b.justify
+	}
+}
+
+// linked reports whether b's ancestors down to the next height to commit
+// are all known and extend the last committed block.
+func (e *Engine) linked(b *block) bool {
+	if b.height < e.top() {
+		return false
+	}
+	for b.height > e.top() {
+		if b = e.blocks[b.parent]; b == nil {
+			return false
+		}
+	}
+	return e.lastID == "" || b.parent == e.lastID
+}
+
+// verifyQC checks a received certificate (the genesis QC needs none).
+func (e *Engine) verifyQC(qc QC) error {
+	if qc.genesis() {
+		return nil
+	}
+	if err := e.scheme.Verify(e.vs, qc); err != nil {
+		return fmt.Errorf("bad qc: %w", err)
+	}
+	return nil
+}
+
+// enter moves to view v when it is ahead of the current one.
+func (e *Engine) enter(v uint64) {
+	if v <= e.view {
+		return
+	}
+	e.view, e.failed = v, 0
+	metrics.SetGauge("hotstuff_view", nil, int64(v))
+	for t := range e.newViews {
+		if t < v {
+			delete(e.newViews, t)
+		}
+	}
+	for k := range e.votes {
+		if k.view+1 < v {
+			delete(e.votes, k)
+		}
+	}
+	for k := range e.formed {
+		if k.view+1 < v {
+			delete(e.formed, k)
+		}
+	}
+}
+
+// observe records a valid QC: it may raise the high QC, the lock (the QC a
+// certified block carries) and commit the head of a three-chain of
+// consecutive views.
+func (e *Engine) observe(qc QC) {
+	if qc.genesis() {
+		return
+	}
+	if _, ok := e.certs[qc.ID]; !ok {
+		e.certs[qc.ID] = qc
+	}
+	if qc.View > e.highQC.View || e.highQC.genesis() {
+		e.highQC = qc
+	}
+	e.enter(qc.View + 1)
+	b2 := e.blocks[qc.ID]
+	if b2 == nil {
+		return
+	}
+	if b2.justify.View > e.lockedQC.View && !b2.justify.genesis() {
+		e.lockedQC = b2.justify
+	}
+	b1 := e.blocks[b2.justify.ID]
+	if b1 == nil {
+		return
+	}
+	b0 := e.blocks[b1.justify.ID]
+	if b0 == nil || b1.view != b0.view+1 || b2.view != b1.view+1 {
+		return
+	}
+	e.commit(b0)
+}
+
+// top is the next height to queue for commit.
+func (e *Engine) top() uint64 { return e.base + uint64(len(e.ready)) }
+
+// commit queues b and its uncommitted ancestors in height order. A gap in
+// the chain (a proposal this replica never saw) leaves the heights to block
+// sync.
+func (e *Engine) commit(b *block) {
+	if b.height < e.top() {
+		return
+	}
+	var chain []*block
+	for cur := b; ; {
+		chain = append(chain, cur)
+		if cur.height == e.top() {
+			break
+		}
+		p := e.blocks[cur.parent]
+		if p == nil || p.height+1 != cur.height {
+			metrics.Inc("hotstuff_commit_total", map[string]string{"result": "gap"})
+			logger.InfoJ("hotstuff", map[string]any{"result": "commit_gap", "height": cur.height, "base": e.top()})
+			return
+		}
+		cur = p
+	}
+	low := chain[len(chain)-1]
+	if e.lastID != "" && low.parent != e.lastID {
+		metrics.Inc("hotstuff_commit_total", map[string]string{"result": "conflict"})
+		logger.ErrorJ("hotstuff", map[string]any{"result": "commit_conflict", "height": low.height, "id": low.id, "parent": low.parent, "last": e.lastID})
+		return
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		e.ready = append(e.ready, chain[i])
+		metrics.Inc("hotstuff_commit_total", map[string]string{"result": "ok"})
+	}
+	e.lastID = b.id
+	logger.InfoJ("hotstuff", map[string]any{"result": "commit", "height": b.height, "view": b.view, "id": b.id, "blocks": len(chain)})
+}
+
+// Propose returns this node's proposal for the current view when it leads
+// it and either holds the QC of the previous view or received 2f+1
+// new-views for it. value builds the block at (height, view).
+func (e *Engine) Propose(value func(height, round uint64) (id string, payload []byte)) (qbft.Message, bool) {
+	v := e.view
+	if e.self == "" || e.Leader(v) != e.self || e.proposed >= v {
+		return qbft.Message{}, false
+	}
+	if e.highQC.View+1 != v && len(e.newViews[v]) < e.vs.Quorum() {
+		return qbft.Message{}, false
+	}
+	h := e.root
+	if !e.highQC.genesis() {
+		h = e.highQC.Height + 1
+	}
+	if h < e.base {
+		return qbft.Message{}, false // high QC predates the committed chain
+	}
+	id, payload := value(h, v)
+	if id == "" {
+		return qbft.Message{}, false
+	}
+	e.proposed = v
+	msg := qbft.Message{Type: qbft.MsgHSProposal, From: e.self, Height: h, Round: v, ID: id}
+	var parent *qbft.Message
+	if b := e.blocks[e.highQC.ID]; b != nil {
+		parent = &b.msg
+	}
+	attach(&msg, payload, e.highQC, parent)
+	return msg, true
+}
+
+// Vote hands out the next vote this node owes.
+func (e *Engine) Vote() (qbft.Message, bool) {
+	if len(e.owed) == 0 {
+		return qbft.Message{}, false
+	}
+	v := e.owed[0]
+	e.owed = e.owed[1:]
+	return v, true
+}
+
+// OnTimeout gives up on the current view: the returned new-view carries the
+// high QC to the leader of view+k after the k-th consecutive timeout, so a
+// crashed leader is skipped without the replica leaving its view on its own.
+// It also carries the replica's last vote when no QC for it was seen: if the
+// leader that should have collected it crashed, the next one still can.
+func (e *Engine) OnTimeout() qbft.Message {
+	e.failed++
+	msg := qbft.Message{Type: qbft.MsgHSNewView, From: e.self, Height: e.base, Round: e.view + e.failed, ID: e.highQC.ID}
+	if e.self == "" {
+		msg.From = "self"
+	}
+	var last *qbft.Message
+	if e.myVote.Type != "" && e.myVote.Round > e.highQC.View {
+		last = &e.myVote
+	}
+	attach(&msg, nil, e.highQC, last)
+	metrics.Inc("hotstuff_timeouts_total", nil)
+	logger.InfoJ("hotstuff", map[string]any{"result": "timeout", "view": e.view, "target": msg.Round, "high_qc": e.highQC.View})
+	return msg
+}
+
+// CommitSeal returns the lowest committed block not advanced past yet and
+// the votes of the QC certifying it (empty for aggregated certificates,
+// see CommitAggSeal).
+func (e *Engine) CommitSeal() (round uint64, id string, payload []byte, seal []qbft.Message, ok bool) {
+	if len(e.ready) == 0 {
+		return 0, "", nil, nil, false
+	}
+	b := e.ready[0]
+	return b.view, b.id, b.payload, e.certs[b.id].Votes, true
+}
+
+// CommitAggSeal returns the signers and aggregate signature of the QC
+// certifying the block CommitSeal returns, when the scheme aggregates.
+func (e *Engine) CommitAggSeal() (signers []string, agg []byte, ok bool) {
+	if len(e.ready) == 0 {
+		return nil, nil, false
+	}
+	qc := e.certs[e.ready[0].id]
+	if len(qc.Agg) == 0 {
+		return nil, nil, false
+	}
+	return qc.Signers, qc.Agg, true
+}
+
+// VerifyAggSeal checks an aggregated seal of block id, committed at height
+// in view, against vs with the engine's scheme.
+func (e *Engine) VerifyAggSeal(vs *qbft.ValidatorSet, height, view uint64, id string, signers []string, agg []byte) error {
+	return e.scheme.Verify(vs, QC{View: view, Height: height, ID: id, Signers: signers, Agg: agg})
+}
+
+// Advance moves past the committed block CommitSeal returned.
+func (e *Engine) Advance() {
+	if len(e.ready) == 0 {
+		return
+	}
+	e.ready = e.ready[1:]
+	e.base++
+	e.prune()
+}
+
+// Restore resumes after height-1 was committed elsewhere (restart or block
+// sync). Queued commits are dropped and the next committed chain is not
+// linked to the previous one.
+func (e *Engine) Restore(height, _ uint64) {
+	e.base, e.root, e.ready, e.lastID = height, height, nil, ""
+	e.prune()
+}
+
+// prune drops blocks and certificates well below the committed height.
+func (e *Engine) prune() {
+	for id, b := range e.blocks {
+		if b.height+PipelineDepth < e.base {
+			delete(e.blocks, id)
+			delete(e.certs, id)
+		}
+	}
+}
+
+var _ qbft.Processor = (*Engine)(nil)
@@ -0,0 +1,373 @@
+package hotstuff
+
+import (
+	"fmt"
+	"testing"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+)
+
+// cluster drives engines the way consensus.Service does: own messages are
+// applied locally, proposals go to everyone and directed messages to their
+// recipient only. Messages to nodes in down are lost.
+type cluster struct {
+	vs      *qbft.ValidatorSet
+	engines map[string]*Engine
+	ids     []string
+	down    map[string]bool
+	limit   uint64 // leaders propose heights below limit only
+}
+
+func newCluster(t *testing.T) *cluster {
+	t.Helper()
+	ids := []string{"n0", "n1", "n2", "n3"}
+	c := &cluster{vs: qbft.NewValidatorSet(ids, 0), engines: map[string]*Engine{}, ids: ids, down: map[string]bool{}, limit: 6}
+	for _, id := range ids {
+		c.engines[id] = New(c.vs, id, nil)
+	}
+	return c
+}
+
+func value(h, v uint64) (string, []byte) {
+	return fmt.Sprintf("b%d-v%d", h, v), []byte(fmt.Sprintf("block %d", h))
+}
+
+// value builds the block leaders propose; the limit makes settle terminate.
+func (c *cluster) value(h, v uint64) (string, []byte) {
+	if h >= c.limit {
+		return "", nil
+	}
+	return value(h, v)
+}
+
+// send applies msg at its sender and delivers it to its addressees.
+func (c *cluster) send(msg qbft.Message) {
+	from := c.engines[msg.From]
+	_ = from.Process(msg)
+	if to, ok := from.Recipient(msg); ok {
+		if to != msg.From && !c.down[to] {
+			_ = c.engines[to].Process(msg)
+		}
+		return
+	}
+	for _, id := range c.ids {
+		if id != msg.From && !c.down[id] {
+			_ = c.engines[id].Process(msg)
+		}
+	}
+}
+
+// settle lets every live node cast what it owes until nobody has anything
+// left to send.
+func (c *cluster) settle() {
+	for busy := true; busy; {
+		busy = false
+		for _, id := range c.ids {
+			if c.down[id] {
+				continue
+			}
+			e := c.engines[id]
+			if msg, ok := e.Vote(); ok {
+				c.send(msg)
+				busy = true
+			} else if msg, ok := e.Propose(c.value); ok {
+				c.send(msg)
+				busy = true
+			}
+		}
+	}
+}
+
+func TestEngine_HappyPath_CommitsAfterThreeChain(t *testing.T) {
+	c := newCluster(t)
+	c.settle()
+	for _, id := range c.ids {
+		e := c.engines[id]
+		for h := uint64(0); h < 3; h++ {
+			round, bid, payload, seal, ok := e.CommitSeal()
+			if !ok {
+				t.Fatalf("%s: height %d not committed", id, h)
+			}
+			want, _ := value(h, h+1)
+			if bid != want || string(payload) != fmt.Sprintf("block %d", h) || round != h+1 {
+				t.Fatalf("%s: committed %s (view %d), want %s", id, bid, round, want)
+			}
+			if len(seal) < c.vs.Quorum() {
+				t.Fatalf("%s: seal of height %d has %d votes", id, h, len(seal))
+			}
+			for _, v := range seal {
+				if v.Type != qbft.MsgHSVote || v.ID != bid || v.Height != h {
+					t.Fatalf("%s: seal vote %+v does not certify %s", id, v, bid)
+				}
+			}
+			if h, _, phase := e.View(); phase != "commit" {
+				t.Fatalf("%s: phase %q at height %d", id, phase, h)
+			}
+			e.Advance()
+		}
+	}
+}
+
+func TestEngine_Recipient(t *testing.T) {
+	c := newCluster(t)
+	e := c.engines["n0"]
+	if to, ok := e.Recipient(qbft.Message{Type: qbft.MsgHSVote, Round: 4}); !ok || to != e.Leader(5) {
+		t.Fatalf("vote recipient %q %v, want leader of next view", to, ok)
+	}
+	if to, ok := e.Recipient(qbft.Message{Type: qbft.MsgHSNewView, Round: 4}); !ok || to != e.Leader(4) {
+		t.Fatalf("new-view recipient %q %v, want leader of target view", to, ok)
+	}
+	if _, ok := e.Recipient(qbft.Message{Type: qbft.MsgHSProposal, Round: 4}); ok {
+		t.Fatalf("proposals are broadcast")
+	}
+}
+
+func TestEngine_RejectsProposalFromNonLeader(t *testing.T) {
+	c := newCluster(t)
+	e := c.engines["n0"]
+	from := "n0"
+	if e.Leader(1) == from {
+		from = "n1"
+	}
+	msg := qbft.Message{Type: qbft.MsgHSProposal, From: from, Round: 1, ID: "x"}
+	attach(&msg, []byte("b"), genesisQC(), nil)
+	if err := e.Process(msg); err == nil {
+		t.Fatalf("proposal from non-leader accepted")
+	}
+	if _, ok := e.Vote(); ok {
+		t.Fatalf("voted for a non-leader proposal")
+	}
+}
+
+func TestEngine_LockingRule(t *testing.T) {
+	c := newCluster(t)
+	e := c.engines["n0"]
+	e.lockedQC = QC{View: 3, Height: 2, ID: "locked"}
+	msg := qbft.Message{Type: qbft.MsgHSProposal, From: e.Leader(5), Round: 5, ID: "fork"}
+	attach(&msg, []byte("b"), genesisQC(), nil)
+	if err := e.Process(msg); err == nil {
+		t.Fatalf("proposal below the locked QC accepted")
+	}
+	if _, ok := e.Vote(); ok {
+		t.Fatalf("voted against the lock")
+	}
+}
+
+func TestEngine_ProposalValidator(t *testing.T) {
+	c := newCluster(t)
+	e := c.engines["n0"]
+	var seen qbft.Message
+	e.SetProposalValidator(func(m qbft.Message) error { seen = m; return fmt.Errorf("bad block") })
+	msg := qbft.Message{Type: qbft.MsgHSProposal, From: e.Leader(1), Round: 1, ID: "x"}
+	attach(&msg, []byte("raw block"), genesisQC(), nil)
+	if err := e.Process(msg); err == nil {
+		t.Fatalf("invalid block accepted")
+	}
+	if string(seen.Payload) != "raw block" || seen.Round != 1 {
+		t.Fatalf("validator saw %+v", seen)
+	}
+	if _, ok := e.Vote(); ok {
+		t.Fatalf("voted for an invalid block")
+	}
+}
+
+// A crashed leader is skipped: replicas time out, send their high QC and
+// last vote to the next leader, which proposes once a quorum asked for it
+// and still certifies the block whose QC the crashed leader would have
+// formed.
+func TestEngine_Timeout_NewViewSkipsCrashedLeader(t *testing.T) {
+	c := newCluster(t)
+	c.settle()
+	crashed := c.engines["n0"].Leader(c.engines["n0"].view)
+	c.down[crashed] = true
+	var high uint64
+	for _, id := range c.ids {
+		if id == crashed {
+			continue
+		}
+		e := c.engines[id]
+		target := e.view + 1
+		nv := e.OnTimeout()
+		if nv.Type != qbft.MsgHSNewView || nv.Round != target {
+			t.Fatalf("%s: new-view %+v, want target %d", id, nv, target)
+		}
+		if to, _ := e.Recipient(nv); to != e.Leader(target) {
+			t.Fatalf("%s: new-view goes to %s", id, to)
+		}
+		if _, _, phase := e.View(); phase == "" {
+			t.Fatalf("%s: empty phase", id)
+		}
+		high = e.highQC.View
+		c.send(nv)
+	}
+	c.limit += 3
+	c.settle()
+	for _, id := range c.ids {
+		if id == crashed {
+			continue
+		}
+		if e := c.engines[id]; e.highQC.View <= high {
+			t.Fatalf("%s: no progress after the view change (high QC %d)", id, e.highQC.View)
+		}
+	}
+}
+
+// A replica that missed a proposal adopts it from its child, and votes only
+// once it holds the whole chain down to its committed prefix.
+func TestEngine_AdoptsParent_VotesOnlyOnLinkedChain(t *testing.T) {
+	c := newCluster(t)
+	c.settle()
+	src := c.engines["n0"]
+	b2 := blockAt(src, 2)
+	if b2 == nil {
+		t.Fatalf("cluster did not reach height 2")
+	}
+
+	// The child carries only its direct parent: heights 0 and 1 stay unknown.
+	e := New(c.vs, "n1", nil)
+	_ = e.Process(childOf(t, src, b2))
+	if e.blocks[b2.id] == nil {
+		t.Fatalf("parent not adopted")
+	}
+	if _, ok := e.Vote(); ok {
+		t.Fatalf("voted without the ancestry down to height 0")
+	}
+
+	// With heights 0 and 1 known the chain links and it votes.
+	e = New(c.vs, "n1", nil)
+	for _, h := range []uint64{0, 1} {
+		e.store(blockAt(src, h))
+	}
+	if err := e.Process(childOf(t, src, b2)); err != nil {
+		t.Fatalf("linked proposal: %v", err)
+	}
+	if _, ok := e.Vote(); !ok {
+		t.Fatalf("no vote on a linked chain")
+	}
+}
+
+func blockAt(e *Engine, h uint64) *block {
+	for _, b := range e.blocks {
+		if b.height == h {
+			return b
+		}
+	}
+	return nil
+}
+
+// childOf builds the next proposal extending b with b's certificate and b's
+// own proposal as carried parent.
+func childOf(t *testing.T, src *Engine, b *block) qbft.Message {
+	t.Helper()
+	qc, ok := src.certs[b.id]
+	if !ok {
+		t.Fatalf("no certificate for %s", b.id)
+	}
+	v := src.view + 10
+	msg := qbft.Message{Type: qbft.MsgHSProposal, From: src.Leader(v), Height: b.height + 1, Round: v, ID: "child"}
+	attach(&msg, []byte("child"), qc, &b.msg)
+	return msg
+}
+
+func TestEngine_RestoreResumesAtHeight(t *testing.T) {
+	c := newCluster(t)
+	e := c.engines["n0"]
+	e.Restore(7, 0)
+	if h, _, _ := e.View(); h != 7 {
+		t.Fatalf("height %d after restore, want 7", h)
+	}
+	msg := qbft.Message{Type: qbft.MsgHSProposal, From: e.Leader(1), Height: 7, Round: 1, ID: "b7"}
+	attach(&msg, []byte("b"), genesisQC(), nil)
+	if err := e.Process(msg); err != nil {
+		t.Fatalf("proposal at the restored height: %v", err)
+	}
+	if vote, ok := e.Vote(); !ok || vote.Height != 7 {
+		t.Fatalf("vote %+v %v", vote, ok)
+	}
+	old := qbft.Message{Type: qbft.MsgHSProposal, From: e.Leader(2), Height: 3, Round: 2, ID: "b3"}
+	attach(&old, []byte("b"), genesisQC(), nil)
+	if err := e.Process(old); err == nil {
+		t.Fatalf("proposal below the restored height accepted")
+	}
+}
+
+// tagScheme aggregates like BLS without pairings: the aggregate names the
+// certified coordinates and the signers.
+type tagScheme struct{}
+
+func tag(qc QC) []byte { return []byte(fmt.Sprintf("%d/%d/%s/%v", qc.Height, qc.View, qc.ID, qc.Signers)) }
+
+func (tagScheme) Name() string                                       { return "tag" }
+func (tagScheme) Share(qbft.Message) ([]byte, error)                 { return []byte("share"), nil }
+func (tagScheme) VerifyShare(*qbft.ValidatorSet, qbft.Message) error { return nil }
+
+func (tagScheme) Aggregate(vs *qbft.ValidatorSet, votes []qbft.Message) (QC, error) {
+	q, err := quorumOf(vs, votes)
+	if err != nil {
+		return QC{}, err
+	}
+	qc := QC{View: q[0].Round, Height: q[0].Height, ID: q[0].ID}
+	for _, v := range q {
+		qc.Signers = append(qc.Signers, v.From)
+	}
+	qc.Agg = tag(qc)
+	return qc, nil
+}
+
+func (tagScheme) Verify(vs *qbft.ValidatorSet, qc QC) error {
+	if len(qc.Signers) < vs.Quorum() || string(qc.Agg) != string(tag(qc)) {
+		return fmt.Errorf("bad aggregate")
+	}
+	return nil
+}
+
+// With an aggregating scheme a block is sealed by its QC's signers and
+// aggregate signature, which the engine verifies for block sync.
+func TestEngine_AggregatedCommitSeal(t *testing.T) {
+	c := newCluster(t)
+	for _, id := range c.ids {
+		c.engines[id] = New(c.vs, id, tagScheme{})
+	}
+	c.settle()
+	e := c.engines["n1"]
+	round, bid, _, seal, ok := e.CommitSeal()
+	if !ok || len(seal) != 0 {
+		t.Fatalf("commit seal %v ok=%v", seal, ok)
+	}
+	signers, agg, ok := e.CommitAggSeal()
+	if !ok || len(signers) < c.vs.Quorum() {
+		t.Fatalf("aggregated seal %v ok=%v", signers, ok)
+	}
+	if err := e.VerifyAggSeal(c.vs, 0, round, bid, signers, agg); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := e.VerifyAggSeal(c.vs, 0, round, "other", signers, agg); err == nil {
+		t.Fatalf("seal of another block accepted")
+	}
+	votes := newCluster(t)
+	votes.settle()
+	if _, _, ok := votes.engines["n1"].CommitAggSeal(); ok {
+		t.Fatalf("vote-set seal reported as aggregated")
+	}
+}
+
+// noShare is VoteSet whose shares cannot be made (a BLS scheme without
+// pairings).
+type noShare struct{ VoteSet }
+
+func (noShare) Share(qbft.Message) ([]byte, error) { return nil, fmt.Errorf("no share") }
+
+// A replica that cannot make its share casts no vote and does not count the
+// view as voted in.
+func TestEngine_ShareErrorCastsNoVote(t *testing.T) {
+	c := newCluster(t)
+	e := New(c.vs, "n1", noShare{})
+	msg := qbft.Message{Type: qbft.MsgHSProposal, From: e.Leader(1), Height: 0, Round: 1, ID: "b0"}
+	attach(&msg, []byte("b"), genesisQC(), nil)
+	if err := e.Process(msg); err != nil {
+		t.Fatalf("proposal: %v", err)
+	}
+	if vote, ok := e.Vote(); ok || e.lastVote != 0 {
+		t.Fatalf("vote %+v ok=%v, last vote view %d", vote, ok, e.lastVote)
+	}
+}
@@ -0,0 +1,301 @@
+package hotstuff
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/tss/core/bls381"
+	"github.com/zmlAEQ/Aequa-network/pkg/config"
+)
+
+// GenesisID is the block id certified by the genesis QC every replica
+// starts from; the first block extends it.
+const GenesisID = "genesis"
+
+// blsDST separates HotStuff vote shares from any other use of the BLS key.
+var blsDST = []byte("AEQUA-HOTSTUFF-VOTE-BLS12381G2_XMD:SHA-256_SSWU_RO_")
+
+// QC is a quorum certificate: votes of 2f+1 validators for block ID (at
+// Height) in View.
+type QC struct {
+	View   uint64 `json:"view"`
+	Height uint64 `json:"height"`
+	ID     string `json:"id"`
+	// Signers and Agg form an aggregated certificate (BLS scheme).
+	Signers []string `json:"signers,omitempty"`
+	Agg     []byte   `json:"agg,omitempty"`
+	// Votes are the certifying votes themselves (vote-set scheme). They
+	// travel as the carrying message's Justification, so the shared QBFT
+	// verifier checks their signatures before the engine sees them.
+	Votes []qbft.Message `json:"-"`
+}
+
+func genesisQC() QC { return QC{ID: GenesisID} }
+
+func (qc QC) genesis() bool { return qc.ID == GenesisID }
+
+// Scheme certifies votes: it attaches the local share to a vote, checks
+// the shares of others, aggregates a quorum into a QC and verifies QCs
+// carried by proposals and new-views.
+type Scheme interface {
+	Name() string
+	Share(vote qbft.Message) ([]byte, error)
+	VerifyShare(vs *qbft.ValidatorSet, vote qbft.Message) error
+	Aggregate(vs *qbft.ValidatorSet, votes []qbft.Message) (QC, error)
+	Verify(vs *qbft.ValidatorSet, qc QC) error
+}
+
+// voteDigest is what every voter for (height, view, id) signs; it does not
+// depend on the voter, so shares aggregate over one message.
+func voteDigest(height, view uint64, id string) []byte {
+	return qbft.SigningBytes(qbft.Message{Type: qbft.MsgHSVote, Height: height, Round: view, ID: id})
+}
+
+// quorumOf checks that votes are hs_votes for one (height, view, id) from a
+// quorum of distinct members, and returns them in validator order.
+func quorumOf(vs *qbft.ValidatorSet, votes []qbft.Message) ([]qbft.Message, error) {
+	if len(votes) == 0 {
+		return nil, errors.New("empty certificate")
+	}
+	first := votes[0]
+	seen := make(map[string]struct{}, len(votes))
+	out := make([]qbft.Message, 0, len(votes))
+	for _, v := range votes {
+		if v.Type != qbft.MsgHSVote || v.Height != first.Height || v.Round != first.Round || v.ID != first.ID {
+			return nil, fmt.Errorf("vote for wrong coordinates")
+		}
+		if !vs.Contains(v.From) {
+			return nil, fmt.Errorf("vote from non-member %q", v.From)
+		}
+		if _, dup := seen[v.From]; dup {
+			continue
+		}
+		seen[v.From] = struct{}{}
+		out = append(out, v)
+	}
+	if len(out) < vs.Quorum() {
+		return nil, fmt.Errorf("vote quorum not reached: %d < %d", len(out), vs.Quorum())
+	}
+	sort.Slice(out, func(i, j int) bool { return vs.Index(out[i].From) < vs.Index(out[j].From) })
+	return out, nil
+}
+
+// VoteSet certifies with the signed votes themselves: a QC is 2f+1 ed25519
+// signed votes. It needs no extra key material.
+type VoteSet struct{}
+
+func (VoteSet) Name() string                                       { return "votes" }
+func (VoteSet) Share(qbft.Message) ([]byte, error)                 { return nil, nil }
+func (VoteSet) VerifyShare(*qbft.ValidatorSet, qbft.Message) error { return nil }
+
+func (VoteSet) Aggregate(vs *qbft.ValidatorSet, votes []qbft.Message) (QC, error) {
+	q, err := quorumOf(vs, votes)
+	if err != nil {
+		return QC{}, err
+	}
+	return QC{View: q[0].Round, Height: q[0].Height, ID: q[0].ID, Votes: q}, nil
+}
+
+func (VoteSet) Verify(vs *qbft.ValidatorSet, qc QC) error {
+	q, err := quorumOf(vs, qc.Votes)
+	if err != nil {
+		return err
+	}
+	if q[0].Round != qc.View || q[0].Height != qc.Height || q[0].ID != qc.ID {
+		return errors.New("votes do not match certificate")
+	}
+	return nil
+}
+
+// BLS certifies with one aggregated BLS12-381 signature and a signer list,
+// so a QC has constant size. Votes carry the voter's share; the leader
+// aggregates 2f+1 of them. Real pairings need the blst build tag; default
+// builds return bls381.ErrNotImplemented.
+type BLS struct {
+	key  bls381.Scalar
+	keys map[string]bls381.PubKey
+}
+
+// NewBLS binds the local secret key to the validators' BLS public keys.
+func NewBLS(key bls381.Scalar, keys map[string]bls381.PubKey) *BLS {
+	return &BLS{key: key, keys: keys}
+}
+
+// BLSFromLock builds the BLS scheme from the hex secret key at keyPath and
+// the operators' bls_pubkey entries in lock. Every operator needs a key. It
+// signs a probe vote first, so a build without the blst tag or an unusable
+// key fails here rather than leave the node unable to vote.
+func BLSFromLock(keyPath string, lock config.ClusterLock) (*BLS, error) {
+	b, err := loadBLS(keyPath, lock)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := b.Share(qbft.Message{Type: qbft.MsgHSVote, ID: GenesisID}); err != nil {
+		if errors.Is(err, bls381.ErrNotImplemented) {
+			return nil, fmt.Errorf("bls scheme needs a -tags blst build: %w", err)
+		}
+		return nil, fmt.Errorf("bls key: %w", err)
+	}
+	return b, nil
+}
+
+// loadBLS reads the scheme's key material for BLSFromLock.
+func loadBLS(keyPath string, lock config.ClusterLock) (*BLS, error) {
+	raw, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(raw)), "0x"))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("bls key: want 32-byte hex scalar")
+	}
+	keys := make(map[string]bls381.PubKey, len(lock.Operators))
+	for _, op := range lock.Operators {
+		pk, err := hex.DecodeString(strings.TrimPrefix(op.BLSPubKey, "0x"))
+		if err != nil || len(pk) != 48 {
+			return nil, fmt.Errorf("operator %d (%s): want 48-byte hex bls_pubkey", op.Index, op.PeerID)
+		}
+		keys[op.PeerID] = pk
+	}
+	return NewBLS(key, keys), nil
+}
+
+func (b *BLS) Name() string { return "bls" }
+
+func (b *BLS) Share(vote qbft.Message) ([]byte, error) {
+	sig, err := bls381.Sign(b.key, voteDigest(vote.Height, vote.Round, vote.ID), blsDST)
+	return sig, err
+}
+
+func (b *BLS) VerifyShare(_ *qbft.ValidatorSet, vote qbft.Message) error {
+	pk, ok := b.keys[vote.From]
+	if !ok {
+		return fmt.Errorf("no bls key for %q", vote.From)
+	}
+	ok, err := bls381.Verify(pk, vote.Share, voteDigest(vote.Height, vote.Round, vote.ID), blsDST)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("invalid share from %q", vote.From)
+	}
+	return nil
+}
+
+func (b *BLS) Aggregate(vs *qbft.ValidatorSet, votes []qbft.Message) (QC, error) {
+	q, err := quorumOf(vs, votes)
+	if err != nil {
+		return QC{}, err
+	}
+	qc := QC{View: q[0].Round, Height: q[0].Height, ID: q[0].ID}
+	shares := make([]bls381.Signature, 0, len(q))
+	for _, v := range q {
+		qc.Signers = append(qc.Signers, v.From)
+		shares = append(shares, v.Share)
+	}
+	agg, err := bls381.Aggregate(shares...)
+	if err != nil {
+		return QC{}, err
+	}
+	qc.Agg = agg
+	return qc, nil
+}
+
+func (b *BLS) Verify(vs *qbft.ValidatorSet, qc QC) error {
+	seen := make(map[string]struct{}, len(qc.Signers))
+	pks := make([]bls381.PubKey, 0, len(qc.Signers))
+	for _, id := range qc.Signers {
+		if _, dup := seen[id]; dup || !vs.Contains(id) {
+			return fmt.Errorf("bad signer %q", id)
+		}
+		seen[id] = struct{}{}
+		pk, ok := b.keys[id]
+		if !ok {
+			return fmt.Errorf("no bls key for %q", id)
+		}
+		pks = append(pks, pk)
+	}
+	if len(pks) < vs.Quorum() {
+		return fmt.Errorf("signer quorum not reached: %d < %d", len(pks), vs.Quorum())
+	}
+	ok, err := bls381.VerifyAggregate(pks, qc.Agg, voteDigest(qc.Height, qc.View, qc.ID), blsDST)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid aggregate signature")
+	}
+	return nil
+}
+
+// envelope is the payload of proposals and new-views: the proposed block
+// (proposals only) and the QC the sender extends.
+type envelope struct {
+	Block []byte `json:"block,omitempty"`
+	QC    QC     `json:"qc"`
+}
+
+// carried is what a proposal or new-view carries besides its own fields.
+type carried struct {
+	block []byte
+	qc    QC
+	// parent is the signed proposal of the QC's block, so a replica that
+	// missed it can still link the chain (proposals only).
+	parent *qbft.Message
+	// vote is the sender's last vote, newer than qc, so the next leader can
+	// still certify a block whose collecting leader failed (new-views only).
+	vote *qbft.Message
+}
+
+// attach sets msg's payload to block and qc; the justification carries the
+// vote-set certificate, then the optional parent proposal or last vote.
+// Justification is not covered by signatures, so entries can be trimmed
+// without invalidating them.
+func attach(msg *qbft.Message, block []byte, qc QC, extra *qbft.Message) {
+	msg.Payload, _ = json.Marshal(envelope{Block: block, QC: qc})
+	msg.Justification = append([]qbft.Message(nil), qc.Votes...)
+	if extra != nil {
+		msg.Justification = append(msg.Justification, *extra)
+	}
+}
+
+// extract is the inverse of attach.
+func extract(msg qbft.Message) (carried, error) {
+	var env envelope
+	if err := json.Unmarshal(msg.Payload, &env); err != nil {
+		return carried{}, fmt.Errorf("decode envelope: %w", err)
+	}
+	c := carried{block: env.Block, qc: env.QC}
+	for i, j := range msg.Justification {
+		switch {
+		case j.Type == qbft.MsgHSVote && j.Round == env.QC.View && j.ID == env.QC.ID:
+			c.qc.Votes = append(c.qc.Votes, j)
+		case j.Type == qbft.MsgHSProposal && j.ID == env.QC.ID && c.parent == nil:
+			c.parent = &msg.Justification[i]
+		case j.Type == qbft.MsgHSVote && j.Round > env.QC.View && j.From == msg.From && c.vote == nil:
+			c.vote = &msg.Justification[i]
+		default:
+			return carried{}, errors.New("unexpected justification entry")
+		}
+	}
+	return c, nil
+}
+
+// trim drops a proposal's parent from its justification, keeping the
+// votes of its own QC.
+func trim(msg qbft.Message) qbft.Message {
+	keep := make([]qbft.Message, 0, len(msg.Justification))
+	for _, j := range msg.Justification {
+		if j.Type == qbft.MsgHSVote {
+			keep = append(keep, j)
+		}
+	}
+	msg.Justification = keep
+	return msg
+}
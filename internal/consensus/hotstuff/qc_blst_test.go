@@ -0,0 +1,89 @@
+//go:build blst
+
+package hotstuff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	blst "github.com/supranational/blst/bindings/go"
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/tss/core/bls381"
+	"github.com/zmlAEQ/Aequa-network/pkg/config"
+)
+
+// blsCluster returns one BLS scheme per validator of vs, sharing the
+// validators' public keys.
+func blsCluster(t *testing.T, ids []string) map[string]*BLS {
+	t.Helper()
+	secrets := make(map[string]bls381.Scalar, len(ids))
+	keys := make(map[string]bls381.PubKey, len(ids))
+	for _, id := range ids {
+		sk := blst.KeyGen([]byte(fmt.Sprintf("hotstuff-qc-test-ikm-%s-0123456789abcdef", id)))
+		secrets[id] = sk.Serialize()
+		keys[id] = new(blst.P1Affine).From(sk).Compress()
+	}
+	out := make(map[string]*BLS, len(ids))
+	for _, id := range ids {
+		out[id] = NewBLS(secrets[id], keys)
+	}
+	return out
+}
+
+func TestBLS_AggregatedQC(t *testing.T) {
+	ids := []string{"n0", "n1", "n2", "n3"}
+	vs := qbft.NewValidatorSet(ids, 0)
+	schemes := blsCluster(t, ids)
+	leader := schemes["n0"]
+	votes := votesFor("b", "n2", "n0", "n1")
+	for i := range votes {
+		share, err := schemes[votes[i].From].Share(votes[i])
+		if err != nil {
+			t.Fatalf("share %s: %v", votes[i].From, err)
+		}
+		votes[i].Share = share
+		if err := leader.VerifyShare(vs, votes[i]); err != nil {
+			t.Fatalf("verify share %s: %v", votes[i].From, err)
+		}
+	}
+	qc, err := leader.Aggregate(vs, votes)
+	if err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	if len(qc.Signers) != 3 || len(qc.Agg) != 96 || len(qc.Votes) != 0 {
+		t.Fatalf("qc %+v", qc)
+	}
+	if err := schemes["n3"].Verify(vs, qc); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	forged := votes[2]
+	forged.Share, _ = schemes["n3"].Share(forged)
+	if err := leader.VerifyShare(vs, forged); err == nil {
+		t.Fatalf("share signed by another key accepted")
+	}
+	bad := map[string]QC{
+		"other block":    {View: qc.View, Height: qc.Height, ID: "c", Signers: qc.Signers, Agg: qc.Agg},
+		"other view":     {View: qc.View + 1, Height: qc.Height, ID: qc.ID, Signers: qc.Signers, Agg: qc.Agg},
+		"swapped signer": {View: qc.View, Height: qc.Height, ID: qc.ID, Signers: []string{"n0", "n1", "n3"}, Agg: qc.Agg},
+		"extra signer":   {View: qc.View, Height: qc.Height, ID: qc.ID, Signers: ids, Agg: qc.Agg},
+	}
+	for name, q := range bad {
+		if err := schemes["n3"].Verify(vs, q); err == nil {
+			t.Fatalf("%s: certificate accepted", name)
+		}
+	}
+}
+
+// BLSFromLock signs a probe vote: a valid scalar loads, the zero scalar
+// does not.
+func TestBLSFromLock_ProbesKey(t *testing.T) {
+	lock := config.ClusterLock{Operators: []config.Operator{{Index: 0, PeerID: "n0", BLSPubKey: strings.Repeat("aa", 48)}}}
+	if _, err := BLSFromLock(blsKeyFile(t, strings.Repeat("01", 32)), lock); err != nil {
+		t.Fatalf("valid key: %v", err)
+	}
+	if _, err := BLSFromLock(blsKeyFile(t, strings.Repeat("00", 32)), lock); err == nil {
+		t.Fatalf("zero key accepted")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !blst
+
+package hotstuff
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zmlAEQ/Aequa-network/internal/tss/core/bls381"
+	"github.com/zmlAEQ/Aequa-network/pkg/config"
+)
+
+// Without the blst tag the scheme cannot vote, so it is refused at startup.
+func TestBLSFromLock_NeedsBlst(t *testing.T) {
+	lock := config.ClusterLock{Operators: []config.Operator{{Index: 0, PeerID: "n0", BLSPubKey: strings.Repeat("aa", 48)}}}
+	if _, err := BLSFromLock(blsKeyFile(t, strings.Repeat("01", 32)), lock); !errors.Is(err, bls381.ErrNotImplemented) {
+		t.Fatalf("want ErrNotImplemented, got %v", err)
+	}
+}
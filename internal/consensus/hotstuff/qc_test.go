@@ -0,0 +1,127 @@
+package hotstuff
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/tss/core/bls381"
+	"github.com/zmlAEQ/Aequa-network/pkg/config"
+)
+
+func votesFor(id string, from ...string) []qbft.Message {
+	out := make([]qbft.Message, 0, len(from))
+	for _, f := range from {
+		out = append(out, qbft.Message{Type: qbft.MsgHSVote, From: f, Height: 4, Round: 9, ID: id})
+	}
+	return out
+}
+
+func TestVoteSet_AggregateAndVerify(t *testing.T) {
+	vs := qbft.NewValidatorSet([]string{"n0", "n1", "n2", "n3"}, 0)
+	qc, err := VoteSet{}.Aggregate(vs, votesFor("b", "n2", "n0", "n1"))
+	if err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	if qc.View != 9 || qc.Height != 4 || qc.ID != "b" || len(qc.Votes) != 3 || qc.Votes[0].From != "n0" {
+		t.Fatalf("qc %+v", qc)
+	}
+	if err := (VoteSet{}).Verify(vs, qc); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	bad := map[string]QC{
+		"duplicates": {View: 9, Height: 4, ID: "b", Votes: votesFor("b", "n0", "n0", "n1")},
+		"non-member": {View: 9, Height: 4, ID: "b", Votes: votesFor("b", "n0", "n1", "x")},
+		"mixed ids":  {View: 9, Height: 4, ID: "b", Votes: append(votesFor("b", "n0", "n1"), votesFor("c", "n2")...)},
+		"mismatch":   {View: 8, Height: 4, ID: "b", Votes: votesFor("b", "n0", "n1", "n2")},
+		"empty":      {View: 9, Height: 4, ID: "b"},
+	}
+	for name, qc := range bad {
+		if err := (VoteSet{}).Verify(vs, qc); err == nil {
+			t.Fatalf("%s: certificate accepted", name)
+		}
+	}
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	qc := QC{View: 9, Height: 4, ID: "b", Votes: votesFor("b", "n0", "n1", "n2")}
+	parent := qbft.Message{Type: qbft.MsgHSProposal, From: "n1", Height: 4, Round: 9, ID: "b"}
+	msg := qbft.Message{Type: qbft.MsgHSProposal, From: "n2", Height: 5, Round: 10, ID: "c"}
+	attach(&msg, []byte("block"), qc, &parent)
+	c, err := extract(msg)
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if string(c.block) != "block" || len(c.qc.Votes) != 3 || c.parent == nil || c.parent.ID != "b" || c.vote != nil {
+		t.Fatalf("carried %+v", c)
+	}
+	if got := trim(msg); len(got.Justification) != 3 {
+		t.Fatalf("trim kept %d entries", len(got.Justification))
+	}
+	msg.Justification = append(msg.Justification, qbft.Message{Type: qbft.MsgPrepare})
+	if _, err := extract(msg); err == nil {
+		t.Fatalf("foreign justification entry accepted")
+	}
+}
+
+func TestBLS_Share(t *testing.T) {
+	key := make(bls381.Scalar, 32)
+	key[31] = 1
+	b := NewBLS(key, map[string]bls381.PubKey{})
+	if _, err := b.Share(votesFor("b", "n0")[0]); err != nil {
+		if errors.Is(err, bls381.ErrNotImplemented) {
+			t.Skip("bls381 built without blst")
+		}
+		t.Fatalf("share: %v", err)
+	}
+}
+
+func TestBLS_VerifyRejectsUnknownSigners(t *testing.T) {
+	vs := qbft.NewValidatorSet([]string{"n0", "n1", "n2", "n3"}, 0)
+	keys := map[string]bls381.PubKey{"n0": {1}, "n1": {2}, "n2": {3}}
+	b := NewBLS(nil, keys)
+	cases := map[string][]string{
+		"duplicate":  {"n0", "n0", "n1"},
+		"non-member": {"n0", "n1", "x"},
+		"no key":     {"n0", "n1", "n3"},
+		"no quorum":  {"n0", "n1"},
+	}
+	for name, signers := range cases {
+		if err := b.Verify(vs, QC{View: 9, Height: 4, ID: "b", Signers: signers}); err == nil || errors.Is(err, bls381.ErrNotImplemented) {
+			t.Fatalf("%s: got %v", name, err)
+		}
+	}
+}
+
+// blsKeyFile writes the hex scalar key to a key file and returns its path.
+func blsKeyFile(t *testing.T, key string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bls.key")
+	if err := os.WriteFile(path, []byte("0x"+key+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBLSFromLock(t *testing.T) {
+	path := blsKeyFile(t, strings.Repeat("01", 32))
+	lock := config.ClusterLock{Operators: []config.Operator{
+		{Index: 0, PeerID: "n0", BLSPubKey: strings.Repeat("aa", 48)},
+		{Index: 1, PeerID: "n1", BLSPubKey: "0x" + strings.Repeat("bb", 48)},
+	}}
+	b, err := loadBLS(path, lock)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(b.keys) != 2 || len(b.keys["n1"]) != 48 || len(b.key) != 32 {
+		t.Fatalf("scheme %+v", b)
+	}
+	lock.Operators = append(lock.Operators, config.Operator{Index: 2, PeerID: "n2"})
+	if _, err := BLSFromLock(path, lock); err == nil {
+		t.Fatalf("operator without bls_pubkey accepted")
+	}
+}
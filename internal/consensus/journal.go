@@ -0,0 +1,175 @@
+package consensus
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/pkg/bus"
+	"github.com/zmlAEQ/Aequa-network/pkg/logger"
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// journalCompactEvery is how many committed heights pass between mempool
+// journal compactions.
+const journalCompactEvery = 32
+
+// journalRecord is one journaled tx with the last committed height at the
+// time it was accepted; a block including it can only be higher.
+type journalRecord struct {
+	Height uint64          `json:"height"`
+	Tx     wire.TxEnvelope `json:"tx"`
+}
+
+type mempoolJournal struct {
+	j      *state.TxJournal
+	all    bool
+	height atomic.Uint64 // last committed height
+}
+
+// SetMempoolJournal records the txs the mempool accepts in j, so a restarted
+// node replays them into its pools: locally submitted txs only, or with all
+// also those received over gossip.
+func (s *Service) SetMempoolJournal(j *state.TxJournal, all bool) {
+	if j == nil {
+		s.journal = nil
+		return
+	}
+	s.journal = &mempoolJournal{j: j, all: all}
+}
+
+// journalTx appends a tx the mempool accepted.
+func (s *Service) journalTx(ev bus.Event, p pl.Payload) {
+	jr := s.journal
+	if jr == nil || (!ev.Local && !jr.all) {
+		return
+	}
+	env, ok := wire.TxFromInternal(p)
+	if !ok {
+		return
+	}
+	body, err := json.Marshal(journalRecord{Height: jr.height.Load(), Tx: env})
+	if err == nil {
+		err = jr.j.Append(body)
+	}
+	if err != nil {
+		metrics.Inc("mempool_journal_total", map[string]string{"op": "append", "result": "error"})
+		logger.ErrorJ("mempool_journal", map[string]any{"op": "append", "result": "error", "err": err.Error()})
+		return
+	}
+	metrics.Inc("mempool_journal_total", map[string]string{"op": "append", "result": "ok"})
+}
+
+// restoreMempool replays the journal into the pools on startup. The stored
+// blocks from the oldest record on go first: their executed txs let the
+// nonce-ordered pools advance their senders, and journaled txs they include
+// are skipped.
+func (s *Service) restoreMempool(ctx context.Context) {
+	jr := s.journal
+	if jr == nil || s.pool == nil {
+		return
+	}
+	last, lastErr := s.blocks.LastBlock(ctx)
+	if lastErr == nil {
+		jr.height.Store(last.Height)
+	}
+	recs, err := jr.j.Load()
+	if err != nil {
+		logger.ErrorJ("mempool_journal", map[string]any{"op": "load", "result": "error", "err": err.Error()})
+	}
+	txs := make([]pl.Payload, 0, len(recs))
+	from := ^uint64(0)
+	for _, body := range recs {
+		var rec journalRecord
+		if json.Unmarshal(body, &rec) != nil {
+			continue
+		}
+		if p := rec.Tx.ToInternal(); p != nil {
+			txs = append(txs, p)
+			from = min(from, rec.Height)
+		}
+	}
+	included := map[string]struct{}{}
+	if lastErr == nil {
+		for h := from; h <= last.Height; h++ {
+			rec, err := s.blocks.BlockByHeight(ctx, h)
+			if err != nil {
+				continue
+			}
+			blk, err := wire.DecodeBlock(rec.Block)
+			if err != nil {
+				continue
+			}
+			skipped := skippedTxs(rec.Receipts)
+			for _, it := range blk.Items {
+				if !skipped[string(it.Hash())] {
+					included[string(it.Hash())] = struct{}{}
+				}
+			}
+			s.pool.RemoveExecuted(blk.Items, skipped)
+		}
+	}
+	restored, skipped, refused := 0, 0, 0
+	for _, p := range txs {
+		if _, ok := included[string(p.Hash())]; ok {
+			skipped++
+			continue
+		}
+		if err := s.pool.Add(p); err != nil {
+			refused++
+			continue
+		}
+		restored++
+	}
+	metrics.Inc("mempool_journal_total", map[string]string{"op": "restore", "result": "ok"})
+	logger.InfoJ("mempool_journal", map[string]any{"op": "restore", "result": "ok", "records": len(recs), "restored": restored, "included": skipped, "refused": refused})
+	s.compactJournal()
+}
+
+// journalCommitted stamps later records with committed height h and
+// compacts the journal every journalCompactEvery heights.
+func (s *Service) journalCommitted(h uint64) {
+	jr := s.journal
+	if jr == nil {
+		return
+	}
+	if h > jr.height.Load() {
+		jr.height.Store(h)
+	}
+	if h%journalCompactEvery == 0 {
+		s.compactJournal()
+	}
+}
+
+// compactJournal drops records of txs the mempool no longer holds
+// (committed, expired, replaced or refused).
+func (s *Service) compactJournal() {
+	jr := s.journal
+	if jr == nil || s.pool == nil {
+		return
+	}
+	dropped, err := jr.j.Compact(func(body []byte) bool {
+		var rec journalRecord
+		if json.Unmarshal(body, &rec) != nil {
+			return false
+		}
+		p := rec.Tx.ToInternal()
+		if p == nil {
+			return false
+		}
+		_, ok := s.pool.Arrival(p)
+		return ok
+	})
+	if err != nil {
+		metrics.Inc("mempool_journal_total", map[string]string{"op": "compact", "result": "error"})
+		logger.ErrorJ("mempool_journal", map[string]any{"op": "compact", "result": "error", "err": err.Error()})
+		return
+	}
+	metrics.Inc("mempool_journal_total", map[string]string{"op": "compact", "result": "ok"})
+	if dropped > 0 {
+		logger.InfoJ("mempool_journal", map[string]any{"op": "compact", "result": "ok", "dropped": dropped})
+	}
+}
@@ -0,0 +1,40 @@
+package consensus
+
+import (
+	"github.com/zmlAEQ/Aequa-network/internal/execution"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/pkg/logger"
+)
+
+// pruneMempool removes the payloads of a committed block from the mempool,
+// so the builder does not propose them again, and lets the nonce-ordered
+// pools advance their senders past the committed nonces. Txs the receipts
+// show as skipped used no nonce: they stay pooled (or are pooled again) and
+// their senders do not advance past them. Entries that outlived the pool
+// limits expire at the same time.
+func (s *Service) pruneMempool(blk pl.StandardBlock, receipts []state.Receipt) {
+	if s.pool == nil {
+		return
+	}
+	n := 0
+	if len(blk.Items) > 0 {
+		n = s.pool.RemoveExecuted(blk.Items, skippedTxs(receipts))
+	}
+	expired := s.pool.Expire(blk.Header.Height)
+	s.journalCommitted(blk.Header.Height)
+	if n > 0 || expired > 0 {
+		logger.InfoJ("consensus_mempool", map[string]any{"op": "prune", "height": blk.Header.Height, "items": len(blk.Items), "removed": n, "expired": expired})
+	}
+}
+
+// skippedTxs returns the hashes of the txs execution skipped.
+func skippedTxs(receipts []state.Receipt) map[string]bool {
+	out := map[string]bool{}
+	for _, r := range receipts {
+		if r.Status == execution.StatusSkipped {
+			out[string(r.Tx)] = true
+		}
+	}
+	return out
+}
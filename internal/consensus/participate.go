@@ -0,0 +1,72 @@
+package consensus
+
+import (
+	"context"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/pkg/logger"
+)
+
+// participant is implemented by processors that produce the local node's own
+// protocol messages (qbft.State and qbft.Manager do).
+type participant interface {
+	Propose(value func(height, round uint64) (id string, payload []byte)) (qbft.Message, bool)
+	Vote() (qbft.Message, bool)
+}
+
+// maxOwnSteps bounds how many own messages one loop iteration may cast, so a
+// single-member set that commits on its own votes cannot spin the loop.
+const maxOwnSteps = 8
+
+// SetVoting makes the node an active QBFT participant: the proposer of
+// round 0 proposes the block it builds as soon as a height starts, every
+// proposal is validated before it is accepted, and the node casts its own
+// prepare and commit for the accepted proposal. Own messages are recorded in
+// the WAL, signed, broadcast and applied locally. Off by default (the node
+// then only relays and counts messages it receives).
+func (s *Service) SetVoting(on bool) { s.voting = on }
+
+// kickoff runs once the loop is ready: it arms the round timer so a silent
+// proposer is replaced, and lets the node propose when it leads the first
+// height.
+func (s *Service) kickoff(ctx context.Context) {
+	if !s.voting || s.rd == nil {
+		return
+	}
+	_, r, _ := s.rd.View()
+	s.rt.arm(r)
+	s.participate(ctx)
+}
+
+// participate casts the messages the processor says this node owes after the
+// last state change (votes first, then a round-0 proposal) until none is
+// left or maxOwnSteps is reached.
+func (s *Service) participate(ctx context.Context) {
+	if !s.voting || s.rd == nil {
+		return
+	}
+	p, ok := s.rd.(participant)
+	if !ok {
+		return
+	}
+	for i := 0; i < maxOwnSteps; i++ {
+		msg, ok := p.Vote()
+		if ok {
+			signed, err := s.signVote(msg)
+			if err != nil {
+				return
+			}
+			msg = signed
+		} else {
+			if msg, ok = p.Propose(s.proposalValue); !ok {
+				return
+			}
+			msg = s.signLocal(msg)
+			logger.InfoJ("consensus_propose", map[string]any{"result": "propose", "height": msg.Height, "round": msg.Round, "id": msg.ID})
+		}
+		s.broadcast(ctx, msg, msg.TraceID)
+		h0, r0, p0 := s.rd.View()
+		_ = s.rd.Process(msg)
+		s.onProgress(ctx, s.rd, s.rt, h0, r0, p0)
+	}
+}
@@ -0,0 +1,206 @@
+package consensus
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	"github.com/zmlAEQ/Aequa-network/pkg/bus"
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+const (
+	// defaultStageQueue bounds each pipeline queue.
+	defaultStageQueue = 256
+	// maxVerifyWorkers caps the default verify parallelism.
+	maxVerifyWorkers = 4
+)
+
+// verified is the outcome of the verify stage for one consensus event.
+type verified struct {
+	seq   uint64
+	ev    bus.Event
+	msg   qbft.Message
+	err   error
+	begin time.Time // ingest time, for end-to-end latency
+}
+
+// verifyJob is an ingested event waiting for a verify worker.
+type verifyJob struct {
+	seq   uint64
+	ev    bus.Event
+	begin time.Time
+}
+
+// persistJob carries what the persistence stage needs from an applied
+// message; the block is a copy so the loop can keep mutating its own map.
+type persistJob struct {
+	height, round uint64
+	commit        bool
+	traceID       string
+	blk           pl.StandardBlock
+	hasBlock      bool
+	queued        time.Time
+}
+
+// pipeline holds the queues between stages: ingest -> verify (parallel) ->
+// apply (in ingest order, on the consensus loop) -> persist (async).
+type pipeline struct {
+	workers int
+	size    int
+	in      chan verifyJob
+	out     chan verified
+	persist chan persistJob
+	pending map[uint64]verified // verified out of order, by seq
+	next    uint64              // seq the apply stage waits for
+}
+
+// SetTxSub injects the low-priority tx lane (bus.Bus.SubscribeTx). Tx events
+// are ingested on their own so a tx flood never delays consensus messages.
+func (s *Service) SetTxSub(sub bus.Subscriber) { s.txSub = sub }
+
+// SetPipeline sizes the message pipeline: workers verify messages in
+// parallel and every stage queue holds at most queue entries. Zero values
+// keep the defaults (min(GOMAXPROCS, 4) workers, 256 entries).
+func (s *Service) SetPipeline(workers, queue int) {
+	s.pipeWorkers, s.pipeQueue = workers, queue
+}
+
+// startPipeline spawns the ingest, verify, persistence and tx stages. A full
+// verify queue blocks ingest, which in turn lets the bus drop (and count)
+// new events at its edge; a full persistence queue blocks the apply stage,
+// since persistence jobs carry committed state and must not be lost.
+func (s *Service) startPipeline(ctx context.Context) {
+	p := &pipeline{workers: s.pipeWorkers, size: s.pipeQueue, pending: map[uint64]verified{}, next: 1}
+	if p.workers <= 0 {
+		p.workers = runtime.GOMAXPROCS(0)
+		if p.workers > maxVerifyWorkers {
+			p.workers = maxVerifyWorkers
+		}
+	}
+	if p.size <= 0 {
+		p.size = defaultStageQueue
+	}
+	p.in = make(chan verifyJob, p.size)
+	p.out = make(chan verified, p.size)
+	p.persist = make(chan persistJob, p.size)
+	s.pipe = p
+	go s.ingest(ctx)
+	for i := 0; i < p.workers; i++ {
+		go s.verifyWorker(ctx)
+	}
+	go s.persister(ctx)
+	if s.txSub != nil {
+		go s.txIngest(ctx)
+	}
+}
+
+// ingest numbers consensus events in arrival order and queues them for
+// verification. Tx events arriving on the consensus lane (legacy wiring)
+// go straight to the mempool.
+func (s *Service) ingest(ctx context.Context) {
+	var seq uint64
+	for {
+		var ev bus.Event
+		select {
+		case ev = <-s.sub:
+		case <-ctx.Done():
+			return
+		}
+		if ev.Kind == bus.KindTx {
+			s.ingestTx(ctx, ev)
+			continue
+		}
+		seq++
+		job := verifyJob{seq: seq, ev: ev, begin: time.Now()}
+		select {
+		case s.pipe.in <- job:
+			continue
+		default:
+			metrics.Inc("consensus_stage_backpressure_total", map[string]string{"stage": "verify"})
+		}
+		select {
+		case s.pipe.in <- job:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Service) verifyWorker(ctx context.Context) {
+	for {
+		select {
+		case job := <-s.pipe.in:
+			v := s.verify(job.ev, job.begin)
+			v.seq = job.seq
+			select {
+			case s.pipe.out <- v:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// applyInOrder buffers v until every earlier event was applied, then applies
+// all events that are ready, preserving ingest order.
+func (s *Service) applyInOrder(ctx context.Context, v verified) {
+	p := s.pipe
+	p.pending[v.seq] = v
+	for {
+		next, ok := p.pending[p.next]
+		if !ok {
+			return
+		}
+		delete(p.pending, p.next)
+		p.next++
+		s.apply(ctx, next)
+	}
+}
+
+// enqueuePersist hands job to the persistence stage, or runs it inline when
+// the service is manually driven. A full queue applies backpressure to the
+// caller instead of dropping the job; only ctx cancellation abandons it.
+func (s *Service) enqueuePersist(ctx context.Context, job persistJob) {
+	if s.pipe == nil {
+		s.persist(ctx, job)
+		return
+	}
+	select {
+	case s.pipe.persist <- job:
+		return
+	default:
+		metrics.Inc("consensus_stage_backpressure_total", map[string]string{"stage": "persist"})
+	}
+	select {
+	case s.pipe.persist <- job:
+	case <-ctx.Done():
+	}
+}
+
+func (s *Service) persister(ctx context.Context) {
+	for {
+		select {
+		case job := <-s.pipe.persist:
+			s.persist(ctx, job)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// txIngest drains the tx lane into the mempool, independently of consensus.
+func (s *Service) txIngest(ctx context.Context) {
+	for {
+		select {
+		case ev := <-s.txSub:
+			s.ingestTx(ctx, ev)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package consensus
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	"github.com/zmlAEQ/Aequa-network/pkg/logger"
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// proposalValidatorSetter is implemented by processors that check proposal
+// values before accepting them (qbft.Manager does).
+type proposalValidatorSetter interface {
+	SetProposalValidator(fn func(qbft.Message) error)
+}
+
+// SetChainID sets the chain id the signatures of block txs are checked
+// against (empty keeps payload.DefaultChainID).
+func (s *Service) SetChainID(id string) { s.chainID = id }
+
+// blockID is the QBFT value id of a block: its hash in hex.
+func blockID(blk pl.StandardBlock) string { return hex.EncodeToString(blk.Hash()) }
+
+// proposalValue builds the block this node proposes at (h, r) and returns its
+// id and encoded form for the PRE-PREPARE (or NEW_VIEW) payload. With the
+// builder enabled the block is selected from the mempools by the builder
+// policy; otherwise an empty block is proposed. The local node validates its
+// own proposal like any other when it processes it.
+func (s *Service) proposalValue(h, r uint64) (string, []byte) {
+	hdr := pl.BlockHeader{Height: h, Round: r, FeeRecipient: s.feeRecipient}
+	blk := pl.StandardBlock{Header: hdr}
+	if s.enableBuilder && s.pool != nil {
+		blk = pl.PrepareProposal(s.pool, hdr, s.policy)
+	}
+	blk.Stats = summarizeStats(blk.Items)
+	raw, err := wire.EncodeBlock(blk)
+	if err != nil {
+		metrics.Inc("consensus_proposal_built_total", map[string]string{"result": "encode_error"})
+		logger.ErrorJ("consensus_proposal", map[string]any{"op": "build", "result": "encode_error", "height": h, "round": r, "err": err.Error()})
+		return "", nil
+	}
+	metrics.Inc("consensus_proposal_built_total", map[string]string{"result": "ok"})
+	logger.InfoJ("consensus_proposal", map[string]any{"op": "build", "result": "ok", "height": h, "round": r, "items": len(blk.Items), "bids": blk.Stats.TotalBids, "fees": blk.Stats.TotalFees})
+	return blockID(blk), raw
+}
+
+// checkProposal validates the block carried by a PRE-PREPARE or NEW_VIEW
+// before the node accepts (and votes for) it: the payload must decode to a
+// block of the message height whose hash is the message id, obey the builder
+// policy (payload.ProcessProposal) and hold only valid, distinct txs signed
+// by their senders (bundle txs included). The
+// accepted block is kept for the commit path under the proposal's round.
+func (s *Service) checkProposal(msg qbft.Message) error {
+	blk, err := s.decodeProposal(msg)
+	if err != nil {
+		metrics.Inc("consensus_proposal_checks_total", map[string]string{"result": "invalid"})
+		logger.ErrorJ("consensus_proposal", map[string]any{"op": "check", "result": "invalid", "from": msg.From, "height": msg.Height, "round": msg.Round, "id": msg.ID, "err": err.Error()})
+		return err
+	}
+	blk.Stats = summarizeStats(blk.Items)
+	if s.lastBlock[msg.Height] == nil {
+		s.lastBlock[msg.Height] = make(map[uint64]pl.StandardBlock)
+	}
+	s.lastBlock[msg.Height][msg.Round] = blk
+	metrics.Inc("consensus_proposal_checks_total", map[string]string{"result": "ok"})
+	logger.InfoJ("consensus_proposal", map[string]any{"op": "check", "result": "ok", "from": msg.From, "height": msg.Height, "round": msg.Round, "items": len(blk.Items)})
+	return nil
+}
+
+func (s *Service) decodeProposal(msg qbft.Message) (pl.StandardBlock, error) {
+	blk, err := wire.DecodeBlock(msg.Payload)
+	if err != nil {
+		return pl.StandardBlock{}, fmt.Errorf("decode block: %w", err)
+	}
+	if blk.Header.Height != msg.Height {
+		return pl.StandardBlock{}, fmt.Errorf("block height %d, proposal height %d", blk.Header.Height, msg.Height)
+	}
+	if id := blockID(blk); id != msg.ID {
+		return pl.StandardBlock{}, fmt.Errorf("block hash %s does not match proposal id", id)
+	}
+	if err := pl.ProcessProposal(blk, s.policy); err != nil {
+		return pl.StandardBlock{}, err
+	}
+	seen := make(map[string]struct{}, len(blk.Items))
+	for i, it := range blk.Items {
+		if err := it.Validate(); err != nil {
+			return pl.StandardBlock{}, fmt.Errorf("item %d (%s): %w", i, it.Type(), err)
+		}
+		if err := pl.VerifySig(it, s.chainID); err != nil {
+			return pl.StandardBlock{}, fmt.Errorf("item %d (%s): %w", i, it.Type(), err)
+		}
+		key := it.Type() + "/" + string(it.Hash())
+		if _, dup := seen[key]; dup {
+			return pl.StandardBlock{}, fmt.Errorf("item %d (%s): duplicate tx", i, it.Type())
+		}
+		seen[key] = struct{}{}
+	}
+	return blk, nil
+}
@@ -0,0 +1,168 @@
+package qbft
+
+import (
+    "crypto/ed25519"
+    "errors"
+    "fmt"
+    "sync"
+
+    "github.com/zmlAEQ/Aequa-network/pkg/config"
+)
+
+// MinEpochLength keeps epochs longer than the windows in which messages for
+// later heights are buffered and verified, so every node knows the set of a
+// height before it sees messages for it.
+const MinEpochLength = 2 * DefaultFutureWindow
+
+// Validator set change operations.
+const (
+    ChangeAdd       = "add"
+    ChangeRemove    = "remove"
+    ChangeThreshold = "threshold"
+)
+
+// Change is one agreed modification of the validator set.
+type Change struct {
+    Op        string            // ChangeAdd, ChangeRemove or ChangeThreshold
+    ID        string            // operator added or removed
+    PubKey    ed25519.PublicKey // signing key of an added operator
+    Threshold int               // new quorum lower bound (ChangeThreshold)
+}
+
+// Apply returns a new set with c applied; vs is left unchanged. Added
+// operators join the end of the rotation and removed ones leave it, the
+// others keep their order. A signed set only admits operators with a key.
+func (vs *ValidatorSet) Apply(c Change) (*ValidatorSet, error) {
+    ids := vs.IDs()
+    threshold := 0
+    if vs != nil { threshold = vs.threshold }
+    switch c.Op {
+    case ChangeAdd:
+        if c.ID == "" { return nil, errors.New("add: empty operator id") }
+        if vs.Contains(c.ID) { return nil, fmt.Errorf("add: %s already a member", c.ID) }
+        if vs.HasKeys() && len(c.PubKey) != ed25519.PublicKeySize { return nil, fmt.Errorf("add: %s needs an ed25519 key", c.ID) }
+        ids = append(ids, c.ID)
+    case ChangeRemove:
+        if !vs.Contains(c.ID) { return nil, fmt.Errorf("remove: %s not a member", c.ID) }
+        if len(ids) == 1 { return nil, errors.New("remove: last member") }
+        ids = append(ids[:vs.Index(c.ID)], ids[vs.Index(c.ID)+1:]...)
+    case ChangeThreshold:
+        if c.Threshold <= 0 || c.Threshold > len(ids) { return nil, fmt.Errorf("threshold %d out of range 1..%d", c.Threshold, len(ids)) }
+        threshold = c.Threshold
+    default:
+        return nil, fmt.Errorf("unknown change %q", c.Op)
+    }
+    if threshold > len(ids) { return nil, fmt.Errorf("threshold %d above %d members", threshold, len(ids)) }
+    out := NewValidatorSet(ids, threshold)
+    for _, id := range ids {
+        if pk := vs.PubKey(id); pk != nil { out.SetPubKey(id, pk) }
+    }
+    if c.Op == ChangeAdd && len(c.PubKey) > 0 { out.SetPubKey(c.ID, c.PubKey) }
+    return out, nil
+}
+
+type epochSet struct {
+    from uint64
+    vs   *ValidatorSet
+}
+
+// Epochs is the validator set schedule: the set in force at a height is the
+// last one scheduled from a boundary at or below it. Sets are only ever
+// scheduled at future epoch boundaries, so every consumer that looks the set
+// up by height (manager, verifier, block sync) switches at the same height on
+// every node. It is safe for concurrent use.
+type Epochs struct {
+    mu        sync.RWMutex
+    length    uint64
+    sets      []epochSet // ascending by from; sets[0].from == 0
+    active    uint64     // highest height announced by Activate
+    announced int        // index of the last set handed to listeners
+    listeners []func(from uint64, vs *ValidatorSet)
+}
+
+// NewEpochs starts a schedule of epochs of length heights with genesis in
+// force from height 0.
+func NewEpochs(length uint64, genesis *ValidatorSet) *Epochs {
+    return &Epochs{length: length, sets: []epochSet{{from: 0, vs: genesis}}, announced: -1}
+}
+
+// EpochsFromLock builds the schedule of a cluster lock over its genesis set.
+// It returns nil when the lock has no epoch_length (fixed membership).
+func EpochsFromLock(lock config.ClusterLock, genesis *ValidatorSet) (*Epochs, error) {
+    if lock.EpochLength == 0 { return nil, nil }
+    if lock.EpochLength < MinEpochLength { return nil, fmt.Errorf("epoch_length %d below minimum %d", lock.EpochLength, MinEpochLength) }
+    return NewEpochs(lock.EpochLength, genesis), nil
+}
+
+// Length returns the number of heights per epoch.
+func (e *Epochs) Length() uint64 { return e.length }
+
+// Epoch returns the epoch of height h.
+func (e *Epochs) Epoch(h uint64) uint64 { return h / e.length }
+
+// At returns the validator set in force at height h.
+func (e *Epochs) At(h uint64) *ValidatorSet {
+    e.mu.RLock()
+    defer e.mu.RUnlock()
+    for i := len(e.sets) - 1; i > 0; i-- {
+        if e.sets[i].from <= h { return e.sets[i].vs }
+    }
+    return e.sets[0].vs
+}
+
+// Schedule applies c to the latest scheduled set and puts the result in
+// force from height from, which must be an epoch boundary not before the
+// latest scheduled one. Changes scheduled for the same boundary compose in
+// call order.
+func (e *Epochs) Schedule(from uint64, c Change) (*ValidatorSet, error) {
+    if from == 0 || from%e.length != 0 { return nil, fmt.Errorf("height %d is not an epoch boundary", from) }
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    last := e.sets[len(e.sets)-1]
+    if from < last.from { return nil, fmt.Errorf("height %d before scheduled change at %d", from, last.from) }
+    if from <= e.active { return nil, fmt.Errorf("height %d already active", from) }
+    vs, err := last.vs.Apply(c)
+    if err != nil { return nil, err }
+    if from == last.from {
+        e.sets[len(e.sets)-1].vs = vs
+    } else {
+        e.sets = append(e.sets, epochSet{from: from, vs: vs})
+    }
+    return vs, nil
+}
+
+// OnActivate registers fn to be called with every set that comes into force
+// (and, on the next Activate, with the one in force already). Listeners run
+// on the caller of Activate.
+func (e *Epochs) OnActivate(fn func(from uint64, vs *ValidatorSet)) {
+    e.mu.Lock()
+    e.listeners = append(e.listeners, fn)
+    e.announced = -1
+    e.mu.Unlock()
+}
+
+// Activate records that the node reached height h and hands the set in force
+// at h to the listeners when it was not announced yet.
+func (e *Epochs) Activate(h uint64) {
+    e.mu.Lock()
+    if h > e.active { e.active = h }
+    idx := 0
+    for i := len(e.sets) - 1; i > 0; i-- {
+        if e.sets[i].from <= e.active { idx = i; break }
+    }
+    if idx == e.announced {
+        e.mu.Unlock()
+        return
+    }
+    e.announced = idx
+    set, fns := e.sets[idx], append([]func(uint64, *ValidatorSet){}, e.listeners...)
+    e.mu.Unlock()
+    for _, fn := range fns { fn(set.from, set.vs) }
+}
+
+// Current returns the epoch of the highest activated height.
+func (e *Epochs) Current() uint64 {
+    e.mu.RLock()
+    defer e.mu.RUnlock()
+    return e.active / e.length
+}
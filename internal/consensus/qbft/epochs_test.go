@@ -0,0 +1,100 @@
+package qbft
+
+import (
+    "crypto/ed25519"
+    "testing"
+
+    "github.com/zmlAEQ/Aequa-network/pkg/config"
+)
+
+func TestValidatorSet_Apply(t *testing.T) {
+    vs := fourNodes()
+    added, err := vs.Apply(Change{Op: ChangeAdd, ID: "n4"})
+    if err != nil { t.Fatalf("add: %v", err) }
+    if added.Size() != 5 || added.Index("n4") != 4 || vs.Size() != 4 { t.Fatalf("add: size=%d index=%d original=%d", added.Size(), added.Index("n4"), vs.Size()) }
+    removed, err := added.Apply(Change{Op: ChangeRemove, ID: "n1"})
+    if err != nil { t.Fatalf("remove: %v", err) }
+    if removed.Contains("n1") || removed.Index("n2") != 1 || removed.Quorum() != 3 { t.Fatalf("remove: %v quorum=%d", removed.IDs(), removed.Quorum()) }
+    raised, err := removed.Apply(Change{Op: ChangeThreshold, Threshold: 4})
+    if err != nil || raised.Quorum() != 4 { t.Fatalf("threshold: %v quorum=%d", err, raised.Quorum()) }
+
+    bad := []Change{
+        {Op: ChangeAdd, ID: "n0"},
+        {Op: ChangeAdd},
+        {Op: ChangeRemove, ID: "x"},
+        {Op: ChangeThreshold, Threshold: 5},
+        {Op: "rename", ID: "n0"},
+    }
+    for _, c := range bad {
+        if _, err := vs.Apply(c); err == nil { t.Fatalf("%+v accepted", c) }
+    }
+}
+
+func TestValidatorSet_Apply_SignedSetNeedsKeys(t *testing.T) {
+    _, vs := testKeys(t)
+    if _, err := vs.Apply(Change{Op: ChangeAdd, ID: "n4"}); err == nil { t.Fatalf("keyless operator joined a signed set") }
+    pk, _, _ := ed25519.GenerateKey(nil)
+    next, err := vs.Apply(Change{Op: ChangeAdd, ID: "n4", PubKey: pk})
+    if err != nil { t.Fatalf("add: %v", err) }
+    if !next.PubKey("n4").Equal(pk) || !next.PubKey("n0").Equal(vs.PubKey("n0")) { t.Fatalf("keys not carried over") }
+}
+
+func TestEpochs_ScheduleAndAt(t *testing.T) {
+    ep := NewEpochs(10, fourNodes())
+    if _, err := ep.Schedule(15, Change{Op: ChangeAdd, ID: "n4"}); err == nil { t.Fatalf("non-boundary accepted") }
+    if _, err := ep.Schedule(20, Change{Op: ChangeAdd, ID: "n4"}); err != nil { t.Fatalf("schedule: %v", err) }
+    // A second change at the same boundary composes with the first.
+    if _, err := ep.Schedule(20, Change{Op: ChangeRemove, ID: "n0"}); err != nil { t.Fatalf("compose: %v", err) }
+    if _, err := ep.Schedule(10, Change{Op: ChangeRemove, ID: "n1"}); err == nil { t.Fatalf("change before the scheduled one accepted") }
+
+    if ep.At(19).Size() != 4 || !ep.At(19).Contains("n0") { t.Fatalf("set changed before its boundary") }
+    at := ep.At(20)
+    if at.Size() != 4 || at.Contains("n0") || !at.Contains("n4") || ep.At(1000) != at { t.Fatalf("set at 20: %v", at.IDs()) }
+    if ep.Epoch(19) != 1 || ep.Epoch(20) != 2 { t.Fatalf("epoch numbering") }
+
+    ep.Activate(30)
+    if _, err := ep.Schedule(30, Change{Op: ChangeRemove, ID: "n1"}); err == nil { t.Fatalf("change of an active height accepted") }
+    if ep.Current() != 3 { t.Fatalf("current epoch %d", ep.Current()) }
+}
+
+func TestEpochs_ActivateAnnouncesOncePerSet(t *testing.T) {
+    ep := NewEpochs(10, fourNodes())
+    var got []uint64
+    ep.OnActivate(func(from uint64, _ *ValidatorSet) { got = append(got, from) })
+    ep.Activate(0)
+    ep.Activate(5)
+    if _, err := ep.Schedule(20, Change{Op: ChangeAdd, ID: "n4"}); err != nil { t.Fatalf("schedule: %v", err) }
+    ep.Activate(19)
+    ep.Activate(20)
+    ep.Activate(21)
+    if len(got) != 2 || got[0] != 0 || got[1] != 20 { t.Fatalf("announced %v, want [0 20]", got) }
+}
+
+func TestEpochsFromLock(t *testing.T) {
+    if ep, err := EpochsFromLock(config.ClusterLock{}, fourNodes()); ep != nil || err != nil { t.Fatalf("no epoch_length: %v %v", ep, err) }
+    if _, err := EpochsFromLock(config.ClusterLock{EpochLength: MinEpochLength - 1}, fourNodes()); err == nil { t.Fatalf("short epochs accepted") }
+    ep, err := EpochsFromLock(config.ClusterLock{EpochLength: MinEpochLength}, fourNodes())
+    if err != nil || ep.Length() != MinEpochLength { t.Fatalf("from lock: %v", err) }
+}
+
+// The manager and the verifier follow the schedule: n4 is a stranger before
+// the boundary and a member from it on.
+func TestEpochs_ManagerAndVerifierSwitchAtBoundary(t *testing.T) {
+    vs := fourNodes()
+    ep := NewEpochs(MinEpochLength, vs)
+    if _, err := ep.Schedule(2*MinEpochLength, Change{Op: ChangeAdd, ID: "n4"}); err != nil { t.Fatalf("schedule: %v", err) }
+    boundary := uint64(2 * MinEpochLength)
+
+    v := NewBasicVerifierWithPolicy(Policy{Validators: vs})
+    v.SetEpochs(ep)
+    if err := v.Verify(Message{ID: "b", From: "n4", Type: MsgPrepare, Height: boundary - 1}); err == nil { t.Fatalf("verifier accepted n4 before the boundary") }
+    if err := v.Verify(Message{ID: "b", From: "n4", Type: MsgPrepare, Height: boundary}); err != nil { t.Fatalf("verifier rejected n4 at the boundary: %v", err) }
+
+    m := NewManager(vs, "n0")
+    m.SetEpochs(ep)
+    m.Restore(boundary-1, 0)
+    if err := m.Process(Message{ID: "b", From: "n4", Type: MsgPrepare, Height: boundary - 1}); err == nil { t.Fatalf("manager accepted n4 before the boundary") }
+    m.Restore(boundary, 0)
+    if err := m.Process(Message{ID: "b", From: "n4", Type: MsgPrepare, Height: boundary}); err != nil { t.Fatalf("manager rejected n4 at the boundary: %v", err) }
+    if got := m.instance().Validators.Size(); got != 5 { t.Fatalf("instance at the boundary runs %d validators", got) }
+}
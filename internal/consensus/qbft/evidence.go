@@ -0,0 +1,198 @@
+package qbft
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+
+    "github.com/zmlAEQ/Aequa-network/pkg/logger"
+    "github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// Equivocation kinds.
+const (
+    EvidenceDoubleProposal = "double_proposal" // two proposals (preprepare/new_view/hs_proposal) for one (height, round)
+    EvidenceDoublePrepare  = "double_prepare"
+    EvidenceDoubleCommit   = "double_commit"
+    EvidenceDoubleVote     = "double_vote" // two HotStuff votes in one view
+)
+
+// DefaultEvidenceWindow is the number of recent heights whose votes are kept
+// for equivocation detection.
+const DefaultEvidenceWindow = 16
+
+// Evidence is a self-contained proof that From equivocated: two signed
+// messages of the same kind for the same (Height, Round) with different
+// values. Anyone holding the validator keys can check it with VerifyEvidence.
+type Evidence struct {
+    Kind   string  `json:"kind"`
+    From   string  `json:"from"`
+    Height uint64  `json:"height"`
+    Round  uint64  `json:"round"`
+    First  Message `json:"first"`
+    Second Message `json:"second"`
+}
+
+func (e Evidence) key() string { return fmt.Sprintf("%s|%s|%d|%d", e.Kind, e.From, e.Height, e.Round) }
+
+// evidenceKind maps a message type to the equivocation kind it can produce.
+func evidenceKind(t Type) (string, bool) {
+    switch t {
+    case MsgPreprepare, MsgNewView, MsgHSProposal:
+        return EvidenceDoubleProposal, true
+    case MsgPrepare:
+        return EvidenceDoublePrepare, true
+    case MsgCommit:
+        return EvidenceDoubleCommit, true
+    case MsgHSVote:
+        return EvidenceDoubleVote, true
+    }
+    return "", false
+}
+
+// VerifyEvidence checks that ev is well-formed: both messages are of its
+// kind, from ev.From, for (ev.Height, ev.Round), carry different values and,
+// when vs has signing keys, are validly signed by ev.From.
+func VerifyEvidence(vs *ValidatorSet, ev Evidence) error {
+    for _, m := range []Message{ev.First, ev.Second} {
+        k, ok := evidenceKind(m.Type)
+        if !ok || k != ev.Kind { return fmt.Errorf("message type %q does not match kind %q", m.Type, ev.Kind) }
+        if m.From != ev.From || m.Height != ev.Height || m.Round != ev.Round {
+            return fmt.Errorf("message coordinates do not match evidence")
+        }
+        if vs.HasKeys() && !VerifySig(vs.PubKey(m.From), m) { return fmt.Errorf("invalid signature from %q", m.From) }
+    }
+    if ev.First.ID == ev.Second.ID { return fmt.Errorf("messages carry the same value") }
+    return nil
+}
+
+// EvidencePool detects equivocations among verified messages and keeps the
+// resulting evidence. With a path, evidence is appended as JSON lines and
+// reloaded on open, so it survives restarts.
+type EvidencePool struct {
+    mu       sync.Mutex
+    path     string
+    window   uint64
+    first    map[string]Message // first vote per (kind, from, height, round)
+    top      uint64             // highest height observed
+    records  []Evidence
+    recorded map[string]struct{}
+}
+
+// NewEvidencePool opens a pool persisting to path ("" keeps it in memory).
+func NewEvidencePool(path string) (*EvidencePool, error) {
+    p := &EvidencePool{path: path, window: DefaultEvidenceWindow, first: make(map[string]Message), recorded: make(map[string]struct{})}
+    if path == "" { return p, nil }
+    f, err := os.Open(path)
+    if os.IsNotExist(err) { return p, nil }
+    if err != nil { return nil, err }
+    defer f.Close()
+    s := bufio.NewScanner(f)
+    s.Buffer(make([]byte, 0, 64*1024), 16<<20)
+    for s.Scan() {
+        var ev Evidence
+        if json.Unmarshal(s.Bytes(), &ev) != nil { continue } // torn last line
+        if _, dup := p.recorded[ev.key()]; dup { continue }
+        p.recorded[ev.key()] = struct{}{}
+        p.records = append(p.records, ev)
+    }
+    logger.InfoJ("qbft_evidence", map[string]any{"op": "load", "result": "ok", "records": len(p.records)})
+    return p, nil
+}
+
+// SetWindow sets how many recent heights are tracked for detection.
+func (p *EvidencePool) SetWindow(w uint64) {
+    if w > 0 { p.mu.Lock(); p.window = w; p.mu.Unlock() }
+}
+
+// Observe records msg and returns evidence when it conflicts with an earlier
+// message of the same kind from the same sender at the same coordinates.
+// Each (kind, from, height, round) is reported at most once. Callers must
+// only pass messages that passed verification (signatures included).
+func (p *EvidencePool) Observe(msg Message) (Evidence, bool) {
+    kind, ok := evidenceKind(msg.Type)
+    if !ok || msg.From == "" { return Evidence{}, false }
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if msg.Height > p.top {
+        p.top = msg.Height
+        p.prune()
+    }
+    if p.top >= p.window && msg.Height < p.top-p.window { return Evidence{}, false }
+    ev := Evidence{Kind: kind, From: msg.From, Height: msg.Height, Round: msg.Round}
+    k := ev.key()
+    prev, seen := p.first[k]
+    if !seen {
+        p.first[k] = msg
+        return Evidence{}, false
+    }
+    if prev.ID == msg.ID { return Evidence{}, false }
+    if _, dup := p.recorded[k]; dup { return Evidence{}, false }
+    ev.First, ev.Second = prev, msg
+    p.recorded[k] = struct{}{}
+    p.records = append(p.records, ev)
+    p.persist(ev)
+    metrics.Inc("qbft_equivocations_total", map[string]string{"kind": kind})
+    logger.ErrorJ("qbft_evidence", map[string]any{"op": "detect", "kind": kind, "from": msg.From, "height": msg.Height, "round": msg.Round, "first": prev.ID, "second": msg.ID, "trace_id": msg.TraceID})
+    return ev, true
+}
+
+// Conflicts reports whether msg differs from the first message of its kind
+// observed from its sender at the same coordinates.
+func (p *EvidencePool) Conflicts(msg Message) bool {
+    kind, ok := evidenceKind(msg.Type)
+    if !ok || msg.From == "" { return false }
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    prev, seen := p.first[Evidence{Kind: kind, From: msg.From, Height: msg.Height, Round: msg.Round}.key()]
+    return seen && prev.ID != msg.ID
+}
+
+// prune forgets first votes below the detection window.
+func (p *EvidencePool) prune() {
+    if p.top < p.window { return }
+    min := p.top - p.window
+    for k, m := range p.first {
+        if m.Height < min { delete(p.first, k) }
+    }
+}
+
+func (p *EvidencePool) persist(ev Evidence) {
+    if p.path == "" { return }
+    err := func() error {
+        if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil { return err }
+        f, err := os.OpenFile(p.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+        if err != nil { return err }
+        b, _ := json.Marshal(ev)
+        if _, err = f.Write(append(b, '\n')); err != nil { _ = f.Close(); return err }
+        if err = f.Sync(); err != nil { _ = f.Close(); return err }
+        return f.Close()
+    }()
+    if err != nil {
+        metrics.Inc("qbft_evidence_persist_total", map[string]string{"result": "error"})
+        logger.ErrorJ("qbft_evidence", map[string]any{"op": "persist", "result": "error", "err": err.Error()})
+        return
+    }
+    metrics.Inc("qbft_evidence_persist_total", map[string]string{"result": "ok"})
+}
+
+// List returns the recorded evidence, optionally filtered by sender ("" for
+// all) and minimum height, ordered by height, round and sender.
+func (p *EvidencePool) List(from string, minHeight uint64) []Evidence {
+    p.mu.Lock()
+    out := make([]Evidence, 0, len(p.records))
+    for _, ev := range p.records {
+        if (from == "" || ev.From == from) && ev.Height >= minHeight { out = append(out, ev) }
+    }
+    p.mu.Unlock()
+    sort.SliceStable(out, func(i, j int) bool {
+        if out[i].Height != out[j].Height { return out[i].Height < out[j].Height }
+        if out[i].Round != out[j].Round { return out[i].Round < out[j].Round }
+        return out[i].From < out[j].From
+    })
+    return out
+}
@@ -0,0 +1,69 @@
+package qbft
+
+import (
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+func TestEvidencePool_DetectsDoubleVoteOnce(t *testing.T) {
+    metrics.Reset()
+    signers, vs := testKeys(t)
+    p, _ := NewEvidencePool("")
+    a := signers["n2"].Sign(Message{Type: MsgPrepare, Height: 4, Round: 1, ID: "a"})
+    b := signers["n2"].Sign(Message{Type: MsgPrepare, Height: 4, Round: 1, ID: "b"})
+    if _, ok := p.Observe(a); ok { t.Fatalf("first vote is not evidence") }
+    if _, ok := p.Observe(a); ok { t.Fatalf("re-delivery of the same vote is not evidence") }
+    // Same value from another sender, or another round, is fine.
+    if _, ok := p.Observe(signers["n1"].Sign(Message{Type: MsgPrepare, Height: 4, Round: 1, ID: "b"})); ok { t.Fatalf("other sender") }
+    if _, ok := p.Observe(signers["n2"].Sign(Message{Type: MsgPrepare, Height: 4, Round: 2, ID: "b"})); ok { t.Fatalf("other round") }
+    ev, ok := p.Observe(b)
+    if !ok || ev.Kind != EvidenceDoublePrepare || ev.From != "n2" || ev.First.ID != "a" || ev.Second.ID != "b" {
+        t.Fatalf("want double_prepare evidence, got %+v ok=%v", ev, ok)
+    }
+    if err := VerifyEvidence(vs, ev); err != nil { t.Fatalf("evidence must verify: %v", err) }
+    c := signers["n2"].Sign(Message{Type: MsgPrepare, Height: 4, Round: 1, ID: "c"})
+    if _, ok := p.Observe(c); ok { t.Fatalf("reported twice") }
+    if !p.Conflicts(c) || p.Conflicts(a) { t.Fatalf("conflicts must compare with the first vote") }
+    if got := p.List("", 0); len(got) != 1 { t.Fatalf("want 1 record, got %d", len(got)) }
+    if !strings.Contains(metrics.DumpProm(), `qbft_equivocations_total{kind="double_prepare"} 1`) { t.Fatalf("missing metric") }
+}
+
+func TestVerifyEvidence_RejectsForgedOrMalformed(t *testing.T) {
+    signers, vs := testKeys(t)
+    a := signers["n1"].Sign(Message{Type: MsgCommit, Height: 2, ID: "a"})
+    b := signers["n1"].Sign(Message{Type: MsgCommit, Height: 2, ID: "b"})
+    ev := Evidence{Kind: EvidenceDoubleCommit, From: "n1", Height: 2, First: a, Second: b}
+    if err := VerifyEvidence(vs, ev); err != nil { t.Fatalf("valid: %v", err) }
+    forged := ev
+    forged.Second = signers["n3"].Sign(Message{Type: MsgCommit, Height: 2, ID: "b"})
+    forged.Second.From = "n1"
+    if err := VerifyEvidence(vs, forged); err == nil { t.Fatalf("forged signature must fail") }
+    same := ev
+    same.Second = a
+    if err := VerifyEvidence(vs, same); err == nil { t.Fatalf("same value is not an equivocation") }
+    kind := ev
+    kind.Kind = EvidenceDoublePrepare
+    if err := VerifyEvidence(vs, kind); err == nil { t.Fatalf("kind mismatch must fail") }
+}
+
+func TestEvidencePool_PersistsAndPrunes(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "evidence.jsonl")
+    p, err := NewEvidencePool(path)
+    if err != nil { t.Fatalf("open: %v", err) }
+    p.SetWindow(2)
+    _, _ = p.Observe(Message{Type: MsgPreprepare, From: "n1", Height: 1, ID: "x"})
+    if _, ok := p.Observe(Message{Type: MsgNewView, From: "n1", Height: 1, ID: "y"}); !ok { t.Fatalf("double proposal") }
+    // Height 1 leaves the window once height 4 is seen.
+    _, _ = p.Observe(Message{Type: MsgCommit, From: "n0", Height: 1, ID: "x"})
+    _, _ = p.Observe(Message{Type: MsgCommit, From: "n0", Height: 4, ID: "z"})
+    if _, ok := p.Observe(Message{Type: MsgCommit, From: "n0", Height: 1, ID: "w"}); ok { t.Fatalf("heights below the window are ignored") }
+
+    p2, err := NewEvidencePool(path)
+    if err != nil { t.Fatalf("reopen: %v", err) }
+    got := p2.List("n1", 1)
+    if len(got) != 1 || got[0].Kind != EvidenceDoubleProposal || got[0].Second.ID != "y" { t.Fatalf("reloaded: %+v", got) }
+    if len(p2.List("n0", 0)) != 0 || len(p2.List("", 2)) != 0 { t.Fatalf("filters") }
+}
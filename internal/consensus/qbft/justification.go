@@ -0,0 +1,94 @@
+package qbft
+
+import "fmt"
+
+// validPrepareCert reports whether cert holds prepares from a quorum of
+// distinct members for exactly (height, round, id).
+func validPrepareCert(vs *ValidatorSet, height, round uint64, id string, cert []Message) bool {
+    seen := make(map[string]struct{}, len(cert))
+    for _, m := range cert {
+        if m.Type != MsgPrepare || m.Height != height || m.Round != round || m.ID != id { return false }
+        if !vs.Contains(m.From) { return false }
+        seen[m.From] = struct{}{}
+    }
+    return len(seen) >= vs.Quorum()
+}
+
+// justifyRoundChange checks a single round-change: a prepared claim must be
+// for a round below the target and backed by a valid prepare certificate.
+func justifyRoundChange(vs *ValidatorSet, rc Message) error {
+    if rc.PreparedID == "" { return nil }
+    if rc.PreparedRound >= rc.Round {
+        return fmt.Errorf("prepared round %d not below target %d", rc.PreparedRound, rc.Round)
+    }
+    if !validPrepareCert(vs, rc.Height, rc.PreparedRound, rc.PreparedID, rc.Justification) {
+        return fmt.Errorf("invalid prepare certificate")
+    }
+    return nil
+}
+
+// highestPrepared returns the round-change with the highest prepared round
+// among rcs, and false if none of them carries a prepared value.
+func highestPrepared(rcs []Message) (Message, bool) {
+    var best Message
+    found := false
+    for _, rc := range rcs {
+        if rc.PreparedID == "" { continue }
+        if !found || rc.PreparedRound > best.PreparedRound {
+            best, found = rc, true
+        }
+    }
+    return best, found
+}
+
+// justifyProposal applies the QBFT justification rule for a proposal of id at
+// (height, round): round 0 needs nothing; otherwise rcs must be round-changes
+// for (height, round) from a quorum of distinct members, each individually
+// justified, and if any of them prepared a value, id must be the value with
+// the highest prepared round.
+func justifyProposal(vs *ValidatorSet, height, round uint64, id string, rcs []Message) error {
+    if round == 0 { return nil }
+    seen := make(map[string]struct{}, len(rcs))
+    for _, rc := range rcs {
+        if rc.Type != MsgViewChange || rc.Height != height || rc.Round != round {
+            return fmt.Errorf("round-change for wrong coordinates")
+        }
+        if !vs.Contains(rc.From) { return fmt.Errorf("round-change from non-member %q", rc.From) }
+        if err := justifyRoundChange(vs, rc); err != nil { return err }
+        seen[rc.From] = struct{}{}
+    }
+    if len(seen) < vs.Quorum() {
+        return fmt.Errorf("round-change quorum not reached: %d < %d", len(seen), vs.Quorum())
+    }
+    if hp, ok := highestPrepared(rcs); ok && hp.PreparedID != id {
+        return fmt.Errorf("proposal %q does not match highest prepared %q", id, hp.PreparedID)
+    }
+    return nil
+}
+
+// VerifyCommitSeal checks that seal finalizes id at (height, round): commits
+// for exactly those coordinates from a quorum of distinct members, each
+// carrying a valid signature when vs registers node keys. Peers use it to
+// accept blocks they did not see committed (catch-up sync).
+func VerifyCommitSeal(vs *ValidatorSet, height, round uint64, id string, seal []Message) error {
+    return VerifySeal(vs, MsgCommit, height, round, id, seal)
+}
+
+// VerifySeal is VerifyCommitSeal for seals made of votes of type typ (the
+// HotStuff engine seals blocks with the hs_vote quorum certifying them).
+func VerifySeal(vs *ValidatorSet, typ Type, height, round uint64, id string, seal []Message) error {
+    if vs.Size() == 0 { return fmt.Errorf("no validator set") }
+    seen := make(map[string]struct{}, len(seal))
+    for _, m := range seal {
+        if m.Type != typ || m.Height != height || m.Round != round || m.ID != id {
+            return fmt.Errorf("commit for wrong coordinates")
+        }
+        if !vs.Contains(m.From) { return fmt.Errorf("commit from non-member %q", m.From) }
+        if vs.HasKeys() && !VerifySig(vs.PubKey(m.From), m) { return fmt.Errorf("invalid commit signature from %q", m.From) }
+        seen[m.From] = struct{}{}
+    }
+    if len(seen) < vs.Quorum() {
+        return fmt.Errorf("commit quorum not reached: %d < %d", len(seen), vs.Quorum())
+    }
+    return nil
+}
@@ -0,0 +1,122 @@
+package qbft
+
+import (
+    "fmt"
+    "testing"
+)
+
+// roundChanges builds unprepared round-changes for (h, r) from the given members.
+func roundChanges(h, r uint64, from ...string) []Message {
+    out := make([]Message, 0, len(from))
+    for _, f := range from {
+        out = append(out, Message{ID: fmt.Sprintf("rc-%s-%d-%d", f, h, r), From: f, Type: MsgViewChange, Height: h, Round: r})
+    }
+    return out
+}
+
+// prepares builds a prepare certificate for (h, r, id) from the given members.
+func prepares(h, r uint64, id string, from ...string) []Message {
+    out := make([]Message, 0, len(from))
+    for _, f := range from {
+        out = append(out, Message{ID: id, From: f, Type: MsgPrepare, Height: h, Round: r})
+    }
+    return out
+}
+
+func TestJustifyRoundChange_PreparedNeedsCertificate(t *testing.T) {
+    vs := fourNodes()
+    rc := Message{From: "n0", Type: MsgViewChange, Height: 3, Round: 1, PreparedRound: 0, PreparedID: "v"}
+    if err := justifyRoundChange(vs, rc); err == nil { t.Fatalf("prepared claim without certificate must fail") }
+    rc.Justification = prepares(3, 0, "v", "n0", "n1")
+    if err := justifyRoundChange(vs, rc); err == nil { t.Fatalf("certificate below quorum must fail") }
+    rc.Justification = prepares(3, 0, "v", "n0", "n1", "x")
+    if err := justifyRoundChange(vs, rc); err == nil { t.Fatalf("certificate with non-member must fail") }
+    rc.Justification = prepares(3, 0, "w", "n0", "n1", "n2")
+    if err := justifyRoundChange(vs, rc); err == nil { t.Fatalf("certificate for another value must fail") }
+    rc.Justification = prepares(3, 0, "v", "n0", "n1", "n2")
+    if err := justifyRoundChange(vs, rc); err != nil { t.Fatalf("valid certificate: %v", err) }
+    rc.PreparedRound = 1
+    if err := justifyRoundChange(vs, rc); err == nil { t.Fatalf("prepared round must be below target") }
+}
+
+func TestJustifyProposal_QuorumAndHighestPrepared(t *testing.T) {
+    vs := fourNodes()
+    if err := justifyProposal(vs, 3, 0, "any", nil); err != nil { t.Fatalf("round 0 needs no justification: %v", err) }
+    if err := justifyProposal(vs, 3, 2, "free", roundChanges(3, 2, "n0", "n1")); err == nil { t.Fatalf("below quorum must fail") }
+    if err := justifyProposal(vs, 3, 2, "free", roundChanges(3, 2, "n0", "n1", "n1")); err == nil { t.Fatalf("duplicate senders must not count twice") }
+    if err := justifyProposal(vs, 3, 2, "free", roundChanges(3, 1, "n0", "n1", "n2")); err == nil { t.Fatalf("round-changes for another round must fail") }
+    if err := justifyProposal(vs, 3, 2, "free", roundChanges(3, 2, "n0", "n1", "n2")); err != nil { t.Fatalf("unprepared quorum frees the value: %v", err) }
+
+    // n1 prepared "old" in round 0, n2 prepared "new" in round 1: "new" wins.
+    rcs := roundChanges(3, 2, "n0", "n1", "n2")
+    rcs[1].PreparedRound, rcs[1].PreparedID, rcs[1].Justification = 0, "old", prepares(3, 0, "old", "n0", "n1", "n3")
+    rcs[2].PreparedRound, rcs[2].PreparedID, rcs[2].Justification = 1, "new", prepares(3, 1, "new", "n1", "n2", "n3")
+    if err := justifyProposal(vs, 3, 2, "old", rcs); err == nil { t.Fatalf("must re-propose the highest prepared value") }
+    if err := justifyProposal(vs, 3, 2, "new", rcs); err != nil { t.Fatalf("highest prepared value: %v", err) }
+}
+
+// A node that prepared a value carries the certificate into its round-change,
+// and the next proposer's NEW_VIEW is forced to re-propose that value.
+func TestState_RoundChange_CarriesPreparedValueIntoNewView(t *testing.T) {
+    vs := fourNodes()
+    // n2 proposes in round 1 at height 1 (proposer(1,1) = n2).
+    st := &State{Validators: vs, Self: "n2"}
+    if err := st.Process(Message{ID: "v", From: "n1", Type: MsgPreprepare, Height: 1, Round: 0, Payload: []byte("blk")}); err != nil { t.Fatalf("preprepare: %v", err) }
+    for _, p := range prepares(1, 0, "v", "n0", "n1", "n2") {
+        if err := st.Process(p); err != nil { t.Fatalf("prepare: %v", err) }
+    }
+    if st.Phase != "prepared" { t.Fatalf("want prepared, got %q", st.Phase) }
+    vc := st.OnTimeout()
+    if vc.PreparedID != "v" || vc.PreparedRound != 0 || string(vc.Payload) != "blk" || len(vc.Justification) != 3 {
+        t.Fatalf("round-change must carry prepared certificate: %+v", vc)
+    }
+    if err := st.Process(vc); err != nil { t.Fatalf("own round-change: %v", err) }
+    if _, ok := st.NewView("fresh", nil); ok { t.Fatalf("new_view before round-change quorum") }
+    for _, rc := range roundChanges(1, 1, "n0", "n3") {
+        if err := st.Process(rc); err != nil { t.Fatalf("round-change: %v", err) }
+    }
+    if st.Round != 1 || st.Phase != "" { t.Fatalf("want round 1 idle, got r=%d phase=%q", st.Round, st.Phase) }
+    nv, ok := st.NewView("fresh", []byte("other"))
+    if !ok { t.Fatalf("proposer should build a new_view") }
+    if nv.ID != "v" || string(nv.Payload) != "blk" || len(nv.Justification) != 3 {
+        t.Fatalf("new_view must re-propose prepared value: %+v", nv)
+    }
+    if _, again := st.NewView("fresh", nil); again { t.Fatalf("new_view built twice for one round") }
+
+    // A follower accepts the justified NEW_VIEW and can prepare it in round 1.
+    f := &State{Validators: vs}
+    if err := f.Process(Message{ID: "v", From: "n1", Type: MsgPreprepare, Height: 1, Round: 0}); err != nil { t.Fatalf("follower preprepare: %v", err) }
+    if err := f.Process(nv); err != nil { t.Fatalf("follower new_view: %v", err) }
+    if f.Round != 1 || f.Phase != "preprepared" { t.Fatalf("follower: r=%d phase=%q", f.Round, f.Phase) }
+    if err := f.Process(Message{ID: "v", From: "n0", Type: MsgPrepare, Height: 1, Round: 0}); err == nil {
+        t.Fatalf("prepare from the old round must not count")
+    }
+    if err := f.Process(Message{ID: "v", From: "n0", Type: MsgPrepare, Height: 1, Round: 1}); err != nil { t.Fatalf("round-1 prepare: %v", err) }
+}
+
+func TestState_NewView_RejectsUnjustifiedOrConflicting(t *testing.T) {
+    vs := fourNodes()
+    st := &State{Validators: vs}
+    if err := st.Process(Message{ID: "x", From: "n2", Type: MsgNewView, Height: 1, Round: 1}); err == nil {
+        t.Fatalf("new_view without round-change quorum must be rejected")
+    }
+    rcs := roundChanges(1, 1, "n0", "n1", "n3")
+    rcs[0].PreparedID, rcs[0].PreparedRound, rcs[0].Justification = "v", 0, prepares(1, 0, "v", "n0", "n1", "n2")
+    if err := st.Process(Message{ID: "x", From: "n2", Type: MsgNewView, Height: 1, Round: 1, Justification: rcs}); err == nil {
+        t.Fatalf("new_view ignoring the prepared value must be rejected")
+    }
+    if st.Round != 0 || st.Phase != "" { t.Fatalf("rejected new_view changed state: r=%d phase=%q", st.Round, st.Phase) }
+    if err := st.Process(Message{ID: "v", From: "n2", Type: MsgNewView, Height: 1, Round: 1, Justification: rcs}); err != nil {
+        t.Fatalf("justified new_view: %v", err)
+    }
+    if err := st.Process(Message{ID: "y", From: "n2", Type: MsgPreprepare, Height: 1, Round: 1, Justification: roundChanges(1, 1, "n0", "n1", "n3")}); err == nil {
+        t.Fatalf("second proposal for the same round must be rejected")
+    }
+}
+
+// Round-changes claiming a prepared value without a certificate are refused.
+func TestState_RoundChange_RejectsForgedPreparedClaim(t *testing.T) {
+    st := &State{Validators: fourNodes()}
+    forged := Message{ID: "rc", From: "n3", Type: MsgViewChange, Height: 1, Round: 1, PreparedID: "evil"}
+    if err := st.Process(forged); err == nil { t.Fatalf("forged prepared claim must be rejected") }
+}
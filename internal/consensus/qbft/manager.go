@@ -0,0 +1,254 @@
+package qbft
+
+import (
+    "fmt"
+
+    "github.com/zmlAEQ/Aequa-network/pkg/logger"
+    "github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// Buffering limits for messages that arrive ahead of the active instance.
+const (
+    DefaultFutureWindow    = 8    // heights (and rounds) ahead that are buffered
+    DefaultMaxBuffered     = 1024 // total buffered messages
+    DefaultMaxPerSenderBuf = 64   // buffered messages per sender
+)
+
+// Manager runs one State per height. Only the active height is processed;
+// messages for later heights (and votes of the active height that are not
+// applicable yet) are buffered within bounded windows and replayed when
+// the instance reaches them. Messages for finished heights are dropped.
+//
+// Heights advance explicitly via Advance (after the active height committed
+// and its block was persisted) or Restore (recovery). Until either is called
+// the first accepted message fixes the starting height.
+type Manager struct {
+    validators *ValidatorSet
+    epochs     *Epochs // optional schedule overriding validators by height
+    self       string
+
+    started bool
+    height  uint64
+    cur     *State
+
+    future    map[uint64][]Message // buffered by height
+    seen      map[string]struct{}  // dedup keys of buffered messages
+    perSender map[string]int
+    buffered  int
+
+    window       uint64
+    maxBuffered  int
+    maxPerSender int
+
+    validate func(Message) error
+}
+
+// NewManager builds a manager whose per-height instances use the given
+// validator set and local operator id.
+func NewManager(vs *ValidatorSet, self string) *Manager {
+    return &Manager{
+        validators:   vs,
+        self:         self,
+        future:       make(map[uint64][]Message),
+        seen:         make(map[string]struct{}),
+        perSender:    make(map[string]int),
+        window:       DefaultFutureWindow,
+        maxBuffered:  DefaultMaxBuffered,
+        maxPerSender: DefaultMaxPerSenderBuf,
+    }
+}
+
+// SetLimits overrides the buffering window and caps (zero keeps the current value).
+func (m *Manager) SetLimits(window uint64, maxBuffered, maxPerSender int) {
+    if window > 0 { m.window = window }
+    if maxBuffered > 0 { m.maxBuffered = maxBuffered }
+    if maxPerSender > 0 { m.maxPerSender = maxPerSender }
+}
+
+// SetProposalValidator installs fn as ValidateProposal of the active and all
+// later instances.
+func (m *Manager) SetProposalValidator(fn func(Message) error) {
+    m.validate = fn
+    if m.cur != nil { m.cur.ValidateProposal = fn }
+}
+
+// SetEpochs makes every height run with the validator set the schedule puts
+// in force at it (quorums, proposer rotation, membership).
+func (m *Manager) SetEpochs(ep *Epochs) { m.epochs = ep }
+
+// validatorsAt returns the validator set of height h.
+func (m *Manager) validatorsAt(h uint64) *ValidatorSet {
+    if m.epochs != nil { return m.epochs.At(h) }
+    return m.validators
+}
+
+func (m *Manager) start(h uint64) {
+    m.started = true
+    m.height = h
+    m.cur = &State{Height: h, Validators: m.validatorsAt(h), Self: m.self, ValidateProposal: m.validate}
+    for bh, msgs := range m.future {
+        if bh >= h { continue }
+        for _, msg := range msgs { m.unbuffer(msg, "stale") }
+        delete(m.future, bh)
+    }
+}
+
+// instance returns the active State, starting height 0 if nothing did yet.
+func (m *Manager) instance() *State {
+    if m.cur == nil { m.start(m.height) }
+    return m.cur
+}
+
+// Height returns the active height.
+func (m *Manager) Height() uint64 { return m.height }
+
+// Buffered returns the number of buffered future messages.
+func (m *Manager) Buffered() int { return m.buffered }
+
+func bufKey(msg Message) string {
+    return fmt.Sprintf("%s|%s|%d|%d|%s", msg.Type, msg.From, msg.Height, msg.Round, msg.ID)
+}
+
+func (m *Manager) drop(msg Message, reason string) {
+    metrics.Inc("qbft_future_msgs_total", map[string]string{"result": reason})
+    logger.InfoJ("qbft_manager", map[string]any{"result": reason, "type": string(msg.Type), "from": msg.From, "height": msg.Height, "round": msg.Round, "active": m.height, "trace_id": msg.TraceID})
+}
+
+// buffer stores msg for later replay unless it is too far ahead, a duplicate
+// or would exceed the caps. It reports whether msg was kept.
+func (m *Manager) buffer(msg Message) bool {
+    ahead := msg.Height - m.height
+    if msg.Height == m.height { ahead = msg.Round - m.cur.Round }
+    if ahead > m.window {
+        m.drop(msg, "too_far")
+        return false
+    }
+    k := bufKey(msg)
+    if _, dup := m.seen[k]; dup {
+        m.drop(msg, "duplicate")
+        return false
+    }
+    if m.buffered >= m.maxBuffered || m.perSender[msg.From] >= m.maxPerSender {
+        m.drop(msg, "full")
+        return false
+    }
+    m.seen[k] = struct{}{}
+    m.perSender[msg.From]++
+    m.buffered++
+    m.future[msg.Height] = append(m.future[msg.Height], msg)
+    metrics.Inc("qbft_future_msgs_total", map[string]string{"result": "buffered"})
+    metrics.SetGauge("qbft_future_buffer_size", nil, int64(m.buffered))
+    return true
+}
+
+func (m *Manager) unbuffer(msg Message, result string) {
+    delete(m.seen, bufKey(msg))
+    if m.perSender[msg.From]--; m.perSender[msg.From] <= 0 { delete(m.perSender, msg.From) }
+    m.buffered--
+    metrics.Inc("qbft_future_msgs_total", map[string]string{"result": result})
+    metrics.SetGauge("qbft_future_buffer_size", nil, int64(m.buffered))
+}
+
+// pending reports whether msg is a vote of the active height that the
+// instance cannot apply yet: a prepare/commit for a later round, a prepare
+// before the round's proposal or a commit before the prepare quorum. Gossip
+// reordering makes all of these routine.
+func (m *Manager) pending(msg Message) bool {
+    if msg.Height != m.height || (msg.Type != MsgPrepare && msg.Type != MsgCommit) { return false }
+    if msg.Round != m.cur.Round { return msg.Round > m.cur.Round }
+    if msg.Type == MsgPrepare { return m.cur.proposalID == "" }
+    return m.cur.Phase != "prepared" && m.cur.Phase != "commit"
+}
+
+// Process routes msg to the active instance, buffers it when it is ahead
+// (later height, later round, or a vote arriving before what it votes on),
+// or drops it when its height is already finished.
+func (m *Manager) Process(msg Message) error {
+    if vs := m.validatorsAt(msg.Height); vs.Size() > 0 && !vs.Contains(msg.From) {
+        m.drop(msg, "not_member")
+        return fmt.Errorf("sender not in validator set")
+    }
+    if !m.started { m.start(msg.Height) }
+    if msg.Height < m.height {
+        m.drop(msg, "stale")
+        return fmt.Errorf("stale height %d (active %d)", msg.Height, m.height)
+    }
+    if msg.Height > m.height || m.pending(msg) {
+        m.buffer(msg)
+        return nil
+    }
+    err := m.cur.Process(msg)
+    m.replay()
+    return err
+}
+
+// replay feeds buffered messages of the active height that became
+// applicable (after a proposal, a prepare quorum or a round change) and
+// drops votes for rounds already left.
+// Processing a replayed message may advance the round again, so it loops
+// until nothing changes.
+func (m *Manager) replay() {
+    for {
+        msgs := m.future[m.height]
+        if len(msgs) == 0 { return }
+        var keep, ready []Message
+        for _, msg := range msgs {
+            switch {
+            case (msg.Type == MsgPrepare || msg.Type == MsgCommit) && msg.Round < m.cur.Round:
+                m.unbuffer(msg, "stale")
+            case m.pending(msg):
+                keep = append(keep, msg)
+            default:
+                ready = append(ready, msg)
+            }
+        }
+        if len(keep) == 0 { delete(m.future, m.height) } else { m.future[m.height] = keep }
+        if len(ready) == 0 { return }
+        for _, msg := range ready {
+            m.unbuffer(msg, "replayed")
+            _ = m.cur.Process(msg)
+        }
+    }
+}
+
+// Advance starts the next height and replays what was buffered for it.
+func (m *Manager) Advance() {
+    m.start(m.instance().Height + 1)
+    logger.InfoJ("qbft_manager", map[string]any{"result": "advance", "height": m.height, "buffered": len(m.future[m.height])})
+    m.replay()
+}
+
+// Restore starts a fresh instance at height (recovery after restart). The
+// instance always starts at round 0; later rounds are re-entered through
+// round-changes, so the persisted round is ignored.
+func (m *Manager) Restore(height, _ uint64) {
+    m.start(height)
+    m.replay()
+}
+
+// OnTimeout delegates to the active instance.
+func (m *Manager) OnTimeout() Message { return m.instance().OnTimeout() }
+
+// View reports the active instance's coordinates and phase.
+func (m *Manager) View() (height, round uint64, phase string) {
+    if m.cur == nil { return m.height, 0, "" }
+    return m.cur.View()
+}
+
+// NewView delegates to the active instance.
+func (m *Manager) NewView(id string, payload []byte) (Message, bool) {
+    return m.instance().NewView(id, payload)
+}
+
+// Propose delegates to the active instance.
+func (m *Manager) Propose(value func(height, round uint64) (id string, payload []byte)) (Message, bool) {
+    return m.instance().Propose(value)
+}
+
+// Vote delegates to the active instance.
+func (m *Manager) Vote() (Message, bool) { return m.instance().Vote() }
+
+// CommitSeal delegates to the active instance.
+func (m *Manager) CommitSeal() (round uint64, id string, payload []byte, seal []Message, ok bool) {
+    return m.instance().CommitSeal()
+}
@@ -0,0 +1,104 @@
+package qbft
+
+import (
+    "fmt"
+    "testing"
+)
+
+// heightMsgs returns a full round-0 instance for height h in gossip order.
+func heightMsgs(vs *ValidatorSet, h uint64, id string) []Message {
+    out := []Message{{ID: id, From: vs.Proposer(h, 0), Type: MsgPreprepare, Height: h}}
+    out = append(out, prepares(h, 0, id, "n0", "n1", "n2")...)
+    for _, f := range []string{"n0", "n1", "n2"} {
+        out = append(out, Message{ID: id, From: f, Type: MsgCommit, Height: h})
+    }
+    return out
+}
+
+func TestManager_FutureHeightBufferedAndReplayedOnAdvance(t *testing.T) {
+    vs := fourNodes()
+    m := NewManager(vs, "n0")
+    m.Restore(1, 0)
+    // Height 2 arrives before height 1 finished: it must not clobber height 1.
+    for _, msg := range heightMsgs(vs, 2, "b2") {
+        if err := m.Process(msg); err != nil { t.Fatalf("buffer: %v", err) }
+    }
+    if h, _, _ := m.View(); h != 1 || m.Buffered() != 7 { t.Fatalf("height=%d buffered=%d", h, m.Buffered()) }
+    for _, msg := range heightMsgs(vs, 1, "b1") { _ = m.Process(msg) }
+    if _, _, p := m.View(); p != "commit" { t.Fatalf("height 1 not committed: %q", p) }
+    if _, id, _, seal, ok := m.CommitSeal(); !ok || id != "b1" || len(seal) != 3 { t.Fatalf("seal: %q %d", id, len(seal)) }
+
+    m.Advance()
+    if h, _, p := m.View(); h != 2 || p != "commit" { t.Fatalf("replay of height 2: h=%d phase=%q", h, p) }
+    if m.Buffered() != 0 { t.Fatalf("buffer not drained: %d", m.Buffered()) }
+}
+
+func TestManager_ReorderedVotesWaitForProposal(t *testing.T) {
+    vs := fourNodes()
+    m := NewManager(vs, "n0")
+    m.Restore(1, 0)
+    msgs := heightMsgs(vs, 1, "b")
+    // commits, then prepares, then the proposal
+    for i := len(msgs) - 1; i >= 0; i-- { _ = m.Process(msgs[i]) }
+    if _, _, p := m.View(); p != "commit" { t.Fatalf("reordered instance must commit, got %q", p) }
+}
+
+func TestManager_StaleAndNonMemberDropped(t *testing.T) {
+    vs := fourNodes()
+    m := NewManager(vs, "n0")
+    m.Restore(5, 0)
+    if err := m.Process(Message{ID: "x", From: "n1", Type: MsgPrepare, Height: 4}); err == nil { t.Fatalf("stale height must fail") }
+    if err := m.Process(Message{ID: "x", From: "zz", Type: MsgPrepare, Height: 6}); err == nil { t.Fatalf("non-member must fail") }
+    if m.Buffered() != 0 { t.Fatalf("nothing must be buffered, got %d", m.Buffered()) }
+}
+
+func TestManager_BufferBounds(t *testing.T) {
+    vs := fourNodes()
+    m := NewManager(vs, "n0")
+    m.SetLimits(2, 4, 2)
+    m.Restore(1, 0)
+    _ = m.Process(Message{ID: "far", From: "n1", Type: MsgPrepare, Height: 4})
+    if m.Buffered() != 0 { t.Fatalf("beyond window must be dropped") }
+    dup := Message{ID: "a", From: "n1", Type: MsgPrepare, Height: 2}
+    _ = m.Process(dup)
+    _ = m.Process(dup)
+    _ = m.Process(Message{ID: "b", From: "n1", Type: MsgPrepare, Height: 3})
+    _ = m.Process(Message{ID: "c", From: "n1", Type: MsgPrepare, Height: 3})
+    if m.Buffered() != 2 { t.Fatalf("duplicate and per-sender cap: buffered=%d", m.Buffered()) }
+    _ = m.Process(Message{ID: "d", From: "n2", Type: MsgPrepare, Height: 2})
+    _ = m.Process(Message{ID: "e", From: "n3", Type: MsgPrepare, Height: 2})
+    _ = m.Process(Message{ID: "f", From: "n0", Type: MsgPrepare, Height: 2})
+    if m.Buffered() != 4 { t.Fatalf("total cap: buffered=%d", m.Buffered()) }
+}
+
+func TestManager_FutureRoundVotesReplayedAfterRoundChange(t *testing.T) {
+    vs := fourNodes()
+    m := NewManager(vs, "n0")
+    m.Restore(1, 0)
+    // Prepares for round 1 arrive before the round-change completes.
+    for _, p := range prepares(1, 1, "v1", "n0", "n1", "n2") { _ = m.Process(p) }
+    if m.Buffered() != 3 { t.Fatalf("future-round votes must be buffered, got %d", m.Buffered()) }
+    rcs := roundChanges(1, 1, "n0", "n1", "n3")
+    for _, rc := range rcs { _ = m.Process(rc) }
+    if err := m.Process(Message{ID: "v1", From: vs.Proposer(1, 1), Type: MsgNewView, Height: 1, Round: 1, Justification: rcs}); err != nil {
+        t.Fatalf("new view: %v", err)
+    }
+    if _, r, p := m.View(); r != 1 || p != "prepared" { t.Fatalf("want prepared in round 1, got r=%d %q", r, p) }
+}
+
+// The proposal validator applies to every instance, including later heights.
+func TestManager_ProposalValidatorCarriesAcrossHeights(t *testing.T) {
+    vs := fourNodes()
+    m := NewManager(vs, "n0")
+    m.Restore(1, 0)
+    m.SetProposalValidator(func(msg Message) error {
+        if msg.ID == "bad" { return fmt.Errorf("invalid block") }
+        return nil
+    })
+    for _, msg := range heightMsgs(vs, 1, "b1") { _ = m.Process(msg) }
+    m.Advance()
+    if err := m.Process(Message{ID: "bad", From: vs.Proposer(2, 0), Type: MsgPreprepare, Height: 2}); err == nil {
+        t.Fatalf("invalid proposal accepted at height 2")
+    }
+    if _, _, p := m.View(); p != "" { t.Fatalf("phase after rejected proposal: %q", p) }
+}
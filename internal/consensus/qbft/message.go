@@ -8,8 +8,18 @@ const (
     MsgCommit    Type = "commit"
     MsgViewChange Type = "view_change"
     MsgNewView    Type = "new_view"
+
+    // Chained HotStuff engine (internal/consensus/hotstuff). They share the
+    // message envelope, signatures and verifier with QBFT; Round carries the
+    // HotStuff view.
+    MsgHSProposal Type = "hs_proposal"
+    MsgHSVote     Type = "hs_vote"
+    MsgHSNewView  Type = "hs_new_view"
 )
 
+// IsVote reports whether t is a vote type the WAL double-sign guard covers.
+func IsVote(t Type) bool { return t == MsgPrepare || t == MsgCommit || t == MsgHSVote }
+
 type Message struct {
     From    string
     Height  uint64
@@ -18,7 +28,21 @@ type Message struct {
     Payload []byte
     ID      string
     TraceID string
-    // Sig is a placeholder for a signature/aggregate signature byte slice.
-    // For now, the verifier仅检查形状（长度阈值），不做密码学验真。
+    // Sig is the sender's ed25519 signature over SigningBytes(msg). It is
+    // enforced when the cluster lock registers node keys; otherwise the
+    // verifier仅检查形状（长度阈值），不做密码学验真。
     Sig     []byte
+
+    // Round-change (view_change) only: the highest round and value this sender
+    // has prepared at Height. An empty PreparedID means nothing prepared; the
+    // prepared value itself travels in Payload.
+    PreparedRound uint64
+    PreparedID    string
+    // Justification carries supporting messages: the prepare certificate on a
+    // round-change, or the round-change quorum on a new_view / round>0
+    // preprepare.
+    Justification []Message
+    // Share is the sender's aggregatable signature share on a HotStuff vote
+    // (BLS certificates). It is a signature itself and not covered by Sig.
+    Share []byte
 }
@@ -0,0 +1,161 @@
+package qbft
+
+import (
+    "sync"
+
+    "github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+const (
+    DefaultReplayKeep       = 2 * DefaultFutureWindow // heights below the committed one still remembered
+    DefaultReplayMaxEntries = 1 << 16                 // memory budget in remembered keys
+)
+
+// AntiReplay remembers message keys by the height they were seen at. It is
+// bounded twice: Advance forgets heights more than keep below the committed
+// one, and past max entries the lowest heights are evicted first. Messages
+// below the pruned floor can no longer be told from replays; Floor lets the
+// verifier reject them as old. It is safe for concurrent use.
+type AntiReplay struct {
+    mu    sync.Mutex
+    seen  map[string]uint64   // key -> height it was recorded at
+    byH   map[uint64][]string // height -> keys recorded at it, in order
+    floor uint64              // heights below were pruned
+    top   uint64              // highest height recorded
+    keep  uint64
+    max   int
+}
+
+// ReplaySnapshot is the persisted form of an AntiReplay.
+type ReplaySnapshot struct {
+    Floor   uint64
+    Entries map[string]uint64
+}
+
+func NewAntiReplay() *AntiReplay {
+    return &AntiReplay{seen: map[string]uint64{}, byH: map[uint64][]string{}, keep: DefaultReplayKeep, max: DefaultReplayMaxEntries}
+}
+
+// SetBounds sets how many heights below the committed one are kept and the
+// entry budget (0 keeps the respective default).
+func (r *AntiReplay) SetBounds(keep uint64, max int) {
+    r.mu.Lock(); defer r.mu.Unlock()
+    if keep > 0 { r.keep = keep }
+    if max > 0 { r.max = max }
+}
+
+// Seen returns true if id already seen; otherwise records and returns false.
+// Keys recorded without a height are kept until the next Advance.
+func (r *AntiReplay) Seen(id string) bool {
+    r.mu.Lock(); defer r.mu.Unlock()
+    return r.seenAt(id, r.floor)
+}
+
+// SeenAt returns true if id was seen at any height still remembered;
+// otherwise records it at h and returns false.
+func (r *AntiReplay) SeenAt(id string, h uint64) bool {
+    r.mu.Lock(); defer r.mu.Unlock()
+    return r.seenAt(id, h)
+}
+
+func (r *AntiReplay) seenAt(id string, h uint64) bool {
+    if id == "" { return false }
+    if _, ok := r.seen[id]; ok { return true }
+    r.record(id, h)
+    return false
+}
+
+// SeenWithin returns true if id was seen within the given height window.
+func (r *AntiReplay) SeenWithin(id string, h, window uint64) bool {
+    if id == "" || window == 0 { return false }
+    r.mu.Lock(); defer r.mu.Unlock()
+    if last, ok := r.seen[id]; ok {
+        if h >= last && h-last <= window { return true }
+    }
+    r.record(id, h)
+    return false
+}
+
+// record stores id at h and enforces the entry budget. Callers hold r.mu.
+func (r *AntiReplay) record(id string, h uint64) {
+    r.seen[id] = h
+    r.byH[h] = append(r.byH[h], id)
+    if h > r.top { r.top = h }
+    for r.max > 0 && len(r.seen) > r.max { r.evict() }
+    metrics.SetGauge("qbft_replay_entries", nil, int64(len(r.seen)))
+}
+
+// evict drops the lowest height and raises the floor above it. When only
+// the highest height is left its oldest keys go instead, without raising the
+// floor past live messages. Callers hold r.mu.
+func (r *AntiReplay) evict() {
+    low := r.top
+    for h := range r.byH {
+        if h < low { low = h }
+    }
+    if low < r.top {
+        r.prune(low + 1)
+        metrics.Inc("qbft_replay_evicted_total", map[string]string{"reason": "height"})
+        return
+    }
+    ids := r.byH[low]
+    for len(ids) > 0 && len(r.seen) > r.max {
+        if r.seen[ids[0]] == low { delete(r.seen, ids[0]) }
+        ids = ids[1:]
+        metrics.Inc("qbft_replay_evicted_total", map[string]string{"reason": "budget"})
+    }
+    r.byH[low] = ids
+}
+
+// prune forgets heights below h. Callers hold r.mu.
+func (r *AntiReplay) prune(below uint64) {
+    if below <= r.floor { return }
+    for h, ids := range r.byH {
+        if h >= below { continue }
+        for _, id := range ids {
+            if r.seen[id] == h { delete(r.seen, id) }
+        }
+        delete(r.byH, h)
+    }
+    r.floor = below
+}
+
+// Advance forgets heights more than keep below the committed height.
+func (r *AntiReplay) Advance(committed uint64) {
+    r.mu.Lock(); defer r.mu.Unlock()
+    if committed <= r.keep { return }
+    r.prune(committed - r.keep)
+    metrics.SetGauge("qbft_replay_entries", nil, int64(len(r.seen)))
+}
+
+// Floor returns the lowest height still remembered.
+func (r *AntiReplay) Floor() uint64 {
+    r.mu.Lock(); defer r.mu.Unlock()
+    return r.floor
+}
+
+// Len returns the number of remembered keys.
+func (r *AntiReplay) Len() int {
+    r.mu.Lock(); defer r.mu.Unlock()
+    return len(r.seen)
+}
+
+// Snapshot returns a copy of the remembered keys for checkpointing.
+func (r *AntiReplay) Snapshot() ReplaySnapshot {
+    r.mu.Lock(); defer r.mu.Unlock()
+    s := ReplaySnapshot{Floor: r.floor, Entries: make(map[string]uint64, len(r.seen))}
+    for id, h := range r.seen { s.Entries[id] = h }
+    return s
+}
+
+// Restore merges a checkpoint taken before a restart: its keys are
+// remembered again and the floor does not go below the checkpointed one.
+func (r *AntiReplay) Restore(s ReplaySnapshot) {
+    r.mu.Lock(); defer r.mu.Unlock()
+    r.prune(s.Floor)
+    for id, h := range s.Entries {
+        if h < r.floor { continue }
+        if _, ok := r.seen[id]; ok { continue }
+        r.record(id, h)
+    }
+}
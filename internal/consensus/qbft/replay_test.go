@@ -0,0 +1,59 @@
+package qbft
+
+import (
+    "fmt"
+    "testing"
+)
+
+func TestAntiReplay_AdvancePrunesBelowKeep(t *testing.T) {
+    r := NewAntiReplay()
+    r.SetBounds(2, 0)
+    for h := uint64(1); h <= 5; h++ {
+        if r.SeenAt(fmt.Sprintf("k%d", h), h) { t.Fatalf("first sighting of height %d", h) }
+    }
+    r.Advance(5)
+    if r.Floor() != 3 || r.Len() != 3 { t.Fatalf("floor=%d len=%d, want 3/3", r.Floor(), r.Len()) }
+    if !r.SeenAt("k3", 3) { t.Fatalf("kept key forgotten") }
+    if r.SeenAt("k2", 7) { t.Fatalf("pruned key still remembered") }
+    // Advancing below the floor never lowers it.
+    r.Advance(4)
+    if r.Floor() != 3 { t.Fatalf("floor lowered to %d", r.Floor()) }
+}
+
+func TestAntiReplay_BudgetEvictsLowestHeightFirst(t *testing.T) {
+    r := NewAntiReplay()
+    r.SetBounds(0, 4)
+    for _, k := range []struct{ id string; h uint64 }{{"a", 1}, {"b", 1}, {"c", 2}, {"d", 3}, {"e", 3}} {
+        r.SeenAt(k.id, k.h)
+    }
+    if r.Len() != 3 || r.Floor() != 2 { t.Fatalf("len=%d floor=%d after evicting height 1", r.Len(), r.Floor()) }
+    // A single live height is trimmed from its oldest key, floor untouched.
+    r = NewAntiReplay()
+    r.SetBounds(0, 2)
+    for _, id := range []string{"x", "y", "z"} { r.SeenAt(id, 9) }
+    if r.Len() != 2 || r.Floor() != 0 || !r.SeenAt("z", 9) { t.Fatalf("len=%d floor=%d", r.Len(), r.Floor()) }
+}
+
+func TestAntiReplay_SnapshotRestore(t *testing.T) {
+    r := NewAntiReplay()
+    r.SetBounds(1, 0)
+    r.SeenAt("old", 1)
+    r.SeenAt("new", 5)
+    r.Advance(5)
+    snap := r.Snapshot()
+    if snap.Floor != 4 || len(snap.Entries) != 1 || snap.Entries["new"] != 5 { t.Fatalf("snapshot %+v", snap) }
+
+    restored := NewAntiReplay()
+    restored.Restore(snap)
+    if !restored.SeenAt("new", 5) || restored.Floor() != 4 { t.Fatalf("checkpoint not restored") }
+}
+
+// Messages the cache pruned are rejected as old rather than accepted again.
+func TestBasicVerifier_RejectsBelowReplayFloor(t *testing.T) {
+    v := NewBasicVerifierWithPolicy(Policy{ReplayKeep: 2})
+    msg := Message{ID: "v", From: "p", Type: MsgPrepare, Height: 3, Round: 1}
+    if err := v.Verify(msg); err != nil { t.Fatalf("first: %v", err) }
+    v.ReplayCache().Advance(10)
+    if err := v.Verify(msg); err == nil || err.Error() != "old height" { t.Fatalf("pruned replay: %v", err) }
+    if err := v.Verify(Message{ID: "v", From: "p", Type: MsgPrepare, Height: 8, Round: 1}); err != nil { t.Fatalf("height within keep: %v", err) }
+}
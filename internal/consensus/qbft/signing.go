@@ -0,0 +1,90 @@
+package qbft
+
+import (
+    "crypto/ed25519"
+    "crypto/sha256"
+    "encoding/binary"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "os"
+    "strings"
+)
+
+// signDomain separates QBFT message signatures from any other use of the key.
+const signDomain = "aequa/qbft/msg/v1"
+
+// SigningBytes returns the canonical byte string an operator signs for msg:
+// domain | type | height | round | id | sha256(payload) | prepared round | prepared id.
+// Strings are length-prefixed (u32 BE), integers are u64 BE. TraceID, Sig and
+// Justification are not covered; justification entries carry their own
+// signatures.
+func SigningBytes(msg Message) []byte {
+    digest := sha256.Sum256(msg.Payload)
+    b := make([]byte, 0, 128+len(msg.ID)+len(msg.PreparedID))
+    b = appendString(b, signDomain)
+    b = appendString(b, string(msg.Type))
+    b = binary.BigEndian.AppendUint64(b, msg.Height)
+    b = binary.BigEndian.AppendUint64(b, msg.Round)
+    b = appendString(b, msg.ID)
+    b = append(b, digest[:]...)
+    b = binary.BigEndian.AppendUint64(b, msg.PreparedRound)
+    b = appendString(b, msg.PreparedID)
+    return b
+}
+
+func appendString(b []byte, s string) []byte {
+    b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+    return append(b, s...)
+}
+
+// Signer signs consensus messages on behalf of the local operator.
+type Signer interface {
+    // ID returns the operator id used as From on signed messages.
+    ID() string
+    // Sign sets From to ID() and fills Sig over SigningBytes.
+    Sign(msg Message) Message
+}
+
+// Ed25519Signer signs with an ed25519 node key registered in the cluster lock.
+type Ed25519Signer struct {
+    id  string
+    key ed25519.PrivateKey
+}
+
+// NewEd25519Signer binds a private key to the operator id it signs as.
+func NewEd25519Signer(id string, key ed25519.PrivateKey) *Ed25519Signer {
+    return &Ed25519Signer{id: id, key: key}
+}
+
+func (s *Ed25519Signer) ID() string { return s.id }
+
+func (s *Ed25519Signer) Sign(msg Message) Message {
+    msg.From = s.id
+    msg.Sig = ed25519.Sign(s.key, SigningBytes(msg))
+    return msg
+}
+
+// VerifySig checks msg.Sig against the ed25519 key registered for msg.From.
+func VerifySig(pub ed25519.PublicKey, msg Message) bool {
+    if len(pub) != ed25519.PublicKeySize || len(msg.Sig) != ed25519.SignatureSize { return false }
+    return ed25519.Verify(pub, SigningBytes(msg), msg.Sig)
+}
+
+// ParsePubKey decodes a hex-encoded ed25519 public key (optional 0x prefix).
+func ParsePubKey(s string) (ed25519.PublicKey, error) {
+    b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(s), "0x"))
+    if err != nil { return nil, err }
+    if len(b) != ed25519.PublicKeySize { return nil, fmt.Errorf("pubkey: want %d bytes, got %d", ed25519.PublicKeySize, len(b)) }
+    return ed25519.PublicKey(b), nil
+}
+
+// LoadNodeKey reads a hex-encoded ed25519 seed (32 bytes) from path.
+func LoadNodeKey(path string) (ed25519.PrivateKey, error) {
+    raw, err := os.ReadFile(path)
+    if err != nil { return nil, err }
+    seed, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(raw)), "0x"))
+    if err != nil { return nil, err }
+    if len(seed) != ed25519.SeedSize { return nil, errors.New("node key: want 32-byte hex seed") }
+    return ed25519.NewKeyFromSeed(seed), nil
+}
@@ -0,0 +1,120 @@
+package qbft
+
+import (
+    "crypto/ed25519"
+    "encoding/hex"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/zmlAEQ/Aequa-network/pkg/config"
+    "github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// testKeys returns deterministic signers for n0..n3 and a keyed validator set.
+func testKeys(t *testing.T) (map[string]*Ed25519Signer, *ValidatorSet) {
+    t.Helper()
+    ids := []string{"n0", "n1", "n2", "n3"}
+    vs := NewValidatorSet(ids, 0)
+    signers := map[string]*Ed25519Signer{}
+    for i, id := range ids {
+        seed := make([]byte, ed25519.SeedSize)
+        seed[0] = byte(i + 1)
+        key := ed25519.NewKeyFromSeed(seed)
+        signers[id] = NewEd25519Signer(id, key)
+        vs.SetPubKey(id, key.Public().(ed25519.PublicKey))
+    }
+    return signers, vs
+}
+
+func TestSigningBytes_CoversFields(t *testing.T) {
+    base := Message{Type: MsgPrepare, Height: 1, Round: 2, ID: "v", Payload: []byte("p"), TraceID: "t1", From: "a"}
+    ref := string(SigningBytes(base))
+    variants := []Message{base, base, base, base, base, base}
+    variants[0].Type = MsgCommit
+    variants[1].Height = 9
+    variants[2].Round = 9
+    variants[3].ID = "w"
+    variants[4].Payload = []byte("q")
+    variants[5].PreparedID = "v"
+    for i, m := range variants {
+        if string(SigningBytes(m)) == ref { t.Fatalf("variant %d not covered by signing bytes", i) }
+    }
+    other := base
+    other.TraceID, other.Sig = "t2", []byte{1}
+    if string(SigningBytes(other)) != ref { t.Fatalf("trace id / sig must not be covered") }
+}
+
+func TestBasicVerifier_Signatures(t *testing.T) {
+    metrics.Reset()
+    signers, vs := testKeys(t)
+    v := NewBasicVerifierWithPolicy(Policy{Validators: vs})
+
+    ok := signers["n1"].Sign(Message{ID: "v", Type: MsgPrepare, Height: 1, Round: 0})
+    if err := v.Verify(ok); err != nil { t.Fatalf("valid signature: %v", err) }
+
+    unsigned := Message{ID: "u", From: "n1", Type: MsgPrepare, Height: 1}
+    if err := v.Verify(unsigned); err == nil { t.Fatalf("unsigned message must fail") }
+
+    impersonated := signers["n0"].Sign(Message{ID: "i", Type: MsgPrepare, Height: 1})
+    impersonated.From = "n2"
+    if err := v.Verify(impersonated); err == nil { t.Fatalf("signature by another operator must fail") }
+
+    tampered := signers["n1"].Sign(Message{ID: "t", Type: MsgCommit, Height: 1})
+    tampered.Round = 5
+    if err := v.Verify(tampered); err == nil { t.Fatalf("tampered message must fail") }
+
+    // A NEW_VIEW whose embedded round-change is forged must fail as a whole.
+    rc := signers["n0"].Sign(Message{ID: "rc0", Type: MsgViewChange, Height: 1, Round: 1})
+    forged := Message{ID: "rc3", From: "n3", Type: MsgViewChange, Height: 1, Round: 1}
+    nv := signers["n2"].Sign(Message{ID: "nv", Type: MsgNewView, Height: 1, Round: 1, Justification: []Message{rc, forged}})
+    if err := v.Verify(nv); err == nil { t.Fatalf("forged justification must fail") }
+
+    if !strings.Contains(metrics.DumpProm(), `qbft_msg_verified_total{result="sig_invalid"} 4`) {
+        t.Fatalf("want sig_invalid=4, got %q", metrics.DumpProm())
+    }
+}
+
+func TestValidatorSetFromLock_PubKeys(t *testing.T) {
+    signers, _ := testKeys(t)
+    pk := signers["n0"].key.Public().(ed25519.PublicKey)
+    lock := config.ClusterLock{Operators: []config.Operator{{Index: 0, PeerID: "n0", PubKey: hex.EncodeToString(pk)}, {Index: 1, PeerID: "n1"}}}
+    vs, err := ValidatorSetFromLock(lock)
+    if err != nil { t.Fatalf("from lock: %v", err) }
+    if !vs.HasKeys() || !pk.Equal(vs.PubKey("n0")) || vs.PubKey("n1") != nil { t.Fatalf("keys not registered as expected") }
+    lock.Operators[1].PubKey = "zz"
+    if _, err := ValidatorSetFromLock(lock); err == nil { t.Fatalf("malformed pubkey must be an error") }
+}
+
+func TestLoadNodeKey(t *testing.T) {
+    dir := t.TempDir()
+    p := filepath.Join(dir, "node.key")
+    seed := make([]byte, ed25519.SeedSize)
+    seed[3] = 7
+    if err := os.WriteFile(p, []byte(hex.EncodeToString(seed)+"\n"), 0o600); err != nil { t.Fatalf("write: %v", err) }
+    key, err := LoadNodeKey(p)
+    if err != nil { t.Fatalf("load: %v", err) }
+    if !key.Equal(ed25519.NewKeyFromSeed(seed)) { t.Fatalf("key mismatch") }
+    _ = os.WriteFile(p, []byte("abcd"), 0o600)
+    if _, err := LoadNodeKey(p); err == nil { t.Fatalf("short seed must fail") }
+}
+
+func TestVerifyCommitSeal(t *testing.T) {
+    signers, vs := testKeys(t)
+    seal := func(id string, from ...string) []Message {
+        out := make([]Message, 0, len(from))
+        for _, f := range from { out = append(out, signers[f].Sign(Message{Type: MsgCommit, Height: 5, Round: 1, ID: id})) }
+        return out
+    }
+    if err := VerifyCommitSeal(vs, 5, 1, "v", seal("v", "n0", "n1", "n2")); err != nil { t.Fatalf("valid seal: %v", err) }
+    if err := VerifyCommitSeal(vs, 5, 1, "v", seal("v", "n0", "n1", "n1")); err == nil { t.Fatalf("duplicate signers must not reach quorum") }
+    if err := VerifyCommitSeal(vs, 5, 1, "v", seal("w", "n0", "n1", "n2")); err == nil { t.Fatalf("seal for another value must fail") }
+    if err := VerifyCommitSeal(vs, 6, 1, "v", seal("v", "n0", "n1", "n2")); err == nil { t.Fatalf("seal for another height must fail") }
+    bad := seal("v", "n0", "n1", "n2")
+    bad[2].Sig = append([]byte(nil), bad[1].Sig...)
+    if err := VerifyCommitSeal(vs, 5, 1, "v", bad); err == nil { t.Fatalf("forged signature must fail") }
+    unkeyed := NewValidatorSet([]string{"n0", "n1", "n2", "n3"}, 0)
+    if err := VerifyCommitSeal(unkeyed, 5, 1, "v", prepares(5, 1, "v", "n0", "n1", "n2")); err == nil { t.Fatalf("prepares are not a commit seal") }
+    if err := VerifyCommitSeal(nil, 5, 1, "v", seal("v", "n0", "n1", "n2")); err == nil { t.Fatalf("seal needs a validator set") }
+}
@@ -2,24 +2,67 @@ package qbft
 
 import (
     "fmt"
+    "sort"
+
     "github.com/zmlAEQ/Aequa-network/pkg/logger"
     "github.com/zmlAEQ/Aequa-network/pkg/metrics"
 )
 
 // State represents a minimal QBFT state snapshot.
 // This is a skeleton for M3: it carries only coordinates and a textual phase.
+//
+// Without a validator set the state runs in legacy placeholder mode. With one
+// it enforces QBFT round rules: votes must match the current round, rounds
+// only move on a justified proposal or a round-change quorum, and proposals
+// for round>0 (preprepare or new_view) must carry a justifying round-change
+// quorum that respects the highest prepared value.
 type State struct {
     Height uint64
     Round  uint64
     Phase  string // e.g., "idle|preprepared|prepared|commit" (placeholder)
-    Leader string // placeholder leader id for current round
+    Leader string // expected proposer for the current round (derived when Validators is set)
+
+    // Validators sizes quorums and gates membership. When nil, the legacy
+    // placeholder thresholds apply (2 prepares, 1 commit, 2 view-changes).
+    Validators *ValidatorSet
+    // Self is the local operator id used as From on locally generated
+    // messages (e.g. timeout view-changes). Empty keeps the "self" placeholder.
+    Self string
+    // ValidateProposal, when set, checks the value of a PRE-PREPARE or
+    // NEW_VIEW before it is accepted. An invalid value is rejected like an
+    // unjustified proposal, so the node neither prepares nor commits it.
+    ValidateProposal func(msg Message) error
 
     // Minimal aggregation placeholders for M3
-    proposalID   string
-    prepareVotes map[string]struct{} // by From
-    commitVotes  map[string]struct{} // by From
-    // View-change aggregation per target round
-    viewVotes map[uint64]map[string]struct{}
+    proposalID      string
+    proposalRound   uint64
+    proposalPayload []byte
+    prepareVotes    map[string]Message // by From
+    commitVotes     map[string]Message // by From (the commit seal once committed)
+    // View-change aggregation per target round (messages kept for NEW_VIEW justification)
+    viewVotes map[uint64]map[string]Message
+    // Highest round this node has asked to move to at vcHeight via OnTimeout;
+    // repeated timeouts escalate the target instead of re-requesting it.
+    vcHeight uint64
+    vcRound  uint64
+
+    // Prepared certificate for the current height: highest round in which a
+    // prepare quorum was observed, its value and the prepares themselves.
+    preparedRound   uint64
+    preparedID      string
+    preparedPayload []byte
+    preparedCert    []Message
+    // Last proposal value seen at this height (fallback for NEW_VIEW) and the
+    // highest round for which this node already built a NEW_VIEW.
+    lastValueID      string
+    lastValuePayload []byte
+    newViewRound     uint64
+
+    // Local participation: whether this node already proposed at round 0 of
+    // the current height, and cast its prepare/commit in the current round.
+    proposed    bool
+    sentPrepare bool
+    sentCommit  bool
 }
 
 // Processor defines the minimal interface for driving state transitions.
@@ -27,77 +70,177 @@ type Processor interface {
     Process(msg Message) error
 }
 
-// Process triggers a placeholder state transition based on the incoming message.
-// It does not enforce any real QBFT rules; it only updates coordinates,
-// emits a log, and increments a Prometheus counter for observability.
+func (s *State) strict() bool { return s.Validators.Size() > 0 }
+
+// Quorum thresholds for each vote kind. With a validator set configured all
+// three are the BFT quorum; otherwise the legacy placeholder values apply.
+func (s *State) prepareQuorum() int {
+    if s.strict() { return s.Validators.Quorum() }
+    return 2
+}
+
+func (s *State) commitQuorum() int {
+    if s.strict() { return s.Validators.Quorum() }
+    return 1
+}
+
+func (s *State) roundChangeQuorum() int {
+    if s.strict() { return s.Validators.Quorum() }
+    return 2
+}
+
+// reject logs a refused transition with the given reason and returns err.
+func (s *State) reject(msg Message, reason string, err error, extra map[string]any) error {
+    fields := map[string]any{
+        "op":        "transition",
+        "event_type": string(msg.Type),
+        "height":    s.Height,
+        "round":     s.Round,
+        "reason":    reason,
+        "trace_id":  msg.TraceID,
+    }
+    for k, v := range extra { fields[k] = v }
+    logger.ErrorJ("qbft_state", fields)
+    return err
+}
+
+// startHeight resets all per-height state when a newer height begins.
+func (s *State) startHeight(h uint64) {
+    s.Height = h
+    s.Round = 0
+    s.Phase = ""
+    s.proposalID, s.proposalRound, s.proposalPayload = "", 0, nil
+    s.prepareVotes, s.commitVotes, s.viewVotes = nil, nil, nil
+    s.preparedRound, s.preparedID, s.preparedPayload, s.preparedCert = 0, "", nil, nil
+    s.lastValueID, s.lastValuePayload, s.newViewRound = "", nil, 0
+    s.proposed, s.sentPrepare, s.sentCommit = false, false, false
+}
+
+// enterRound moves to round r and clears per-round proposal and votes.
+// The prepared certificate survives: it must be carried into round-changes.
+func (s *State) enterRound(r uint64) {
+    s.Round = r
+    s.Phase = ""
+    s.proposalID, s.proposalRound, s.proposalPayload = "", 0, nil
+    s.prepareVotes = nil
+    s.commitVotes = nil
+    s.sentPrepare, s.sentCommit = false, false
+}
+
+// acceptProposal installs msg as the proposal of its round.
+func (s *State) acceptProposal(msg Message) {
+    s.Round = msg.Round
+    s.Phase = "preprepared"
+    s.proposalID = msg.ID
+    s.proposalRound = msg.Round
+    s.proposalPayload = msg.Payload
+    s.prepareVotes = make(map[string]Message)
+    s.commitVotes = make(map[string]Message)
+    s.lastValueID, s.lastValuePayload = msg.ID, msg.Payload
+    s.sentPrepare, s.sentCommit = false, false
+}
+
+// Process triggers a state transition based on the incoming message. When a
+// validator set is configured, votes from non-members are rejected and phase
+// advances require a 2f+1 quorum of distinct members.
 func (s *State) Process(msg Message) error {
-    // Lightweight, non-authoritative update of coordinates for visibility.
-    s.Height = msg.Height
-    // Do not advance round eagerly on view-change/new-view; round updates
-    // for these types are handled conditionally below when thresholds met.
-    if msg.Type != MsgViewChange && msg.Type != MsgNewView {
-        s.Round = msg.Round
+    if s.strict() && !s.Validators.Contains(msg.From) {
+        metrics.Inc("qbft_msg_total", map[string]string{"type": string(msg.Type)})
+        logger.ErrorJ("qbft_state", map[string]any{
+            "op":        "transition",
+            "event_type": string(msg.Type),
+            "height":    msg.Height,
+            "round":     msg.Round,
+            "reason":    "not_member",
+            "from":      msg.From,
+            "trace_id":  msg.TraceID,
+        })
+        return fmt.Errorf("sender not in validator set")
+    }
+    if s.strict() {
+        // Older heights are finished; a newer height starts a fresh instance.
+        if msg.Height < s.Height {
+            metrics.Inc("qbft_msg_total", map[string]string{"type": string(msg.Type)})
+            return s.reject(msg, "old_height", fmt.Errorf("old height"), map[string]any{"got": msg.Height})
+        }
+        if msg.Height > s.Height { s.startHeight(msg.Height) }
+    } else {
+        // Lightweight, non-authoritative update of coordinates for visibility.
+        s.Height = msg.Height
+        // Do not advance round eagerly on view-change/new-view; round updates
+        // for these types are handled conditionally below when thresholds met.
+        if msg.Type != MsgViewChange && msg.Type != MsgNewView {
+            s.Round = msg.Round
+        }
     }
     var ok bool
     changed := false // only count/log transition when state actually changes
     // Count processed messages (new family; labels unchanged elsewhere)
     metrics.Inc("qbft_msg_total", map[string]string{"type": string(msg.Type)})
+    // Strict mode: votes only count in the round they were cast for.
+    if s.strict() && (msg.Type == MsgPrepare || msg.Type == MsgCommit) && msg.Round != s.Round {
+        return s.reject(msg, "round_mismatch", fmt.Errorf("%s for round %d, current %d", msg.Type, msg.Round, s.Round), map[string]any{"got": msg.Round})
+    }
     switch msg.Type {
-    case MsgPreprepare:
-        // Placeholder leader validation: if Leader is set, only accept from that id
+    case MsgPreprepare, MsgNewView:
+        if msg.Type == MsgNewView && !s.strict() {
+            // Placeholder: accept new-view as authoritative round update
+            if msg.Round > s.Round {
+                s.enterRound(msg.Round)
+                changed = true
+                metrics.Inc("qbft_view_changes_total", nil)
+            }
+            break
+        }
+        // With a validator set the proposer is derived from (height, round);
+        // otherwise fall back to the statically configured Leader (if any).
+        if s.strict() {
+            s.Leader = s.Validators.Proposer(msg.Height, msg.Round)
+        }
         if s.Leader != "" && msg.From != s.Leader {
-            logger.ErrorJ("qbft_state", map[string]any{
-                "op":        "transition",
-                "event_type": string(msg.Type),
-                "height":    s.Height,
-                "round":     s.Round,
-                "reason":    "unauthorized_leader",
-                "from":      msg.From,
-                "expect":    s.Leader,
-                "trace_id":  msg.TraceID,
-            })
-            return fmt.Errorf("unauthorized leader")
-        }
-        s.Phase = "preprepared"
-        s.proposalID = msg.ID
-        s.prepareVotes = make(map[string]struct{})
-        s.commitVotes = make(map[string]struct{})
+            return s.reject(msg, "unauthorized_leader", fmt.Errorf("unauthorized leader"), map[string]any{"from": msg.From, "expect": s.Leader})
+        }
+        if s.strict() {
+            if msg.Round < s.Round {
+                return s.reject(msg, "old_round", fmt.Errorf("proposal for old round"), map[string]any{"got": msg.Round})
+            }
+            if s.proposalID != "" && msg.Round == s.proposalRound {
+                if msg.ID == s.proposalID { goto END } // duplicate proposal is a no-op
+                return s.reject(msg, "conflicting_proposal", fmt.Errorf("conflicting proposal"), map[string]any{"got": msg.ID, "expect": s.proposalID})
+            }
+            if err := justifyProposal(s.Validators, msg.Height, msg.Round, msg.ID, msg.Justification); err != nil {
+                return s.reject(msg, "unjustified", err, map[string]any{"err": err.Error()})
+            }
+            if s.ValidateProposal != nil {
+                if err := s.ValidateProposal(msg); err != nil {
+                    return s.reject(msg, "invalid_proposal", err, map[string]any{"err": err.Error()})
+                }
+            }
+            if msg.Round > s.Round { metrics.Inc("qbft_view_changes_total", nil) }
+        }
+        s.acceptProposal(msg)
         changed = true
     case MsgPrepare:
         // Strict: require preprepare for this proposal first.
         if s.proposalID == "" || (s.Phase != "preprepared" && s.Phase != "prepared") {
-            logger.ErrorJ("qbft_state", map[string]any{
-                "op":        "transition",
-                "event_type": string(msg.Type),
-                "height":    s.Height,
-                "round":     s.Round,
-                "reason":    "not_preprepared",
-                "trace_id":  msg.TraceID,
-            })
-            return fmt.Errorf("prepare before preprepared")
+            return s.reject(msg, "not_preprepared", fmt.Errorf("prepare before preprepared"), nil)
         }
         if msg.ID != s.proposalID {
-            logger.ErrorJ("qbft_state", map[string]any{
-                "op":        "transition",
-                "event_type": string(msg.Type),
-                "height":    s.Height,
-                "round":     s.Round,
-                "reason":    "proposal_mismatch",
-                "got":       msg.ID,
-                "expect":    s.proposalID,
-                "trace_id":  msg.TraceID,
-            })
-            return fmt.Errorf("proposal mismatch")
+            return s.reject(msg, "proposal_mismatch", fmt.Errorf("proposal mismatch"), map[string]any{"got": msg.ID, "expect": s.proposalID})
         }
         if _, ok = s.prepareVotes[msg.From]; ok {
             // Duplicate prepare is a no-op regardless of current phase.
             // no-op
             goto END
         }
-        s.prepareVotes[msg.From] = struct{}{}
-        if s.Phase == "preprepared" && len(s.prepareVotes) >= 2 { // minimal threshold (2)
+        s.prepareVotes[msg.From] = msg
+        if s.Phase == "preprepared" && len(s.prepareVotes) >= s.prepareQuorum() {
             s.Phase = "prepared"
             changed = true
+            // Remember the prepared certificate for later round-changes.
+            s.preparedRound, s.preparedID, s.preparedPayload = s.Round, s.proposalID, s.proposalPayload
+            s.preparedCert = make([]Message, 0, len(s.prepareVotes))
+            for _, p := range s.prepareVotes { s.preparedCert = append(s.preparedCert, p) }
             // Built a prepare QC (new family; single tick on first advance)
             metrics.Inc("qbft_qc_built_total", map[string]string{"kind":"prepare"})
             break
@@ -108,70 +251,43 @@ func (s *State) Process(msg Message) error {
         // Commit is valid for the current proposal after prepared.
         // If already in commit phase for the same proposal, treat duplicates as no-op.
         if s.Phase != "prepared" && s.Phase != "commit" {
-            logger.ErrorJ("qbft_state", map[string]any{
-                "op":        "transition",
-                "event_type": string(msg.Type),
-                "height":    s.Height,
-                "round":     s.Round,
-                "reason":    "not_prepared",
-                "trace_id":  msg.TraceID,
-            })
-            return fmt.Errorf("commit before prepared")
+            return s.reject(msg, "not_prepared", fmt.Errorf("commit before prepared"), nil)
         }
         if msg.ID != s.proposalID {
-            logger.ErrorJ("qbft_state", map[string]any{
-                "op":        "transition",
-                "event_type": string(msg.Type),
-                "height":    s.Height,
-                "round":     s.Round,
-                "reason":    "proposal_mismatch",
-                "got":       msg.ID,
-                "expect":    s.proposalID,
-                "trace_id":  msg.TraceID,
-            })
-            return fmt.Errorf("proposal mismatch")
+            return s.reject(msg, "proposal_mismatch", fmt.Errorf("proposal mismatch"), map[string]any{"got": msg.ID, "expect": s.proposalID})
         }
         if _, ok = s.commitVotes[msg.From]; ok {
             // Duplicate commit (including when phase already is commit) is a no-op.
             // no-op
             goto END
         }
-        s.commitVotes[msg.From] = struct{}{}
-        // Minimal rule: first distinct commit advances to commit phase.
-        if s.Phase != "commit" && len(s.commitVotes) >= 1 {
+        s.commitVotes[msg.From] = msg
+        // A commit quorum of distinct members advances to commit phase.
+        if s.Phase != "commit" && len(s.commitVotes) >= s.commitQuorum() {
             s.Phase = "commit"
             changed = true
-            // Built a commit QC (placeholder 1 threshold for now)
+            // Built a commit QC (single tick on first advance)
             metrics.Inc("qbft_qc_built_total", map[string]string{"kind":"commit"})
         }
     case MsgViewChange:
+        if s.strict() {
+            if msg.Round <= s.Round { goto END } // stale round-change: no-op
+            if err := justifyRoundChange(s.Validators, msg); err != nil {
+                return s.reject(msg, "unjustified", err, map[string]any{"err": err.Error()})
+            }
+        }
         // Aggregate view-change votes for the target round (usually current+1)
-        if s.viewVotes == nil { s.viewVotes = make(map[uint64]map[string]struct{}) }
+        if s.viewVotes == nil { s.viewVotes = make(map[uint64]map[string]Message) }
         bucket := s.viewVotes[msg.Round]
-        if bucket == nil { bucket = map[string]struct{}{}; s.viewVotes[msg.Round] = bucket }
+        if bucket == nil { bucket = map[string]Message{}; s.viewVotes[msg.Round] = bucket }
         if _, ok = bucket[msg.From]; ok {
             goto END
         }
-        bucket[msg.From] = struct{}{}
-        // Minimal threshold 2 to advance the round
-        if len(bucket) >= 2 && msg.Round > s.Round {
-            s.Round = msg.Round
+        bucket[msg.From] = msg
+        // A round-change quorum advances to the target round
+        if len(bucket) >= s.roundChangeQuorum() && msg.Round > s.Round {
             // reset phase and votes on view change
-            s.Phase = ""
-            s.proposalID = ""
-            s.prepareVotes = nil
-            s.commitVotes = nil
-            changed = true
-            metrics.Inc("qbft_view_changes_total", nil)
-        }
-    case MsgNewView:
-        // Placeholder: accept new-view as authoritative round update
-        if msg.Round > s.Round {
-            s.Round = msg.Round
-            s.Phase = ""
-            s.proposalID = ""
-            s.prepareVotes = nil
-            s.commitVotes = nil
+            s.enterRound(msg.Round)
             changed = true
             metrics.Inc("qbft_view_changes_total", nil)
         }
@@ -207,11 +323,107 @@ END:
 
 // OnTimeout records a timeout for the current phase and returns a local view-change
 // message targeting the next round. Callers may broadcast the returned message.
+//
+// Consecutive timeouts at the same height escalate the target round (r+1,
+// r+2, ...) so a node keeps moving forward while its peers catch up. The
+// round-change carries this node's prepared certificate, if any.
 func (s *State) OnTimeout() Message {
     phase := s.Phase
     if phase == "" { phase = "idle" }
     metrics.Inc("qbft_timeouts_total", map[string]string{"phase": phase})
-    return Message{From: "self", Height: s.Height, Round: s.Round + 1, Type: MsgViewChange, ID: "vc"}
+    target := s.Round + 1
+    if s.vcHeight == s.Height && s.vcRound >= target { target = s.vcRound + 1 }
+    s.vcHeight, s.vcRound = s.Height, target
+    from := s.Self
+    if from == "" { from = "self" }
+    vc := Message{From: from, Height: s.Height, Round: target, Type: MsgViewChange, ID: fmt.Sprintf("vc-%s-%d-%d", from, s.Height, target)}
+    if s.preparedID != "" {
+        vc.PreparedRound, vc.PreparedID, vc.Payload = s.preparedRound, s.preparedID, s.preparedPayload
+        vc.Justification = append([]Message(nil), s.preparedCert...)
+    }
+    return vc
+}
+
+// NewView returns a justified NEW_VIEW for the current round when this node
+// (Self) is its proposer, a round-change quorum for the round is known and no
+// proposal has been seen yet. The value is forced to the highest prepared
+// value among the round-changes; otherwise id/payload are proposed, falling
+// back to the last proposal seen at this height. It returns false when any
+// of these conditions does not hold or a NEW_VIEW was already built.
+func (s *State) NewView(id string, payload []byte) (Message, bool) {
+    r := s.Round
+    if !s.strict() || r == 0 || s.Self == "" || s.proposalID != "" || s.newViewRound >= r { return Message{}, false }
+    if s.Validators.Proposer(s.Height, r) != s.Self { return Message{}, false }
+    bucket := s.viewVotes[r]
+    if len(bucket) < s.roundChangeQuorum() { return Message{}, false }
+    rcs := make([]Message, 0, len(bucket))
+    for _, rc := range bucket { rcs = append(rcs, rc) }
+    if hp, ok := highestPrepared(rcs); ok {
+        id, payload = hp.PreparedID, hp.Payload
+    } else if id == "" {
+        id, payload = s.lastValueID, s.lastValuePayload
+    }
+    if id == "" { return Message{}, false }
+    s.newViewRound = r
+    return Message{From: s.Self, Height: s.Height, Round: r, Type: MsgNewView, ID: id, Payload: payload, Justification: rcs}, true
+}
+
+// leftRound reports whether this node already sent a round-change for a later
+// round of the current height. It must not vote in the current round any
+// more: a commit cast after a round-change without a prepared certificate
+// could finalize a value the next proposer is free to replace.
+func (s *State) leftRound() bool { return s.vcHeight == s.Height && s.vcRound > s.Round }
+
+// Propose returns the round-0 proposal for the current height when this node
+// (Self) is its proposer and no proposal was made or seen yet. value is only
+// called then, with the proposal's (height, round), so building the proposed
+// block stays off the hot path. Later
+// rounds are proposed through NewView. The caller processes the returned
+// message locally and broadcasts it.
+func (s *State) Propose(value func(height, round uint64) (id string, payload []byte)) (Message, bool) {
+    if !s.strict() || s.Self == "" || s.Round != 0 || s.proposed || s.proposalID != "" || s.leftRound() { return Message{}, false }
+    if s.Validators.Proposer(s.Height, 0) != s.Self { return Message{}, false }
+    id, payload := value(s.Height, 0)
+    if id == "" { return Message{}, false }
+    s.proposed = true
+    return Message{From: s.Self, Height: s.Height, Round: 0, Type: MsgPreprepare, ID: id, Payload: payload}, true
+}
+
+// Vote returns the next vote this node (Self) owes the current proposal: its
+// prepare once the proposal is accepted, then its commit once prepared. Each
+// is returned at most once per round, and never after the node timed out of
+// the round; false when nothing is owed.
+func (s *State) Vote() (Message, bool) {
+    if !s.strict() || s.Self == "" || s.proposalID == "" || s.leftRound() { return Message{}, false }
+    vote := Message{From: s.Self, Height: s.Height, Round: s.Round, ID: s.proposalID}
+    switch {
+    case !s.sentPrepare && (s.Phase == "preprepared" || s.Phase == "prepared" || s.Phase == "commit"):
+        s.sentPrepare = true
+        vote.Type = MsgPrepare
+    case !s.sentCommit && (s.Phase == "prepared" || s.Phase == "commit"):
+        s.sentCommit = true
+        vote.Type = MsgCommit
+    default:
+        return Message{}, false
+    }
+    return vote, true
+}
+
+// CommitSeal returns the committed value of the current height and the commit
+// messages that finalized it, ordered by sender. ok is false until the
+// instance reached the commit phase.
+func (s *State) CommitSeal() (round uint64, id string, payload []byte, seal []Message, ok bool) {
+    if s.Phase != "commit" { return 0, "", nil, nil, false }
+    seal = make([]Message, 0, len(s.commitVotes))
+    for _, m := range s.commitVotes { seal = append(seal, m) }
+    sort.Slice(seal, func(i, j int) bool { return seal[i].From < seal[j].From })
+    return s.Round, s.proposalID, s.proposalPayload, seal, true
+}
+
+// View returns the current coordinates and phase. Drivers (e.g. round timers)
+// use it to detect progress after processing a message.
+func (s *State) View() (height, round uint64, phase string) {
+    return s.Height, s.Round, s.Phase
 }
 
 // Restore sets the state coordinates to the given height/round. It is used by
@@ -220,4 +432,3 @@ func (s *State) Restore(height, round uint64) {
     s.Height = height
     s.Round = round
 }
-
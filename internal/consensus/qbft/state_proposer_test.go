@@ -0,0 +1,86 @@
+package qbft
+
+import (
+    "errors"
+    "testing"
+)
+
+// With a validator set, the expected leader follows the rotation and a
+// preprepare from any other member takes the unauthorized_leader path.
+func TestState_Proposer_RejectsWrongLeader(t *testing.T) {
+    st := &State{Validators: fourNodes()}
+    if err := st.Process(Message{ID: "b", From: "n0", Type: MsgPreprepare, Height: 1, Round: 0}); err == nil {
+        t.Fatalf("want unauthorized leader for n0 at h=1")
+    }
+    if st.Leader != "n1" { t.Fatalf("leader not derived from rotation: %q", st.Leader) }
+    if st.Phase != "" { t.Fatalf("phase must not change on rejected preprepare, got %q", st.Phase) }
+    if err := st.Process(Message{ID: "b", From: "n1", Type: MsgPreprepare, Height: 1, Round: 0}); err != nil {
+        t.Fatalf("proposer preprepare: %v", err)
+    }
+    if st.Phase != "preprepared" { t.Fatalf("want preprepared, got %q", st.Phase) }
+}
+
+func TestState_Proposer_RotatesWithRound(t *testing.T) {
+    st := &State{Validators: fourNodes()}
+    rcs := roundChanges(1, 1, "n0", "n1", "n3")
+    if err := st.Process(Message{ID: "b", From: "n1", Type: MsgPreprepare, Height: 1, Round: 1, Justification: rcs}); err == nil {
+        t.Fatalf("round-0 proposer must not propose in round 1")
+    }
+    if err := st.Process(Message{ID: "b", From: "n2", Type: MsgPreprepare, Height: 1, Round: 1, Justification: rcs}); err != nil {
+        t.Fatalf("round-1 proposer: %v", err)
+    }
+    if st.Leader != "n2" { t.Fatalf("leader: %q", st.Leader) }
+}
+
+// The local proposer proposes once at round 0; every node then owes exactly
+// one prepare and, once prepared, one commit.
+func TestState_ProposeAndVote(t *testing.T) {
+    st := &State{Validators: fourNodes(), Self: "n1", Height: 1}
+    if _, ok := st.Vote(); ok { t.Fatalf("no vote before a proposal") }
+    pp, ok := st.Propose(value("v"))
+    if !ok || pp.From != "n1" || pp.Type != MsgPreprepare || pp.Height != 1 { t.Fatalf("propose: %+v ok=%v", pp, ok) }
+    if _, ok := st.Propose(value("v")); ok { t.Fatalf("must propose once per height") }
+    if err := st.Process(pp); err != nil { t.Fatalf("own proposal: %v", err) }
+    v, ok := st.Vote()
+    if !ok || v.Type != MsgPrepare || v.ID != "v" || v.From != "n1" { t.Fatalf("prepare: %+v ok=%v", v, ok) }
+    if _, ok := st.Vote(); ok { t.Fatalf("prepare owed once; commit only after prepared") }
+    for _, from := range []string{"n1", "n2", "n3"} {
+        _ = st.Process(Message{ID: "v", From: from, Type: MsgPrepare, Height: 1})
+    }
+    v, ok = st.Vote()
+    if !ok || v.Type != MsgCommit { t.Fatalf("commit: %+v ok=%v", v, ok) }
+    if _, ok := st.Vote(); ok { t.Fatalf("commit owed once") }
+
+    other := &State{Validators: fourNodes(), Self: "n0", Height: 1}
+    if _, ok := other.Propose(value("v")); ok { t.Fatalf("non-proposer must not propose") }
+}
+
+// After its round-change a node stays silent in the old round, even when
+// the proposal or a prepare quorum arrives late.
+func TestState_NoVoteAfterTimeout(t *testing.T) {
+    st := &State{Validators: fourNodes(), Self: "n0", Height: 1}
+    _ = st.OnTimeout()
+    if err := st.Process(Message{ID: "v", From: "n1", Type: MsgPreprepare, Height: 1}); err != nil { t.Fatalf("late proposal: %v", err) }
+    if v, ok := st.Vote(); ok { t.Fatalf("voted after timeout: %+v", v) }
+    lead := &State{Validators: fourNodes(), Self: "n1", Height: 1}
+    _ = lead.OnTimeout()
+    if _, ok := lead.Propose(value("v")); ok { t.Fatalf("proposed after timeout") }
+}
+
+func value(id string) func(uint64, uint64) (string, []byte) {
+    return func(uint64, uint64) (string, []byte) { return id, nil }
+}
+
+// A proposal whose value fails validation is not accepted: no prepare, and
+// the node's prepare/commit counting for it never starts.
+func TestState_ValidateProposal_RejectsBeforeVoting(t *testing.T) {
+    bad := errors.New("bad block")
+    st := &State{Validators: fourNodes(), Self: "n0", Height: 1, ValidateProposal: func(m Message) error {
+        if m.ID == "bad" { return bad }
+        return nil
+    }}
+    if err := st.Process(Message{ID: "bad", From: "n1", Type: MsgPreprepare, Height: 1}); err == nil { t.Fatalf("invalid proposal accepted") }
+    if _, ok := st.Vote(); ok { t.Fatalf("voted for a rejected proposal") }
+    if err := st.Process(Message{ID: "good", From: "n1", Type: MsgPreprepare, Height: 1}); err != nil { t.Fatalf("valid proposal: %v", err) }
+    if v, ok := st.Vote(); !ok || v.ID != "good" { t.Fatalf("prepare: %+v ok=%v", v, ok) }
+}
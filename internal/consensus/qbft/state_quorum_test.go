@@ -0,0 +1,49 @@
+package qbft
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+func fourNodes() *ValidatorSet { return NewValidatorSet([]string{"n0", "n1", "n2", "n3"}, 0) }
+
+// With n=4 the prepare and commit quorums are 3 distinct members.
+func TestState_Quorum_FourNodes_PrepareAndCommit(t *testing.T) {
+    metrics.Reset()
+    st := &State{Validators: fourNodes()}
+    if err := st.Process(Message{ID: "b", From: "n1", Type: MsgPreprepare, Height: 1, Round: 0}); err != nil { t.Fatalf("preprepare: %v", err) }
+    for i, from := range []string{"n0", "n1"} {
+        if err := st.Process(Message{ID: "b", From: from, Type: MsgPrepare, Height: 1, Round: 0}); err != nil { t.Fatalf("prepare %d: %v", i, err) }
+        if st.Phase != "preprepared" { t.Fatalf("advanced below quorum after %d prepares", i+1) }
+    }
+    if err := st.Process(Message{ID: "b", From: "n2", Type: MsgPrepare, Height: 1, Round: 0}); err != nil { t.Fatalf("prepare 3: %v", err) }
+    if st.Phase != "prepared" { t.Fatalf("want prepared at quorum, got %q", st.Phase) }
+
+    for i, from := range []string{"n1", "n3"} {
+        if err := st.Process(Message{ID: "b", From: from, Type: MsgCommit, Height: 1, Round: 0}); err != nil { t.Fatalf("commit %d: %v", i, err) }
+        if st.Phase != "prepared" { t.Fatalf("committed below quorum after %d commits", i+1) }
+    }
+    if err := st.Process(Message{ID: "b", From: "n0", Type: MsgCommit, Height: 1, Round: 0}); err != nil { t.Fatalf("commit 3: %v", err) }
+    if st.Phase != "commit" { t.Fatalf("want commit at quorum, got %q", st.Phase) }
+    if !strings.Contains(metrics.DumpProm(), `qbft_qc_built_total{kind="commit"} 1`) { t.Fatalf("missing commit QC metric") }
+}
+
+func TestState_Quorum_RejectsNonMember(t *testing.T) {
+    st := &State{Validators: fourNodes()}
+    if err := st.Process(Message{ID: "b", From: "n1", Type: MsgPreprepare, Height: 9, Round: 0}); err != nil { t.Fatalf("preprepare: %v", err) }
+    if err := st.Process(Message{ID: "b", From: "intruder", Type: MsgPrepare, Height: 9, Round: 0}); err == nil {
+        t.Fatalf("want error for non-member vote")
+    }
+    if st.Height != 9 { t.Fatalf("non-member message must not touch coordinates, height=%d", st.Height) }
+}
+
+func TestState_Quorum_RoundChangeNeedsQuorum(t *testing.T) {
+    st := &State{Validators: fourNodes()}
+    _ = st.Process(Message{ID: "v0", From: "n0", Type: MsgViewChange, Round: 1})
+    _ = st.Process(Message{ID: "v1", From: "n1", Type: MsgViewChange, Round: 1})
+    if st.Round != 0 { t.Fatalf("round advanced below quorum") }
+    _ = st.Process(Message{ID: "v2", From: "n2", Type: MsgViewChange, Round: 1})
+    if st.Round != 1 { t.Fatalf("want round=1 at quorum, got %d", st.Round) }
+}
@@ -0,0 +1,123 @@
+package qbft
+
+import (
+    "crypto/ed25519"
+    "fmt"
+    "sort"
+
+    "github.com/zmlAEQ/Aequa-network/pkg/config"
+)
+
+// ValidatorSet is the ordered operator membership of a cluster. It sizes the
+// QBFT quorums (prepare, commit, round-change) and answers membership checks.
+// A nil *ValidatorSet is valid and means "unknown membership" (legacy mode).
+type ValidatorSet struct {
+    ids       []string
+    index     map[string]int
+    threshold int
+    // optional ed25519 node keys by id; when any is set, messages must be signed
+    keys      map[string]ed25519.PublicKey
+}
+
+// NewValidatorSet builds a set from operator ids in rotation order. Empty and
+// duplicate ids are skipped. threshold is an optional lower bound for the
+// quorum size (0 keeps the BFT default); it can raise but never lower 2f+1.
+func NewValidatorSet(ids []string, threshold int) *ValidatorSet {
+    vs := &ValidatorSet{index: make(map[string]int, len(ids)), threshold: threshold}
+    for _, id := range ids {
+        if id == "" { continue }
+        if _, dup := vs.index[id]; dup { continue }
+        vs.index[id] = len(vs.ids)
+        vs.ids = append(vs.ids, id)
+    }
+    return vs
+}
+
+// ValidatorSetFromLock builds a set from a cluster lock, ordering operators by
+// their Index and using PeerID as the operator identity. Operators with a
+// PubKey register it as their message signing key; a malformed key is an error.
+func ValidatorSetFromLock(lock config.ClusterLock) (*ValidatorSet, error) {
+    ops := append([]config.Operator(nil), lock.Operators...)
+    sort.SliceStable(ops, func(i, j int) bool { return ops[i].Index < ops[j].Index })
+    ids := make([]string, 0, len(ops))
+    for _, op := range ops { ids = append(ids, op.PeerID) }
+    vs := NewValidatorSet(ids, lock.Threshold)
+    for _, op := range ops {
+        if op.PubKey == "" { continue }
+        pk, err := ParsePubKey(op.PubKey)
+        if err != nil { return nil, fmt.Errorf("operator %d (%s): %w", op.Index, op.PeerID, err) }
+        vs.SetPubKey(op.PeerID, pk)
+    }
+    return vs, nil
+}
+
+// SetPubKey registers the signing key of member id (ignored for non-members).
+func (vs *ValidatorSet) SetPubKey(id string, pk ed25519.PublicKey) {
+    if !vs.Contains(id) { return }
+    if vs.keys == nil { vs.keys = make(map[string]ed25519.PublicKey) }
+    vs.keys[id] = pk
+}
+
+// PubKey returns the registered signing key of id, or nil.
+func (vs *ValidatorSet) PubKey(id string) ed25519.PublicKey {
+    if vs == nil { return nil }
+    return vs.keys[id]
+}
+
+// HasKeys reports whether signing keys are registered, i.e. whether messages
+// must carry valid signatures.
+func (vs *ValidatorSet) HasKeys() bool { return vs != nil && len(vs.keys) > 0 }
+
+// Size returns the number of validators (n).
+func (vs *ValidatorSet) Size() int {
+    if vs == nil { return 0 }
+    return len(vs.ids)
+}
+
+// F returns the number of tolerated byzantine validators, floor((n-1)/3).
+func (vs *ValidatorSet) F() int {
+    n := vs.Size()
+    if n == 0 { return 0 }
+    return (n - 1) / 3
+}
+
+// Quorum returns the QBFT quorum size ceil(2n/3) (2f+1 when n=3f+1), raised to
+// the configured threshold when that is larger, and capped at n.
+func (vs *ValidatorSet) Quorum() int {
+    n := vs.Size()
+    if n == 0 { return 0 }
+    q := (2*n + 2) / 3
+    if vs.threshold > q { q = vs.threshold }
+    if q > n { q = n }
+    return q
+}
+
+// Contains reports whether id is a member of the set.
+func (vs *ValidatorSet) Contains(id string) bool {
+    if vs == nil { return false }
+    _, ok := vs.index[id]
+    return ok
+}
+
+// Index returns the rotation position of id, or -1 if not a member.
+func (vs *ValidatorSet) Index(id string) int {
+    if vs == nil { return -1 }
+    if i, ok := vs.index[id]; ok { return i }
+    return -1
+}
+
+// IDs returns a copy of the member ids in rotation order.
+func (vs *ValidatorSet) IDs() []string {
+    if vs == nil { return nil }
+    return append([]string(nil), vs.ids...)
+}
+
+// Proposer returns the deterministic proposer for (height, round): a
+// round-robin over the rotation order keyed by height+round, so every height
+// starts at a different operator and each round change moves to the next one.
+// It returns "" for an empty set.
+func (vs *ValidatorSet) Proposer(height, round uint64) string {
+    n := vs.Size()
+    if n == 0 { return "" }
+    return vs.ids[(height+round)%uint64(n)]
+}
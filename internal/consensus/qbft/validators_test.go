@@ -0,0 +1,57 @@
+package qbft
+
+import (
+    "testing"
+
+    "github.com/zmlAEQ/Aequa-network/pkg/config"
+)
+
+func TestValidatorSet_QuorumSizes(t *testing.T) {
+    cases := []struct{ n, f, q int }{{1, 0, 1}, {3, 0, 2}, {4, 1, 3}, {5, 1, 4}, {7, 2, 5}, {10, 3, 7}}
+    for _, c := range cases {
+        ids := make([]string, c.n)
+        for i := range ids { ids[i] = string(rune('a' + i)) }
+        vs := NewValidatorSet(ids, 0)
+        if vs.Size() != c.n || vs.F() != c.f || vs.Quorum() != c.q {
+            t.Fatalf("n=%d: got size=%d f=%d q=%d, want f=%d q=%d", c.n, vs.Size(), vs.F(), vs.Quorum(), c.f, c.q)
+        }
+    }
+}
+
+func TestValidatorSet_ThresholdRaisesButNeverLowers(t *testing.T) {
+    ids := []string{"a", "b", "c", "d"}
+    if q := NewValidatorSet(ids, 1).Quorum(); q != 3 { t.Fatalf("threshold below 2f+1 must not lower quorum, got %d", q) }
+    if q := NewValidatorSet(ids, 4).Quorum(); q != 4 { t.Fatalf("threshold above 2f+1 should raise quorum, got %d", q) }
+    if q := NewValidatorSet(ids, 9).Quorum(); q != 4 { t.Fatalf("quorum must be capped at n, got %d", q) }
+}
+
+func TestValidatorSet_FromLock_OrdersByIndexAndDedups(t *testing.T) {
+    lock := config.ClusterLock{Threshold: 3, Operators: []config.Operator{
+        {Index: 2, PeerID: "node2"}, {Index: 0, PeerID: "node0"}, {Index: 1, PeerID: "node1"},
+        {Index: 3, PeerID: "node3"}, {Index: 4, PeerID: "node0"},
+    }}
+    vs, err := ValidatorSetFromLock(lock)
+    if err != nil { t.Fatalf("from lock: %v", err) }
+    if vs.Size() != 4 { t.Fatalf("want 4 members, got %d", vs.Size()) }
+    for i, id := range []string{"node0", "node1", "node2", "node3"} {
+        if vs.Index(id) != i { t.Fatalf("index(%s)=%d want %d", id, vs.Index(id), i) }
+    }
+    if vs.Contains("nodeX") || vs.Index("nodeX") != -1 { t.Fatalf("unexpected membership for nodeX") }
+}
+
+func TestValidatorSet_NilIsEmpty(t *testing.T) {
+    var vs *ValidatorSet
+    if vs.Size() != 0 || vs.Quorum() != 0 || vs.Contains("a") || vs.IDs() != nil {
+        t.Fatalf("nil set must behave as empty")
+    }
+}
+
+func TestValidatorSet_Proposer_RoundRobin(t *testing.T) {
+    vs := NewValidatorSet([]string{"n0", "n1", "n2", "n3"}, 0)
+    if p := vs.Proposer(0, 0); p != "n0" { t.Fatalf("h0r0: %s", p) }
+    if p := vs.Proposer(1, 0); p != "n1" { t.Fatalf("h1r0: %s", p) }
+    if p := vs.Proposer(1, 1); p != "n2" { t.Fatalf("h1r1: %s", p) }
+    if p := vs.Proposer(3, 2); p != "n1" { t.Fatalf("h3r2 should wrap: %s", p) }
+    var empty *ValidatorSet
+    if p := empty.Proposer(1, 1); p != "" { t.Fatalf("empty set proposer: %q", p) }
+}
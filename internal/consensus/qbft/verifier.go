@@ -2,7 +2,6 @@ package qbft
 
 import (
     "fmt"
-    "sync"
 
     "github.com/zmlAEQ/Aequa-network/pkg/logger"
     "github.com/zmlAEQ/Aequa-network/pkg/metrics"
@@ -17,42 +16,27 @@ type Policy struct {
     MinHeight     uint64
     RoundWindow   uint64
     ReplayWindow  uint64
+    // ReplayKeep and ReplayMaxEntries bound the replay cache (0 keeps the
+    // defaults): heights kept below the committed one and remembered keys.
+    ReplayKeep       uint64
+    ReplayMaxEntries int
     TypeMinHeight map[Type]uint64
     TypeRoundMax  map[Type]uint64
     Allowed       []string
+    // Validators, when set, restricts senders to its members and requires
+    // preprepares to come from the proposer of (height, round).
+    Validators    *ValidatorSet
 }
 
 // DefaultPolicy returns a zero-valued policy that keeps current behavior.
 func DefaultPolicy() Policy { return Policy{} }
 
-type AntiReplay struct {
-    mu     sync.Mutex
-    seen   map[string]struct{}
-    hSeen  map[string]uint64
-}
-
-func NewAntiReplay() *AntiReplay { return &AntiReplay{seen: make(map[string]struct{}), hSeen: make(map[string]uint64)} }
-
-// Seen returns true if id already seen; otherwise records and returns false.
-func (r *AntiReplay) Seen(id string) bool {
-    if id == "" { return false }
-    r.mu.Lock()
-    defer r.mu.Unlock()
-    if _, ok := r.seen[id]; ok { return true }
-    r.seen[id] = struct{}{}
-    return false
-}
-
-// SeenWithin returns true if id was seen within the given height window.
-func (r *AntiReplay) SeenWithin(id string, h, window uint64) bool {
-    if id == "" || window == 0 { return false }
-    r.mu.Lock()
-    defer r.mu.Unlock()
-    if last, ok := r.hSeen[id]; ok {
-        if h >= last && h-last <= window { return true }
-    }
-    r.hSeen[id] = h
-    return false
+// replayKey identifies a message for anti-replay. Votes of different
+// operators (or of different kinds/rounds) for the same value share the value
+// id, so the id alone would reject every vote after the first.
+func replayKey(msg Message) string {
+    if msg.ID == "" { return "" }
+    return fmt.Sprintf("%s|%s|%d|%s", msg.Type, msg.From, msg.Round, msg.ID)
 }
 
 type BasicVerifier struct {
@@ -64,6 +48,10 @@ type BasicVerifier struct {
     // type-scoped windows (placeholders; 0 disables)
     typeMinHeight map[Type]uint64
     typeRoundMax  map[Type]uint64
+    // optional validator set for proposer checks (nil disables)
+    validators    *ValidatorSet
+    // optional schedule; when set it replaces validators and the allowlist
+    epochs        *Epochs
 }
 
 func NewBasicVerifier() *BasicVerifier { return &BasicVerifier{replay: NewAntiReplay()} }
@@ -73,9 +61,11 @@ func NewBasicVerifierWithPolicy(p Policy) *BasicVerifier {
     if p.MinHeight > 0 { v.minHeight = p.MinHeight }
     if p.RoundWindow > 0 { v.roundWindow = p.RoundWindow }
     if p.ReplayWindow > 0 { v.replayWindow = p.ReplayWindow }
+    v.replay.SetBounds(p.ReplayKeep, p.ReplayMaxEntries)
     if len(p.TypeMinHeight) > 0 { v.typeMinHeight = p.TypeMinHeight }
     if len(p.TypeRoundMax) > 0 { v.typeRoundMax = p.TypeRoundMax }
     if len(p.Allowed) > 0 { v.SetAllowed(p.Allowed...) }
+    if p.Validators.Size() > 0 { v.SetValidators(p.Validators) }
     return v
 }
 
@@ -87,6 +77,29 @@ func (v *BasicVerifier) SetAllowed(ids ...string) {
 }
 func (v *BasicVerifier) SetReplayWindow(w uint64) { v.replayWindow = w }
 
+// ReplayCache returns the anti-replay cache so the caller can prune it as the
+// committed height advances and checkpoint it across restarts.
+func (v *BasicVerifier) ReplayCache() *AntiReplay { return v.replay }
+
+// SetValidators installs a validator set: its members are added to the sender
+// allowlist and preprepares must come from the rotation proposer.
+func (v *BasicVerifier) SetValidators(vs *ValidatorSet) {
+    v.validators = vs
+    v.SetAllowed(vs.IDs()...)
+}
+
+// SetEpochs checks every message against the validator set in force at its
+// height: membership, proposer rotation and signing keys follow the
+// schedule instead of the static allowlist.
+func (v *BasicVerifier) SetEpochs(ep *Epochs) { v.epochs = ep }
+
+// validatorsAt returns the validator set messages of height h are checked
+// against.
+func (v *BasicVerifier) validatorsAt(h uint64) *ValidatorSet {
+    if v.epochs != nil { return v.epochs.At(h) }
+    return v.validators
+}
+
 // SetTypeMinHeight sets a per-type minimum acceptable height (0 disables for that type).
 func (v *BasicVerifier) SetTypeMinHeight(t Type, h uint64) {
     if v.typeMinHeight == nil { v.typeMinHeight = map[Type]uint64{} }
@@ -99,8 +112,21 @@ func (v *BasicVerifier) SetTypeRoundMax(t Type, max uint64) {
     v.typeRoundMax[t] = max
 }
 
+// verifySigs checks msg and, recursively, its justification against the
+// registered keys. It returns the first offending message on failure.
+func (v *BasicVerifier) verifySigs(vs *ValidatorSet, msg Message) (Message, bool) {
+    if !VerifySig(vs.PubKey(msg.From), msg) { return msg, false }
+    for _, j := range msg.Justification {
+        if bad, ok := v.verifySigs(vs, j); !ok { return bad, false }
+    }
+    return Message{}, true
+}
+
 func validType(t Type) bool {
-    switch t { case MsgPreprepare, MsgPrepare, MsgCommit, MsgViewChange, MsgNewView: return true }
+    switch t {
+    case MsgPreprepare, MsgPrepare, MsgCommit, MsgViewChange, MsgNewView: return true
+    case MsgHSProposal, MsgHSVote, MsgHSNewView: return true
+    }
     return false
 }
 
@@ -112,22 +138,48 @@ func (v *BasicVerifier) Verify(msg Message) error {
         logger.ErrorJ("qbft_verify", map[string]any{"result":"error", "reason":"invalid", "type": string(msg.Type), "trace_id": msg.TraceID})
         return fmt.Errorf("invalid message")
     }
-    // optional sender whitelist
-    if len(v.allowed) > 0 {
-        if _, ok := v.allowed[msg.From]; !ok {
+    vs := v.validatorsAt(msg.Height)
+    // optional sender whitelist (the members of the height's set with a schedule)
+    if v.epochs != nil || len(v.allowed) > 0 {
+        ok := vs.Contains(msg.From)
+        if v.epochs == nil { _, ok = v.allowed[msg.From] }
+        if !ok {
             metrics.Inc("qbft_msg_verified_total", map[string]string{"result":"unauthorized"})
             logger.ErrorJ("qbft_verify", map[string]any{"result":"unauthorized", "from": msg.From, "type": string(msg.Type), "trace_id": msg.TraceID})
             return fmt.Errorf("unauthorized")
         }
     }
-    // signature shape placeholder (no crypto)
-    if l := len(msg.Sig); l > 0 && l < 32 {
+    // proposer rotation: only the elected proposer may send a preprepare/new_view
+    if (msg.Type == MsgPreprepare || msg.Type == MsgNewView) && vs.Size() > 0 {
+        if want := vs.Proposer(msg.Height, msg.Round); msg.From != want {
+            metrics.Inc("qbft_msg_verified_total", map[string]string{"result":"unauthorized"})
+            logger.ErrorJ("qbft_verify", map[string]any{"result":"unauthorized", "reason":"unauthorized_leader", "from": msg.From, "expect": want, "type": string(msg.Type), "height": msg.Height, "round": msg.Round, "trace_id": msg.TraceID})
+            return fmt.Errorf("unauthorized leader")
+        }
+    }
+    // signatures: with registered node keys, the message and every embedded
+    // justification must be signed by its From; otherwise a shape placeholder.
+    if vs.HasKeys() {
+        if bad, ok := v.verifySigs(vs, msg); !ok {
+            metrics.Inc("qbft_msg_verified_total", map[string]string{"result":"sig_invalid"})
+            logger.ErrorJ("qbft_verify", map[string]any{"result":"sig_invalid", "from": bad.From, "signed_type": string(bad.Type), "type": string(msg.Type), "trace_id": msg.TraceID})
+            return fmt.Errorf("sig invalid")
+        }
+    } else if l := len(msg.Sig); l > 0 && l < 32 {
         metrics.Inc("qbft_msg_verified_total", map[string]string{"result":"sig_invalid"})
         logger.ErrorJ("qbft_verify", map[string]any{"result":"sig_invalid", "type": string(msg.Type), "trace_id": msg.TraceID})
         return fmt.Errorf("sig invalid")
     }
-    // context semantic: preprepare must have round == 0 (placeholder constraint)
-    if msg.Type == MsgPreprepare {
+    // context semantic: preprepare must have round == 0 (placeholder constraint).
+    // With a validator set, round>0 proposals are allowed but must carry a
+    // round-change justification (checked in full by the state machine).
+    if vs.Size() > 0 {
+        if (msg.Type == MsgPreprepare && msg.Round > 0 || msg.Type == MsgNewView) && len(msg.Justification) == 0 {
+            metrics.Inc("qbft_msg_verified_total", map[string]string{"result":"error"})
+            logger.ErrorJ("qbft_verify", map[string]any{"result":"error", "reason":"unjustified", "type": string(msg.Type), "round": msg.Round, "trace_id": msg.TraceID})
+            return fmt.Errorf("missing justification for %s at round %d", msg.Type, msg.Round)
+        }
+    } else if msg.Type == MsgPreprepare {
         if msg.Round != 0 {
             metrics.Inc("qbft_msg_verified_total", map[string]string{"result":"error"})
             logger.ErrorJ("qbft_verify", map[string]any{"result":"error", "reason":"round_semantic", "type": string(msg.Type), "round": msg.Round, "trace_id": msg.TraceID})
@@ -148,14 +200,20 @@ func (v *BasicVerifier) Verify(msg Message) error {
     }
     // anti-replay: prefer height-windowed replay if configured; otherwise id-level replay
     if v.replay != nil {
+        // below the pruned floor a replay can no longer be recognized
+        if floor := v.replay.Floor(); msg.Height < floor {
+            metrics.Inc("qbft_msg_verified_total", map[string]string{"result":"old"})
+            logger.ErrorJ("qbft_verify", map[string]any{"result":"old", "reason":"replay_pruned", "height": msg.Height, "min": floor, "type": string(msg.Type), "trace_id": msg.TraceID})
+            return fmt.Errorf("old height")
+        }
         if v.replayWindow > 0 {
-            if v.replay.SeenWithin(msg.ID, msg.Height, v.replayWindow) {
+            if v.replay.SeenWithin(replayKey(msg), msg.Height, v.replayWindow) {
                 metrics.Inc("qbft_msg_verified_total", map[string]string{"result":"replay"})
                 logger.ErrorJ("qbft_verify", map[string]any{"result":"replay", "id": msg.ID, "type": string(msg.Type), "window": v.replayWindow, "trace_id": msg.TraceID})
                 return fmt.Errorf("replay")
             }
         } else {
-            if v.replay.Seen(msg.ID) {
+            if v.replay.SeenAt(replayKey(msg), msg.Height) {
                 metrics.Inc("qbft_msg_verified_total", map[string]string{"result":"replay"})
                 logger.ErrorJ("qbft_verify", map[string]any{"result":"replay", "id": msg.ID, "type": string(msg.Type), "trace_id": msg.TraceID})
                 return fmt.Errorf("replay")
@@ -163,9 +221,9 @@ func (v *BasicVerifier) Verify(msg Message) error {
         }
     }
 
-    // context semantics (placeholder, non-breaking):
+    // context semantics (placeholder, non-breaking; legacy mode without a validator set):
     // - preprepare must have round == 0 (added earlier)
-    if msg.Type == MsgPreprepare {
+    if msg.Type == MsgPreprepare && vs.Size() == 0 {
         if msg.Round != 0 {
             metrics.Inc("qbft_msg_verified_total", map[string]string{"result":"error"})
             logger.ErrorJ("qbft_verify", map[string]any{"result":"error", "reason":"round_semantic", "type": string(msg.Type), "round": msg.Round, "trace_id": msg.TraceID})
@@ -173,7 +231,7 @@ func (v *BasicVerifier) Verify(msg Message) error {
         }
     }
     // - prepare/commit must have round >= 1
-    if msg.Type == MsgPrepare || msg.Type == MsgCommit {
+    if (msg.Type == MsgPrepare || msg.Type == MsgCommit) && vs.Size() == 0 {
         if msg.Round < 1 {
             metrics.Inc("qbft_msg_verified_total", map[string]string{"result":"error"})
             logger.ErrorJ("qbft_verify", map[string]any{"result":"error", "reason":"round_semantic", "type": string(msg.Type), "round": msg.Round, "trace_id": msg.TraceID})
@@ -349,3 +349,59 @@ func TestBasicVerifier_Commit_SenderUnauthorized(t *testing.T) {
         t.Fatalf("want unauthorized=1, got %q", dump)
     }
 }
+
+func TestBasicVerifier_Proposer_UnauthorizedLeader(t *testing.T) {
+    metrics.Reset()
+    v := NewBasicVerifierWithPolicy(Policy{Validators: NewValidatorSet([]string{"n0", "n1", "n2", "n3"}, 0)})
+    if err := v.Verify(Message{ID:"pp-bad", From:"n0", Type:MsgPreprepare, Height:2, Round:0}); err == nil {
+        t.Fatalf("want unauthorized leader")
+    }
+    if err := v.Verify(Message{ID:"pp-ok", From:"n2", Type:MsgPreprepare, Height:2, Round:0}); err != nil {
+        t.Fatalf("proposer must pass: %v", err)
+    }
+    // non-proposer members may still send votes
+    if err := v.Verify(Message{ID:"p-ok", From:"n0", Type:MsgPrepare, Height:2, Round:1}); err != nil {
+        t.Fatalf("member prepare must pass: %v", err)
+    }
+    if err := v.Verify(Message{ID:"p-out", From:"x", Type:MsgPrepare, Height:2, Round:1}); err == nil {
+        t.Fatalf("non-member must be rejected")
+    }
+    dump := metrics.DumpProm()
+    if !strings.Contains(dump, `qbft_msg_verified_total{result="unauthorized"} 2`) {
+        t.Fatalf("want unauthorized=2, got %q", dump)
+    }
+}
+
+func TestBasicVerifier_Validators_RoundProposalNeedsJustification(t *testing.T) {
+    metrics.Reset()
+    v := NewBasicVerifierWithPolicy(Policy{Validators: NewValidatorSet([]string{"n0", "n1", "n2", "n3"}, 0)})
+    if err := v.Verify(Message{ID:"pp-r1", From:"n3", Type:MsgPreprepare, Height:2, Round:1}); err == nil {
+        t.Fatalf("round>0 preprepare without justification must fail")
+    }
+    if err := v.Verify(Message{ID:"nv-r1", From:"n3", Type:MsgNewView, Height:2, Round:1}); err == nil {
+        t.Fatalf("new_view without justification must fail")
+    }
+    rc := Message{ID:"rc", From:"n0", Type:MsgViewChange, Height:2, Round:1}
+    if err := v.Verify(Message{ID:"nv-ok", From:"n3", Type:MsgNewView, Height:2, Round:1, Justification: []Message{rc}}); err != nil {
+        t.Fatalf("justified new_view shape must pass: %v", err)
+    }
+    // round semantics of legacy mode do not apply: round-0 votes are valid
+    if err := v.Verify(Message{ID:"p-r0", From:"n1", Type:MsgPrepare, Height:2, Round:0}); err != nil {
+        t.Fatalf("round-0 prepare must pass with validators: %v", err)
+    }
+}
+
+// Votes of different operators for the same value are not replays of each other.
+func TestBasicVerifier_Replay_KeyedBySenderTypeRound(t *testing.T) {
+    metrics.Reset()
+    v := NewBasicVerifier()
+    for _, m := range []Message{
+        {ID: "val", From: "a", Type: MsgPrepare, Round: 1},
+        {ID: "val", From: "b", Type: MsgPrepare, Round: 1},
+        {ID: "val", From: "a", Type: MsgCommit, Round: 1},
+        {ID: "val", From: "a", Type: MsgPrepare, Round: 2},
+    } {
+        if err := v.Verify(m); err != nil { t.Fatalf("%+v: %v", m, err) }
+    }
+    if err := v.Verify(Message{ID: "val", From: "b", Type: MsgPrepare, Round: 1}); err == nil { t.Fatalf("want replay of identical vote") }
+}
@@ -40,3 +40,15 @@ func TestViewChange_DuplicatesNoDoubleAdvance(t *testing.T) {
     if !strings.Contains(dump, `qbft_view_changes_total`) { t.Fatalf("missing view change counter in %q", dump) }
 }
 
+
+func TestViewChange_OnTimeout_EscalatesTargetRound(t *testing.T) {
+    st := &State{Self: "n2"}
+    _ = st.Process(Message{ID:"blk", From:"L", Type:MsgPreprepare, Height: 31, Round:0})
+    vc1 := st.OnTimeout()
+    vc2 := st.OnTimeout()
+    if vc1.Round != 1 || vc2.Round != 2 { t.Fatalf("want targets 1 then 2, got %d and %d", vc1.Round, vc2.Round) }
+    if vc1.From != "n2" || vc1.ID == vc2.ID { t.Fatalf("timeout votes must carry self and distinct ids: %+v %+v", vc1, vc2) }
+    // a new height restarts escalation from the current round
+    _ = st.Process(Message{ID:"blk2", From:"L", Type:MsgPreprepare, Height: 32, Round:0})
+    if vc := st.OnTimeout(); vc.Round != 1 || vc.Height != 32 { t.Fatalf("want h=32 r=1, got %+v", vc) }
+}
@@ -2,22 +2,72 @@ package qbft
 
 import (
     "bufio"
+    "encoding/binary"
     "encoding/json"
     "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
     "os"
     "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
     "sync"
 
     "github.com/zmlAEQ/Aequa-network/pkg/logger"
     "github.com/zmlAEQ/Aequa-network/pkg/metrics"
 )
 
-// WAL implements a minimal append-only write-ahead log for vote intents
-// (prepare/commit). Each entry is one JSON line. This is a best-effort guard
-// to reconstruct the last intent on restart and prevent double-sign.
+// ErrConflictingVote is returned when a prepare/commit conflicts with a vote
+// already recorded for the same sender, type, height and round.
+var ErrConflictingVote = errors.New("conflicting vote at same coordinates")
+
+// DefaultWALSegmentSize is the size at which the active segment is rotated.
+const DefaultWALSegmentSize int64 = 4 << 20
+
+// WAL is a segmented write-ahead log of vote intents (prepare/commit). It
+// lives in a directory of segments wal-<seq>.seg; each record is framed as
+// [length u32][crc32 u32][JSON entry] and fsynced before AppendIntent
+// returns. On open, a torn or corrupt tail of the last segment is truncated.
+//
+// Besides recovering the last intent, the WAL is a double-sign guard: it
+// refuses to record a vote that conflicts with one already recorded for the
+// same (from, type, height, round). Compact drops segments below the
+// committed height.
+//
+// Older releases kept the WAL as a single JSON-lines file at the same path;
+// such a file is imported into the first segment on open (see Open).
 type WAL struct{
-    mu   sync.Mutex
-    path string
+    mu      sync.Mutex
+    dir     string
+    segSize int64
+    loaded  bool
+
+    segs    []walSegment // ordered by seq; the last one is active
+    f       *os.File     // active segment, opened lazily for append
+    size    int64        // active segment size
+    last    Message
+    hasLast bool
+    votes   map[voteKey]walVote // recorded vote per coordinate
+}
+
+// walVote is a recorded vote id and the segment holding it.
+type walVote struct{
+    id  string
+    seq uint64
+}
+
+type walSegment struct{
+    seq       uint64
+    maxHeight uint64
+}
+
+type voteKey struct{
+    from   string
+    typ    Type
+    height uint64
+    round  uint64
 }
 
 type walEntry struct{
@@ -28,44 +78,265 @@ type walEntry struct{
     From   string `json:"from"`
 }
 
-func NewWAL(path string) *WAL { return &WAL{path: path} }
+const walHeaderSize = 8
 
-// AppendIntent appends a prepare/commit intent as a single JSON line.
-func (w *WAL) AppendIntent(msg Message) error {
+// NewWAL returns a WAL stored in directory path. Nothing is created on disk
+// until the first vote is appended.
+func NewWAL(path string) *WAL { return &WAL{dir: path, segSize: DefaultWALSegmentSize} }
+
+// Open loads the WAL, importing a legacy single-file WAL found at its path.
+// The node calls it at startup and refuses to run when it fails, rather
+// than run without its double-sign guard. Other methods load lazily.
+func (w *WAL) Open() error {
     if w == nil { return nil }
-    if msg.Type != MsgPrepare && msg.Type != MsgCommit { return nil }
     w.mu.Lock(); defer w.mu.Unlock()
-    if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil { return err }
-    f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+    if err := w.load(); err != nil { return fmt.Errorf("wal %s: %w", w.dir, err) }
+    return nil
+}
+
+// SetSegmentSize overrides the segment rotation size (tests, tuning).
+func (w *WAL) SetSegmentSize(n int64) {
+    if n > 0 { w.mu.Lock(); w.segSize = n; w.mu.Unlock() }
+}
+
+func segName(seq uint64) string { return fmt.Sprintf("wal-%08d.seg", seq) }
+
+func (w *WAL) segPath(seq uint64) string { return filepath.Join(w.dir, segName(seq)) }
+
+// load scans existing segments once: it rebuilds the vote index and last
+// intent and truncates a torn tail of the last segment. A failed load is
+// retried from scratch by the next call.
+func (w *WAL) load() error {
+    if w.loaded { return nil }
+    w.segs, w.votes = w.segs[:0], make(map[voteKey]walVote)
+    w.last, w.hasLast, w.size = Message{}, false, 0
+    if err := w.migrateLegacy(); err != nil { return err }
+    ents, err := os.ReadDir(w.dir)
+    if err != nil && !os.IsNotExist(err) { return err }
+    for _, e := range ents {
+        name := e.Name()
+        if !strings.HasPrefix(name, "wal-") || !strings.HasSuffix(name, ".seg") { continue }
+        seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, "wal-"), ".seg"), 10, 64)
+        if err != nil { continue }
+        w.segs = append(w.segs, walSegment{seq: seq})
+    }
+    sort.Slice(w.segs, func(i, j int) bool { return w.segs[i].seq < w.segs[j].seq })
+    for i := range w.segs {
+        valid, err := w.scan(&w.segs[i])
+        if err != nil { return err }
+        if i == len(w.segs)-1 {
+            w.size = valid
+            if err := w.truncateTail(w.segs[i].seq, valid); err != nil { return err }
+        }
+    }
+    w.loaded = true
+    return nil
+}
+
+// scan replays one segment and returns the offset after its last valid record.
+func (w *WAL) scan(seg *walSegment) (int64, error) {
+    f, err := os.Open(w.segPath(seg.seq))
+    if err != nil { return 0, err }
+    defer f.Close()
+    var off int64
+    var hdr [walHeaderSize]byte
+    for {
+        if _, err := io.ReadFull(f, hdr[:]); err != nil { return off, nil }
+        n := binary.BigEndian.Uint32(hdr[0:4])
+        want := binary.BigEndian.Uint32(hdr[4:8])
+        if n > 1<<20 { return off, nil }
+        body := make([]byte, n)
+        if _, err := io.ReadFull(f, body); err != nil { return off, nil }
+        if crc32.ChecksumIEEE(body) != want { return off, nil }
+        var e walEntry
+        if json.Unmarshal(body, &e) != nil { return off, nil }
+        w.apply(seg, e)
+        off += walHeaderSize + int64(n)
+    }
+}
+
+func (w *WAL) apply(seg *walSegment, e walEntry) {
+    w.votes[voteKey{from: e.From, typ: e.Type, height: e.Height, round: e.Round}] = walVote{id: e.ID, seq: seg.seq}
+    w.last = Message{Type: e.Type, Height: e.Height, Round: e.Round, ID: e.ID, From: e.From}
+    w.hasLast = true
+    if e.Height > seg.maxHeight { seg.maxHeight = e.Height }
+}
+
+func (w *WAL) truncateTail(seq uint64, valid int64) error {
+    p := w.segPath(seq)
+    st, err := os.Stat(p)
     if err != nil { return err }
-    enc := walEntry{Type: msg.Type, Height: msg.Height, Round: msg.Round, ID: msg.ID, From: msg.From}
-    b, _ := json.Marshal(enc)
-    if _, err = f.Write(append(b, '\n')); err != nil { _=f.Close(); return err }
-    if err = f.Sync(); err != nil { _=f.Close(); return err }
-    _ = f.Close()
+    if st.Size() == valid { return nil }
+    if err := os.Truncate(p, valid); err != nil { return err }
+    metrics.Inc("qbft_wal_recover_total", map[string]string{"result":"truncated"})
+    logger.ErrorJ("qbft_wal", map[string]any{"op":"recover", "result":"truncated", "segment": segName(seq), "from": st.Size(), "to": valid})
+    return nil
+}
+
+// CheckVote reports ErrConflictingVote when msg is a vote (IsVote) that
+// conflicts with a recorded vote of the same sender at the same coordinates.
+func (w *WAL) CheckVote(msg Message) error {
+    if w == nil || !IsVote(msg.Type) { return nil }
+    w.mu.Lock(); defer w.mu.Unlock()
+    if err := w.load(); err != nil { return err }
+    _, err := w.conflict(msg)
+    return err
+}
+
+// conflict returns (recorded, err): recorded is true when the same vote is
+// already in the log.
+func (w *WAL) conflict(msg Message) (bool, error) {
+    v, ok := w.votes[voteKey{from: msg.From, typ: msg.Type, height: msg.Height, round: msg.Round}]
+    if !ok { return false, nil }
+    if v.id != msg.ID { return false, ErrConflictingVote }
+    return true, nil
+}
+
+// AppendIntent durably records a vote intent (prepare, commit or HotStuff
+// vote). Other message types
+// are ignored. Re-appending the same vote is a no-op; a conflicting vote for
+// the same (from, type, height, round) is refused with ErrConflictingVote.
+func (w *WAL) AppendIntent(msg Message) error {
+    if w == nil { return nil }
+    if !IsVote(msg.Type) { return nil }
+    w.mu.Lock(); defer w.mu.Unlock()
+    if err := w.load(); err != nil { return err }
+    recorded, err := w.conflict(msg)
+    if err != nil {
+        metrics.Inc("qbft_wal_conflicts_total", map[string]string{"type": string(msg.Type)})
+        logger.ErrorJ("qbft_wal", map[string]any{"op":"append", "result":"conflict", "type": string(msg.Type), "from": msg.From, "height": msg.Height, "round": msg.Round, "id": msg.ID})
+        return err
+    }
+    if recorded { return nil }
+    if err := w.openActive(); err != nil { return err }
+    e := walEntry{Type: msg.Type, Height: msg.Height, Round: msg.Round, ID: msg.ID, From: msg.From}
+    rec := frame(e)
+    if _, err := w.f.Write(rec); err != nil { return err }
+    if err := w.f.Sync(); err != nil { return err }
+    w.size += int64(len(rec))
+    w.apply(&w.segs[len(w.segs)-1], e)
     metrics.Inc("qbft_wal_appends_total", nil)
     logger.InfoJ("qbft_wal", map[string]any{"op":"append", "result":"ok", "type": string(msg.Type), "height": msg.Height, "round": msg.Round})
     return nil
 }
 
-// LastIntent returns the last valid entry from the WAL (if any).
+// frame encodes one record: [length u32][crc32 u32][JSON entry].
+func frame(e walEntry) []byte {
+    body, _ := json.Marshal(e)
+    rec := make([]byte, walHeaderSize, walHeaderSize+len(body))
+    binary.BigEndian.PutUint32(rec[0:4], uint32(len(body)))
+    binary.BigEndian.PutUint32(rec[4:8], crc32.ChecksumIEEE(body))
+    return append(rec, body...)
+}
+
+// migrateLegacy imports a legacy WAL, a JSON-lines file at the WAL path,
+// into the first segment of a WAL directory at that path. The segment is
+// written to <path>.migrating and swapped in by rename; the old file stays
+// as <path>.legacy. A swap interrupted between the two renames is finished
+// on the next open.
+func (w *WAL) migrateLegacy() error {
+    tmp, old := w.dir+".migrating", w.dir+".legacy"
+    st, err := os.Stat(w.dir)
+    if os.IsNotExist(err) {
+        if _, err := os.Stat(tmp); err == nil { return os.Rename(tmp, w.dir) }
+        return nil
+    }
+    if err != nil || st.IsDir() { return err }
+    f, err := os.Open(w.dir)
+    if err != nil { return err }
+    var recs []byte
+    n := 0
+    sc := bufio.NewScanner(f)
+    for sc.Scan() {
+        var e walEntry
+        if json.Unmarshal(sc.Bytes(), &e) != nil || !IsVote(e.Type) { continue }
+        recs = append(recs, frame(e)...)
+        n++
+    }
+    _ = f.Close()
+    if err := sc.Err(); err != nil { return fmt.Errorf("read legacy wal: %w", err) }
+    if err := os.RemoveAll(tmp); err != nil { return err }
+    if err := os.MkdirAll(tmp, 0o755); err != nil { return err }
+    seg, err := os.OpenFile(filepath.Join(tmp, segName(1)), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+    if err != nil { return err }
+    _, err = seg.Write(recs)
+    if err == nil { err = seg.Sync() }
+    if cerr := seg.Close(); err == nil { err = cerr }
+    if err != nil { return fmt.Errorf("import legacy wal: %w", err) }
+    if err := os.Rename(w.dir, old); err != nil { return err }
+    if err := os.Rename(tmp, w.dir); err != nil { return err }
+    metrics.Inc("qbft_wal_recover_total", map[string]string{"result":"migrated"})
+    logger.InfoJ("qbft_wal", map[string]any{"op":"migrate", "result":"ok", "entries": n, "legacy": old})
+    return nil
+}
+
+// openActive opens the active segment for append, rotating to a new one
+// when it reached the segment size.
+func (w *WAL) openActive() error {
+    if w.f != nil && w.size < w.segSize { return nil }
+    if w.f != nil {
+        _ = w.f.Close()
+        w.f = nil
+    }
+    if len(w.segs) == 0 || w.size >= w.segSize {
+        var seq uint64 = 1
+        if len(w.segs) > 0 { seq = w.segs[len(w.segs)-1].seq + 1 }
+        w.segs = append(w.segs, walSegment{seq: seq})
+        w.size = 0
+    }
+    if err := os.MkdirAll(w.dir, 0o755); err != nil { return err }
+    f, err := os.OpenFile(w.segPath(w.segs[len(w.segs)-1].seq), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+    if err != nil { return err }
+    w.f = f
+    return nil
+}
+
+// LastIntent returns the most recently recorded intent (if any).
 func (w *WAL) LastIntent() (Message, error) {
     if w == nil { return Message{}, errors.New("nil wal") }
-    f, err := os.Open(w.path)
-    if err != nil { return Message{}, err }
-    defer f.Close()
-    // Scan all lines and keep the last valid one (files are expected to be small)
-    var last Message
-    s := bufio.NewScanner(f)
-    for s.Scan() {
-        var e walEntry
-        if json.Unmarshal(s.Bytes(), &e) == nil {
-            last = Message{Type: e.Type, Height: e.Height, Round: e.Round, ID: e.ID, From: e.From}
+    w.mu.Lock(); defer w.mu.Unlock()
+    if err := w.load(); err != nil { return Message{}, err }
+    if !w.hasLast { return Message{}, errors.New("no entries") }
+    metrics.Inc("qbft_wal_recover_total", map[string]string{"result":"ok"})
+    logger.InfoJ("qbft_wal", map[string]any{"op":"recover", "result":"ok", "type": string(w.last.Type), "height": w.last.Height, "round": w.last.Round})
+    return w.last, nil
+}
+
+// Compact removes segments that only hold votes below height (typically the
+// latest committed height) and forgets their votes. The active segment is
+// always kept, and so are the votes it holds, exactly as a reload would
+// find them.
+func (w *WAL) Compact(height uint64) error {
+    if w == nil { return nil }
+    w.mu.Lock(); defer w.mu.Unlock()
+    if err := w.load(); err != nil { return err }
+    removed := map[uint64]bool{}
+    keep := w.segs[:0]
+    for i, seg := range w.segs {
+        if i < len(w.segs)-1 && seg.maxHeight < height {
+            if err := os.Remove(w.segPath(seg.seq)); err != nil && !os.IsNotExist(err) { return err }
+            removed[seg.seq] = true
+            continue
         }
+        keep = append(keep, seg)
     }
-    if last.Type == "" { return Message{}, errors.New("no entries") }
-    metrics.Inc("qbft_wal_recover_total", map[string]string{"result":"ok"})
-    logger.InfoJ("qbft_wal", map[string]any{"op":"recover", "result":"ok", "type": string(last.Type), "height": last.Height, "round": last.Round})
-    return last, nil
+    w.segs = keep
+    for k, v := range w.votes {
+        if removed[v.seq] { delete(w.votes, k) }
+    }
+    if len(removed) > 0 {
+        metrics.Inc("qbft_wal_compactions_total", nil)
+        logger.InfoJ("qbft_wal", map[string]any{"op":"compact", "result":"ok", "below": height, "segments_removed": len(removed)})
+    }
+    return nil
 }
 
+// Close releases the active segment.
+func (w *WAL) Close() error {
+    if w == nil { return nil }
+    w.mu.Lock(); defer w.mu.Unlock()
+    if w.f == nil { return nil }
+    err := w.f.Close()
+    w.f = nil
+    return err
+}
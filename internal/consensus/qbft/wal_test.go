@@ -1,6 +1,7 @@
 package qbft
 
 import (
+    "errors"
     "os"
     "path/filepath"
     "testing"
@@ -36,3 +37,121 @@ func TestWAL_IgnoreNonVoteTypes(t *testing.T) {
     }
 }
 
+
+func TestWAL_RefusesConflictingVote(t *testing.T) {
+    w := NewWAL(filepath.Join(t.TempDir(), "wal"))
+    vote := Message{ID: "a", From: "n0", Type: MsgPrepare, Height: 3, Round: 1}
+    if err := w.AppendIntent(vote); err != nil { t.Fatalf("append: %v", err) }
+    if err := w.AppendIntent(vote); err != nil { t.Fatalf("same vote again must be a no-op: %v", err) }
+    other := vote
+    other.ID = "b"
+    if err := w.AppendIntent(other); !errors.Is(err, ErrConflictingVote) { t.Fatalf("want conflict, got %v", err) }
+    if err := w.CheckVote(other); !errors.Is(err, ErrConflictingVote) { t.Fatalf("check: want conflict, got %v", err) }
+    // Other type, round or sender is not a conflict.
+    for _, m := range []Message{
+        {ID: "b", From: "n0", Type: MsgCommit, Height: 3, Round: 1},
+        {ID: "b", From: "n0", Type: MsgPrepare, Height: 3, Round: 2},
+        {ID: "b", From: "n1", Type: MsgPrepare, Height: 3, Round: 1},
+    } {
+        if err := w.AppendIntent(m); err != nil { t.Fatalf("append %+v: %v", m, err) }
+    }
+    // The guard survives a restart.
+    _ = w.Close()
+    w2 := NewWAL(w.dir)
+    if err := w2.AppendIntent(other); !errors.Is(err, ErrConflictingVote) { t.Fatalf("after reopen: want conflict, got %v", err) }
+}
+
+func TestWAL_TruncatesTornTail(t *testing.T) {
+    dir := filepath.Join(t.TempDir(), "wal")
+    w := NewWAL(dir)
+    _ = w.AppendIntent(Message{ID: "p1", From: "n1", Type: MsgPrepare, Height: 1})
+    _ = w.AppendIntent(Message{ID: "c1", From: "n1", Type: MsgCommit, Height: 1})
+    _ = w.Close()
+    seg := filepath.Join(dir, segName(1))
+    st, _ := os.Stat(seg)
+    // Simulate a crash mid-write: half a record at the end.
+    f, _ := os.OpenFile(seg, os.O_APPEND|os.O_WRONLY, 0o600)
+    _, _ = f.Write([]byte{0, 0, 0, 40, 1, 2, 3, 4, '{', '"'})
+    _ = f.Close()
+
+    w2 := NewWAL(dir)
+    last, err := w2.LastIntent()
+    if err != nil || last.ID != "c1" { t.Fatalf("last after torn tail: %+v err=%v", last, err) }
+    if st2, _ := os.Stat(seg); st2.Size() != st.Size() { t.Fatalf("tail not truncated: %d != %d", st2.Size(), st.Size()) }
+    if err := w2.AppendIntent(Message{ID: "p2", From: "n1", Type: MsgPrepare, Height: 2}); err != nil { t.Fatalf("append after recovery: %v", err) }
+    if last, _ := NewWAL(dir).LastIntent(); last.ID != "p2" { t.Fatalf("record after truncation lost: %+v", last) }
+}
+
+func TestWAL_RotatesAndCompacts(t *testing.T) {
+    dir := filepath.Join(t.TempDir(), "wal")
+    w := NewWAL(dir)
+    w.SetSegmentSize(64) // roughly one record per segment
+    for h := uint64(1); h <= 5; h++ {
+        if err := w.AppendIntent(Message{ID: "v", From: "n0", Type: MsgCommit, Height: h}); err != nil { t.Fatalf("append %d: %v", h, err) }
+    }
+    segs, _ := filepath.Glob(filepath.Join(dir, "wal-*.seg"))
+    if len(segs) < 3 { t.Fatalf("want rotation, got %d segments", len(segs)) }
+    if err := w.Compact(4); err != nil { t.Fatalf("compact: %v", err) }
+    left, _ := filepath.Glob(filepath.Join(dir, "wal-*.seg"))
+    if len(left) >= len(segs) { t.Fatalf("nothing compacted: %d -> %d", len(segs), len(left)) }
+    w2 := NewWAL(dir)
+    last, err := w2.LastIntent()
+    if err != nil || last.Height != 5 { t.Fatalf("last after compaction: %+v err=%v", last, err) }
+    if err := w2.CheckVote(Message{ID: "x", From: "n0", Type: MsgCommit, Height: 4}); !errors.Is(err, ErrConflictingVote) {
+        t.Fatalf("votes at/above the compaction height must still be guarded: %v", err)
+    }
+}
+
+func TestWAL_ImportsLegacyFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "wal")
+    legacy := `{"type":"prepare","height":3,"round":0,"id":"p3","from":"n1"}
+{"type":"commit","height":3,"round":0,"id":"c3","from":"n1"}
+not json
+`
+    if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil { t.Fatal(err) }
+    w := NewWAL(path)
+    if err := w.Open(); err != nil { t.Fatalf("open legacy: %v", err) }
+    last, err := w.LastIntent()
+    if err != nil || last.ID != "c3" { t.Fatalf("last after import: %+v err=%v", last, err) }
+    if err := w.CheckVote(Message{ID: "x", From: "n1", Type: MsgCommit, Height: 3}); !errors.Is(err, ErrConflictingVote) {
+        t.Fatalf("imported votes must be guarded: %v", err)
+    }
+    if st, err := os.Stat(path); err != nil || !st.IsDir() { t.Fatalf("wal path not a directory after import: %v", err) }
+    if _, err := os.Stat(path + ".legacy"); err != nil { t.Fatalf("legacy file not kept: %v", err) }
+    if err := w.AppendIntent(Message{ID: "p4", From: "n1", Type: MsgPrepare, Height: 4}); err != nil { t.Fatalf("append after import: %v", err) }
+    if last, _ := NewWAL(path).LastIntent(); last.ID != "p4" { t.Fatalf("reopen after import: %+v", last) }
+}
+
+func TestWAL_CompactMatchesReload(t *testing.T) {
+    dir := filepath.Join(t.TempDir(), "wal")
+    w := NewWAL(dir)
+    // Everything lands in the active segment, which compaction keeps.
+    _ = w.AppendIntent(Message{ID: "c1", From: "n0", Type: MsgCommit, Height: 1})
+    _ = w.AppendIntent(Message{ID: "c2", From: "n0", Type: MsgCommit, Height: 2})
+    if err := w.Compact(2); err != nil { t.Fatalf("compact: %v", err) }
+    stale := Message{ID: "x", From: "n0", Type: MsgCommit, Height: 1}
+    before := w.CheckVote(stale)
+    _ = w.Close()
+    after := NewWAL(dir).CheckVote(stale)
+    if !errors.Is(before, ErrConflictingVote) || !errors.Is(after, ErrConflictingVote) {
+        t.Fatalf("guard differs across restart: before=%v after=%v", before, after)
+    }
+}
+
+func TestWAL_RetriesFailedLoad(t *testing.T) {
+    dir := filepath.Join(t.TempDir(), "wal")
+    w := NewWAL(dir)
+    if err := w.AppendIntent(Message{ID: "c1", From: "n0", Type: MsgCommit, Height: 1}); err != nil { t.Fatalf("append: %v", err) }
+    _ = w.Close()
+    // A directory in place of the last segment cannot be truncated, so the
+    // first load fails after listing the segments.
+    bad := filepath.Join(dir, segName(2))
+    if err := os.Mkdir(bad, 0o755); err != nil { t.Fatal(err) }
+    w = NewWAL(dir)
+    if err := w.Open(); err == nil { t.Fatalf("open with a bad segment succeeded") }
+    if err := os.Remove(bad); err != nil { t.Fatal(err) }
+    if err := w.Open(); err != nil { t.Fatalf("retry: %v", err) }
+    if len(w.segs) != 1 { t.Fatalf("segments after retry: %+v", w.segs) }
+    if err := w.AppendIntent(Message{ID: "c2", From: "n0", Type: MsgCommit, Height: 2}); err != nil { t.Fatalf("append after retry: %v", err) }
+    if last, _ := NewWAL(dir).LastIntent(); last.ID != "c2" { t.Fatalf("reopen after retry: %+v", last) }
+}
@@ -0,0 +1,184 @@
+package consensus
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"sync"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	reconfig "github.com/zmlAEQ/Aequa-network/internal/payload/reconfig_v1"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/pkg/logger"
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// epochSetter is implemented by processors and verifiers that look the
+// validator set up by height (qbft.Manager and qbft.BasicVerifier do).
+type epochSetter interface {
+	SetEpochs(ep *qbft.Epochs)
+}
+
+// reconfigTracker tallies the reconfig_v1 endorsements of committed blocks
+// and schedules a change once a quorum of the set in force endorsed it
+// within one epoch. The change takes effect at the start of the epoch after
+// the next, so every node has scheduled it before any message of its first
+// height is verified. Blocks are applied strictly in height order, from the
+// loop on commit and from the sync goroutine, hence the mutex.
+type reconfigTracker struct {
+	mu    sync.Mutex
+	ep    *qbft.Epochs
+	next  uint64                         // first height not applied yet
+	epoch uint64                         // epoch the tally belongs to
+	votes map[string]map[string]struct{} // change key -> endorsers
+	done  map[string]bool                // changes scheduled this epoch
+}
+
+// SetEpochs enables validator set reconfiguration: the verifier, the
+// processor and block sync check each height against the set ep has in
+// force, committed reconfig_v1 endorsements schedule changes on ep, and the
+// builder includes endorsements ahead of other payloads.
+func (s *Service) SetEpochs(ep *qbft.Epochs) {
+	if ep == nil {
+		s.reconf = nil
+		return
+	}
+	s.reconf = &reconfigTracker{ep: ep, votes: map[string]map[string]struct{}{}, done: map[string]bool{}}
+}
+
+// startEpochs hands the schedule to the verifier and the processor and
+// rebuilds it from the stored blocks, so a restarted node agrees with its
+// peers on the set of every height it is about to run.
+func (s *Service) startEpochs(ctx context.Context) {
+	if s.reconf == nil {
+		return
+	}
+	if es, ok := s.v.(epochSetter); ok {
+		es.SetEpochs(s.reconf.ep)
+	}
+	if es, ok := s.st.(epochSetter); ok {
+		es.SetEpochs(s.reconf.ep)
+	}
+	next := uint64(0)
+	if last, err := s.blocks.LastBlock(ctx); err == nil {
+		s.applyReconfig(ctx, last.Height)
+		next = last.Height + 1
+	}
+	s.reconf.ep.Activate(next)
+}
+
+// applyReconfig applies the stored blocks up to height h that were not
+// applied yet. A height missing from the store (history starting later) is
+// skipped.
+func (s *Service) applyReconfig(ctx context.Context, h uint64) {
+	t := s.reconf
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ; t.next <= h; t.next++ {
+		rec, err := s.blocks.BlockByHeight(ctx, t.next)
+		if errors.Is(err, state.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			logger.ErrorJ("consensus_reconfig", map[string]any{"op": "load", "result": "error", "height": t.next, "err": err.Error()})
+			return
+		}
+		blk, err := wire.DecodeBlock(rec.Block)
+		if err != nil {
+			logger.ErrorJ("consensus_reconfig", map[string]any{"op": "decode", "result": "error", "height": t.next, "err": err.Error()})
+			continue
+		}
+		t.apply(t.next, blk)
+	}
+}
+
+// apply tallies the endorsements of the block committed at h. Callers hold
+// t.mu.
+func (t *reconfigTracker) apply(h uint64, blk pl.StandardBlock) {
+	if e := t.ep.Epoch(h); e != t.epoch {
+		t.epoch = e
+		t.votes = map[string]map[string]struct{}{}
+		t.done = map[string]bool{}
+	}
+	vs := t.ep.At(h)
+	for _, it := range blk.Items {
+		tx, ok := it.(*reconfig.ReconfigTx)
+		if !ok {
+			continue
+		}
+		if result := t.check(vs, tx); result != "" {
+			metrics.Inc("consensus_reconfig_total", map[string]string{"result": result})
+			logger.InfoJ("consensus_reconfig", map[string]any{"op": "endorse", "result": result, "height": h, "from": tx.From})
+			continue
+		}
+		key := tx.ChangeKey()
+		if t.done[key] {
+			continue
+		}
+		if t.votes[key] == nil {
+			t.votes[key] = map[string]struct{}{}
+		}
+		t.votes[key][tx.From] = struct{}{}
+		metrics.Inc("consensus_reconfig_total", map[string]string{"result": "endorsed"})
+		if len(t.votes[key]) < vs.Quorum() {
+			continue
+		}
+		t.done[key] = true
+		from := (t.epoch + 2) * t.ep.Length()
+		c := qbft.Change{Op: tx.Op, ID: tx.Operator, PubKey: ed25519.PublicKey(tx.PubKey), Threshold: tx.Threshold}
+		next, err := t.ep.Schedule(from, c)
+		if err != nil {
+			metrics.Inc("consensus_reconfig_total", map[string]string{"result": "rejected"})
+			logger.ErrorJ("consensus_reconfig", map[string]any{"op": "schedule", "result": "rejected", "height": h, "change": tx.Op, "operator": tx.Operator, "err": err.Error()})
+			continue
+		}
+		metrics.Inc("consensus_reconfig_total", map[string]string{"result": "scheduled"})
+		logger.InfoJ("consensus_reconfig", map[string]any{"op": "schedule", "result": "ok", "height": h, "from": from, "change": tx.Op, "operator": tx.Operator, "size": next.Size(), "quorum": next.Quorum()})
+	}
+}
+
+// check returns why an endorsement committed under vs does not count, or ""
+// when it does.
+func (t *reconfigTracker) check(vs *qbft.ValidatorSet, tx *reconfig.ReconfigTx) string {
+	switch {
+	case tx.Validate() != nil:
+		return "invalid"
+	case tx.Epoch != t.epoch:
+		return "stale"
+	case !vs.Contains(tx.From):
+		return "unauthorized"
+	case vs.HasKeys() && (len(vs.PubKey(tx.From)) != ed25519.PublicKeySize || !ed25519.Verify(vs.PubKey(tx.From), tx.SigningBytes(), tx.Sig)):
+		return "bad_sig"
+	}
+	return ""
+}
+
+// activateEpochs records that the node runs height h from now on.
+func (s *Service) activateEpochs(h uint64) {
+	if s.reconf != nil {
+		s.reconf.ep.Activate(h)
+	}
+}
+
+// syncValidators returns the set a synced block of height h is checked
+// against.
+func (s *Service) syncValidators(h uint64) *qbft.ValidatorSet {
+	if s.reconf != nil {
+		return s.reconf.ep.At(h)
+	}
+	return s.syncer.vs
+}
+
+func containsType(order []string, typ string) bool {
+	for _, t := range order {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package consensus
+
+import (
+	"context"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/pkg/logger"
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// replayCacher is implemented by verifiers whose anti-replay cache can be
+// pruned and checkpointed (qbft.BasicVerifier does).
+type replayCacher interface {
+	ReplayCache() *qbft.AntiReplay
+}
+
+func (s *Service) replayCache() *qbft.AntiReplay {
+	rc, ok := s.v.(replayCacher)
+	if !ok {
+		return nil
+	}
+	return rc.ReplayCache()
+}
+
+// restoreReplay reloads the anti-replay checkpoint saved before a restart, so
+// messages verified by the previous run are still rejected as replays.
+func (s *Service) restoreReplay(ctx context.Context) {
+	cache, rs := s.replayCache(), replayStore(s.store)
+	if cache == nil || rs == nil {
+		return
+	}
+	cp, err := rs.LoadReplay(ctx)
+	if err != nil {
+		logger.InfoJ("consensus_replay", map[string]any{"op": "restore", "result": "miss"})
+		return
+	}
+	snap := qbft.ReplaySnapshot{Floor: cp.Floor, Entries: make(map[string]uint64, len(cp.Entries))}
+	for _, e := range cp.Entries {
+		snap.Entries[e.Key] = e.Height
+	}
+	cache.Restore(snap)
+	logger.InfoJ("consensus_replay", map[string]any{"op": "restore", "result": "ok", "floor": cp.Floor, "entries": len(cp.Entries)})
+}
+
+// checkpointReplay prunes the anti-replay cache once height h is committed
+// and saves what is left next to the last state.
+func (s *Service) checkpointReplay(ctx context.Context, h uint64) {
+	cache := s.replayCache()
+	if cache == nil {
+		return
+	}
+	cache.Advance(h)
+	rs := replayStore(s.store)
+	if rs == nil {
+		return
+	}
+	snap := cache.Snapshot()
+	cp := state.ReplayCheckpoint{Floor: snap.Floor, Entries: make([]state.ReplayEntry, 0, len(snap.Entries))}
+	for k, eh := range snap.Entries {
+		cp.Entries = append(cp.Entries, state.ReplayEntry{Key: k, Height: eh})
+	}
+	if err := rs.SaveReplay(ctx, cp); err != nil {
+		metrics.Inc("consensus_replay_checkpoint_total", map[string]string{"result": "error"})
+		logger.ErrorJ("consensus_replay", map[string]any{"op": "checkpoint", "result": "error", "height": h, "err": err.Error()})
+		return
+	}
+	metrics.Inc("consensus_replay_checkpoint_total", map[string]string{"result": "ok"})
+}
+
+func replayStore(st state.Store) state.ReplayStore {
+	rs, _ := st.(state.ReplayStore)
+	return rs
+}
@@ -0,0 +1,80 @@
+package consensus
+
+import (
+	"time"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+)
+
+const (
+	defaultRoundTimeout    = 2 * time.Second
+	defaultMaxRoundTimeout = 60 * time.Second
+)
+
+// roundDriver is implemented by processors that support round-change timeouts
+// (qbft.State does). Processors without it run with timers disabled.
+type roundDriver interface {
+	qbft.Processor
+	OnTimeout() qbft.Message
+	View() (height, round uint64, phase string)
+}
+
+// roundTimer is the per-round timeout of the active consensus instance.
+// The timeout for round r is base*2^r, capped at max.
+type roundTimer struct {
+	base  time.Duration
+	max   time.Duration
+	clock Clock
+	t     Timer
+}
+
+func newRoundTimer(base, max time.Duration) *roundTimer {
+	return newRoundTimerWithClock(base, max, realClock{})
+}
+
+func newRoundTimerWithClock(base, max time.Duration, clock Clock) *roundTimer {
+	if base <= 0 {
+		base = defaultRoundTimeout
+	}
+	if max <= 0 {
+		max = defaultMaxRoundTimeout
+	}
+	if max < base {
+		max = base
+	}
+	return &roundTimer{base: base, max: max, clock: clock}
+}
+
+// timeout returns the backoff duration for the given round.
+func (rt *roundTimer) timeout(round uint64) time.Duration {
+	d := rt.base
+	for i := uint64(0); i < round; i++ {
+		if d >= rt.max/2 {
+			return rt.max
+		}
+		d *= 2
+	}
+	return d
+}
+
+// arm (re)starts the timer for the given round.
+func (rt *roundTimer) arm(round uint64) {
+	rt.stop()
+	rt.t = rt.clock.NewTimer(rt.timeout(round))
+}
+
+// stop disarms the timer; a stopped timer never fires.
+func (rt *roundTimer) stop() {
+	if rt != nil && rt.t != nil {
+		rt.t.Stop()
+		rt.t = nil
+	}
+}
+
+// C returns the fire channel, or nil when disarmed (blocks forever in select).
+func (rt *roundTimer) C() <-chan time.Time {
+	if rt == nil || rt.t == nil {
+		return nil
+	}
+	return rt.t.C()
+}
@@ -4,13 +4,14 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"os"
+	"strconv"
 	"time"
 
 	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
 	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
-	auction_v1 "github.com/zmlAEQ/Aequa-network/internal/payload/auction_bid_v1"
-	plaintext_v1 "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+	reconfig "github.com/zmlAEQ/Aequa-network/internal/payload/reconfig_v1"
 	"github.com/zmlAEQ/Aequa-network/internal/state"
 	"github.com/zmlAEQ/Aequa-network/pkg/bus"
 	"github.com/zmlAEQ/Aequa-network/pkg/lifecycle"
@@ -24,10 +25,16 @@ type QbftBroadcaster interface {
 	BroadcastQBFT(ctx context.Context, msg qbft.Message) error
 }
 
+// TxBroadcaster gossips a payload on the tx topic (e.g., libp2p transport).
+type TxBroadcaster interface {
+	BroadcastTx(ctx context.Context, tx pl.Payload) error
+}
+
 type Service struct {
 	sub           bus.Subscriber
 	v             qbft.Verifier
 	store         state.Store
+	blocks        state.BlockStore
 	st            qbft.Processor
 	wal           *qbft.WAL
 	lastWAL       qbft.Message
@@ -41,7 +48,30 @@ type Service struct {
 	enableTSSSign bool
 	signer        TSSSigner
 	bc            QbftBroadcaster
+	txb           TxBroadcaster
+	journal       *mempoolJournal
 	sink          FeeSink
+	roundTimeout  time.Duration
+	maxTimeout    time.Duration
+	msgSigner     qbft.Signer
+	evidence      *qbft.EvidencePool
+	penalizer     PeerPenalizer
+	penalty       int64
+	syncer        *blockSync
+	clock         Clock
+	manual        bool
+	voting        bool
+	rd            roundDriver
+	rt            *roundTimer
+	txSub         bus.Subscriber
+	pipe          *pipeline
+	pipeWorkers   int
+	pipeQueue     int
+	reconf        *reconfigTracker
+	exec          *executor
+	genesis       map[string]uint64
+	feeRecipient  string
+	chainID       string
 }
 
 func New() *Service                          { return &Service{} }
@@ -90,6 +120,52 @@ func (s *Service) SetTSSSigner(si TSSSigner) { s.signer = si }
 // (prepare/commit) to the network. When nil, broadcasting is disabled.
 func (s *Service) SetBroadcaster(b QbftBroadcaster) { s.bc = b }
 
+// SetTxBroadcaster injects an optional broadcaster that gossips txs which
+// replaced a pooled one (replace-by-fee).
+func (s *Service) SetTxBroadcaster(b TxBroadcaster) { s.txb = b }
+
+// SetRoundTimeout configures the round-change timer: round r times out after
+// base*2^r, capped at max. Zero values keep the defaults (2s base, 60s cap).
+func (s *Service) SetRoundTimeout(base, max time.Duration) {
+	s.roundTimeout = base
+	s.maxTimeout = max
+}
+
+// SetMessageSigner injects the operator key used to sign locally generated
+// QBFT messages (round-changes, NEW_VIEW). When nil, messages go out unsigned.
+func (s *Service) SetMessageSigner(si qbft.Signer) { s.msgSigner = si }
+
+// signLocal signs a locally generated message when a signer is configured.
+func (s *Service) signLocal(msg qbft.Message) qbft.Message {
+	if s.msgSigner == nil {
+		return msg
+	}
+	return s.msgSigner.Sign(msg)
+}
+
+// isSelf reports whether from is this node's message signer id.
+func (s *Service) isSelf(from string) bool {
+	return s.msgSigner != nil && from != "" && from == s.msgSigner.ID()
+}
+
+// signVote records a local prepare/commit in the WAL before signing it and
+// refuses to sign one that conflicts with a vote already cast at the same
+// (height, round); the WAL append is durable before the signature exists.
+func (s *Service) signVote(msg qbft.Message) (qbft.Message, error) {
+	if s.msgSigner != nil {
+		msg.From = s.msgSigner.ID()
+	}
+	if s.wal != nil {
+		if err := s.wal.AppendIntent(msg); err != nil {
+			metrics.Inc("consensus_vote_sign_total", map[string]string{"type": string(msg.Type), "result": "refused"})
+			logger.ErrorJ("qbft_wal_guard", map[string]any{"result": "refuse_sign", "type": string(msg.Type), "height": msg.Height, "round": msg.Round, "id": msg.ID, "err": err.Error()})
+			return qbft.Message{}, err
+		}
+	}
+	metrics.Inc("consensus_vote_sign_total", map[string]string{"type": string(msg.Type), "result": "ok"})
+	return s.signLocal(msg), nil
+}
+
 // SetFeeSink injects a non-blocking sink to export block value accounting.
 func (s *Service) SetFeeSink(fs FeeSink) { s.sink = fs }
 
@@ -104,6 +180,9 @@ func (s *Service) Start(ctx context.Context) error {
 	if s.store == nil {
 		s.store = state.NewMemoryStore()
 	}
+	if s.blocks == nil {
+		s.blocks = state.NewMemoryBlockStore()
+	}
 	if s.st == nil {
 		s.st = &qbft.State{}
 	}
@@ -114,6 +193,11 @@ func (s *Service) Start(ctx context.Context) error {
 	s.enableTSSSync = s.enableTSSSync || os.Getenv("AEQUA_ENABLE_TSS_STATE_SYNC") == "1"
 	s.enableBuilder = s.enableBuilder || os.Getenv("AEQUA_ENABLE_BUILDER") == "1"
 	s.enableTSSSign = s.enableTSSSign || os.Getenv("AEQUA_ENABLE_TSS_SIGN") == "1"
+	if s.roundTimeout == 0 {
+		if ms, err := strconv.Atoi(os.Getenv("AEQUA_QBFT_ROUND_TIMEOUT_MS")); err == nil && ms > 0 {
+			s.roundTimeout = time.Duration(ms) * time.Millisecond
+		}
+	}
 	if s.lastBlock == nil {
 		s.lastBlock = make(map[uint64]map[uint64]pl.StandardBlock)
 	}
@@ -121,21 +205,27 @@ func (s *Service) Start(ctx context.Context) error {
 	// so plaintext_v1 can be deterministically selected in small steps.
 	if s.enableBuilder {
 		if !s.polConfigured {
-			order := []string{"auction_bid_v1", "plaintext_v1"}
+			order := []string{"bundle_v1", "auction_bid_v1", "plaintext_v1"}
 			if os.Getenv("AEQUA_ENABLE_BEAST") == "1" {
 				order = append([]string{"private_v1"}, order...)
 			}
+			if s.reconf != nil {
+				order = append([]string{reconfig.Type}, order...)
+			}
 			s.policy = pl.BuilderPolicy{Order: order, MaxN: 1024}
 			metrics.Inc("builder_policy_total", map[string]string{"result": "default"})
 			logger.InfoJ("consensus_builder_policy", map[string]any{"result": "default", "order": s.policy.Order, "max_n": s.policy.MaxN, "use_dfba": s.policy.UseDFBA})
 		} else {
 			if len(s.policy.Order) == 0 {
-				order := []string{"auction_bid_v1", "plaintext_v1"}
+				order := []string{"bundle_v1", "auction_bid_v1", "plaintext_v1"}
 				if os.Getenv("AEQUA_ENABLE_BEAST") == "1" {
 					order = append([]string{"private_v1"}, order...)
 				}
 				s.policy.Order = order
 			}
+			if s.reconf != nil && !containsType(s.policy.Order, reconfig.Type) {
+				s.policy.Order = append([]string{reconfig.Type}, s.policy.Order...)
+			}
 			if s.policy.MaxN <= 0 {
 				s.policy.MaxN = 1024
 			}
@@ -154,125 +244,386 @@ func (s *Service) Start(ctx context.Context) error {
 			logger.InfoJ("qbft_wal_guard", map[string]any{"result": "miss"})
 		}
 	}
+	s.restoreHeight(ctx)
+	s.restoreExec(ctx)
+	s.startEpochs(ctx)
+	s.restoreReplay(ctx)
+	s.restoreMempool(ctx)
 	if ls, err := s.store.LoadLastState(ctx); err != nil {
 		logger.InfoJ("consensus_state", map[string]any{"op": "load", "result": "miss", "err": err.Error(), "trace_id": ""})
 	} else {
 		logger.InfoJ("consensus_state", map[string]any{"op": "load", "result": "ok", "height": ls.Height, "round": ls.Round, "trace_id": ""})
 	}
+	// Round-change timer: armed while an instance is in progress, reset on
+	// progress (new height/round/phase) and disarmed once committed.
+	// Voting nodes agree on blocks: proposals must carry a valid one.
+	if pv, ok := s.st.(proposalValidatorSetter); ok && s.voting {
+		pv.SetProposalValidator(s.checkProposal)
+	}
+	s.rd, _ = s.st.(roundDriver)
+	if s.rd != nil {
+		clock := s.clock
+		if clock == nil {
+			clock = realClock{}
+		}
+		s.rt = newRoundTimerWithClock(s.roundTimeout, s.maxTimeout, clock)
+	}
+	// Catch up on heights committed while this node was down.
+	s.maybeSync(ctx, "startup")
+	if s.manual {
+		s.kickoff(ctx)
+		return nil
+	}
+	s.startPipeline(ctx)
 	go func() {
-		ticker := time.NewTicker(5 * time.Second)
-		defer ticker.Stop()
+		defer s.rt.stop()
+		s.kickoff(ctx)
 		for {
 			select {
-			case ev := <-s.sub:
-				// Handle transaction gossip (if any) before consensus mapping.
-				if ev.Kind == bus.KindTx {
-					if s.pool != nil {
-						if plAny, ok := ev.Body.(pl.Payload); ok && plAny != nil {
-							_ = s.pool.Add(plAny)
-						}
-					}
-					// Proceed to next event; tx does not map to qbft.
-					continue
-				}
-				// Count the event as received
-				metrics.Inc("consensus_events_total", map[string]string{"kind": string(ev.Kind)})
-
-				// Measure full processing time: verify -> state -> persist
-				begin := time.Now()
-				// Map event to qbft message via adapter
-				msg := MapEventToQBFT(ev)
-				if err := s.v.Verify(msg); err == nil {
-					// Persist vote intent to WAL before processing (best-effort)
-					if s.wal != nil && (msg.Type == qbft.MsgPrepare || msg.Type == qbft.MsgCommit) {
-						_ = s.wal.AppendIntent(msg)
-					}
-					// Optional: broadcast local votes (prepare/commit) via injected broadcaster.
-					if s.bc != nil && (msg.Type == qbft.MsgPrepare || msg.Type == qbft.MsgCommit) {
-						if err := s.bc.BroadcastQBFT(ctx, msg); err != nil {
-							metrics.Inc("consensus_broadcast_total", map[string]string{"type": string(msg.Type), "result": "error"})
-							logger.ErrorJ("consensus_broadcast", map[string]any{"result": "error", "type": string(msg.Type), "height": msg.Height, "round": msg.Round, "trace_id": ev.TraceID, "err": err.Error()})
-						} else {
-							metrics.Inc("consensus_broadcast_total", map[string]string{"type": string(msg.Type), "result": "ok"})
-							logger.InfoJ("consensus_broadcast", map[string]any{"result": "ok", "type": string(msg.Type), "height": msg.Height, "round": msg.Round, "trace_id": ev.TraceID})
-						}
-					}
-					// Behind-flag builder: prepare deterministic block for this coordinate
-					if s.enableBuilder && s.pool != nil {
-						hdr := pl.BlockHeader{Height: msg.Height, Round: msg.Round}
-						blk := pl.PrepareProposal(s.pool, hdr, s.policy)
-						if err := pl.ProcessProposal(blk, s.policy); err == nil {
-							blk.Stats = summarizeStats(blk.Items)
-							if s.lastBlock[msg.Height] == nil {
-								s.lastBlock[msg.Height] = make(map[uint64]pl.StandardBlock)
-							}
-							s.lastBlock[msg.Height][msg.Round] = blk
-							logger.InfoJ("consensus_builder", map[string]any{
-								"result": "ok", "height": msg.Height, "round": msg.Round,
-								"items": len(blk.Items), "bids": blk.Stats.TotalBids, "fees": blk.Stats.TotalFees,
-							})
-						} else {
-							logger.ErrorJ("consensus_builder", map[string]any{"result": "reject", "err": err.Error(), "height": msg.Height, "round": msg.Round})
-						}
-					}
-					// Guard against processing intents older than last WAL entry (best-effort)
-					allowed := true
-					if (msg.Type == qbft.MsgPrepare || msg.Type == qbft.MsgCommit) && s.lastWAL.Type != "" {
-						if msg.Height < s.lastWAL.Height || (msg.Height == s.lastWAL.Height && msg.Round < s.lastWAL.Round) {
-							allowed = false
-							metrics.Inc("qbft_wal_guard_drops_total", nil)
-							logger.InfoJ("qbft_wal_guard", map[string]any{"result": "drop", "height": msg.Height, "round": msg.Round, "last_h": s.lastWAL.Height, "last_r": s.lastWAL.Round})
-						}
-					}
-					if allowed {
-						_ = s.st.Process(msg)
-						if row, ok := s.lastBlock[msg.Height]; ok {
-							if blk, ok2 := row[msg.Round]; ok2 {
-								// Emit block value accounting metrics/logs on commit path.
-								metrics.ObserveSummary("block_value_bids", nil, float64(blk.Stats.TotalBids))
-								metrics.ObserveSummary("block_value_fees", nil, float64(blk.Stats.TotalFees))
-								logger.InfoJ("consensus_block_value", map[string]any{
-									"height": msg.Height, "round": msg.Round,
-									"bids": blk.Stats.TotalBids, "fees": blk.Stats.TotalFees, "items": len(blk.Items),
-								})
-								// Non-blocking fee sink publish (best-effort).
-								s.sink.Publish(ValueRecord{
-									Height: msg.Height, Round: msg.Round,
-									Bids: blk.Stats.TotalBids, Fees: blk.Stats.TotalFees, Items: len(blk.Items),
-								})
-								if s.enableTSSSign && s.signer != nil && msg.Type == qbft.MsgCommit {
-									b, _ := json.Marshal(blk)
-									sum := sha256.Sum256(b)
-									if _, err := s.signer.Sign(ctx, msg.Height, msg.Round, sum[:]); err != nil {
-										metrics.Inc("block_sign_total", map[string]string{"result": "error"})
-										logger.ErrorJ("consensus_block", map[string]any{"op": "sign", "result": "error", "err": err.Error(), "height": msg.Height, "round": msg.Round})
-									} else {
-										metrics.Inc("block_sign_total", map[string]string{"result": "ok"})
-										logger.InfoJ("consensus_block", map[string]any{"op": "sign", "result": "ok", "height": msg.Height, "round": msg.Round})
-									}
-								}
-							}
-						}
-						if err2 := s.store.SaveLastState(ctx, state.LastState{Height: msg.Height, Round: msg.Round}); err2 != nil {
-							logger.ErrorJ("consensus_state", map[string]any{"op": "save", "result": "error", "err": err2.Error(), "trace_id": ev.TraceID})
-						} else {
-							logger.InfoJ("consensus_state", map[string]any{"op": "save", "result": "ok", "height": msg.Height, "round": msg.Round, "trace_id": ev.TraceID})
-						}
-					}
-				}
-				durMs := time.Since(begin).Milliseconds()
-				// Audit log and summary with the full processing latency; labels unchanged
-				logger.InfoJ("consensus_recv", map[string]any{"kind": string(ev.Kind), "trace_id": ev.TraceID, "result": "recv", "latency_ms": durMs})
-				metrics.ObserveSummary("consensus_proc_ms", map[string]string{"kind": string(ev.Kind)}, float64(durMs))
+			case <-s.rt.C():
+				s.onRoundTimeout(ctx, s.rd, s.rt)
+			case last := <-s.syncDone():
+				s.applySynced(ctx, s.rd, s.rt, last)
+			case v := <-s.pipe.out:
+				s.applyInOrder(ctx, v)
 			case <-ctx.Done():
 				return
 			}
+			s.participate(ctx)
 		}
 	}()
 	return nil
 }
 
-func (s *Service) Stop(ctx context.Context) error { logger.Info("consensus stop (stub)"); return nil }
+// SetManualDrive makes Start initialize the service without spawning its
+// event loop; the caller then runs the loop one step at a time with Step.
+// Deterministic simulations use it together with SetClock.
+func (s *Service) SetManualDrive(on bool) { s.manual = on }
+
+// Step runs at most one pending unit of loop work without blocking: a fired
+// round timer, then a finished sync, then one consensus event, then one tx
+// event, checked in that fixed order. Stages run inline. It reports whether anything ran. Only valid after Start with
+// SetManualDrive(true).
+func (s *Service) Step(ctx context.Context) bool {
+	select {
+	case <-s.rt.C():
+		s.onRoundTimeout(ctx, s.rd, s.rt)
+		s.participate(ctx)
+		return true
+	default:
+	}
+	select {
+	case last := <-s.syncDone():
+		s.applySynced(ctx, s.rd, s.rt, last)
+		s.participate(ctx)
+		return true
+	default:
+	}
+	select {
+	case ev := <-s.sub:
+		s.handleEvent(ctx, ev)
+		s.participate(ctx)
+		return true
+	default:
+	}
+	select {
+	case ev := <-s.txSub:
+		s.ingestTx(ctx, ev)
+		return true
+	default:
+	}
+	return false
+}
+
+// handleEvent processes one bus event inline: tx ingest, or verify -> WAL ->
+// state transition -> persistence for consensus messages. Manually driven
+// nodes use it; the running loop splits the same stages across the pipeline.
+func (s *Service) handleEvent(ctx context.Context, ev bus.Event) {
+	if ev.Kind == bus.KindTx {
+		s.ingestTx(ctx, ev)
+		return
+	}
+	s.apply(ctx, s.verify(ev, time.Now()))
+}
+
+// ingestTx adds a gossiped or submitted tx to the local mempool. A tx that
+// replaced a pooled one is gossiped again, so peers that kept the original
+// learn about the bump even when they missed its first broadcast.
+func (s *Service) ingestTx(ctx context.Context, ev bus.Event) {
+	if s.pool == nil {
+		return
+	}
+	plAny, ok := ev.Body.(pl.Payload)
+	if !ok || plAny == nil {
+		return
+	}
+	replaced, err := s.pool.AddReplacing(plAny)
+	if err != nil {
+		return
+	}
+	s.journalTx(ev, plAny)
+	if replaced == nil || s.txb == nil {
+		return
+	}
+	if err := s.txb.BroadcastTx(ctx, plAny); err != nil {
+		metrics.Inc("consensus_tx_regossip_total", map[string]string{"result": "error"})
+		return
+	}
+	metrics.Inc("consensus_tx_regossip_total", map[string]string{"result": "ok"})
+}
+
+// verify maps a consensus event to its QBFT message and checks it. It keeps
+// no state of its own, so pipeline workers run it in parallel.
+func (s *Service) verify(ev bus.Event, begin time.Time) verified {
+	// Count the event as received
+	metrics.Inc("consensus_events_total", map[string]string{"kind": string(ev.Kind)})
+	// Map event to qbft message via adapter
+	msg := MapEventToQBFT(ev)
+	err := s.v.Verify(msg)
+	metrics.ObserveSummary("consensus_stage_ms", map[string]string{"stage": "verify"}, float64(time.Since(begin).Milliseconds()))
+	return verified{ev: ev, msg: msg, err: err, begin: begin}
+}
+
+// apply runs the ordered part of message handling on the consensus loop:
+// evidence, sync trigger, WAL guard, relay, state transition and commit.
+// Accounting, TSS signing and the LastState save are handed to persistence.
+func (s *Service) apply(ctx context.Context, v verified) {
+	ev, msg := v.ev, v.msg
+	applyBegin := time.Now()
+	if v.err == nil {
+		// Equivocation check on every verified message, before the
+		// state machine sees it: a prepare/commit conflicting with its
+		// sender's first one at the same coordinates is neither
+		// relayed nor processed.
+		vote := msg.Type == qbft.MsgPrepare || msg.Type == qbft.MsgCommit
+		allowed := !s.observeEvidence(msg) || !vote
+		// Peers committing well ahead of the active instance mean
+		// this node missed heights: fetch them instead of waiting.
+		if s.behind(s.rd, msg.Height) {
+			s.maybeSync(ctx, "behind")
+		}
+		// The WAL guards this node's own votes only (signVote records
+		// them before signing); peers' votes are not fsynced here.
+		if allowed && vote && s.wal != nil && s.isSelf(msg.From) {
+			if err := s.wal.AppendIntent(msg); errors.Is(err, qbft.ErrConflictingVote) {
+				allowed = false
+				logger.InfoJ("qbft_wal_guard", map[string]any{"result": "conflict", "type": string(msg.Type), "from": msg.From, "height": msg.Height, "round": msg.Round, "id": msg.ID})
+			}
+		}
+		// Optional: broadcast local votes (prepare/commit) via injected broadcaster.
+		if allowed && vote {
+			s.broadcast(ctx, msg, ev.TraceID)
+		}
+		// Behind-flag builder: prepare deterministic block for this coordinate.
+		// Voting nodes build only when they propose and keep the
+		// validated proposal instead.
+		if s.enableBuilder && s.pool != nil && !s.voting {
+			hdr := pl.BlockHeader{Height: msg.Height, Round: msg.Round, FeeRecipient: s.feeRecipient}
+			blk := pl.PrepareProposal(s.pool, hdr, s.policy)
+			if err := pl.ProcessProposal(blk, s.policy); err == nil {
+				blk.Stats = summarizeStats(blk.Items)
+				if s.lastBlock[msg.Height] == nil {
+					s.lastBlock[msg.Height] = make(map[uint64]pl.StandardBlock)
+				}
+				s.lastBlock[msg.Height][msg.Round] = blk
+				logger.InfoJ("consensus_builder", map[string]any{
+					"result": "ok", "height": msg.Height, "round": msg.Round,
+					"items": len(blk.Items), "bids": blk.Stats.TotalBids, "fees": blk.Stats.TotalFees,
+				})
+			} else {
+				logger.ErrorJ("consensus_builder", map[string]any{"result": "reject", "err": err.Error(), "height": msg.Height, "round": msg.Round})
+			}
+		}
+		// Guard against processing intents older than last WAL entry (best-effort)
+		if vote && s.lastWAL.Type != "" {
+			if msg.Height < s.lastWAL.Height || (msg.Height == s.lastWAL.Height && msg.Round < s.lastWAL.Round) {
+				allowed = false
+				metrics.Inc("qbft_wal_guard_drops_total", nil)
+				logger.InfoJ("qbft_wal_guard", map[string]any{"result": "drop", "height": msg.Height, "round": msg.Round, "last_h": s.lastWAL.Height, "last_r": s.lastWAL.Round})
+			}
+		}
+		if allowed {
+			if s.rd != nil {
+				h0, r0, p0 := s.rd.View()
+				_ = s.st.Process(msg)
+				s.onProgress(ctx, s.rd, s.rt, h0, r0, p0)
+			} else {
+				_ = s.st.Process(msg)
+			}
+			job := persistJob{height: msg.Height, round: msg.Round, commit: msg.Type == qbft.MsgCommit, traceID: ev.TraceID, queued: time.Now()}
+			if blk, ok := s.lastBlock[msg.Height][msg.Round]; ok {
+				job.blk, job.hasBlock = blk, true
+			}
+			s.enqueuePersist(ctx, job)
+		}
+	}
+	metrics.ObserveSummary("consensus_stage_ms", map[string]string{"stage": "apply"}, float64(time.Since(applyBegin).Milliseconds()))
+	durMs := time.Since(v.begin).Milliseconds()
+	// Audit log and summary with the full processing latency; labels unchanged
+	logger.InfoJ("consensus_recv", map[string]any{"kind": string(ev.Kind), "trace_id": ev.TraceID, "result": "recv", "latency_ms": durMs})
+	metrics.ObserveSummary("consensus_proc_ms", map[string]string{"kind": string(ev.Kind)}, float64(durMs))
+}
+
+// persist runs the side effects of an applied message: block value
+// accounting, fee sink export and TSS signing of the block on commit, then
+// the LastState save.
+func (s *Service) persist(ctx context.Context, job persistJob) {
+	if job.hasBlock {
+		blk := job.blk
+		// Emit block value accounting metrics/logs on commit path.
+		metrics.ObserveSummary("block_value_bids", nil, float64(blk.Stats.TotalBids))
+		metrics.ObserveSummary("block_value_fees", nil, float64(blk.Stats.TotalFees))
+		logger.InfoJ("consensus_block_value", map[string]any{
+			"height": job.height, "round": job.round,
+			"bids": blk.Stats.TotalBids, "fees": blk.Stats.TotalFees, "items": len(blk.Items),
+		})
+		// Non-blocking fee sink publish (best-effort).
+		s.sink.Publish(ValueRecord{
+			Height: job.height, Round: job.round,
+			Bids: blk.Stats.TotalBids, Fees: blk.Stats.TotalFees, Items: len(blk.Items),
+		})
+		if s.enableTSSSign && s.signer != nil && job.commit {
+			b, _ := json.Marshal(blk)
+			sum := sha256.Sum256(b)
+			if _, err := s.signer.Sign(ctx, job.height, job.round, sum[:]); err != nil {
+				metrics.Inc("block_sign_total", map[string]string{"result": "error"})
+				logger.ErrorJ("consensus_block", map[string]any{"op": "sign", "result": "error", "err": err.Error(), "height": job.height, "round": job.round})
+			} else {
+				metrics.Inc("block_sign_total", map[string]string{"result": "ok"})
+				logger.InfoJ("consensus_block", map[string]any{"op": "sign", "result": "ok", "height": job.height, "round": job.round})
+			}
+		}
+	}
+	if err2 := s.store.SaveLastState(ctx, state.LastState{Height: job.height, Round: job.round}); err2 != nil {
+		logger.ErrorJ("consensus_state", map[string]any{"op": "save", "result": "error", "err": err2.Error(), "trace_id": job.traceID})
+	} else {
+		logger.InfoJ("consensus_state", map[string]any{"op": "save", "result": "ok", "height": job.height, "round": job.round, "trace_id": job.traceID})
+	}
+	metrics.ObserveSummary("consensus_stage_ms", map[string]string{"stage": "persist"}, float64(time.Since(job.queued).Milliseconds()))
+}
+
+func (s *Service) Stop(ctx context.Context) error {
+	if s.journal != nil {
+		_ = s.journal.j.Close()
+	}
+	logger.Info("consensus stop (stub)")
+	return nil
+}
+
+// QbftUnicaster is implemented by broadcasters that can also address a
+// single peer. Without it, addressed messages are gossiped to everyone.
+type QbftUnicaster interface {
+	SendQBFT(ctx context.Context, to string, msg qbft.Message) error
+}
+
+// director is implemented by processors whose messages may have a single
+// addressee (hotstuff.Engine sends votes and new-views to the next leader).
+type director interface {
+	Recipient(msg qbft.Message) (to string, ok bool)
+}
+
+// broadcast publishes msg via the injected broadcaster (no-op when unset).
+// Messages the processor addresses to one node are sent to it alone, or not
+// at all when that node is the sender (it already applied them).
+func (s *Service) broadcast(ctx context.Context, msg qbft.Message, traceID string) {
+	if s.bc == nil {
+		return
+	}
+	send := func() error { return s.bc.BroadcastQBFT(ctx, msg) }
+	if d, ok := s.st.(director); ok {
+		if to, ok := d.Recipient(msg); ok {
+			if to == msg.From {
+				return
+			}
+			if u, ok := s.bc.(QbftUnicaster); ok {
+				send = func() error { return u.SendQBFT(ctx, to, msg) }
+			}
+		}
+	}
+	if err := send(); err != nil {
+		metrics.Inc("consensus_broadcast_total", map[string]string{"type": string(msg.Type), "result": "error"})
+		logger.ErrorJ("consensus_broadcast", map[string]any{"result": "error", "type": string(msg.Type), "height": msg.Height, "round": msg.Round, "trace_id": traceID, "err": err.Error()})
+		return
+	}
+	metrics.Inc("consensus_broadcast_total", map[string]string{"type": string(msg.Type), "result": "ok"})
+	logger.InfoJ("consensus_broadcast", map[string]any{"result": "ok", "type": string(msg.Type), "height": msg.Height, "round": msg.Round, "trace_id": traceID})
+}
+
+// onProgress re-arms the round timer when the processor's view moved away
+// from (h0, r0, p0), and disarms it once the instance has committed (the
+// finalized block is then persisted and per-height processors advance). When a round change happened, the new
+// proposer publishes its NEW_VIEW.
+func (s *Service) onProgress(ctx context.Context, rd roundDriver, rt *roundTimer, h0, r0 uint64, p0 string) {
+	h, r, p := rd.View()
+	if h == h0 && r == r0 && p == p0 {
+		return
+	}
+	if p == "commit" {
+		rt.stop()
+		s.onCommit(ctx, h)
+		s.activateEpochs(h + 1)
+		// Per-height processors move on to the next height right away;
+		// messages buffered for it may already drive it forward.
+		if adv, ok := rd.(heightAdvancer); ok {
+			adv.Advance()
+			s.onProgress(ctx, rd, rt, h, r, p)
+		}
+		return
+	}
+	rt.arm(r)
+	if h == h0 && r > r0 {
+		s.maybeNewView(ctx, rd, rt)
+	}
+}
+
+// newViewer is implemented by processors that can build a justified NEW_VIEW
+// when the local node is the proposer of a new round (qbft.State does).
+type newViewer interface {
+	NewView(id string, payload []byte) (qbft.Message, bool)
+}
+
+// maybeNewView broadcasts and locally applies a NEW_VIEW when the processor
+// reports that this node must propose the current round.
+func (s *Service) maybeNewView(ctx context.Context, rd roundDriver, rt *roundTimer) {
+	nvr, ok := rd.(newViewer)
+	if !ok {
+		return
+	}
+	// A voting node brings its own value for rounds nobody proposed in;
+	// otherwise the processor falls back to the last proposal it saw.
+	var id string
+	var payload []byte
+	if s.voting {
+		h, r, _ := rd.View()
+		id, payload = s.proposalValue(h, r)
+	}
+	nv, ok := nvr.NewView(id, payload)
+	if !ok {
+		return
+	}
+	nv = s.signLocal(nv)
+	logger.InfoJ("consensus_new_view", map[string]any{"result": "propose", "height": nv.Height, "round": nv.Round, "id": nv.ID, "justification": len(nv.Justification)})
+	s.broadcast(ctx, nv, nv.TraceID)
+	h0, r0, p0 := rd.View()
+	_ = rd.Process(nv)
+	s.onProgress(ctx, rd, rt, h0, r0, p0)
+}
+
+// onRoundTimeout fires the processor timeout, broadcasts the resulting
+// view-change and counts it locally so the node's own vote joins the quorum.
+// The timer is re-armed with the backoff of the requested round.
+func (s *Service) onRoundTimeout(ctx context.Context, rd roundDriver, rt *roundTimer) {
+	h0, r0, p0 := rd.View()
+	vc := s.signLocal(rd.OnTimeout())
+	logger.InfoJ("consensus_round_timer", map[string]any{"result": "timeout", "height": h0, "round": r0, "phase": p0, "target": vc.Round})
+	s.broadcast(ctx, vc, vc.TraceID)
+	_ = rd.Process(vc)
+	if h, r, p := rd.View(); h != h0 || r != r0 || p != p0 {
+		s.onProgress(ctx, rd, rt, h0, r0, p0)
+		return
+	}
+	rt.arm(vc.Round)
+}
 
 var _ lifecycle.Service = (*Service)(nil)
 
@@ -296,17 +647,6 @@ func (s *Service) VerifyHeaderWithTSS(pkGroup, header, sig []byte) bool {
 	return ok
 }
 
-// summarizeStats aggregates bids/fees for a block selection without importing payload in the payload package.
-func summarizeStats(items []pl.Payload) pl.BlockStats {
-	var stats pl.BlockStats
-	stats.Items = len(items)
-	for _, it := range items {
-		switch tx := it.(type) {
-		case *auction_v1.AuctionBidTx:
-			stats.TotalBids += tx.Bid
-		case *plaintext_v1.PlaintextTx:
-			stats.TotalFees += tx.Fee
-		}
-	}
-	return stats
-}
+// summarizeStats aggregates bids/fees for a block selection through the
+// stats rule each payload type registers.
+func summarizeStats(items []pl.Payload) pl.BlockStats { return pl.Summarize(items) }
@@ -0,0 +1,166 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	pt "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/pkg/bus"
+)
+
+// publishHeight drives one height through proposal, prepare and commit
+// quorums of a four-node set.
+func publishHeight(ctx context.Context, b *bus.Bus, vs *qbft.ValidatorSet, h uint64) {
+	id := "blk-" + string(rune('a'+h))
+	send := func(m qbft.Message) { b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Body: m, Height: h}) }
+	send(qbft.Message{Type: qbft.MsgPreprepare, From: vs.Proposer(h, 0), Height: h, ID: id})
+	for _, from := range []string{"n0", "n1", "n2"} {
+		send(qbft.Message{Type: qbft.MsgPrepare, From: from, Height: h, ID: id})
+	}
+	for _, from := range []string{"n0", "n1", "n2"} {
+		send(qbft.Message{Type: qbft.MsgCommit, From: from, Height: h, ID: id, Sig: []byte("sig-" + from)})
+	}
+}
+
+func TestService_Commit_PersistsBlockWithSealAndPrunes(t *testing.T) {
+	b := bus.New(32)
+	s := NewWithSub(b.Subscribe())
+	vs := qbft.NewValidatorSet([]string{"n0", "n1", "n2", "n3"}, 0)
+	s.SetProcessor(&qbft.State{Validators: vs, Self: "n0"})
+	s.SetVerifier(okVerifier{})
+	blocks := state.NewMemoryBlockStore()
+	s.SetBlockStore(blocks)
+	s.SetGenesis(map[string]uint64{"A": 10}) // A pays its fee, so the tx executes
+	s.enableBuilder = true
+	pool := pt.New()
+	c := pl.NewContainer(map[string]pl.TypedMempool{"plaintext_v1": pool})
+	_ = c.Add(&pt.PlaintextTx{From: "A", Nonce: 0, Gas: 1, Fee: 3, Sig: make([]byte, 32)})
+	s.SetPayloadContainer(c)
+	s.SetBuilderPolicy(pl.BuilderPolicy{Order: []string{"plaintext_v1"}, MaxN: 1})
+
+	// Driven inline, so the test reads service state no loop writes.
+	s.SetManualDrive(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	publishHeight(ctx, b, vs, 1)
+	for s.Step(ctx) {
+	}
+
+	rec, err := blocks.BlockByHeight(ctx, 1)
+	if err != nil {
+		t.Fatalf("height 1 not persisted: %v", err)
+	}
+	if rec.ID != "blk-b" || len(rec.Seal) != 3 || rec.Seal[0].From != "n0" || string(rec.Seal[2].Sig) != "sig-n2" {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+	blk, err := wire.DecodeBlock(rec.Block)
+	if err != nil || len(blk.Items) != 1 || blk.Header.Height != 1 {
+		t.Fatalf("decode: %+v err=%v", blk, err)
+	}
+	if !bytes.Equal(blk.Hash(), rec.Hash) {
+		t.Fatalf("hash mismatch after round-trip")
+	}
+	if byHash, err := blocks.BlockByHash(ctx, rec.Hash); err != nil || byHash.Height != 1 {
+		t.Fatalf("by hash: %+v err=%v", byHash, err)
+	}
+
+	publishHeight(ctx, b, vs, 2)
+	for s.Step(ctx) {
+	}
+	rec2, err := blocks.BlockByHeight(ctx, 2)
+	if err != nil {
+		t.Fatalf("height 2 not persisted: %v", err)
+	}
+	// The committed tx left the mempool and is not proposed again.
+	if blk2, _ := wire.DecodeBlock(rec2.Block); len(blk2.Items) != 0 || c.Len() != 0 {
+		t.Fatalf("committed tx re-included: items=%d pool=%d", len(blk2.Items), c.Len())
+	}
+	if _, ok := s.lastBlock[1]; ok {
+		t.Fatalf("built blocks of committed height 1 must be pruned")
+	}
+}
+
+// With a per-height manager, a height gossiped ahead of time is buffered and
+// finalized right after the current one, and restarts resume after the last
+// stored block.
+func TestService_Manager_OutOfOrderHeightsAndRestore(t *testing.T) {
+	b := bus.New(32)
+	s := NewWithSub(b.Subscribe())
+	vs := qbft.NewValidatorSet([]string{"n0", "n1", "n2", "n3"}, 0)
+	mgr := qbft.NewManager(vs, "n0")
+	s.SetProcessor(mgr)
+	s.SetVerifier(okVerifier{})
+	blocks := state.NewMemoryBlockStore()
+	_ = blocks.SaveBlock(context.Background(), state.BlockRecord{Height: 4, Hash: []byte("h4")})
+	s.SetBlockStore(blocks)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	publishHeight(ctx, b, vs, 6)
+	publishHeight(ctx, b, vs, 3) // finished before the restart: dropped
+	publishHeight(ctx, b, vs, 5)
+	time.Sleep(80 * time.Millisecond)
+
+	for _, h := range []uint64{5, 6} {
+		if _, err := blocks.BlockByHeight(ctx, h); err != nil {
+			t.Fatalf("height %d not persisted: %v", h, err)
+		}
+	}
+	if _, err := blocks.BlockByHeight(ctx, 3); err == nil {
+		t.Fatalf("stale height 3 must not be finalized")
+	}
+}
+
+type recPenalizer struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recPenalizer) Penalize(id string, delta int64, reason string) {
+	r.mu.Lock()
+	r.calls = append(r.calls, id+":"+reason)
+	r.mu.Unlock()
+}
+
+// A member voting for two values at one (height, round) produces evidence
+// and, when configured, a P2P penalty.
+func TestService_Equivocation_RecordsEvidenceAndPenalizes(t *testing.T) {
+	b := bus.New(8)
+	s := NewWithSub(b.Subscribe())
+	s.SetVerifier(okVerifier{})
+	pool, _ := qbft.NewEvidencePool("")
+	s.SetEvidencePool(pool)
+	pen := &recPenalizer{}
+	s.SetPeerPenalizer(pen, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	for _, id := range []string{"a", "b"} {
+		b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Body: qbft.Message{Type: qbft.MsgCommit, From: "n3", Height: 7, ID: id}})
+	}
+	time.Sleep(30 * time.Millisecond)
+	if got := pool.List("n3", 0); len(got) != 1 || got[0].Kind != qbft.EvidenceDoubleCommit {
+		t.Fatalf("want double_commit evidence, got %+v", got)
+	}
+	pen.mu.Lock()
+	defer pen.mu.Unlock()
+	if len(pen.calls) != 1 || pen.calls[0] != "n3:double_commit" {
+		t.Fatalf("want one penalty, got %v", pen.calls)
+	}
+}
@@ -0,0 +1,115 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	pt "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+)
+
+// execRecord encodes a block of h paying fees to P for saveExecuted.
+func execRecord(t *testing.T, h uint64, items ...pl.Payload) (state.BlockRecord, pl.StandardBlock) {
+	t.Helper()
+	blk := pl.StandardBlock{Header: pl.BlockHeader{Height: h, FeeRecipient: "P"}, Items: items}
+	raw, err := wire.EncodeBlock(blk)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return state.BlockRecord{Height: h, ID: "b", Hash: blk.Hash(), Block: raw}, blk
+}
+
+// Committed blocks are stored with receipts and the post-state root; a
+// restarted node rebuilds the same state from the stored blocks.
+func TestService_Exec_StoresRootAndSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	blocks := state.NewMemoryBlockStore()
+	s := New()
+	s.blocks = blocks
+	s.SetGenesis(map[string]uint64{"A": 100})
+	s.restoreExec(ctx)
+
+	tx := &pt.PlaintextTx{From: "A", Nonce: 0, Gas: 1, Fee: 2, To: "B", Value: 10, Sig: make([]byte, 32)}
+	rec, blk := execRecord(t, 1, tx)
+	rec.StateRoot = []byte("peer root") // from state sync: replaced by the local result
+	if err := s.saveExecuted(ctx, &rec, blk); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	stored, _ := blocks.BlockByHeight(ctx, 1)
+	if len(stored.Receipts) != 1 || stored.Receipts[0].Status != "ok" || !bytes.Equal(stored.Receipts[0].Tx, tx.Hash()) {
+		t.Fatalf("receipts %+v", stored.Receipts)
+	}
+	if !bytes.Equal(stored.StateRoot, s.exec.st.Root()) {
+		t.Fatalf("stored root is not the post-state root")
+	}
+	if s.exec.st.Account("B").Balance != 10 || s.exec.st.Account("P").Balance != 2 {
+		t.Fatalf("B=%+v P=%+v", s.exec.st.Account("B"), s.exec.st.Account("P"))
+	}
+	rec2, blk2 := execRecord(t, 2)
+	_ = s.saveExecuted(ctx, &rec2, blk2)
+
+	s2 := New()
+	s2.blocks = blocks
+	s2.SetGenesis(map[string]uint64{"A": 100})
+	s2.restoreExec(ctx)
+	if s2.exec.next != 3 || !bytes.Equal(s2.exec.st.Root(), stored.StateRoot) {
+		t.Fatalf("restored next=%d, root differs", s2.exec.next)
+	}
+}
+
+// A height missing once execution started halts it: later blocks are stored
+// without a root rather than with one for an unknown state.
+func TestService_Exec_GapHalts(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	s.blocks = state.NewMemoryBlockStore()
+	s.restoreExec(ctx)
+	rec, blk := execRecord(t, 5)
+	_ = s.saveExecuted(ctx, &rec, blk)
+	if rec.StateRoot == nil {
+		t.Fatalf("first stored height not executed")
+	}
+	rec, blk = execRecord(t, 7)
+	if err := s.saveExecuted(ctx, &rec, blk); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if stored, _ := s.blocks.BlockByHeight(ctx, 7); stored.StateRoot != nil || !s.exec.halted {
+		t.Fatalf("block after a gap executed: %+v", stored)
+	}
+}
+
+// Only executed txs advance the mempool: a tx execution skipped stays
+// pooled (or is pooled again) and keeps its sender's nonce open.
+func TestService_Exec_PrunesMempoolFromReceipts(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	s.blocks = state.NewMemoryBlockStore()
+	s.SetGenesis(map[string]uint64{"A": 3})
+	s.restoreExec(ctx)
+	c := pl.NewContainer(map[string]pl.TypedMempool{"plaintext_v1": pt.New()})
+	s.SetPayloadContainer(c)
+	a0 := &pt.PlaintextTx{From: "A", Nonce: 0, Gas: 1, Fee: 2, Sig: make([]byte, 32)}
+	a1 := &pt.PlaintextTx{From: "A", Nonce: 1, Gas: 1, Fee: 5, Sig: make([]byte, 32)} // A cannot pay it
+	b0 := &pt.PlaintextTx{From: "B", Nonce: 0, Gas: 1, Fee: 1, Sig: make([]byte, 32)} // another proposer's, B is empty
+	_ = c.Add(a0)
+	_ = c.Add(a1)
+	rec, blk := execRecord(t, 1, a0, a1, b0)
+	if err := s.saveExecuted(ctx, &rec, blk); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	s.pruneMempool(blk, rec.Receipts)
+	for _, tx := range []*pt.PlaintextTx{a1, b0} {
+		if _, ok := c.Arrival(tx); !ok {
+			t.Fatalf("skipped tx %s/%d not pooled", tx.From, tx.Nonce)
+		}
+	}
+	if _, ok := c.Arrival(a0); ok {
+		t.Fatalf("executed tx still pooled")
+	}
+	if got := c.GetAll("plaintext_v1"); len(got) != 2 {
+		t.Fatalf("pending %d, want a1 and b0", len(got))
+	}
+}
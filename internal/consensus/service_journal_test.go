@@ -0,0 +1,54 @@
+package consensus
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	pt "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/pkg/bus"
+)
+
+func plainTx(from string, nonce uint64) *pt.PlaintextTx {
+	return &pt.PlaintextTx{From: from, Nonce: nonce, Gas: 1, Fee: 1, Sig: make([]byte, 32)}
+}
+
+// Locally submitted txs survive a restart; the ones a stored block already
+// included are not replayed and drop out of the journal.
+func TestService_MempoolJournal_ReplaysAcrossRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "mempool.journal")
+	blocks := state.NewMemoryBlockStore()
+
+	s := New()
+	s.SetPayloadContainer(pl.NewContainer(map[string]pl.TypedMempool{"plaintext_v1": pt.New()}))
+	s.SetMempoolJournal(state.NewTxJournal(path), false)
+	s.ingestTx(ctx, bus.Event{Kind: bus.KindTx, Body: plainTx("A", 0), Local: true})
+	s.ingestTx(ctx, bus.Event{Kind: bus.KindTx, Body: plainTx("A", 1), Local: true})
+	s.ingestTx(ctx, bus.Event{Kind: bus.KindTx, Body: plainTx("B", 0)}) // gossiped: not journaled
+	_ = s.Stop(ctx)
+	// A/0 is committed while the node is down.
+	commitItems(t, blocks, 1, plainTx("A", 0))
+
+	c := pl.NewContainer(map[string]pl.TypedMempool{"plaintext_v1": pt.New()})
+	s2 := NewWithSub(bus.New(4).Subscribe())
+	s2.SetBlockStore(blocks)
+	s2.SetPayloadContainer(c)
+	s2.SetMempoolJournal(state.NewTxJournal(path), false)
+	s2.SetManualDrive(true)
+	if err := s2.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	got := c.GetAll("plaintext_v1")
+	if len(got) != 1 || got[0].(*pt.PlaintextTx).From != "A" || got[0].(*pt.PlaintextTx).Nonce != 1 {
+		t.Fatalf("restored %v", got)
+	}
+	if err := c.Add(plainTx("A", 0)); err == nil {
+		t.Fatalf("committed nonce admitted after the restore")
+	}
+	if recs, _ := state.NewTxJournal(path).Load(); len(recs) != 1 {
+		t.Fatalf("journal not compacted: %d records", len(recs))
+	}
+}
@@ -0,0 +1,197 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	pt "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/pkg/bus"
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// recProcessor records the ids it was asked to process, in order.
+type recProcessor struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (r *recProcessor) Process(msg qbft.Message) error {
+	r.mu.Lock()
+	r.ids = append(r.ids, msg.ID)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *recProcessor) seen() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.ids...)
+}
+
+// slowVerifier takes longer for earlier messages, so parallel workers finish
+// them out of order.
+type slowVerifier struct{}
+
+func (slowVerifier) Verify(msg qbft.Message) error {
+	var i int
+	fmt.Sscanf(msg.ID, "m%d", &i)
+	time.Sleep(time.Duration(10-i%10) * time.Millisecond)
+	return nil
+}
+
+func waitSeen(p *recProcessor, n int) []string {
+	for end := time.Now().Add(2 * time.Second); time.Now().Before(end); time.Sleep(5 * time.Millisecond) {
+		if ids := p.seen(); len(ids) >= n {
+			return ids
+		}
+	}
+	return p.seen()
+}
+
+func TestService_Pipeline_ParallelVerifyAppliesInOrder(t *testing.T) {
+	b := bus.New(64)
+	s := NewWithSub(b.Subscribe())
+	proc := &recProcessor{}
+	s.SetProcessor(proc)
+	s.SetVerifier(slowVerifier{})
+	s.SetPipeline(4, 8)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	var want []string
+	for i := 0; i < 30; i++ {
+		id := fmt.Sprintf("m%d", i)
+		want = append(want, id)
+		b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Body: qbft.Message{Type: qbft.MsgPrepare, ID: id, Height: 1}})
+	}
+	got := waitSeen(proc, len(want))
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("apply order changed:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestService_Pipeline_TxFloodDoesNotStarveConsensus(t *testing.T) {
+	metrics.Reset()
+	b := bus.New(16)
+	s := NewWithSub(b.Subscribe())
+	s.SetTxSub(b.SubscribeTx())
+	proc := &recProcessor{}
+	s.SetProcessor(proc)
+	s.SetVerifier(okVerifier{})
+	c := pl.NewContainer(map[string]pl.TypedMempool{"plaintext_v1": pt.New()})
+	s.SetPayloadContainer(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 200; j++ {
+			b.Publish(ctx, bus.Event{Kind: bus.KindTx, Body: &pt.PlaintextTx{From: fmt.Sprintf("s%d", j), Nonce: uint64(i), Gas: 1, Fee: 1, Sig: make([]byte, 32)}})
+		}
+		b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Body: qbft.Message{Type: qbft.MsgPrepare, ID: fmt.Sprintf("m%d", i), Height: 1}})
+	}
+	if got := waitSeen(proc, 10); len(got) != 10 {
+		t.Fatalf("consensus events lost under tx flood: %v", got)
+	}
+	dump := metrics.DumpProm()
+	if strings.Contains(dump, `bus_dropped_total{kind="consensus"}`) {
+		t.Fatalf("consensus events dropped:\n%s", dump)
+	}
+	if len(c.GetAll("plaintext_v1")) == 0 {
+		t.Fatalf("tx lane not ingested")
+	}
+}
+
+type recTxBroadcaster struct{ sent []pl.Payload }
+
+func (r *recTxBroadcaster) BroadcastTx(_ context.Context, tx pl.Payload) error {
+	r.sent = append(r.sent, tx)
+	return nil
+}
+
+// Only a tx that replaced a pooled one is gossiped again.
+func TestService_IngestTx_GossipsReplacement(t *testing.T) {
+	s := New()
+	c := pl.NewContainer(map[string]pl.TypedMempool{"plaintext_v1": pt.New()})
+	s.SetPayloadContainer(c)
+	txb := &recTxBroadcaster{}
+	s.SetTxBroadcaster(txb)
+	ctx := context.Background()
+	first := &pt.PlaintextTx{From: "A", Gas: 1, Fee: 10, Sig: make([]byte, 32)}
+	bump := &pt.PlaintextTx{From: "A", Gas: 1, Fee: 20, Sig: make([]byte, 32)}
+	for _, tx := range []*pt.PlaintextTx{first, bump, bump} {
+		s.ingestTx(ctx, bus.Event{Kind: bus.KindTx, Body: tx})
+	}
+	if len(txb.sent) != 1 || txb.sent[0] != bump {
+		t.Fatalf("gossiped %v", txb.sent)
+	}
+	if _, ok := c.Arrival(first); ok || c.Len() != 1 {
+		t.Fatalf("replaced tx still tracked by the container")
+	}
+}
+
+// gateStore blocks SaveLastState until released and counts the saves.
+type gateStore struct {
+	gate  chan struct{}
+	mu    sync.Mutex
+	saves int
+}
+
+func (g *gateStore) SaveLastState(_ context.Context, _ state.LastState) error {
+	<-g.gate
+	g.mu.Lock()
+	g.saves++
+	g.mu.Unlock()
+	return nil
+}
+func (g *gateStore) LoadLastState(context.Context) (state.LastState, error) {
+	return state.LastState{}, nil
+}
+func (g *gateStore) Close() error { return nil }
+
+func (g *gateStore) count() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.saves
+}
+
+// A stalled persistence stage pushes back on the caller; no job is dropped.
+func TestService_Pipeline_PersistAppliesBackpressure(t *testing.T) {
+	s := New()
+	st := &gateStore{gate: make(chan struct{})}
+	s.SetStore(st)
+	s.pipe = &pipeline{persist: make(chan persistJob, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.persister(ctx)
+	const jobs = 5
+	done := make(chan struct{})
+	go func() {
+		for h := uint64(1); h <= jobs; h++ {
+			s.enqueuePersist(ctx, persistJob{height: h, commit: true})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatalf("enqueue returned while the persistence stage was stalled")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(st.gate)
+	<-done
+	for end := time.Now().Add(2 * time.Second); st.count() < jobs && time.Now().Before(end); time.Sleep(5 * time.Millisecond) {
+	}
+	if got := st.count(); got != jobs {
+		t.Fatalf("persisted %d of %d jobs", got, jobs)
+	}
+}
@@ -0,0 +1,136 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	bundle "github.com/zmlAEQ/Aequa-network/internal/payload/bundle_v1"
+	pt "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+	"github.com/zmlAEQ/Aequa-network/pkg/bus"
+)
+
+// testKey returns the ed25519 key seeded with seed.
+func testKey(seed byte) ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+}
+
+// signedTx returns a plaintext tx signed by testKey(seed).
+func signedTx(seed byte, nonce, fee uint64) *pt.PlaintextTx {
+	tx := &pt.PlaintextTx{Nonce: nonce, Gas: 1, Fee: fee}
+	tx.Sign(pl.DefaultChainID, testKey(seed))
+	return tx
+}
+
+// votingNode starts a manually driven voting service for id with the
+// builder over a plaintext pool holding txs.
+func votingNode(t *testing.T, ctx context.Context, vs *qbft.ValidatorSet, id string, txs ...*pt.PlaintextTx) (*Service, *bus.Bus, *recBroadcaster) {
+	t.Helper()
+	b := bus.New(16)
+	s := NewWithSub(b.Subscribe())
+	s.SetProcessor(qbft.NewManager(vs, id))
+	s.SetVerifier(okVerifier{})
+	c := pl.NewContainer(map[string]pl.TypedMempool{"plaintext_v1": pt.New()})
+	for _, tx := range txs {
+		_ = c.Add(tx)
+	}
+	s.enableBuilder = true
+	s.SetPayloadContainer(c)
+	s.SetBuilderPolicy(pl.BuilderPolicy{Order: []string{"plaintext_v1"}, MaxN: 8})
+	bc := &recBroadcaster{}
+	s.SetBroadcaster(bc)
+	s.SetManualDrive(true)
+	s.SetVoting(true)
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	return s, b, bc
+}
+
+func deliver(ctx context.Context, s *Service, b *bus.Bus, msg qbft.Message) {
+	b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Height: msg.Height, Round: msg.Round, Body: msg})
+	for s.Step(ctx) {
+	}
+}
+
+func TestService_Proposal_LeaderProposesBuiltBlock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vs := qbft.NewValidatorSet([]string{"n0", "n1", "n2", "n3"}, 0)
+	leader := vs.Proposer(0, 0)
+	_, _, bc := votingNode(t, ctx, vs, leader, signedTx(1, 0, 3), signedTx(2, 0, 5))
+
+	pps := bc.ofType(qbft.MsgPreprepare)
+	if len(pps) != 1 {
+		t.Fatalf("want one proposal, got %d", len(pps))
+	}
+	blk, err := wire.DecodeBlock(pps[0].Payload)
+	if err != nil || len(blk.Items) != 2 || blk.Items[0].SortKey() != 5 {
+		t.Fatalf("proposal does not carry the built block: %+v err=%v", blk, err)
+	}
+	if pps[0].ID != blockID(blk) {
+		t.Fatalf("proposal id %s is not the block hash", pps[0].ID)
+	}
+	// The leader validated its own proposal and prepares it.
+	if prs := bc.ofType(qbft.MsgPrepare); len(prs) != 1 || prs[0].ID != pps[0].ID {
+		t.Fatalf("leader prepare: %+v", prs)
+	}
+}
+
+func TestService_Proposal_FollowerValidatesBeforePrepare(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vs := qbft.NewValidatorSet([]string{"n0", "n1", "n2", "n3"}, 0)
+	leader := vs.Proposer(0, 0)
+	follower := "n0"
+	if leader == follower {
+		follower = "n1"
+	}
+	propose := func(items ...pl.Payload) qbft.Message {
+		blk := pl.StandardBlock{Header: pl.BlockHeader{}, Items: items}
+		raw, err := wire.EncodeBlock(blk)
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		return qbft.Message{Type: qbft.MsgPreprepare, From: leader, ID: blockID(blk), Payload: raw}
+	}
+	good := signedTx(1, 0, 1)
+	forged := signedTx(2, 0, 1)
+	forged.Fee = 9 // no longer what the sender signed
+	inner, _ := forged.MarshalBinary()
+	forgedBundle := &bundle.BundleTx{Bid: 1, Txs: [][]byte{inner}}
+	forgedBundle.Sign(pl.DefaultChainID, testKey(3)) // the searcher's signature is valid
+
+	cases := map[string]qbft.Message{
+		"undecodable":      {Type: qbft.MsgPreprepare, From: leader, ID: "x", Payload: []byte("{")},
+		"wrong id":         func() qbft.Message { m := propose(good); m.ID = "blk-0"; return m }(),
+		"invalid tx":       propose(&pt.PlaintextTx{From: "A", Gas: 1, Sig: []byte{1}}),
+		"duplicate":        propose(good, good),
+		"bad order":        propose(signedTx(1, 0, 1), signedTx(2, 0, 9)),
+		"forged sig":       propose(forged),
+		"forged bundle tx": propose(forgedBundle),
+	}
+	for name, msg := range cases {
+		s, b, bc := votingNode(t, ctx, vs, follower)
+		s.SetBuilderPolicy(pl.BuilderPolicy{Order: []string{"bundle_v1", "plaintext_v1"}, MaxN: 8})
+		deliver(ctx, s, b, msg)
+		if prs := bc.ofType(qbft.MsgPrepare); len(prs) != 0 {
+			t.Fatalf("%s: follower prepared an invalid proposal", name)
+		}
+	}
+
+	s, b, bc := votingNode(t, ctx, vs, follower)
+	msg := propose(good)
+	deliver(ctx, s, b, msg)
+	prs := bc.ofType(qbft.MsgPrepare)
+	if len(prs) != 1 || prs[0].ID != msg.ID || prs[0].From != follower {
+		t.Fatalf("follower prepare: %+v", prs)
+	}
+	if blk, ok := s.lastBlock[0][0]; !ok || len(blk.Items) != 1 || blk.Stats.TotalFees != 1 {
+		t.Fatalf("validated block not kept for commit: %+v", blk)
+	}
+}
@@ -0,0 +1,154 @@
+package consensus
+
+import (
+	"context"
+	"crypto/ed25519"
+	"strings"
+	"testing"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	reconfig "github.com/zmlAEQ/Aequa-network/internal/payload/reconfig_v1"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/pkg/bus"
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+const testEpoch = qbft.MinEpochLength
+
+// signedSet returns a four-node set with ed25519 keys.
+func signedSet(t *testing.T) (*qbft.ValidatorSet, map[string]ed25519.PrivateKey) {
+	t.Helper()
+	ids := []string{"n0", "n1", "n2", "n3"}
+	vs := qbft.NewValidatorSet(ids, 0)
+	keys := map[string]ed25519.PrivateKey{}
+	for _, id := range ids {
+		pk, sk, _ := ed25519.GenerateKey(nil)
+		vs.SetPubKey(id, pk)
+		keys[id] = sk
+	}
+	return vs, keys
+}
+
+func removeN3(from string, epoch uint64, key ed25519.PrivateKey) *reconfig.ReconfigTx {
+	tx := &reconfig.ReconfigTx{From: from, Epoch: epoch, Op: reconfig.OpRemove, Operator: "n3"}
+	tx.Sign(key)
+	return tx
+}
+
+// commitItems stores a block of items at height h.
+func commitItems(t *testing.T, bs state.BlockStore, h uint64, items ...pl.Payload) {
+	t.Helper()
+	blk := pl.StandardBlock{Header: pl.BlockHeader{Height: h}, Items: items}
+	raw, err := wire.EncodeBlock(blk)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if err := bs.SaveBlock(context.Background(), state.BlockRecord{Height: h, ID: "b", Hash: blk.Hash(), Block: raw}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+}
+
+func TestReconfig_QuorumSchedulesChangeTwoEpochsAhead(t *testing.T) {
+	ctx := context.Background()
+	vs, keys := signedSet(t)
+	ep := qbft.NewEpochs(testEpoch, vs)
+	s := New()
+	s.SetEpochs(ep)
+	s.blocks = state.NewMemoryBlockStore()
+
+	commitItems(t, s.blocks, 1, removeN3("n0", 0, keys["n0"]))
+	// A repeated endorsement of the same operator counts once.
+	commitItems(t, s.blocks, 2, removeN3("n1", 0, keys["n1"]), removeN3("n0", 0, keys["n0"]))
+	s.applyReconfig(ctx, 2)
+	if !ep.At(10 * testEpoch).Contains("n3") {
+		t.Fatalf("change scheduled below quorum")
+	}
+	commitItems(t, s.blocks, 3, removeN3("n2", 0, keys["n2"]))
+	s.applyReconfig(ctx, 3)
+	if !ep.At(2*testEpoch - 1).Contains("n3") {
+		t.Fatalf("change in force before its boundary")
+	}
+	if next := ep.At(2 * testEpoch); next.Contains("n3") || next.Size() != 3 {
+		t.Fatalf("set from %d: %v", 2*testEpoch, next.IDs())
+	}
+}
+
+func TestReconfig_IgnoresInvalidEndorsements(t *testing.T) {
+	metrics.Reset()
+	ctx := context.Background()
+	vs, keys := signedSet(t)
+	_, stranger, _ := ed25519.GenerateKey(nil)
+	ep := qbft.NewEpochs(testEpoch, vs)
+	s := New()
+	s.SetEpochs(ep)
+	s.blocks = state.NewMemoryBlockStore()
+
+	commitItems(t, s.blocks, 1,
+		removeN3("n0", 0, keys["n0"]),
+		removeN3("x", 0, stranger),    // not a member
+		removeN3("n1", 0, stranger),   // not n1's key
+		removeN3("n2", 1, keys["n2"]), // endorses the next epoch
+	)
+	// Endorsements of epoch 0 no longer count in epoch 1.
+	commitItems(t, s.blocks, testEpoch, removeN3("n1", 0, keys["n1"]), removeN3("n2", 0, keys["n2"]))
+	s.applyReconfig(ctx, testEpoch)
+	if !ep.At(10 * testEpoch).Contains("n3") {
+		t.Fatalf("change scheduled from invalid endorsements")
+	}
+	dump := metrics.DumpProm()
+	for _, want := range []string{
+		`consensus_reconfig_total{result="unauthorized"} 1`,
+		`consensus_reconfig_total{result="bad_sig"} 1`,
+		`consensus_reconfig_total{result="stale"} 3`,
+		`consensus_reconfig_total{result="endorsed"} 1`,
+	} {
+		if !strings.Contains(dump, want) {
+			t.Fatalf("missing %s in %s", want, dump)
+		}
+	}
+}
+
+// A restarted node rebuilds the schedule from its stored blocks and hands it
+// to the verifier and the processor; the builder includes endorsements.
+func TestReconfig_StartReplaysStoredBlocks(t *testing.T) {
+	vs, keys := signedSet(t)
+	blocks := state.NewMemoryBlockStore()
+	for i, id := range []string{"n0", "n1", "n2"} {
+		commitItems(t, blocks, uint64(i+1), removeN3(id, 0, keys[id]))
+	}
+
+	ep := qbft.NewEpochs(testEpoch, vs)
+	var active []uint64
+	ep.OnActivate(func(from uint64, _ *qbft.ValidatorSet) { active = append(active, from) })
+	b := bus.New(4)
+	s := NewWithSub(b.Subscribe())
+	s.SetBlockStore(blocks)
+	s.SetEpochs(ep)
+	s.SetProcessor(qbft.NewManager(vs, "n0"))
+	s.SetVerifier(qbft.NewBasicVerifierWithPolicy(qbft.Policy{Validators: vs}))
+	s.enableBuilder = true
+	s.SetManualDrive(true)
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if ep.At(2 * testEpoch).Contains("n3") {
+		t.Fatalf("schedule not rebuilt from stored blocks")
+	}
+	if len(active) != 1 || active[0] != 0 {
+		t.Fatalf("activated %v", active)
+	}
+	sign := func(id string, h uint64) qbft.Message {
+		return qbft.NewEd25519Signer(id, keys[id]).Sign(qbft.Message{ID: "b", From: id, Type: qbft.MsgPrepare, Height: h})
+	}
+	if err := s.v.Verify(sign("n3", 2*testEpoch-1)); err != nil {
+		t.Fatalf("n3 rejected before the change: %v", err)
+	}
+	if err := s.v.Verify(sign("n3", 2*testEpoch)); err == nil {
+		t.Fatalf("verifier still admits n3 after the change")
+	}
+	if s.policy.Order[0] != reconfig.Type {
+		t.Fatalf("builder order %v", s.policy.Order)
+	}
+}
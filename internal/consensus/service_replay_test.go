@@ -0,0 +1,71 @@
+package consensus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/pkg/bus"
+)
+
+// Commits prune the verifier's replay cache and checkpoint it next to the
+// last state; a restarted node reloads it and still rejects the messages it
+// verified before the restart.
+func TestService_ReplayCheckpoint_SurvivesRestart(t *testing.T) {
+	vs, keys := signedSet(t)
+	sign := func(m qbft.Message) qbft.Message { return qbft.NewEd25519Signer(m.From, keys[m.From]).Sign(m) }
+	store := state.NewMemoryStore()
+	policy := qbft.Policy{Validators: vs, ReplayKeep: 1}
+
+	b := bus.New(64)
+	s := NewWithSub(b.Subscribe())
+	s.SetStore(store)
+	s.SetVerifier(qbft.NewBasicVerifierWithPolicy(policy))
+	s.SetProcessor(qbft.NewManager(vs, "n0"))
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	for h := uint64(0); h < 4; h++ {
+		id := "blk-" + string(rune('a'+h))
+		msgs := []qbft.Message{{Type: qbft.MsgPreprepare, From: vs.Proposer(h, 0), Height: h, ID: id}}
+		for _, typ := range []qbft.Type{qbft.MsgPrepare, qbft.MsgCommit} {
+			for _, from := range []string{"n1", "n2", "n3"} {
+				msgs = append(msgs, qbft.Message{Type: typ, From: from, Height: h, ID: id})
+			}
+		}
+		for _, m := range msgs {
+			b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Body: sign(m), Height: h})
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	cp, err := store.LoadReplay(context.Background())
+	if err != nil {
+		t.Fatalf("no checkpoint: %v", err)
+	}
+	if cp.Floor != 2 {
+		t.Fatalf("checkpoint floor %d, want 2 (committed 3, keep 1)", cp.Floor)
+	}
+
+	v := qbft.NewBasicVerifierWithPolicy(policy)
+	s2 := NewWithSub(bus.New(4).Subscribe())
+	s2.SetStore(store)
+	s2.SetVerifier(v)
+	s2.SetManualDrive(true)
+	if err := s2.Start(context.Background()); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	if err := v.Verify(sign(qbft.Message{Type: qbft.MsgCommit, From: "n1", Height: 3, ID: "blk-d"})); err == nil {
+		t.Fatalf("replay of a pre-restart commit accepted")
+	}
+	if err := v.Verify(sign(qbft.Message{Type: qbft.MsgPrepare, From: "n1", Height: 1, ID: "blk-b"})); err == nil {
+		t.Fatalf("message below the checkpoint floor accepted")
+	}
+	if err := v.Verify(sign(qbft.Message{Type: qbft.MsgPrepare, From: "n1", Height: 4, ID: "blk-e"})); err != nil {
+		t.Fatalf("fresh message rejected: %v", err)
+	}
+}
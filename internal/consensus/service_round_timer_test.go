@@ -0,0 +1,145 @@
+package consensus
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/pkg/bus"
+)
+
+type recBroadcaster struct {
+	mu   sync.Mutex
+	msgs []qbft.Message
+}
+
+func (r *recBroadcaster) BroadcastQBFT(_ context.Context, msg qbft.Message) error {
+	r.mu.Lock()
+	r.msgs = append(r.msgs, msg)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *recBroadcaster) ofType(t qbft.Type) []qbft.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []qbft.Message
+	for _, m := range r.msgs {
+		if m.Type == t {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func TestRoundTimer_BackoffDoublesAndCaps(t *testing.T) {
+	rt := newRoundTimer(100*time.Millisecond, time.Second)
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for r, w := range want {
+		if got := rt.timeout(uint64(r)); got != w*time.Millisecond {
+			t.Fatalf("round %d: got %v want %v", r, got, w*time.Millisecond)
+		}
+	}
+	if got := rt.timeout(1 << 40); got != time.Second {
+		t.Fatalf("huge round must cap: %v", got)
+	}
+}
+
+// A proposal that never reaches prepared makes the node time out, broadcast a
+// view-change for the next round and escalate on further timeouts.
+func TestService_RoundTimer_BroadcastsViewChange(t *testing.T) {
+	b := bus.New(8)
+	s := NewWithSub(b.Subscribe())
+	st := &qbft.State{Self: "n0"}
+	s.SetProcessor(st)
+	s.SetVerifier(okVerifier{})
+	bc := &recBroadcaster{}
+	s.SetBroadcaster(bc)
+	s.SetRoundTimeout(20*time.Millisecond, 40*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	b.Publish(ctx, bus.Event{Kind: bus.KindDuty, Height: 3, TraceID: "rt"})
+	time.Sleep(150 * time.Millisecond)
+
+	vcs := bc.ofType(qbft.MsgViewChange)
+	if len(vcs) < 2 {
+		t.Fatalf("want at least two view-changes, got %d", len(vcs))
+	}
+	if vcs[0].From != "n0" || vcs[0].Height != 3 || vcs[0].Round != 1 || vcs[1].Round != 2 {
+		t.Fatalf("unexpected view-changes: %+v", vcs[:2])
+	}
+}
+
+// No timer runs before any instance is active, and it is disarmed on commit.
+func TestService_RoundTimer_IdleAndCommittedDoNotFire(t *testing.T) {
+	b := bus.New(8)
+	s := NewWithSub(b.Subscribe())
+	st := &qbft.State{}
+	s.SetProcessor(st)
+	s.SetVerifier(okVerifier{})
+	bc := &recBroadcaster{}
+	s.SetBroadcaster(bc)
+	s.SetRoundTimeout(20*time.Millisecond, 20*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if n := len(bc.ofType(qbft.MsgViewChange)); n != 0 {
+		t.Fatalf("idle node must not time out, got %d view-changes", n)
+	}
+	for _, m := range []qbft.Message{
+		{ID: "blk", From: "L", Type: qbft.MsgPreprepare, Height: 4},
+		{ID: "blk", From: "P1", Type: qbft.MsgPrepare, Height: 4, Round: 1},
+		{ID: "blk", From: "P2", Type: qbft.MsgPrepare, Height: 4, Round: 1},
+		{ID: "blk", From: "C1", Type: qbft.MsgCommit, Height: 4, Round: 1},
+	} {
+		b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Body: m})
+	}
+	time.Sleep(80 * time.Millisecond)
+	if n := len(bc.ofType(qbft.MsgViewChange)); n != 0 {
+		t.Fatalf("committed instance must not time out, got %d view-changes", n)
+	}
+}
+
+// After a round-change quorum, the proposer of the new round broadcasts a
+// NEW_VIEW justified by the collected round-changes.
+func TestService_RoundChange_ProposerBroadcastsNewView(t *testing.T) {
+	b := bus.New(8)
+	s := NewWithSub(b.Subscribe())
+	vs := qbft.NewValidatorSet([]string{"n0", "n1", "n2", "n3"}, 0)
+	st := &qbft.State{Validators: vs, Self: "n2"} // proposer of (1,1)
+	s.SetProcessor(st)
+	s.SetVerifier(okVerifier{})
+	bc := &recBroadcaster{}
+	s.SetBroadcaster(bc)
+	s.SetRoundTimeout(20*time.Millisecond, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Body: qbft.Message{ID: "v", From: "n1", Type: qbft.MsgPreprepare, Height: 1}})
+	time.Sleep(35 * time.Millisecond) // own timeout -> round-change for round 1
+	for _, from := range []string{"n0", "n3"} {
+		b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Body: qbft.Message{ID: "rc-" + from, From: from, Type: qbft.MsgViewChange, Height: 1, Round: 1}})
+	}
+	time.Sleep(15 * time.Millisecond)
+
+	nvs := bc.ofType(qbft.MsgNewView)
+	if len(nvs) != 1 {
+		t.Fatalf("want one new_view, got %d", len(nvs))
+	}
+	if nvs[0].From != "n2" || nvs[0].Round != 1 || nvs[0].ID != "v" || len(nvs[0].Justification) != 3 {
+		t.Fatalf("unexpected new_view: %+v", nvs[0])
+	}
+}
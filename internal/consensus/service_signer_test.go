@@ -0,0 +1,46 @@
+package consensus
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/pkg/bus"
+)
+
+// Locally generated round-changes are signed with the node key and pass a
+// verifier that knows the registered public key.
+func TestService_SignsLocalRoundChange(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	seed[0] = 9
+	key := ed25519.NewKeyFromSeed(seed)
+	vs := qbft.NewValidatorSet([]string{"n0", "n1", "n2", "n3"}, 0)
+	vs.SetPubKey("n0", key.Public().(ed25519.PublicKey))
+
+	b := bus.New(8)
+	s := NewWithSub(b.Subscribe())
+	s.SetProcessor(&qbft.State{Validators: vs, Self: "n0"})
+	s.SetVerifier(okVerifier{})
+	s.SetMessageSigner(qbft.NewEd25519Signer("n0", key))
+	bc := &recBroadcaster{}
+	s.SetBroadcaster(bc)
+	s.SetRoundTimeout(20*time.Millisecond, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Body: qbft.Message{ID: "v", From: "n1", Type: qbft.MsgPreprepare, Height: 1}})
+	time.Sleep(35 * time.Millisecond)
+
+	vcs := bc.ofType(qbft.MsgViewChange)
+	if len(vcs) == 0 {
+		t.Fatalf("expected a round-change")
+	}
+	if err := qbft.NewBasicVerifierWithPolicy(qbft.Policy{Validators: vs}).Verify(vcs[0]); err != nil {
+		t.Fatalf("signed round-change must verify: %v", err)
+	}
+}
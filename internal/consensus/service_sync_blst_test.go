@@ -0,0 +1,86 @@
+//go:build blst
+
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	blst "github.com/supranational/blst/bindings/go"
+	"github.com/zmlAEQ/Aequa-network/internal/consensus/hotstuff"
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/p2p"
+	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/internal/tss/core/bls381"
+)
+
+// blsSchemes returns the HotStuff BLS scheme of each of n0..n3.
+func blsSchemes() map[string]*hotstuff.BLS {
+	ids := []string{"n0", "n1", "n2", "n3"}
+	secrets := map[string]bls381.Scalar{}
+	keys := map[string]bls381.PubKey{}
+	for _, id := range ids {
+		sk := blst.KeyGen([]byte(fmt.Sprintf("consensus-sync-test-ikm-%s-0123456789abcdef", id)))
+		secrets[id] = sk.Serialize()
+		keys[id] = new(blst.P1Affine).From(sk).Compress()
+	}
+	out := map[string]*hotstuff.BLS{}
+	for _, id := range ids {
+		out[id] = hotstuff.NewBLS(secrets[id], keys)
+	}
+	return out
+}
+
+// blsRecord builds the stored form of an empty block committed at h in view
+// h, sealed by the aggregated QC of n0..n2 for certified (the block id
+// unless a test forges it).
+func blsRecord(t *testing.T, schemes map[string]*hotstuff.BLS, vs *qbft.ValidatorSet, h uint64, certified string) state.BlockRecord {
+	t.Helper()
+	blk := pl.StandardBlock{Header: pl.BlockHeader{Height: h, Round: h}}
+	raw, err := wire.EncodeBlock(blk)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	var votes []qbft.Message
+	for _, from := range []string{"n0", "n1", "n2"} {
+		v := qbft.Message{Type: qbft.MsgHSVote, From: from, Height: h, Round: h, ID: certified}
+		if v.Share, err = schemes[from].Share(v); err != nil {
+			t.Fatalf("share: %v", err)
+		}
+		votes = append(votes, v)
+	}
+	qc, err := schemes["n0"].Aggregate(vs, votes)
+	if err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	return state.BlockRecord{Height: h, Round: h, ID: fmt.Sprintf("blk-%d", h), Hash: blk.Hash(), Block: raw,
+		AggSeal: &state.AggSeal{Signers: qc.Signers, Sig: qc.Agg}}
+}
+
+// A HotStuff node with the BLS scheme syncs blocks sealed by aggregated
+// QCs and skips a peer whose aggregate certifies another block.
+func TestService_Sync_BLSAggregatedSeal(t *testing.T) {
+	_, vs := syncCluster()
+	schemes := blsSchemes()
+	net := p2p.NewMemSyncNetwork()
+	servingPeer(net, "n1", blsRecord(t, schemes, vs, 1, "blk-other"))
+	good := blsRecord(t, schemes, vs, 1, "blk-1")
+	servingPeer(net, "n2", good, blsRecord(t, schemes, vs, 2, "blk-2"))
+
+	s := New()
+	s.SetProcessor(hotstuff.New(vs, "n0", schemes["n0"]))
+	s.SetBlockStore(state.NewMemoryBlockStore())
+	s.SetStore(state.NewMemoryStore())
+	s.SetBlockSync(net.Join("n0"), vs, 0)
+	if last, ok := s.catchUp(context.Background()); !ok || last != 2 {
+		t.Fatalf("want synced to 2, got %d ok=%v", last, ok)
+	}
+	rec, _ := s.blocks.BlockByHeight(context.Background(), 1)
+	if rec.AggSeal == nil || !bytes.Equal(rec.AggSeal.Sig, good.AggSeal.Sig) {
+		t.Fatalf("stored %+v", rec)
+	}
+}
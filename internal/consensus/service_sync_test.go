@@ -0,0 +1,267 @@
+package consensus
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"testing"
+	"time"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/p2p"
+	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/pkg/bus"
+)
+
+// syncCluster returns signers for n0..n3 and the keyed validator set.
+func syncCluster() (map[string]*qbft.Ed25519Signer, *qbft.ValidatorSet) {
+	ids := []string{"n0", "n1", "n2", "n3"}
+	vs := qbft.NewValidatorSet(ids, 0)
+	signers := map[string]*qbft.Ed25519Signer{}
+	for i, id := range ids {
+		seed := make([]byte, ed25519.SeedSize)
+		seed[0] = byte(i + 1)
+		key := ed25519.NewKeyFromSeed(seed)
+		signers[id] = qbft.NewEd25519Signer(id, key)
+		vs.SetPubKey(id, key.Public().(ed25519.PublicKey))
+	}
+	return signers, vs
+}
+
+// sealedRecord builds the stored form of a block of items (none by default)
+// committed at h by n0..n2.
+func sealedRecord(t *testing.T, signers map[string]*qbft.Ed25519Signer, h uint64, items ...pl.Payload) state.BlockRecord {
+	t.Helper()
+	blk := pl.StandardBlock{Header: pl.BlockHeader{Height: h}, Items: items}
+	raw, err := wire.EncodeBlock(blk)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	id := fmt.Sprintf("blk-%d", h)
+	rec := state.BlockRecord{Height: h, ID: id, Hash: blk.Hash(), Block: raw}
+	for _, from := range []string{"n0", "n1", "n2"} {
+		m := signers[from].Sign(qbft.Message{Type: qbft.MsgCommit, Height: h, ID: id})
+		rec.Seal = append(rec.Seal, state.CommitSig{From: m.From, Sig: m.Sig})
+	}
+	return rec
+}
+
+// waitHeight polls blocks until height h is stored or the deadline passes.
+func waitHeight(blocks state.BlockStore, h uint64, d time.Duration) {
+	for end := time.Now().Add(d); time.Now().Before(end); time.Sleep(5 * time.Millisecond) {
+		if _, err := blocks.BlockByHeight(context.Background(), h); err == nil {
+			return
+		}
+	}
+}
+
+// servingPeer joins net as id and serves the given blocks.
+func servingPeer(net *p2p.MemSyncNetwork, id string, recs ...state.BlockRecord) {
+	src := New()
+	src.SetBlockStore(state.NewMemoryBlockStore())
+	for _, rec := range recs {
+		_ = src.blocks.SaveBlock(context.Background(), rec)
+	}
+	net.Join(id).OnSyncRequest(src.ServeBlocks)
+}
+
+func TestService_Sync_CatchesUpOnStartAndRejoins(t *testing.T) {
+	signers, vs := syncCluster()
+	net := p2p.NewMemSyncNetwork()
+	var recs []state.BlockRecord
+	for h := uint64(1); h <= 70; h++ { // more than one response batch
+		recs = append(recs, sealedRecord(t, signers, h))
+	}
+	servingPeer(net, "n1", recs...)
+
+	b := bus.New(32)
+	s := NewWithSub(b.Subscribe())
+	mgr := qbft.NewManager(vs, "n0")
+	s.SetProcessor(mgr)
+	s.SetVerifier(okVerifier{})
+	blocks := state.NewMemoryBlockStore()
+	_ = blocks.SaveBlock(context.Background(), recs[0])
+	s.SetBlockStore(blocks)
+	s.SetBlockSync(net.Join("n0"), vs, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	waitHeight(blocks, 70, 2*time.Second)
+
+	last, err := blocks.LastBlock(ctx)
+	if err != nil || last.Height != 70 {
+		t.Fatalf("want synced up to 70, got %+v err=%v", last, err)
+	}
+	// The node rejoins live consensus at the first height it lacks.
+	publishHeight(ctx, b, vs, 71)
+	waitHeight(blocks, 71, time.Second)
+	if _, err := blocks.BlockByHeight(ctx, 71); err != nil {
+		t.Fatalf("height 71 not committed after sync: %v", err)
+	}
+	if ls, err := s.store.LoadLastState(ctx); err != nil || ls.Height < 71 {
+		t.Fatalf("last state not advanced: %+v err=%v", ls, err)
+	}
+}
+
+// A peer serving a block with a forged seal is skipped; the node syncs from
+// an honest peer instead.
+func TestService_Sync_RejectsInvalidSealAndSwitchesPeer(t *testing.T) {
+	signers, vs := syncCluster()
+	net := p2p.NewMemSyncNetwork()
+	forged := sealedRecord(t, signers, 1)
+	forged.Seal[2].Sig = append([]byte(nil), forged.Seal[1].Sig...)
+	servingPeer(net, "n1", forged)
+	servingPeer(net, "n2", sealedRecord(t, signers, 1), sealedRecord(t, signers, 2))
+
+	s := New()
+	s.SetBlockStore(state.NewMemoryBlockStore())
+	s.SetStore(state.NewMemoryStore())
+	s.SetBlockSync(net.Join("n0"), vs, 0)
+	last, ok := s.catchUp(context.Background())
+	if !ok || last != 2 {
+		t.Fatalf("want synced to 2, got %d ok=%v", last, ok)
+	}
+	rec, _ := s.blocks.BlockByHeight(context.Background(), 1)
+	if len(rec.Seal) != 3 || string(rec.Seal[2].Sig) == string(rec.Seal[1].Sig) {
+		t.Fatalf("forged block stored: %+v", rec)
+	}
+}
+
+// A sealed block is still refused when one of its txs is not signed by its
+// sender; the node syncs the height from another peer.
+func TestService_Sync_RejectsForgedTxSignature(t *testing.T) {
+	signers, vs := syncCluster()
+	net := p2p.NewMemSyncNetwork()
+	good := signedTx(1, 0, 1)
+	forged := signedTx(1, 0, 1)
+	forged.Fee = 7
+	servingPeer(net, "n1", sealedRecord(t, signers, 1, forged))
+	servingPeer(net, "n2", sealedRecord(t, signers, 1, good))
+
+	s := New()
+	s.SetBlockStore(state.NewMemoryBlockStore())
+	s.SetStore(state.NewMemoryStore())
+	s.SetBlockSync(net.Join("n0"), vs, 0)
+	if last, ok := s.catchUp(context.Background()); !ok || last != 1 {
+		t.Fatalf("want synced to 1, got %d ok=%v", last, ok)
+	}
+	rec, _ := s.blocks.BlockByHeight(context.Background(), 1)
+	blk, err := wire.DecodeBlock(rec.Block)
+	if err != nil || len(blk.Items) != 1 || blk.Items[0].SortKey() != 1 {
+		t.Fatalf("stored block %+v err=%v", blk, err)
+	}
+}
+
+// A verified message far ahead of the active height triggers a sync.
+func TestService_Sync_TriggeredWhenBehind(t *testing.T) {
+	signers, vs := syncCluster()
+	net := p2p.NewMemSyncNetwork()
+	servingPeer(net, "n1")
+
+	b := bus.New(32)
+	s := NewWithSub(b.Subscribe())
+	s.SetProcessor(qbft.NewManager(vs, "n0"))
+	s.SetVerifier(okVerifier{})
+	blocks := state.NewMemoryBlockStore()
+	s.SetBlockStore(blocks)
+	s.SetBlockSync(net.Join("n0"), vs, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	publishHeight(ctx, b, vs, 1)
+	time.Sleep(30 * time.Millisecond)
+	// The peer has committed 2 and 3 meanwhile; traffic for 4 shows we lag.
+	servingPeer(net, "n1", sealedRecord(t, signers, 2), sealedRecord(t, signers, 3))
+	b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Body: qbft.Message{Type: qbft.MsgPrepare, From: "n1", Height: 4, ID: "blk-4"}})
+	waitHeight(blocks, 3, time.Second)
+	time.Sleep(20 * time.Millisecond) // let the loop apply the synced range
+	if last, err := blocks.LastBlock(ctx); err != nil || last.Height != 3 {
+		t.Fatalf("want synced to 3, got %+v err=%v", last, err)
+	}
+	// Height 4 proceeds right away: the instance restarted after the synced range.
+	publishHeight(ctx, b, vs, 4)
+	waitHeight(blocks, 4, time.Second)
+	if _, err := blocks.BlockByHeight(ctx, 4); err != nil {
+		t.Fatalf("height 4 not committed after sync: %v", err)
+	}
+}
+
+func TestService_ServeBlocks_Limits(t *testing.T) {
+	signers, _ := syncCluster()
+	s := New()
+	s.SetBlockStore(state.NewMemoryBlockStore())
+	if resp := s.ServeBlocks(context.Background(), wire.SyncRequest{From: 1}); resp.HasLast || len(resp.Blocks) != 0 {
+		t.Fatalf("empty store must serve nothing: %+v", resp)
+	}
+	for h := uint64(1); h <= 5; h++ {
+		_ = s.blocks.SaveBlock(context.Background(), sealedRecord(t, signers, h))
+	}
+	resp := s.ServeBlocks(context.Background(), wire.SyncRequest{From: 2, Limit: 2})
+	if !resp.HasLast || resp.Last != 5 || len(resp.Blocks) != 2 || resp.Blocks[0].Height != 2 || resp.Blocks[1].Height != 3 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+// aggProcessor is a processor whose committed block at height h is sealed
+// by one aggregated signature, "agg-<id>" from n0..n2.
+type aggProcessor struct{ h uint64 }
+
+func (aggProcessor) Process(qbft.Message) error { return nil }
+
+func (p aggProcessor) CommitSeal() (uint64, string, []byte, []qbft.Message, bool) {
+	return 1, fmt.Sprintf("blk-%d", p.h), nil, nil, true
+}
+
+func (p aggProcessor) CommitAggSeal() ([]string, []byte, bool) {
+	return []string{"n0", "n1", "n2"}, []byte(fmt.Sprintf("agg-blk-%d", p.h)), true
+}
+
+func (aggProcessor) VerifyAggSeal(vs *qbft.ValidatorSet, height, round uint64, id string, signers []string, agg []byte) error {
+	if len(signers) < vs.Quorum() || round != 1 || string(agg) != "agg-"+id {
+		return fmt.Errorf("bad aggregate")
+	}
+	return nil
+}
+
+// A block sealed by an aggregated signature is stored with it and synced by
+// peers verifying it through their processor; a processor that cannot
+// verify aggregated seals refuses it.
+func TestService_Sync_AggregatedSeal(t *testing.T) {
+	_, vs := syncCluster()
+	src := New()
+	src.SetProcessor(aggProcessor{h: 1})
+	src.SetBlockStore(state.NewMemoryBlockStore())
+	src.onCommit(context.Background(), 1)
+	rec, err := src.blocks.BlockByHeight(context.Background(), 1)
+	if err != nil || len(rec.Seal) != 0 || rec.AggSeal == nil || string(rec.AggSeal.Sig) != "agg-blk-1" {
+		t.Fatalf("stored %+v err=%v", rec, err)
+	}
+	net := p2p.NewMemSyncNetwork()
+	forged := rec
+	forged.AggSeal = &state.AggSeal{Signers: rec.AggSeal.Signers, Sig: []byte("agg-other")}
+	servingPeer(net, "n1", forged)
+	servingPeer(net, "n2", rec)
+
+	s := New()
+	s.SetProcessor(aggProcessor{})
+	s.SetBlockStore(state.NewMemoryBlockStore())
+	s.SetStore(state.NewMemoryStore())
+	s.SetBlockSync(net.Join("n0"), vs, 0)
+	if last, ok := s.catchUp(context.Background()); !ok || last != 1 {
+		t.Fatalf("want synced to 1, got %d ok=%v", last, ok)
+	}
+	if got, _ := s.blocks.BlockByHeight(context.Background(), 1); got.AggSeal == nil || string(got.AggSeal.Sig) != "agg-blk-1" {
+		t.Fatalf("synced %+v", got)
+	}
+	if err := New().verifySynced(rec); err == nil {
+		t.Fatalf("aggregated seal accepted without a verifying processor")
+	}
+}
@@ -2,6 +2,7 @@ package consensus
 
 import (
 	"context"
+	"crypto/ed25519"
 	"path/filepath"
 	"sync/atomic"
 	"testing"
@@ -55,3 +56,59 @@ func TestService_WAL_Guard_DropsOlderIntents(t *testing.T) {
 		t.Fatalf("older intent should be dropped, got saves=%d", st.saves)
 	}
 }
+
+// A received vote conflicting with its sender's first one at the same
+// coordinates is recorded as evidence and neither processed nor
+// re-broadcast. Peers' votes are not written to the WAL.
+func TestService_WAL_Guard_RefusesConflictingVote(t *testing.T) {
+	w := qbft.NewWAL(filepath.Join(t.TempDir(), "wal"))
+	pool, _ := qbft.NewEvidencePool("")
+	b := bus.New(4)
+	s := NewWithSub(b.Subscribe())
+	st := &guardStore{}
+	s.SetStore(st)
+	s.SetVerifier(allowAllVerifier{})
+	s.SetWAL(w)
+	s.SetEvidencePool(pool)
+	bc := &recBroadcaster{}
+	s.SetBroadcaster(bc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Body: qbft.Message{ID: id, From: "n1", Type: qbft.MsgCommit, Height: 2}})
+	}
+	time.Sleep(30 * time.Millisecond)
+	if got := atomic.LoadInt32(&st.saves); got != 1 {
+		t.Fatalf("only the first vote may be processed, saves=%d", got)
+	}
+	if got := bc.ofType(qbft.MsgCommit); len(got) != 1 || got[0].ID != "a" {
+		t.Fatalf("only the first vote may be broadcast, got %+v", got)
+	}
+	if evs := pool.List("n1", 0); len(evs) != 1 || evs[0].Second.ID != "b" {
+		t.Fatalf("evidence %+v", evs)
+	}
+	if last, err := w.LastIntent(); err == nil {
+		t.Fatalf("peer vote written to the WAL: %+v", last)
+	}
+}
+
+func TestService_SignVote_RefusesDoubleSign(t *testing.T) {
+	s := New()
+	s.SetWAL(qbft.NewWAL(filepath.Join(t.TempDir(), "wal")))
+	seed := make([]byte, ed25519.SeedSize)
+	s.SetMessageSigner(qbft.NewEd25519Signer("n0", ed25519.NewKeyFromSeed(seed)))
+	v, err := s.signVote(qbft.Message{Type: qbft.MsgPrepare, Height: 4, Round: 0, ID: "a"})
+	if err != nil || v.From != "n0" || len(v.Sig) == 0 {
+		t.Fatalf("first vote must be signed: %+v err=%v", v, err)
+	}
+	if _, err := s.signVote(qbft.Message{Type: qbft.MsgPrepare, Height: 4, Round: 0, ID: "b"}); err == nil {
+		t.Fatalf("conflicting vote at the same coordinates must be refused")
+	}
+	if _, err := s.signVote(qbft.Message{Type: qbft.MsgPrepare, Height: 4, Round: 1, ID: "b"}); err != nil {
+		t.Fatalf("next round is a new vote: %v", err)
+	}
+}
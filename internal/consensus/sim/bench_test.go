@@ -0,0 +1,39 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// benchHeights is how many heights each benchmark run finalizes.
+const benchHeights = 20
+
+// BenchmarkSim_Engines compares the engines on the same harness: messages
+// sent and virtual time per committed height, by cluster size.
+func BenchmarkSim_Engines(b *testing.B) {
+	for _, engine := range []string{"qbft", "hotstuff"} {
+		for _, n := range []int{4, 10, 16} {
+			b.Run(fmt.Sprintf("%s/n=%d", engine, n), func(b *testing.B) {
+				var sent int
+				var elapsed time.Duration
+				for i := 0; i < b.N; i++ {
+					s, err := New(context.Background(), Config{Nodes: n, Seed: int64(i + 1), Engine: engine,
+						Faults: Faults{MinDelay: 5 * time.Millisecond, MaxDelay: 20 * time.Millisecond}})
+					if err != nil {
+						b.Fatal(err)
+					}
+					if !s.RunUntil(benchHeights, time.Hour) {
+						b.Fatalf("%v", s.CheckLiveness(benchHeights))
+					}
+					sent += s.Stats().Sent
+					elapsed += s.Now()
+				}
+				runs := float64(b.N) * benchHeights
+				b.ReportMetric(float64(sent)/runs, "msgs/height")
+				b.ReportMetric(float64(elapsed.Milliseconds())/runs, "vms/height")
+			})
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package sim
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/zmlAEQ/Aequa-network/internal/consensus"
+)
+
+// epoch anchors virtual time for the time.Time values timers deliver.
+var epoch = time.Unix(0, 0).UTC()
+
+// event is a scheduled action at virtual time at; seq breaks ties in
+// scheduling order so runs are reproducible.
+type event struct {
+	at   time.Duration
+	seq  uint64
+	fire func()
+}
+
+type eventQueue []event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x any)   { *q = append(*q, x.(event)) }
+func (q *eventQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// scheduler is a discrete-event queue over virtual time.
+type scheduler struct {
+	now time.Duration
+	seq uint64
+	q   eventQueue
+}
+
+// after schedules fire at now+d.
+func (s *scheduler) after(d time.Duration, fire func()) {
+	s.seq++
+	heap.Push(&s.q, event{at: s.now + d, seq: s.seq, fire: fire})
+}
+
+// next pops the earliest event, advancing now; false when the queue is empty
+// or the event lies beyond limit.
+func (s *scheduler) next(limit time.Duration) bool {
+	if len(s.q) == 0 || s.q[0].at > limit {
+		return false
+	}
+	e := heap.Pop(&s.q).(event)
+	s.now = e.at
+	e.fire()
+	return true
+}
+
+// Clock is a consensus.Clock on the simulation's virtual time: timers fire
+// when the scheduler reaches their deadline, never on wall-clock time.
+type Clock struct{ s *scheduler }
+
+// Now returns the current virtual time.
+func (c Clock) Now() time.Time { return epoch.Add(c.s.now) }
+
+// NewTimer schedules a one-shot timer d ahead on virtual time.
+func (c Clock) NewTimer(d time.Duration) consensus.Timer {
+	t := &timer{c: make(chan time.Time, 1)}
+	c.s.after(d, func() {
+		if t.stopped {
+			return
+		}
+		t.stopped = true
+		t.c <- c.Now()
+	})
+	return t
+}
+
+type timer struct {
+	c       chan time.Time
+	stopped bool
+}
+
+func (t *timer) C() <-chan time.Time { return t.c }
+
+func (t *timer) Stop() bool {
+	was := !t.stopped
+	t.stopped = true
+	return was
+}
+
+var _ consensus.Clock = Clock{}
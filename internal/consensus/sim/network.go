@@ -0,0 +1,238 @@
+package sim
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"math/rand"
+	"time"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/p2p"
+	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
+	"github.com/zmlAEQ/Aequa-network/internal/payload"
+)
+
+// Faults configures per-delivery fault injection. Probabilities are in
+// [0,1] and drawn from the simulation seed, independently per recipient.
+type Faults struct {
+	Drop      float64 // delivery is lost
+	Duplicate float64 // delivery happens twice (independent delays)
+	Reorder   float64 // delivery is held back by ReorderDelay on top of its latency
+	// Latency is uniform in [MinDelay, MaxDelay].
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// ReorderDelay is the extra hold applied to reordered deliveries
+	// (default 2*MaxDelay).
+	ReorderDelay time.Duration
+}
+
+// Partition splits the network into Groups from virtual time From until
+// Until (exclusive; 0 means forever). Nodes in different groups cannot
+// reach each other; nodes not listed form one extra group together.
+type Partition struct {
+	From   time.Duration
+	Until  time.Duration
+	Groups [][]string
+}
+
+// Stats counts what the network did to deliveries.
+type Stats struct {
+	Sent        int
+	Delivered   int
+	Dropped     int
+	Partitioned int
+	Duplicated  int
+	Reordered   int
+}
+
+// network is an in-memory gossip mesh: a broadcast is delivered to every
+// other node after a seeded latency, subject to faults and partitions.
+type network struct {
+	sched      *scheduler
+	rng        *rand.Rand
+	faults     Faults
+	partitions []Partition
+	nodes      []*transport
+	stats      Stats
+	trace      hash.Hash
+}
+
+func newNetwork(sched *scheduler, seed int64, f Faults, parts []Partition) *network {
+	if f.MaxDelay < f.MinDelay {
+		f.MaxDelay = f.MinDelay
+	}
+	if f.ReorderDelay == 0 {
+		f.ReorderDelay = 2*f.MaxDelay + time.Millisecond
+	}
+	return &network{sched: sched, rng: rand.New(rand.NewSource(seed)), faults: f, partitions: parts, trace: sha256.New()}
+}
+
+func (n *network) join(id string) *transport {
+	t := &transport{id: id, net: n}
+	n.nodes = append(n.nodes, t)
+	return t
+}
+
+// group returns the partition group of id at the current time, or -1 when no
+// partition is active.
+func (n *network) group(id string) int {
+	for _, p := range n.partitions {
+		if n.sched.now < p.From || (p.Until > 0 && n.sched.now >= p.Until) {
+			continue
+		}
+		for i, g := range p.Groups {
+			for _, m := range g {
+				if m == id {
+					return i
+				}
+			}
+		}
+		return len(p.Groups)
+	}
+	return -1
+}
+
+func (n *network) latency() time.Duration {
+	d := n.faults.MinDelay
+	if span := n.faults.MaxDelay - n.faults.MinDelay; span > 0 {
+		d += time.Duration(n.rng.Int63n(int64(span) + 1))
+	}
+	return d
+}
+
+// send schedules delivery of msg from one node to every other node. Faults
+// are drawn in a fixed order per recipient so a seed fully determines a run.
+func (n *network) send(from string, msg qbft.Message) {
+	for _, to := range n.nodes {
+		if to.id != from {
+			n.post(from, to, msg)
+		}
+	}
+}
+
+// sendTo schedules delivery of msg to the single node id.
+func (n *network) sendTo(from, id string, msg qbft.Message) {
+	for _, to := range n.nodes {
+		if to.id == id && id != from {
+			n.post(from, to, msg)
+		}
+	}
+}
+
+// post schedules one delivery subject to partitions and faults.
+func (n *network) post(from string, to *transport, msg qbft.Message) {
+	n.stats.Sent++
+	if n.group(from) != n.group(to.id) {
+		n.stats.Partitioned++
+		return
+	}
+	if n.rng.Float64() < n.faults.Drop {
+		n.stats.Dropped++
+		return
+	}
+	copies := 1
+	if n.rng.Float64() < n.faults.Duplicate {
+		copies = 2
+		n.stats.Duplicated++
+	}
+	for i := 0; i < copies; i++ {
+		d := n.latency()
+		if n.rng.Float64() < n.faults.Reorder {
+			d += n.faults.ReorderDelay
+			n.stats.Reordered++
+		}
+		n.sched.after(d, func() { n.deliver(from, to, msg) })
+	}
+}
+
+// deliver hands msg to the recipient unless a partition started meanwhile.
+func (n *network) deliver(from string, to *transport, msg qbft.Message) {
+	if n.group(from) != n.group(to.id) {
+		n.stats.Partitioned++
+		return
+	}
+	n.stats.Delivered++
+	n.record(from, to.id, msg)
+	if to.onQBFT != nil {
+		to.onQBFT(msg)
+	}
+}
+
+// record folds a delivery into the run trace digest.
+func (n *network) record(from, to string, msg qbft.Message) {
+	var b [24]byte
+	binary.BigEndian.PutUint64(b[0:], uint64(n.sched.now))
+	binary.BigEndian.PutUint64(b[8:], msg.Height)
+	binary.BigEndian.PutUint64(b[16:], msg.Round)
+	n.trace.Write(b[:])
+	for _, s := range []string{from, to, string(msg.Type), msg.From, msg.ID} {
+		n.trace.Write([]byte(s))
+		n.trace.Write([]byte{0})
+	}
+}
+
+// transport is one node's endpoint; it implements p2p.Transport so nodes are
+// wired exactly like dvt-node wires a real transport.
+type transport struct {
+	id     string
+	net    *network
+	onQBFT func(qbft.Message)
+	onTx   func(payload.Payload)
+	serve  func(context.Context, wire.SyncRequest) wire.SyncResponse
+}
+
+func (t *transport) Start(context.Context) error { return nil }
+func (t *transport) Stop(context.Context) error  { return nil }
+
+func (t *transport) BroadcastQBFT(_ context.Context, msg qbft.Message) error {
+	t.net.send(t.id, msg)
+	return nil
+}
+
+// SendQBFT delivers msg to one peer (consensus.QbftUnicaster).
+func (t *transport) SendQBFT(_ context.Context, to string, msg qbft.Message) error {
+	t.net.sendTo(t.id, to, msg)
+	return nil
+}
+
+func (t *transport) BroadcastTx(context.Context, payload.Payload) error { return nil }
+
+func (t *transport) OnQBFT(fn func(qbft.Message))  { t.onQBFT = fn }
+func (t *transport) OnTx(fn func(payload.Payload)) { t.onTx = fn }
+
+// Peers returns the other nodes in join order.
+func (t *transport) Peers() []string {
+	out := make([]string, 0, len(t.net.nodes)-1)
+	for _, p := range t.net.nodes {
+		if p.id != t.id {
+			out = append(out, p.id)
+		}
+	}
+	return out
+}
+
+// RequestBlocks serves req from peer synchronously, unless a partition
+// separates the two nodes.
+func (t *transport) RequestBlocks(ctx context.Context, peer string, req wire.SyncRequest) (wire.SyncResponse, error) {
+	for _, p := range t.net.nodes {
+		if p.id != peer || p.serve == nil {
+			continue
+		}
+		if t.net.group(t.id) != t.net.group(peer) {
+			return wire.SyncResponse{}, p2p.ErrSyncUnavailable
+		}
+		return p.serve(ctx, req), nil
+	}
+	return wire.SyncResponse{}, p2p.ErrSyncUnavailable
+}
+
+func (t *transport) OnSyncRequest(fn func(context.Context, wire.SyncRequest) wire.SyncResponse) {
+	t.serve = fn
+}
+
+var (
+	_ p2p.Transport     = (*transport)(nil)
+	_ p2p.SyncTransport = (*transport)(nil)
+)
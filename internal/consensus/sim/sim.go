@@ -0,0 +1,241 @@
+// Package sim runs N consensus.Service instances in one process on virtual
+// time over an in-memory network with seeded fault injection (drop, delay,
+// duplicate, reorder, partition). Every node is wired like dvt-node with a
+// cluster lock: per-height manager (or the HotStuff engine), signing
+// verifier, ed25519 node keys and active voting, plus block sync between
+// peers. A run is fully determined by its Config, so a failing seed
+// can be kept as a regression test.
+package sim
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/zmlAEQ/Aequa-network/internal/consensus"
+	"github.com/zmlAEQ/Aequa-network/internal/consensus/hotstuff"
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/pkg/bus"
+)
+
+// Config describes one simulation run.
+type Config struct {
+	Nodes        int   // cluster size (default 4)
+	Seed         int64 // drives latency and all faults
+	Faults       Faults
+	Partitions   []Partition
+	RoundTimeout time.Duration // base QBFT round timeout (default 1s)
+	Engine       string        // "qbft" (default) or "hotstuff"
+}
+
+// Node is one simulated operator.
+type Node struct {
+	ID     string
+	svc    *consensus.Service
+	blocks state.BlockStore
+	top    uint64 // highest committed height
+	any    bool   // committed at least one height
+}
+
+// Committed returns the highest height this node committed, and false when
+// it committed none.
+func (n *Node) Committed() (uint64, bool) { return n.top, n.any }
+
+// commit is the first finalized block seen at a height, for safety checks.
+type commit struct {
+	node string
+	id   string
+	hash []byte
+}
+
+// Sim is a running simulation.
+type Sim struct {
+	cfg        Config
+	ctx        context.Context
+	sched      *scheduler
+	net        *network
+	nodes      []*Node
+	commits    map[uint64]commit
+	violations []string
+}
+
+// New builds and starts a simulated cluster. Nodes are n0..n{N-1}; their
+// keys derive from their index so runs are reproducible.
+func New(ctx context.Context, cfg Config) (*Sim, error) {
+	if cfg.Nodes <= 0 {
+		cfg.Nodes = 4
+	}
+	if cfg.RoundTimeout <= 0 {
+		cfg.RoundTimeout = time.Second
+	}
+	sched := &scheduler{}
+	s := &Sim{cfg: cfg, ctx: ctx, sched: sched, net: newNetwork(sched, cfg.Seed, cfg.Faults, cfg.Partitions), commits: map[uint64]commit{}}
+
+	ids := make([]string, cfg.Nodes)
+	keys := make([]ed25519.PrivateKey, cfg.Nodes)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("n%d", i)
+		seed := make([]byte, ed25519.SeedSize)
+		seed[0], seed[1] = byte(i+1), byte((i+1)>>8)
+		keys[i] = ed25519.NewKeyFromSeed(seed)
+	}
+	vs := qbft.NewValidatorSet(ids, 0)
+	for i, id := range ids {
+		vs.SetPubKey(id, keys[i].Public().(ed25519.PublicKey))
+	}
+	for i, id := range ids {
+		n := &Node{ID: id}
+		n.blocks = &observedStore{BlockStore: state.NewMemoryBlockStore(), sim: s, node: n}
+		b := bus.New(4096)
+		svc := consensus.NewWithSub(b.Subscribe())
+		svc.SetTxSub(b.SubscribeTx())
+		lag := uint64(0)
+		switch cfg.Engine {
+		case "", "qbft":
+			svc.SetProcessor(qbft.NewManager(vs, id))
+		case "hotstuff":
+			svc.SetProcessor(hotstuff.New(vs, id, nil))
+			lag = hotstuff.PipelineDepth + 2
+		default:
+			return nil, fmt.Errorf("unknown engine %q", cfg.Engine)
+		}
+		svc.SetVerifier(qbft.NewBasicVerifierWithPolicy(qbft.Policy{Validators: vs}))
+		svc.SetMessageSigner(qbft.NewEd25519Signer(id, keys[i]))
+		svc.SetStore(state.NewMemoryStore())
+		svc.SetBlockStore(n.blocks)
+		svc.SetRoundTimeout(cfg.RoundTimeout, 0)
+		svc.SetClock(Clock{s: sched})
+		svc.SetManualDrive(true)
+		svc.SetVoting(true)
+		t := s.net.join(id)
+		t.OnQBFT(func(m qbft.Message) {
+			b.Publish(ctx, bus.Event{Kind: bus.KindConsensus, Height: m.Height, Round: m.Round, Body: m, TraceID: m.TraceID})
+		})
+		svc.SetBroadcaster(t)
+		t.OnSyncRequest(svc.ServeBlocks)
+		svc.SetBlockSync(t, vs, lag)
+		n.svc = svc
+		s.nodes = append(s.nodes, n)
+	}
+	for _, n := range s.nodes {
+		if err := n.svc.Start(ctx); err != nil {
+			return nil, fmt.Errorf("start %s: %w", n.ID, err)
+		}
+	}
+	s.settle()
+	return s, nil
+}
+
+// settle steps every node, in index order, until none has pending work.
+func (s *Sim) settle() {
+	for busy := true; busy; {
+		busy = false
+		for _, n := range s.nodes {
+			for n.svc.Step(s.ctx) {
+				busy = true
+			}
+		}
+	}
+}
+
+// step fires the next scheduled event up to limit and lets nodes react.
+func (s *Sim) step(limit time.Duration) bool {
+	if !s.sched.next(limit) {
+		return false
+	}
+	s.settle()
+	return true
+}
+
+// RunFor advances virtual time by d.
+func (s *Sim) RunFor(d time.Duration) {
+	limit := s.sched.now + d
+	for s.step(limit) {
+	}
+	s.sched.now = limit
+}
+
+// RunUntil advances virtual time until every node committed height h or
+// limit of virtual time elapsed. It reports whether all nodes reached h.
+func (s *Sim) RunUntil(h uint64, limit time.Duration) bool {
+	end := s.sched.now + limit
+	for !s.allCommitted(h) {
+		if !s.step(end) {
+			s.sched.now = end
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Sim) allCommitted(h uint64) bool {
+	for _, n := range s.nodes {
+		if top, ok := n.Committed(); !ok || top < h {
+			return false
+		}
+	}
+	return true
+}
+
+// Now returns the elapsed virtual time.
+func (s *Sim) Now() time.Duration { return s.sched.now }
+
+// Nodes returns the simulated nodes in index order.
+func (s *Sim) Nodes() []*Node { return s.nodes }
+
+// Stats returns the network counters.
+func (s *Sim) Stats() Stats { return s.net.stats }
+
+// Digest returns a hash of every delivery so far (time, endpoints and
+// message coordinates). Equal configs must yield equal digests.
+func (s *Sim) Digest() string { return hex.EncodeToString(s.net.trace.Sum(nil)) }
+
+// CheckSafety returns an error describing the first conflicting commit: two
+// nodes finalizing different blocks at one height.
+func (s *Sim) CheckSafety() error {
+	if len(s.violations) > 0 {
+		return fmt.Errorf("safety violated: %s", s.violations[0])
+	}
+	return nil
+}
+
+// CheckLiveness returns an error naming the nodes that did not commit h.
+func (s *Sim) CheckLiveness(h uint64) error {
+	var lag []string
+	for _, n := range s.nodes {
+		if top, ok := n.Committed(); !ok || top < h {
+			lag = append(lag, fmt.Sprintf("%s@%d", n.ID, top))
+		}
+	}
+	if len(lag) > 0 {
+		return fmt.Errorf("liveness: height %d not committed by %v after %v", h, lag, s.sched.now)
+	}
+	return nil
+}
+
+// observedStore records every finalized block into the simulation so
+// conflicting commits are caught as they happen.
+type observedStore struct {
+	state.BlockStore
+	sim  *Sim
+	node *Node
+}
+
+func (o *observedStore) SaveBlock(ctx context.Context, rec state.BlockRecord) error {
+	if err := o.BlockStore.SaveBlock(ctx, rec); err != nil {
+		return err
+	}
+	if prev, ok := o.sim.commits[rec.Height]; !ok {
+		o.sim.commits[rec.Height] = commit{node: o.node.ID, id: rec.ID, hash: rec.Hash}
+	} else if prev.id != rec.ID || !bytes.Equal(prev.hash, rec.Hash) {
+		o.sim.violations = append(o.sim.violations, fmt.Sprintf("height %d: %s committed %q, %s committed %q", rec.Height, prev.node, prev.id, o.node.ID, rec.ID))
+	}
+	if !o.node.any || rec.Height > o.node.top {
+		o.node.top, o.node.any = rec.Height, true
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package sim
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func run(t *testing.T, cfg Config) *Sim {
+	t.Helper()
+	s, err := New(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	return s
+}
+
+func TestSim_HappyPath_CommitsHeights(t *testing.T) {
+	s := run(t, Config{Seed: 1, Faults: Faults{MinDelay: 5 * time.Millisecond, MaxDelay: 20 * time.Millisecond}})
+	if !s.RunUntil(5, time.Minute) {
+		t.Fatalf("%v", s.CheckLiveness(5))
+	}
+	if err := s.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Now() > 5*time.Second {
+		t.Fatalf("fault-free run should not need round changes, took %v", s.Now())
+	}
+}
+
+// Lossy, duplicating, reordering links over many seeds: never two different
+// commits at one height, and the cluster keeps making progress.
+func TestSim_Chaos_SafetyAndLiveness(t *testing.T) {
+	f := Faults{Drop: 0.1, Duplicate: 0.1, Reorder: 0.2, MinDelay: time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for seed := int64(1); seed <= 20; seed++ {
+		s := run(t, Config{Seed: seed, Faults: f})
+		s.RunUntil(3, 10*time.Minute)
+		if err := s.CheckSafety(); err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if err := s.CheckLiveness(3); err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+	}
+}
+
+// A 2/2 split has no quorum on either side: nothing commits while it lasts,
+// and the cluster recovers through round changes once it heals.
+func TestSim_Partition_HaltsThenRecovers(t *testing.T) {
+	heal := 30 * time.Second
+	s := run(t, Config{Seed: 7, Faults: Faults{MinDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+		Partitions: []Partition{{Until: heal, Groups: [][]string{{"n0", "n1"}, {"n2", "n3"}}}}})
+	s.RunFor(heal - time.Second)
+	for _, n := range s.Nodes() {
+		if _, ok := n.Committed(); ok {
+			t.Fatalf("%s committed without a quorum", n.ID)
+		}
+	}
+	if !s.RunUntil(2, 10*time.Minute) {
+		t.Fatalf("%v", s.CheckLiveness(2))
+	}
+	if err := s.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// An isolated minority does not stop the majority.
+func TestSim_IsolatedNode_MajorityProgresses(t *testing.T) {
+	s := run(t, Config{Seed: 3, Faults: Faults{MinDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+		Partitions: []Partition{{Groups: [][]string{{"n3"}}}}})
+	s.RunFor(20 * time.Second)
+	for _, n := range s.Nodes()[:3] {
+		if top, ok := n.Committed(); !ok || top < 3 {
+			t.Fatalf("%s stuck at %d (ok=%v)", n.ID, top, ok)
+		}
+	}
+	if _, ok := s.Nodes()[3].Committed(); ok {
+		t.Fatalf("isolated node cannot commit")
+	}
+	if err := s.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The same config replays the exact same run; another seed does not.
+func TestSim_Deterministic(t *testing.T) {
+	cfg := Config{Seed: 42, Faults: Faults{Drop: 0.1, Duplicate: 0.1, Reorder: 0.2, MinDelay: time.Millisecond, MaxDelay: 30 * time.Millisecond}}
+	a, b := run(t, cfg), run(t, cfg)
+	a.RunFor(10 * time.Second)
+	b.RunFor(10 * time.Second)
+	if a.Digest() != b.Digest() || a.Stats() != b.Stats() {
+		t.Fatalf("same seed diverged: %s vs %s", a.Digest(), b.Digest())
+	}
+	cfg.Seed = 43
+	c := run(t, cfg)
+	c.RunFor(10 * time.Second)
+	if c.Digest() == a.Digest() {
+		t.Fatalf("different seeds produced identical traces")
+	}
+}
@@ -0,0 +1,302 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
+	pl "github.com/zmlAEQ/Aequa-network/internal/payload"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+	"github.com/zmlAEQ/Aequa-network/pkg/logger"
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// BlockSource fetches committed blocks from peers (p2p.SyncTransport
+// implements it).
+type BlockSource interface {
+	Peers() []string
+	RequestBlocks(ctx context.Context, peer string, req wire.SyncRequest) (wire.SyncResponse, error)
+}
+
+const (
+	// defaultSyncLag is how many heights a verified message must be ahead of
+	// the active instance before the node assumes it fell behind and syncs.
+	defaultSyncLag = 2
+	// syncRequestTimeout bounds a single request to one peer.
+	syncRequestTimeout = 10 * time.Second
+)
+
+// blockSync holds the catch-up configuration and the hand-off of synced
+// heights to the consensus loop.
+type blockSync struct {
+	src     BlockSource
+	vs      *qbft.ValidatorSet
+	lag     uint64
+	running atomic.Bool
+	done    chan uint64 // highest height applied by a finished sync
+}
+
+// SetBlockSync enables catch-up: when the node starts or sees verified
+// messages at least lag heights ahead of its active instance, it fetches
+// committed blocks from peers via src, checks their commit seals against vs
+// and stores them before resuming consensus after the last one. lag=0 keeps
+// the default (2).
+func (s *Service) SetBlockSync(src BlockSource, vs *qbft.ValidatorSet, lag uint64) {
+	if lag == 0 {
+		lag = defaultSyncLag
+	}
+	s.syncer = &blockSync{src: src, vs: vs, lag: lag, done: make(chan uint64, 1)}
+}
+
+// syncDone returns the channel the loop reads applied sync heights from (nil
+// when sync is disabled, which blocks forever in select).
+func (s *Service) syncDone() <-chan uint64 {
+	if s.syncer == nil {
+		return nil
+	}
+	return s.syncer.done
+}
+
+// ServeBlocks answers a peer's sync request from the local block store. It is
+// safe to call concurrently with the consensus loop.
+func (s *Service) ServeBlocks(ctx context.Context, req wire.SyncRequest) wire.SyncResponse {
+	var resp wire.SyncResponse
+	if s.blocks == nil {
+		return resp
+	}
+	last, err := s.blocks.LastBlock(ctx)
+	if err != nil {
+		metrics.Inc("consensus_sync_served_total", map[string]string{"result": "empty"})
+		return resp
+	}
+	resp.Last, resp.HasLast = last.Height, true
+	// Blocks are served consecutively; a leading gap (history starting above
+	// From) is skipped, a later one ends the response.
+	for h := req.From; h <= last.Height && len(resp.Blocks) < req.SyncLimit(); h++ {
+		rec, err := s.blocks.BlockByHeight(ctx, h)
+		if err != nil {
+			if len(resp.Blocks) == 0 {
+				continue
+			}
+			break
+		}
+		resp.Blocks = append(resp.Blocks, rec)
+	}
+	metrics.Inc("consensus_sync_served_total", map[string]string{"result": "ok"})
+	logger.InfoJ("consensus_sync", map[string]any{"op": "serve", "from": req.From, "blocks": len(resp.Blocks), "last": last.Height})
+	return resp
+}
+
+// maybeSync starts a background catch-up unless one is already running.
+func (s *Service) maybeSync(ctx context.Context, reason string) {
+	if s.syncer == nil || !s.syncer.running.CompareAndSwap(false, true) {
+		return
+	}
+	logger.InfoJ("consensus_sync", map[string]any{"op": "start", "reason": reason})
+	if s.manual {
+		// Manually driven nodes sync inline so a simulation stays
+		// deterministic; Step applies the result like the loop would.
+		last, ok := s.catchUp(ctx)
+		s.syncer.running.Store(false)
+		if ok {
+			select {
+			case <-s.syncer.done:
+			default:
+			}
+			s.syncer.done <- last
+		}
+		return
+	}
+	go func() {
+		defer s.syncer.running.Store(false)
+		last, ok := s.catchUp(ctx)
+		if !ok {
+			return
+		}
+		select {
+		case s.syncer.done <- last:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// behind reports whether a verified message at height h shows that peers
+// moved at least lag heights past the active instance.
+func (s *Service) behind(rd roundDriver, h uint64) bool {
+	if s.syncer == nil || rd == nil {
+		return false
+	}
+	active, _, _ := rd.View()
+	return h >= active+s.syncer.lag
+}
+
+// syncStart returns the first height the node lacks: right after the last
+// stored block, or the persisted LastState height when no block is stored.
+// anchored is false when no block is stored, so the first height peers serve
+// is not known in advance.
+func (s *Service) syncStart(ctx context.Context) (next uint64, anchored bool) {
+	if last, err := s.blocks.LastBlock(ctx); err == nil {
+		return last.Height + 1, true
+	}
+	if ls, err := s.store.LoadLastState(ctx); err == nil {
+		return ls.Height, false
+	}
+	return 0, false
+}
+
+// catchUp fetches, verifies and stores blocks from peers until none of them
+// has anything newer. A peer serving an invalid block is skipped for the
+// rest of the run. It returns the highest height stored, and false when no
+// block was applied.
+func (s *Service) catchUp(ctx context.Context) (uint64, bool) {
+	next, anchored := s.syncStart(ctx)
+	applied := 0
+	bad := map[string]bool{}
+	for progressed := true; progressed && ctx.Err() == nil; {
+		progressed = false
+		for _, p := range s.syncer.src.Peers() {
+			if bad[p] {
+				continue
+			}
+			n, last, err := s.fetchFrom(ctx, p, next, anchored || applied > 0)
+			if n > 0 {
+				next, applied, progressed = last+1, applied+n, true
+			}
+			if err != nil {
+				bad[p] = true
+				metrics.Inc("consensus_sync_total", map[string]string{"result": "peer_error"})
+				logger.ErrorJ("consensus_sync", map[string]any{"op": "fetch", "result": "error", "peer": p, "height": next, "err": err.Error()})
+			}
+		}
+	}
+	if applied == 0 {
+		metrics.Inc("consensus_sync_total", map[string]string{"result": "up_to_date"})
+		logger.InfoJ("consensus_sync", map[string]any{"op": "done", "result": "up_to_date", "height": next})
+		return 0, false
+	}
+	metrics.Inc("consensus_sync_total", map[string]string{"result": "ok"})
+	logger.InfoJ("consensus_sync", map[string]any{"op": "done", "result": "ok", "blocks": applied, "last": next - 1})
+	return next - 1, true
+}
+
+// fetchFrom pulls consecutive blocks starting at next from one peer until it
+// has no more, returning how many were verified and stored and the height of
+// the last one. Unless anchored, the first block may start above next (the
+// peer's history begins later).
+func (s *Service) fetchFrom(ctx context.Context, peer string, next uint64, anchored bool) (int, uint64, error) {
+	applied := 0
+	for ctx.Err() == nil {
+		rctx, cancel := context.WithTimeout(ctx, syncRequestTimeout)
+		resp, err := s.syncer.src.RequestBlocks(rctx, peer, wire.SyncRequest{From: next})
+		cancel()
+		if err != nil {
+			return applied, next - 1, err
+		}
+		if len(resp.Blocks) == 0 {
+			return applied, next - 1, nil
+		}
+		for _, rec := range resp.Blocks {
+			if rec.Height != next && (anchored || applied > 0 || rec.Height < next) {
+				return applied, next - 1, fmt.Errorf("block height %d, want %d", rec.Height, next)
+			}
+			if err := s.verifySynced(rec); err != nil {
+				metrics.Inc("consensus_sync_blocks_total", map[string]string{"result": "invalid"})
+				return applied, next - 1, err
+			}
+			blk, _ := wire.DecodeBlock(rec.Block) // checked by verifySynced
+			if err := s.saveExecuted(ctx, &rec, blk); err != nil {
+				metrics.Inc("consensus_sync_blocks_total", map[string]string{"result": "store_error"})
+				return applied, next - 1, err
+			}
+			metrics.Inc("consensus_sync_blocks_total", map[string]string{"result": "ok"})
+			s.applyReconfig(ctx, rec.Height)
+			s.pruneMempool(blk, rec.Receipts)
+			next = rec.Height + 1
+			applied++
+		}
+		if !resp.HasLast || resp.Last < next {
+			return applied, next - 1, nil
+		}
+	}
+	return applied, next - 1, ctx.Err()
+}
+
+// verifySynced checks a block received from a peer: the encoded block must
+// decode to the record's height and hash, every tx must carry its sender's
+// signature, and the commit seal must be a valid quorum of the processor's
+// seal votes for the record's (height, round, id), or an aggregated seal
+// the processor verifies.
+func (s *Service) verifySynced(rec state.BlockRecord) error {
+	blk, err := wire.DecodeBlock(rec.Block)
+	if err != nil {
+		return fmt.Errorf("decode block %d: %w", rec.Height, err)
+	}
+	if blk.Header.Height != rec.Height || !bytes.Equal(blk.Hash(), rec.Hash) {
+		return errors.New("block does not match record height/hash")
+	}
+	for i, it := range blk.Items {
+		if err := pl.VerifySig(it, s.chainID); err != nil {
+			return fmt.Errorf("block %d item %d (%s): %w", rec.Height, i, it.Type(), err)
+		}
+	}
+	if rec.AggSeal != nil {
+		av, ok := s.st.(aggSealVerifier)
+		if !ok {
+			return errors.New("aggregated seal not supported by the engine")
+		}
+		return av.VerifyAggSeal(s.syncValidators(rec.Height), rec.Height, rec.Round, rec.ID, rec.AggSeal.Signers, rec.AggSeal.Sig)
+	}
+	typ := qbft.MsgCommit
+	if st, ok := s.st.(sealTyper); ok {
+		typ = st.SealType()
+	}
+	seal := make([]qbft.Message, 0, len(rec.Seal))
+	for _, cs := range rec.Seal {
+		seal = append(seal, qbft.Message{Type: typ, From: cs.From, Height: rec.Height, Round: rec.Round, ID: rec.ID, Sig: cs.Sig})
+	}
+	return qbft.VerifySeal(s.syncValidators(rec.Height), typ, rec.Height, rec.Round, rec.ID, seal)
+}
+
+// sealTyper is implemented by processors whose commit seals are not QBFT
+// commits (hotstuff.Engine seals with the votes of a block's QC).
+type sealTyper interface {
+	SealType() qbft.Type
+}
+
+// aggSealVerifier is implemented by processors that check aggregated commit
+// seals (hotstuff.Engine through its certification scheme).
+type aggSealVerifier interface {
+	VerifyAggSeal(vs *qbft.ValidatorSet, height, round uint64, id string, signers []string, agg []byte) error
+}
+
+// applySynced runs on the consensus loop after a sync stored blocks up to
+// last: it records the new last state, compacts the WAL and restarts the
+// per-height processor right after last so the node rejoins live consensus.
+func (s *Service) applySynced(ctx context.Context, rd roundDriver, rt *roundTimer, last uint64) {
+	if rd != nil {
+		if h, _, _ := rd.View(); h > last {
+			return // consensus already moved past the synced range
+		}
+	}
+	if err := s.wal.Compact(last + 1); err != nil {
+		logger.ErrorJ("qbft_wal", map[string]any{"op": "compact", "result": "error", "below": last + 1, "err": err.Error()})
+	}
+	s.pruneBlocks(last + 1)
+	s.checkpointReplay(ctx, last)
+	if err := s.store.SaveLastState(ctx, state.LastState{Height: last + 1}); err != nil {
+		logger.ErrorJ("consensus_state", map[string]any{"op": "save", "result": "error", "err": err.Error(), "trace_id": ""})
+	}
+	s.activateEpochs(last + 1)
+	if adv, ok := s.st.(heightAdvancer); ok {
+		adv.Restore(last+1, 0)
+		if rd != nil {
+			rt.arm(0)
+		}
+	}
+	logger.InfoJ("consensus_sync", map[string]any{"op": "apply", "result": "ok", "height": last + 1})
+}
@@ -0,0 +1,232 @@
+// Package execution is the account state machine: committed blocks are
+// applied to balances and nonces in item order, producing one receipt per
+// executed tx and a state root committing to the post-state.
+package execution
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/zmlAEQ/Aequa-network/internal/payload"
+	bundle "github.com/zmlAEQ/Aequa-network/internal/payload/bundle_v1"
+	plaintext "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+	"github.com/zmlAEQ/Aequa-network/internal/state"
+)
+
+// Receipt statuses.
+const (
+	StatusOK       = "ok"
+	StatusReverted = "reverted" // fee charged and nonce used, transfer not applied
+	StatusSkipped  = "skipped"  // not executable, state untouched
+)
+
+// Account is the executed state of one address.
+type Account struct {
+	Balance uint64
+	Nonce   uint64
+}
+
+// State maps addresses to accounts. Accounts with zero balance and nonce are
+// not stored, so equal states always have equal roots. It is not safe for
+// concurrent use.
+type State struct {
+	accts map[string]Account
+}
+
+// NewState returns the genesis state funding each address of alloc.
+func NewState(alloc map[string]uint64) *State {
+	s := &State{accts: make(map[string]Account, len(alloc))}
+	for addr, bal := range alloc {
+		s.set(addr, Account{Balance: bal})
+	}
+	return s
+}
+
+// Account returns the account of addr (zero if it never held anything).
+func (s *State) Account(addr string) Account { return s.accts[addr] }
+
+// Len returns the number of non-empty accounts.
+func (s *State) Len() int { return len(s.accts) }
+
+// Clone returns an independent copy, so a block can be executed without
+// touching the state until it is known to be stored.
+func (s *State) Clone() *State {
+	c := &State{accts: make(map[string]Account, len(s.accts))}
+	for addr, a := range s.accts {
+		c.accts[addr] = a
+	}
+	return c
+}
+
+func (s *State) set(addr string, a Account) {
+	if a == (Account{}) {
+		delete(s.accts, addr)
+		return
+	}
+	s.accts[addr] = a
+}
+
+// stateDomain separates state roots from other hashed byte strings.
+const stateDomain = "aequa/state/v1"
+
+// Root returns the state commitment: sha256 over the accounts in address
+// order, each as (length-prefixed address, balance, nonce).
+func (s *State) Root() []byte {
+	addrs := make([]string, 0, len(s.accts))
+	for addr := range s.accts {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	h := sha256.New()
+	var buf [8]byte
+	writeString := func(v string) {
+		binary.BigEndian.PutUint32(buf[:4], uint32(len(v)))
+		h.Write(buf[:4])
+		h.Write([]byte(v))
+	}
+	writeString(stateDomain)
+	for _, addr := range addrs {
+		a := s.accts[addr]
+		writeString(addr)
+		binary.BigEndian.PutUint64(buf[:], a.Balance)
+		h.Write(buf[:])
+		binary.BigEndian.PutUint64(buf[:], a.Nonce)
+		h.Write(buf[:])
+	}
+	return h.Sum(nil)
+}
+
+// ApplyBlock executes the plaintext_v1 txs of blk in item order, those
+// carried by bundles included, and returns their receipts. A bundle gets a
+// receipt of its own for its bid. Other payload types carry no account
+// semantics and are passed over.
+func (s *State) ApplyBlock(blk payload.StandardBlock) []state.Receipt {
+	var rs []state.Receipt
+	for _, it := range blk.Items {
+		switch tx := it.(type) {
+		case *bundle.BundleTx:
+			rs = append(rs, s.applyBundle(tx, blk.Header.FeeRecipient)...)
+		case *plaintext.PlaintextTx:
+			rs = append(rs, s.applyTx(tx, blk.Header.FeeRecipient))
+		}
+	}
+	return rs
+}
+
+// applyBundle charges the bid of b and runs its plaintext txs all or none:
+// unless the signer pays the bid and every tx succeeds, the state is left
+// untouched and each receipt is skipped, the failing one with its own error
+// and the others with "bundle". The bid receipt comes first.
+func (s *State) applyBundle(b *bundle.BundleTx, recipient string) []state.Receipt {
+	trial := s.Clone()
+	rs := []state.Receipt{trial.chargeBid(b, recipient)}
+	failed := -1
+	if rs[0].Status != StatusOK {
+		failed = 0
+	}
+	for _, it := range b.Inner() {
+		tx, ok := it.(*plaintext.PlaintextTx)
+		if !ok {
+			continue
+		}
+		if failed >= 0 {
+			rs = append(rs, state.Receipt{Tx: tx.Hash(), From: tx.From, Nonce: tx.Nonce})
+			continue
+		}
+		r := trial.applyTx(tx, recipient)
+		if r.Status != StatusOK {
+			failed = len(rs)
+		}
+		rs = append(rs, r)
+	}
+	if failed < 0 {
+		s.accts = trial.accts
+		return rs
+	}
+	for i := range rs {
+		if i != failed {
+			rs[i].Err = "bundle"
+		}
+		rs[i].Status, rs[i].Fee = StatusSkipped, 0
+	}
+	return rs
+}
+
+// chargeBid moves the bid of b from its signer to the fee recipient (burned
+// when there is none). The signer uses no nonce: the bundle txs carry their
+// own.
+func (s *State) chargeBid(b *bundle.BundleTx, recipient string) state.Receipt {
+	r := state.Receipt{Tx: b.Hash(), From: b.From, Status: StatusSkipped}
+	from := s.accts[b.From]
+	switch {
+	case from.Balance < b.Bid:
+		r.Err = "insufficient_bid"
+		return r
+	case recipient != "" && recipient != b.From && !fits(s.accts[recipient].Balance, b.Bid):
+		r.Err = "overflow"
+		return r
+	}
+	from.Balance -= b.Bid
+	s.set(b.From, from)
+	if recipient != "" {
+		rc := s.accts[recipient]
+		rc.Balance += b.Bid
+		s.set(recipient, rc)
+	}
+	r.Status, r.Fee = StatusOK, b.Bid
+	return r
+}
+
+// applyTx runs one transfer. A tx whose nonce is not the sender's next one,
+// or whose fee the sender cannot pay, is skipped. Otherwise the fee moves to
+// the fee recipient (burned when there is none) and the nonce is used, even
+// if the transfer itself then fails.
+func (s *State) applyTx(tx *plaintext.PlaintextTx, recipient string) state.Receipt {
+	r := state.Receipt{Tx: tx.Hash(), From: tx.From, Nonce: tx.Nonce, Status: StatusSkipped}
+	from := s.accts[tx.From]
+	switch {
+	case tx.Nonce != from.Nonce:
+		r.Err = "nonce"
+		return r
+	case from.Balance < tx.Fee:
+		r.Err = "insufficient_fee"
+		return r
+	case recipient != "" && recipient != tx.From && !fits(s.accts[recipient].Balance, tx.Fee):
+		r.Err = "overflow"
+		return r
+	}
+	from.Balance -= tx.Fee
+	from.Nonce++
+	s.set(tx.From, from)
+	if recipient != "" {
+		rc := s.accts[recipient]
+		rc.Balance += tx.Fee
+		s.set(recipient, rc)
+	}
+	r.Fee = tx.Fee
+	r.Status = StatusOK
+	if tx.Value == 0 || tx.To == tx.From {
+		if s.accts[tx.From].Balance < tx.Value {
+			r.Status, r.Err = StatusReverted, "insufficient_balance"
+		}
+		return r
+	}
+	from, to := s.accts[tx.From], s.accts[tx.To]
+	switch {
+	case from.Balance < tx.Value:
+		r.Status, r.Err = StatusReverted, "insufficient_balance"
+	case !fits(to.Balance, tx.Value):
+		r.Status, r.Err = StatusReverted, "overflow"
+	default:
+		from.Balance -= tx.Value
+		to.Balance += tx.Value
+		s.set(tx.From, from)
+		s.set(tx.To, to)
+	}
+	return r
+}
+
+// fits reports whether v can be credited to bal without overflow.
+func fits(bal, v uint64) bool { return bal <= math.MaxUint64-v }
@@ -0,0 +1,131 @@
+package execution
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/zmlAEQ/Aequa-network/internal/payload"
+	bundle "github.com/zmlAEQ/Aequa-network/internal/payload/bundle_v1"
+	plaintext "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+)
+
+func transfer(from string, nonce uint64, to string, value, fee uint64) *plaintext.PlaintextTx {
+	return &plaintext.PlaintextTx{From: from, Nonce: nonce, Gas: 1, Fee: fee, To: to, Value: value, Sig: make([]byte, 32)}
+}
+
+func block(recipient string, items ...payload.Payload) payload.StandardBlock {
+	return payload.StandardBlock{Header: payload.BlockHeader{Height: 1, FeeRecipient: recipient}, Items: items}
+}
+
+func TestApplyBlock_TransferChargesFeeToRecipient(t *testing.T) {
+	s := NewState(map[string]uint64{"A": 100})
+	rs := s.ApplyBlock(block("P", transfer("A", 0, "B", 30, 5), transfer("A", 1, "C", 0, 1)))
+	if len(rs) != 2 || rs[0].Status != StatusOK || rs[0].Fee != 5 || rs[1].Status != StatusOK {
+		t.Fatalf("receipts %+v", rs)
+	}
+	if a := s.Account("A"); a.Balance != 64 || a.Nonce != 2 {
+		t.Fatalf("sender %+v", a)
+	}
+	if s.Account("B").Balance != 30 || s.Account("P").Balance != 6 || s.Account("C") != (Account{}) {
+		t.Fatalf("B=%+v P=%+v C=%+v", s.Account("B"), s.Account("P"), s.Account("C"))
+	}
+}
+
+func TestApplyBlock_SkipsAndReverts(t *testing.T) {
+	s := NewState(map[string]uint64{"A": 10, "B": 1})
+	rs := s.ApplyBlock(block("",
+		transfer("A", 1, "B", 1, 1),  // nonce gap
+		transfer("B", 0, "C", 0, 2),  // cannot pay the fee
+		transfer("A", 0, "B", 50, 3), // fee paid, transfer too large
+	))
+	want := []struct{ status, err string }{{StatusSkipped, "nonce"}, {StatusSkipped, "insufficient_fee"}, {StatusReverted, "insufficient_balance"}}
+	for i, w := range want {
+		if rs[i].Status != w.status || rs[i].Err != w.err {
+			t.Fatalf("receipt %d: %+v, want %s/%s", i, rs[i], w.status, w.err)
+		}
+	}
+	// Without a fee recipient the fee is burned; the skipped txs left no trace.
+	if a, b := s.Account("A"), s.Account("B"); a.Balance != 7 || a.Nonce != 1 || b.Balance != 1 || b.Nonce != 0 {
+		t.Fatalf("A=%+v B=%+v", a, b)
+	}
+}
+
+func TestApplyBlock_IgnoresOtherPayloads(t *testing.T) {
+	s := NewState(map[string]uint64{"A": 1})
+	root := s.Root()
+	if rs := s.ApplyBlock(block("P", fakePayload{})); len(rs) != 0 || !bytes.Equal(s.Root(), root) {
+		t.Fatalf("non-plaintext payload executed: %+v", rs)
+	}
+}
+
+func bundleOf(txs ...*plaintext.PlaintextTx) *bundle.BundleTx {
+	b := &bundle.BundleTx{From: "S", Bid: 1}
+	for _, tx := range txs {
+		enc, _ := tx.MarshalBinary()
+		b.Txs = append(b.Txs, enc)
+	}
+	return b
+}
+
+// A bundle applies all of its txs or none of them.
+func TestApplyBlock_BundleAllOrNothing(t *testing.T) {
+	s := NewState(map[string]uint64{"A": 100, "B": 5, "S": 10})
+	rs := s.ApplyBlock(block("P", bundleOf(transfer("A", 0, "B", 10, 1), transfer("B", 0, "C", 14, 1))))
+	if len(rs) != 3 || rs[0].Status != StatusOK || rs[1].Status != StatusOK || rs[2].Status != StatusOK || s.Account("C").Balance != 14 {
+		t.Fatalf("receipts %+v C=%+v", rs, s.Account("C"))
+	}
+	root := s.Root()
+	// B cannot cover the second transfer, so neither executes.
+	rs = s.ApplyBlock(block("P", bundleOf(transfer("A", 1, "B", 1, 1), transfer("B", 1, "C", 99, 1))))
+	if rs[0].Status != StatusSkipped || rs[0].Fee != 0 || rs[1].Err != "bundle" || rs[2].Status != StatusSkipped || rs[2].Err != "insufficient_balance" || rs[1].Fee != 0 {
+		t.Fatalf("receipts %+v", rs)
+	}
+	if !bytes.Equal(s.Root(), root) {
+		t.Fatalf("failed bundle changed the state")
+	}
+}
+
+// The bid moves from the bundle signer to the fee recipient with the txs; a
+// signer that cannot pay it gets the whole bundle skipped.
+func TestApplyBlock_BundleChargesBid(t *testing.T) {
+	s := NewState(map[string]uint64{"A": 100, "S": 3})
+	b := bundleOf(transfer("A", 0, "B", 10, 1))
+	b.Bid = 3
+	rs := s.ApplyBlock(block("P", b))
+	if len(rs) != 2 || rs[0].Status != StatusOK || rs[0].From != "S" || rs[0].Fee != 3 || !bytes.Equal(rs[0].Tx, b.Hash()) {
+		t.Fatalf("bid receipt %+v", rs)
+	}
+	if s.Account("S").Balance != 0 || s.Account("P").Balance != 4 || s.Account("B").Balance != 10 {
+		t.Fatalf("S=%+v P=%+v B=%+v", s.Account("S"), s.Account("P"), s.Account("B"))
+	}
+	root := s.Root()
+	rs = s.ApplyBlock(block("P", bundleOf(transfer("A", 1, "B", 10, 1))))
+	if len(rs) != 2 || rs[0].Status != StatusSkipped || rs[0].Err != "insufficient_bid" || rs[1].Status != StatusSkipped || rs[1].Err != "bundle" || rs[1].Fee != 0 {
+		t.Fatalf("receipts %+v", rs)
+	}
+	if !bytes.Equal(s.Root(), root) || s.Account("A").Nonce != 1 {
+		t.Fatalf("unpaid bundle changed the state")
+	}
+}
+
+// Roots depend on the non-empty accounts only, and a clone executes
+// independently of its original.
+func TestRoot_Canonical(t *testing.T) {
+	a := NewState(map[string]uint64{"A": 5, "B": 5})
+	b := NewState(map[string]uint64{"B": 5, "A": 5, "Z": 0})
+	if !bytes.Equal(a.Root(), b.Root()) {
+		t.Fatalf("equal states, different roots")
+	}
+	c := a.Clone()
+	c.ApplyBlock(block("", transfer("A", 0, "B", 1, 0)))
+	if bytes.Equal(a.Root(), c.Root()) || a.Account("A").Nonce != 0 {
+		t.Fatalf("clone shares state with the original")
+	}
+}
+
+type fakePayload struct{}
+
+func (fakePayload) Type() string    { return "fake" }
+func (fakePayload) Hash() []byte    { return []byte("fake") }
+func (fakePayload) Validate() error { return nil }
+func (fakePayload) SortKey() uint64 { return 0 }
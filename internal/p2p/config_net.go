@@ -9,4 +9,5 @@ type NetConfig struct {
     NAT        bool     // enable NAT port mapping if available
     EnableBeast bool    // enable BEAST private tx topic when true
     EnableTSSDKG bool   // enable TSS/BEAST DKG topic when true
+    ChainID    string   // chain id gossiped tx signatures are checked against (empty: payload.DefaultChainID)
 }
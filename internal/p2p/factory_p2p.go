@@ -12,7 +12,9 @@ import (
 	libp2p "github.com/libp2p/go-libp2p"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	p2phost "github.com/libp2p/go-libp2p/core/host"
+	network "github.com/libp2p/go-libp2p/core/network"
 	peer "github.com/libp2p/go-libp2p/core/peer"
+	protocol "github.com/libp2p/go-libp2p/core/protocol"
 	ma "github.com/multiformats/go-multiaddr"
 
 	qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
@@ -47,6 +49,7 @@ type Libp2pTransport struct {
 	onTx      func(payload.Payload)
 	onShare   func(wire.BeastShare)
 	onDKG     func(wire.TSSDKG)
+	onSync    func(context.Context, wire.SyncRequest) wire.SyncResponse
 }
 
 func (t *Libp2pTransport) Start(ctx context.Context) error {
@@ -109,6 +112,9 @@ func (t *Libp2pTransport) Start(ctx context.Context) error {
 		}
 	}
 
+	// Block sync is request/response over a dedicated stream protocol.
+	h.SetStreamHandler(protocol.ID(wire.ProtocolSync), func(st network.Stream) { t.handleSync(ctx, st) })
+
 	// connect bootnodes (best effort)
 	for _, b := range t.cfg.Bootnodes {
 		if strings.TrimSpace(b) == "" {
@@ -293,12 +299,16 @@ func (t *Libp2pTransport) loopTx(ctx context.Context) {
 			metrics.Inc(MetricP2PMessagesTotal, map[string]string{"topic": wire.TopicTx, "direction": "rx", "result": "decode_error"})
 			continue
 		}
+		pl := w.ToInternal()
+		if pl != nil && payload.VerifySig(pl, t.cfg.ChainID) != nil {
+			metrics.Inc(MetricP2PMessagesTotal, map[string]string{"topic": wire.TopicTx, "direction": "rx", "result": "bad_sig"})
+			metrics.Inc("mempool_in_total", map[string]string{"result": "bad_sig"})
+			continue
+		}
 		metrics.Inc(MetricP2PMessagesTotal, map[string]string{"topic": wire.TopicTx, "direction": "rx", "result": "ok"})
 		metrics.Inc(MetricP2PBytesTotal, map[string]string{"topic": wire.TopicTx, "direction": "rx"})
-		if t.onTx != nil {
-			if pl := w.ToInternal(); pl != nil {
-				t.onTx(pl)
-			}
+		if t.onTx != nil && pl != nil {
+			t.onTx(pl)
 		}
 	}
 }
@@ -365,6 +375,79 @@ func (t *Libp2pTransport) loopTSSDKG(ctx context.Context) {
 	}
 }
 
+// Peers returns the ids of currently connected peers.
+func (t *Libp2pTransport) Peers() []string {
+	if t.host == nil {
+		return nil
+	}
+	var out []string
+	for _, p := range t.host.Network().Peers() {
+		out = append(out, p.String())
+	}
+	return out
+}
+
+func (t *Libp2pTransport) OnSyncRequest(fn func(context.Context, wire.SyncRequest) wire.SyncResponse) {
+	t.onSync = fn
+}
+
+// RequestBlocks opens a sync stream to peer, writes req and reads the response.
+func (t *Libp2pTransport) RequestBlocks(ctx context.Context, id string, req wire.SyncRequest) (wire.SyncResponse, error) {
+	if t.host == nil {
+		return wire.SyncResponse{}, errors.New("p2p not started")
+	}
+	pid, err := peer.Decode(id)
+	if err != nil {
+		return wire.SyncResponse{}, err
+	}
+	ctx2, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	st, err := t.host.NewStream(ctx2, pid, protocol.ID(wire.ProtocolSync))
+	if err != nil {
+		metrics.Inc(MetricP2PMessagesTotal, map[string]string{"topic": wire.ProtocolSync, "direction": "tx", "result": "error"})
+		return wire.SyncResponse{}, err
+	}
+	defer st.Close()
+	if dl, ok := ctx2.Deadline(); ok {
+		_ = st.SetDeadline(dl)
+	}
+	if err := json.NewEncoder(st).Encode(req); err != nil {
+		metrics.Inc(MetricP2PMessagesTotal, map[string]string{"topic": wire.ProtocolSync, "direction": "tx", "result": "error"})
+		return wire.SyncResponse{}, err
+	}
+	_ = st.CloseWrite()
+	metrics.Inc(MetricP2PMessagesTotal, map[string]string{"topic": wire.ProtocolSync, "direction": "tx", "result": "ok"})
+	var resp wire.SyncResponse
+	if err := json.NewDecoder(st).Decode(&resp); err != nil {
+		metrics.Inc(MetricP2PMessagesTotal, map[string]string{"topic": wire.ProtocolSync, "direction": "rx", "result": "decode_error"})
+		return wire.SyncResponse{}, err
+	}
+	metrics.Inc(MetricP2PMessagesTotal, map[string]string{"topic": wire.ProtocolSync, "direction": "rx", "result": "ok"})
+	return resp, nil
+}
+
+// handleSync answers one inbound sync request on st.
+func (t *Libp2pTransport) handleSync(ctx context.Context, st network.Stream) {
+	defer st.Close()
+	_ = st.SetDeadline(time.Now().Add(10 * time.Second))
+	var req wire.SyncRequest
+	if err := json.NewDecoder(st).Decode(&req); err != nil {
+		metrics.Inc(MetricP2PMessagesTotal, map[string]string{"topic": wire.ProtocolSync, "direction": "rx", "result": "decode_error"})
+		_ = st.Reset()
+		return
+	}
+	metrics.Inc(MetricP2PMessagesTotal, map[string]string{"topic": wire.ProtocolSync, "direction": "rx", "result": "ok"})
+	var resp wire.SyncResponse
+	if t.onSync != nil {
+		resp = t.onSync(ctx, req)
+	}
+	if err := json.NewEncoder(st).Encode(resp); err != nil {
+		metrics.Inc(MetricP2PMessagesTotal, map[string]string{"topic": wire.ProtocolSync, "direction": "tx", "result": "error"})
+		return
+	}
+	metrics.Inc(MetricP2PMessagesTotal, map[string]string{"topic": wire.ProtocolSync, "direction": "tx", "result": "ok"})
+}
+
 func connectOnce(ctx context.Context, h p2phost.Host, addr string) error {
 	maAddr, err := ma.NewMultiaddr(addr)
 	if err != nil {
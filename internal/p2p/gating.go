@@ -40,6 +40,13 @@ func (g *AllowListGate) Add(id PeerID) { g.mu.Lock(); g.allowed[id] = struct{}{}
 // Remove deletes a peer id from the allowlist.
 func (g *AllowListGate) Remove(id PeerID) { g.mu.Lock(); delete(g.allowed, id); g.mu.Unlock() }
 
+// Set replaces the allowlist with ids.
+func (g *AllowListGate) Set(ids ...PeerID) {
+    m := make(map[PeerID]struct{}, len(ids))
+    for _, id := range ids { m[id] = struct{}{} }
+    g.mu.Lock(); g.allowed = m; g.mu.Unlock()
+}
+
 // ReasonedGate optionally returns a reason when denying a peer.
 type ReasonedGate interface { AllowWithReason(id PeerID) (bool, string) }
 
@@ -88,15 +95,25 @@ func (g *RateLimitGate) AllowWithReason(id PeerID) (bool, string) {
     }
 }
 
-// ScoreGate denies peers whose score is below threshold.
+// ScoreGate denies peers whose score is below threshold. Scores can be
+// adjusted at runtime (e.g. penalties for consensus misbehaviour).
 type ScoreGate struct{
     threshold int64
+    mu     *sync.RWMutex
     scores map[PeerID]int64
 }
 
 func NewScoreGate(threshold int64, scores map[PeerID]int64) ScoreGate {
     if scores == nil { scores = map[PeerID]int64{} }
-    return ScoreGate{threshold: threshold, scores: scores}
+    return ScoreGate{threshold: threshold, mu: &sync.RWMutex{}, scores: scores}
+}
+
+// Adjust adds delta to the peer's score (missing scores start at 0) and
+// returns the new score.
+func (g ScoreGate) Adjust(id PeerID, delta int64) int64 {
+    g.mu.Lock(); defer g.mu.Unlock()
+    g.scores[id] += delta
+    return g.scores[id]
 }
 
 func (g ScoreGate) Allow(id PeerID) bool {
@@ -106,7 +123,7 @@ func (g ScoreGate) Allow(id PeerID) bool {
 
 func (g ScoreGate) AllowWithReason(id PeerID) (bool, string) {
     if g.threshold <= 0 { return true, "allowed" }
-    s, ok := g.scores[id]
+    g.mu.RLock(); s, ok := g.scores[id]; g.mu.RUnlock()
     if !ok || s < g.threshold { return false, "scored_out" }
     return true, "allowed"
 }
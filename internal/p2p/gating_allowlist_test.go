@@ -112,3 +112,24 @@ func TestAllowListGate_ConcurrentUpdates(t *testing.T) {
 		t.Fatalf("attempts mismatch: allowed(%d)+denied(%d) != %d; dump=%q", allowed, denied, totalAttempts, dump)
 	}
 }
+
+func TestService_SetAllowList(t *testing.T) {
+	metrics.Reset()
+	g := NewAllowListGate("A", "B")
+	s := NewWithOpts(nil, &CombinedGate{allow: g}, NewResourceManager(ResourceLimits{MaxConns: 4}), NopHook{})
+	s.SetAllowList("B", "C")
+	if err := s.Connect("A"); err == nil {
+		t.Fatalf("A should be denied after the update")
+	}
+	if err := s.Connect("C"); err != nil {
+		t.Fatalf("C should pass: %v", err)
+	}
+	if !strings.Contains(metrics.DumpProm(), `p2p_allowlist_updates_total{result="ok"} 1`) {
+		t.Fatalf("missing update metric")
+	}
+
+	NewWithOpts(nil, nil, NewResourceManager(ResourceLimits{MaxConns: 4}), NopHook{}).SetAllowList("A")
+	if !strings.Contains(metrics.DumpProm(), `p2p_allowlist_updates_total{result="no_gate"} 1`) {
+		t.Fatalf("missing no_gate metric")
+	}
+}
@@ -36,4 +36,20 @@ func TestScoreGate_ScoredOut(t *testing.T) {
     if !strings.Contains(dump, `p2p_conn_attempts_total{result="scored_out"} 1`) {
         t.Fatalf("expected scored_out=1, got %q", dump)
     }
-}
\ No newline at end of file
+}
+func TestService_Penalize_ScoresOutPeer(t *testing.T) {
+    metrics.Reset()
+    sg := NewScoreGate(10, map[PeerID]int64{"A": 12, "B": 12})
+    s := NewWithOpts(nil, &CombinedGate{score: &sg}, NewResourceManager(ResourceLimits{MaxConns: 4}), NopHook{})
+    s.Penalize("A", 5, "double_commit")
+    if err := s.Connect("A"); err == nil { t.Fatalf("penalized peer A should be scored out") }
+    if err := s.Connect("B"); err != nil { t.Fatalf("B should pass: %v", err) }
+    if !strings.Contains(metrics.DumpProm(), `p2p_peer_penalties_total{reason="double_commit",result="ok"} 1`) {
+        t.Fatalf("missing penalty metric: %q", metrics.DumpProm())
+    }
+    // Without score gating a penalty is only recorded.
+    New().Penalize("A", 5, "double_commit")
+    if !strings.Contains(metrics.DumpProm(), `p2p_peer_penalties_total{reason="double_commit",result="no_gate"} 1`) {
+        t.Fatalf("missing no_gate metric")
+    }
+}
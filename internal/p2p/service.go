@@ -155,6 +155,40 @@ func (s *Service) Connect(id PeerID) error {
     return nil
 }
 
+// Penalize lowers the score of a peer (consensus operator id) by delta for
+// the given reason. It only has an effect when score gating is configured
+// (ScoreThreshold > 0); a peer scored below the threshold is refused on its
+// next connection attempt.
+func (s *Service) Penalize(id string, delta int64, reason string) {
+    cg, ok := s.gate.(*CombinedGate)
+    if !ok || cg.score == nil {
+        metrics.Inc("p2p_peer_penalties_total", map[string]string{"reason": reason, "result": "no_gate"})
+        logger.InfoJ("p2p_penalty", map[string]any{"peer_id": id, "reason": reason, "delta": delta, "result": "no_gate"})
+        return
+    }
+    score := cg.score.Adjust(PeerID(id), -delta)
+    metrics.Inc("p2p_peer_penalties_total", map[string]string{"reason": reason, "result": "ok"})
+    logger.InfoJ("p2p_penalty", map[string]any{"peer_id": id, "reason": reason, "delta": delta, "score": score, "result": "ok"})
+}
+
+// SetAllowList replaces the admitted peers (consensus operator ids) when
+// allowlist gating is configured, e.g. when a new validator set comes into
+// force. Connected peers are not dropped; the list applies to new attempts.
+func (s *Service) SetAllowList(ids ...string) {
+    g, _ := s.gate.(*AllowListGate)
+    if cg, ok := s.gate.(*CombinedGate); ok { g = cg.allow }
+    if g == nil {
+        metrics.Inc("p2p_allowlist_updates_total", map[string]string{"result": "no_gate"})
+        logger.InfoJ("p2p_allowlist", map[string]any{"peers": len(ids), "result": "no_gate"})
+        return
+    }
+    peers := make([]PeerID, 0, len(ids))
+    for _, id := range ids { peers = append(peers, PeerID(id)) }
+    g.Set(peers...)
+    metrics.Inc("p2p_allowlist_updates_total", map[string]string{"result": "ok"})
+    logger.InfoJ("p2p_allowlist", map[string]any{"peers": len(ids), "result": "ok"})
+}
+
 // Disconnect unregisters a peer and releases resources.
 func (s *Service) Disconnect(id PeerID) {
     s.mgr.RemovePeer(id)
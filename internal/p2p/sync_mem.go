@@ -0,0 +1,97 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/zmlAEQ/Aequa-network/internal/p2p/wire"
+)
+
+// ErrSyncUnavailable is returned when the requested peer is unknown or does
+// not serve sync requests.
+var ErrSyncUnavailable = errors.New("sync peer unavailable")
+
+// MemSyncNetwork connects in-process SyncTransports by id. It is meant for
+// tests and single-process simulations; requests are served synchronously.
+type MemSyncNetwork struct {
+	mu    sync.RWMutex
+	nodes map[string]*MemSyncTransport
+}
+
+// NewMemSyncNetwork constructs an empty in-memory sync network.
+func NewMemSyncNetwork() *MemSyncNetwork {
+	return &MemSyncNetwork{nodes: make(map[string]*MemSyncTransport)}
+}
+
+// Join registers (or returns) the transport of node id.
+func (n *MemSyncNetwork) Join(id string) *MemSyncTransport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if t, ok := n.nodes[id]; ok {
+		return t
+	}
+	t := &MemSyncTransport{id: id, net: n}
+	n.nodes[id] = t
+	return t
+}
+
+// Leave disconnects node id; requests to it fail afterwards.
+func (n *MemSyncNetwork) Leave(id string) {
+	n.mu.Lock()
+	delete(n.nodes, id)
+	n.mu.Unlock()
+}
+
+// MemSyncTransport is one node's endpoint on a MemSyncNetwork.
+type MemSyncTransport struct {
+	id  string
+	net *MemSyncNetwork
+
+	mu     sync.RWMutex
+	handle func(context.Context, wire.SyncRequest) wire.SyncResponse
+}
+
+// Peers returns the other joined nodes in id order.
+func (t *MemSyncTransport) Peers() []string {
+	t.net.mu.RLock()
+	defer t.net.mu.RUnlock()
+	out := make([]string, 0, len(t.net.nodes))
+	for id := range t.net.nodes {
+		if id != t.id {
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// RequestBlocks invokes peer's sync handler directly.
+func (t *MemSyncTransport) RequestBlocks(ctx context.Context, peer string, req wire.SyncRequest) (wire.SyncResponse, error) {
+	t.net.mu.RLock()
+	p := t.net.nodes[peer]
+	t.net.mu.RUnlock()
+	if p == nil {
+		return wire.SyncResponse{}, ErrSyncUnavailable
+	}
+	p.mu.RLock()
+	fn := p.handle
+	p.mu.RUnlock()
+	if fn == nil {
+		return wire.SyncResponse{}, ErrSyncUnavailable
+	}
+	if err := ctx.Err(); err != nil {
+		return wire.SyncResponse{}, err
+	}
+	return fn(ctx, req), nil
+}
+
+// OnSyncRequest registers the handler answering requests from other nodes.
+func (t *MemSyncTransport) OnSyncRequest(fn func(context.Context, wire.SyncRequest) wire.SyncResponse) {
+	t.mu.Lock()
+	t.handle = fn
+	t.mu.Unlock()
+}
+
+var _ SyncTransport = (*MemSyncTransport)(nil)
@@ -46,6 +46,17 @@ type TSSDKGTransport interface {
 	OnTSSDKG(fn func(wire.TSSDKG))
 }
 
+// SyncTransport is an optional extension implemented by transports that
+// serve committed blocks to lagging peers and fetch them (catch-up sync).
+type SyncTransport interface {
+	// Peers returns the ids of currently connected peers.
+	Peers() []string
+	// RequestBlocks sends req to peer and waits for its response.
+	RequestBlocks(ctx context.Context, peer string, req wire.SyncRequest) (wire.SyncResponse, error)
+	// OnSyncRequest registers the handler answering inbound sync requests.
+	OnSyncRequest(fn func(ctx context.Context, req wire.SyncRequest) wire.SyncResponse)
+}
+
 // NoopTransport is a stub implementation used when P2P is disabled.
 // It satisfies the interface without performing any network I/O.
 type NoopTransport struct {
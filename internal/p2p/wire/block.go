@@ -0,0 +1,71 @@
+package wire
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/zmlAEQ/Aequa-network/internal/payload"
+)
+
+// Block is the wire/storage form of payload.StandardBlock. Items are carried
+// as TxEnvelopes so any supported tx type round-trips.
+type Block struct {
+	Height       uint64       `json:"height"`
+	Round        uint64       `json:"round"`
+	FeeRecipient string       `json:"fee_recipient,omitempty"`
+	Items        []TxEnvelope `json:"items,omitempty"`
+	TotalFees    uint64       `json:"total_fees,omitempty"`
+	TotalBids    uint64       `json:"total_bids,omitempty"`
+}
+
+// BlockFromInternal converts a StandardBlock to its wire form. It fails when
+// an item has no wire representation, rather than silently dropping it.
+func BlockFromInternal(b payload.StandardBlock) (Block, error) {
+	w := Block{
+		Height:       b.Header.Height,
+		Round:        b.Header.Round,
+		FeeRecipient: b.Header.FeeRecipient,
+		TotalFees:    b.Stats.TotalFees,
+		TotalBids:    b.Stats.TotalBids,
+	}
+	for i, it := range b.Items {
+		env, ok := TxFromInternal(it)
+		if !ok {
+			return Block{}, fmt.Errorf("block item %d: unsupported payload type %q", i, it.Type())
+		}
+		w.Items = append(w.Items, env)
+	}
+	return w, nil
+}
+
+// ToInternal converts the wire block back to a StandardBlock.
+func (w Block) ToInternal() payload.StandardBlock {
+	b := payload.StandardBlock{
+		Header: payload.BlockHeader{Height: w.Height, Round: w.Round, FeeRecipient: w.FeeRecipient},
+		Stats:  payload.BlockStats{TotalFees: w.TotalFees, TotalBids: w.TotalBids, Items: len(w.Items)},
+	}
+	for _, env := range w.Items {
+		if it := env.ToInternal(); it != nil {
+			b.Items = append(b.Items, it)
+		}
+	}
+	return b
+}
+
+// EncodeBlock serializes a StandardBlock (JSON of the wire form).
+func EncodeBlock(b payload.StandardBlock) ([]byte, error) {
+	w, err := BlockFromInternal(b)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(w)
+}
+
+// DecodeBlock parses bytes produced by EncodeBlock.
+func DecodeBlock(raw []byte) (payload.StandardBlock, error) {
+	var w Block
+	if err := json.Unmarshal(raw, &w); err != nil {
+		return payload.StandardBlock{}, err
+	}
+	return w.ToInternal(), nil
+}
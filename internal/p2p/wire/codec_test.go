@@ -0,0 +1,168 @@
+package wire
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/zmlAEQ/Aequa-network/internal/payload"
+	auction "github.com/zmlAEQ/Aequa-network/internal/payload/auction_bid_v1"
+	plaintext "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+	private "github.com/zmlAEQ/Aequa-network/internal/payload/private_v1"
+	bundle "github.com/zmlAEQ/Aequa-network/internal/payload/bundle_v1"
+	reconfig "github.com/zmlAEQ/Aequa-network/internal/payload/reconfig_v1"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden codec vectors")
+
+// vectorsPath is shared with the JS SDK (sdk/js/test/codec.test.mjs).
+const vectorsPath = "../../../sdk/js/vectors/tx_codec_v1.json"
+
+type codecVectors struct {
+	CodecVersion int           `json:"codec_version"`
+	ChainID      string        `json:"chain_id"`
+	Seed         string        `json:"seed"` // ed25519 seed signing the signed vectors
+	Vectors      []codecVector `json:"vectors"`
+}
+
+type codecVector struct {
+	Name         string     `json:"name"`
+	Tx           TxEnvelope `json:"tx"`
+	Encoding     string     `json:"encoding"`
+	Hash         string     `json:"hash"`
+	SigningBytes string     `json:"signing_bytes,omitempty"`
+}
+
+type signingPayloader interface {
+	SigningPayload(chainID string) []byte
+}
+
+func goldenVectors(t *testing.T) codecVectors {
+	seed := bytes.Repeat([]byte{7}, ed25519.SeedSize)
+	key := ed25519.NewKeyFromSeed(seed)
+	fee := &plaintext.PlaintextTx{Nonce: 0, Gas: 21000, Fee: 100}
+	fee.Sign(payload.DefaultChainID, key)
+	// Nonces 1 and 257 collided when hashes truncated integers to a byte.
+	transfer := &plaintext.PlaintextTx{Nonce: 257, Gas: 21000, Fee: 2, To: "bob", Value: 1<<53 - 1}
+	transfer.Sign(payload.DefaultChainID, key)
+	low := &plaintext.PlaintextTx{Nonce: 1, Gas: 21000, Fee: 2, To: "bob", Value: 1<<53 - 1}
+	low.Sign(payload.DefaultChainID, key)
+	bid := &auction.AuctionBidTx{Nonce: 4, Gas: 50000, Bid: 1000, FeeRecipient: "builder-1"}
+	bid.Sign(payload.DefaultChainID, key)
+	feeEnc, _ := fee.MarshalBinary()
+	bidEnc, _ := bid.MarshalBinary()
+	bnd := &bundle.BundleTx{Bid: 500, Txs: [][]byte{feeEnc, bidEnc}}
+	bnd.Sign(payload.DefaultChainID, key)
+	sig := make([]byte, ed25519.SignatureSize)
+	for i := range sig {
+		sig[i] = byte(i)
+	}
+	cases := []struct {
+		name string
+		tx   payload.Payload
+	}{
+		{"plaintext_fee_only", fee},
+		{"plaintext_transfer_nonce_257", transfer},
+		{"plaintext_transfer_nonce_1", low},
+		{"plaintext_unsigned_utf8", &plaintext.PlaintextTx{From: "älice", Gas: 1}},
+		{"auction_bid", bid},
+		{"private", &private.PrivateTx{From: "carol", Nonce: 9, Ciphertext: []byte{0xde, 0xad, 0xbe, 0xef}, EphemeralKey: bytes.Repeat([]byte{1}, 32), TargetHeight: 42, BatchIndex: 3, PuncturedKey: []byte{5, 6}}},
+		{"reconfig_add", &reconfig.ReconfigTx{From: "n0", Nonce: 1, Epoch: 5, Op: reconfig.OpAdd, Operator: "n4", PubKey: bytes.Repeat([]byte{2}, 32), Threshold: 3, Sig: sig}},
+		{"bundle", bnd},
+	}
+	v := codecVectors{CodecVersion: int(payload.CodecVersion), ChainID: payload.DefaultChainID, Seed: hex.EncodeToString(seed)}
+	for _, c := range cases {
+		env, ok := TxFromInternal(c.tx)
+		if !ok {
+			t.Fatalf("%s: no wire form", c.name)
+		}
+		enc, err := MarshalTx(c.tx)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		cv := codecVector{Name: c.name, Tx: env, Encoding: hex.EncodeToString(enc), Hash: hex.EncodeToString(c.tx.Hash())}
+		if sp, ok := c.tx.(signingPayloader); ok {
+			cv.SigningBytes = hex.EncodeToString(sp.SigningPayload(payload.DefaultChainID))
+		}
+		v.Vectors = append(v.Vectors, cv)
+	}
+	return v
+}
+
+// The golden vectors pin the v1 encoding: Go must reproduce them exactly,
+// and each decodes back to the same tx.
+func TestCodec_GoldenVectors(t *testing.T) {
+	want := goldenVectors(t)
+	if *update {
+		b, _ := json.MarshalIndent(want, "", "  ")
+		if err := os.WriteFile(vectorsPath, append(b, '\n'), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	raw, err := os.ReadFile(vectorsPath)
+	if err != nil {
+		t.Fatalf("read vectors: %v", err)
+	}
+	var got codecVectors
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("parse vectors: %v", err)
+	}
+	if len(got.Vectors) != len(want.Vectors) {
+		t.Fatalf("%d vectors on disk, %d generated (run with -update)", len(got.Vectors), len(want.Vectors))
+	}
+	for i, v := range got.Vectors {
+		if !vectorEqual(v, want.Vectors[i]) {
+			t.Fatalf("%s: vector differs from the codec (run with -update if intended)", v.Name)
+		}
+		tx := v.Tx.ToInternal()
+		enc, _ := MarshalTx(tx)
+		if hex.EncodeToString(enc) != v.Encoding || hex.EncodeToString(tx.Hash()) != v.Hash {
+			t.Fatalf("%s: encoding/hash mismatch", v.Name)
+		}
+		dec, err := UnmarshalTx(enc)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", v.Name, err)
+		}
+		if re, _ := MarshalTx(dec); !bytes.Equal(re, enc) {
+			t.Fatalf("%s: decode does not round-trip", v.Name)
+		}
+		if v.SigningBytes != "" && v.Tx.Sig != nil {
+			if err := payload.VerifySig(tx, got.ChainID); err != nil {
+				t.Fatalf("%s: signature: %v", v.Name, err)
+			}
+		}
+	}
+	if got.Vectors[1].Hash == got.Vectors[2].Hash {
+		t.Fatalf("nonces 1 and 257 hash alike")
+	}
+}
+
+func vectorEqual(a, b codecVector) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return bytes.Equal(ja, jb)
+}
+
+func TestCodec_RejectsMalformed(t *testing.T) {
+	enc, _ := MarshalTx(&plaintext.PlaintextTx{From: "a", Gas: 1})
+	bad := map[string][]byte{
+		"empty":     nil,
+		"version":   append([]byte{9}, enc[1:]...),
+		"truncated": enc[:len(enc)-1],
+		"trailing":  append(append([]byte(nil), enc...), 0),
+	}
+	for name, b := range bad {
+		if _, err := UnmarshalTx(b); !errors.Is(err, payload.ErrCodec) {
+			t.Fatalf("%s: err=%v", name, err)
+		}
+	}
+	// A payload of one type does not decode as another.
+	if err := (&auction.AuctionBidTx{}).UnmarshalBinary(enc); !errors.Is(err, payload.ErrCodec) {
+		t.Fatalf("cross-type decode: %v", err)
+	}
+}
@@ -21,32 +21,51 @@ type QBFT struct {
 	Payload []byte `json:"payload,omitempty"`
 	TraceID string `json:"trace_id,omitempty"`
 	Sig     []byte `json:"sig,omitempty"`
+
+	PreparedRound uint64 `json:"prepared_round,omitempty"`
+	PreparedID    string `json:"prepared_id,omitempty"`
+	Justification []QBFT `json:"justification,omitempty"`
+	Share         []byte `json:"share,omitempty"`
 }
 
 // FromInternal converts an internal qbft.Message to its wire form.
 func FromInternal(msg qbft.Message) QBFT {
-	return QBFT{
-		ID:      msg.ID,
-		From:    msg.From,
-		Height:  msg.Height,
-		Round:   msg.Round,
-		Type:    string(msg.Type),
-		Payload: msg.Payload,
-		TraceID: msg.TraceID,
-		Sig:     msg.Sig,
+	w := QBFT{
+		ID:            msg.ID,
+		From:          msg.From,
+		Height:        msg.Height,
+		Round:         msg.Round,
+		Type:          string(msg.Type),
+		Payload:       msg.Payload,
+		TraceID:       msg.TraceID,
+		Sig:           msg.Sig,
+		PreparedRound: msg.PreparedRound,
+		PreparedID:    msg.PreparedID,
+		Share:         msg.Share,
+	}
+	for _, j := range msg.Justification {
+		w.Justification = append(w.Justification, FromInternal(j))
 	}
+	return w
 }
 
 // ToInternal converts a wire-form QBFT message to the internal type.
 func (w QBFT) ToInternal() qbft.Message {
-	return qbft.Message{
-		ID:      w.ID,
-		From:    w.From,
-		Height:  w.Height,
-		Round:   w.Round,
-		Type:    qbft.Type(w.Type),
-		Payload: w.Payload,
-		TraceID: w.TraceID,
-		Sig:     w.Sig,
+	msg := qbft.Message{
+		ID:            w.ID,
+		From:          w.From,
+		Height:        w.Height,
+		Round:         w.Round,
+		Type:          qbft.Type(w.Type),
+		Payload:       w.Payload,
+		TraceID:       w.TraceID,
+		Sig:           w.Sig,
+		PreparedRound: w.PreparedRound,
+		PreparedID:    w.PreparedID,
+		Share:         w.Share,
+	}
+	for _, j := range w.Justification {
+		msg.Justification = append(msg.Justification, j.ToInternal())
 	}
+	return msg
 }
@@ -0,0 +1,36 @@
+package wire
+
+import "github.com/zmlAEQ/Aequa-network/internal/state"
+
+// ProtocolSync is the request/response protocol a lagging node uses to fetch
+// committed blocks from a peer (one request and one response per stream).
+const ProtocolSync = "/aequa/sync/v1"
+
+// MaxSyncBlocks caps the number of blocks a peer returns per request.
+const MaxSyncBlocks = 64
+
+// SyncRequest asks a peer for committed blocks starting at From, in
+// ascending height order, at most Limit of them (0 or above MaxSyncBlocks
+// means MaxSyncBlocks).
+type SyncRequest struct {
+	From  uint64 `json:"from"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+// SyncResponse returns consecutive committed blocks starting at the requested
+// height, each with its commit seal, and the responder's highest committed
+// height (Last) so the requester knows whether to continue. HasLast is false
+// when the responder has no block at all.
+type SyncResponse struct {
+	Blocks  []state.BlockRecord `json:"blocks,omitempty"`
+	Last    uint64              `json:"last"`
+	HasLast bool                `json:"has_last"`
+}
+
+// SyncLimit returns the effective number of blocks to serve for req.
+func (r SyncRequest) SyncLimit() int {
+	if r.Limit <= 0 || r.Limit > MaxSyncBlocks {
+		return MaxSyncBlocks
+	}
+	return r.Limit
+}
@@ -1,12 +1,19 @@
 package wire
 
 import (
+	"encoding"
 	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/zmlAEQ/Aequa-network/internal/payload"
-	auction "github.com/zmlAEQ/Aequa-network/internal/payload/auction_bid_v1"
-	plaintext "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
-	private "github.com/zmlAEQ/Aequa-network/internal/payload/private_v1"
+	// The built-in types register their payload.Kind.
+	_ "github.com/zmlAEQ/Aequa-network/internal/payload/auction_bid_v1"
+	_ "github.com/zmlAEQ/Aequa-network/internal/payload/bundle_v1"
+	_ "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+	_ "github.com/zmlAEQ/Aequa-network/internal/payload/private_v1"
+	_ "github.com/zmlAEQ/Aequa-network/internal/payload/reconfig_v1"
 )
 
 // Topic name for transaction gossip.
@@ -16,17 +23,24 @@ const (
 	TypePlaintextV1  = "plaintext_v1"
 	TypeAuctionBidV1 = "auction_bid_v1"
 	TypePrivateV1    = "private_v1"
+	TypeReconfigV1   = "reconfig_v1"
+	TypeBundleV1     = "bundle_v1"
 )
 
-// TxEnvelope is a wire-format transaction that supports multiple tx types.
+// TxEnvelope is a wire-format transaction: the type tag and the JSON fields
+// of the registered payload type, flattened. The named fields are those of
+// the built-in types; fields of other types are kept in Extra.
 type TxEnvelope struct {
-	Type         string `json:"type"` // plaintext_v1 (default) or auction_bid_v1
+	Type         string `json:"type"` // a registered payload type; empty means plaintext_v1
 	From         string `json:"from"`
 	Nonce        uint64 `json:"nonce"`
 	Gas          uint64 `json:"gas"`
 	Fee          uint64 `json:"fee,omitempty"`
 	Bid          uint64 `json:"bid,omitempty"`
 	FeeRecipient string `json:"fee_recipient,omitempty"`
+	// plaintext_v1 transfer fields
+	To    string `json:"to,omitempty"`
+	Value uint64 `json:"value,omitempty"`
 	// private_v1 fields
 	Ciphertext   []byte `json:"ciphertext,omitempty"`
 	EphemeralKey []byte `json:"ephemeral_key,omitempty"`
@@ -34,91 +48,115 @@ type TxEnvelope struct {
 	BatchIndex   uint64 `json:"batch_index,omitempty"`
 	PuncturedKey []byte `json:"punctured_key,omitempty"`
 	Sig          []byte `json:"sig,omitempty"`
+
+	// reconfig_v1 fields
+	Epoch     uint64 `json:"epoch,omitempty"`
+	Op        string `json:"op,omitempty"`
+	Operator  string `json:"operator,omitempty"`
+	PubKey    []byte `json:"pubkey,omitempty"`
+	Threshold int    `json:"threshold,omitempty"`
+
+	// Extra holds the fields no named field covers, by JSON key.
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
-// TxFromInternal converts a generic payload to a wire tx if supported.
-func TxFromInternal(pl payload.Payload) (TxEnvelope, bool) {
-	switch tx := pl.(type) {
-	case *plaintext.PlaintextTx:
-		if tx.Type() != TypePlaintextV1 {
-			return TxEnvelope{}, false
+// envelopeKeys are the (lower-cased) JSON keys of the named fields.
+var envelopeKeys = func() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(TxEnvelope{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			keys[strings.ToLower(name)] = true
 		}
-		return TxEnvelope{
-			Type:  tx.Type(),
-			From:  tx.From,
-			Nonce: tx.Nonce,
-			Gas:   tx.Gas,
-			Fee:   tx.Fee,
-			Sig:   tx.Sig,
-		}, true
-	case *auction.AuctionBidTx:
-		if tx.Type() != TypeAuctionBidV1 {
-			return TxEnvelope{}, false
+	}
+	return keys
+}()
+
+// UnmarshalJSON decodes the named fields and keeps the rest in Extra.
+func (w *TxEnvelope) UnmarshalJSON(b []byte) error {
+	type named TxEnvelope
+	var n named
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return err
+	}
+	n.Extra = nil
+	for k, v := range all {
+		if envelopeKeys[strings.ToLower(k)] {
+			continue
 		}
-		return TxEnvelope{
-			Type:         tx.Type(),
-			From:         tx.From,
-			Nonce:        tx.Nonce,
-			Gas:          tx.Gas,
-			Bid:          tx.Bid,
-			FeeRecipient: tx.FeeRecipient,
-			Sig:          tx.Sig,
-		}, true
-	case *private.PrivateTx:
-		if tx.Type() != TypePrivateV1 {
-			return TxEnvelope{}, false
+		if n.Extra == nil {
+			n.Extra = map[string]json.RawMessage{}
 		}
-		return TxEnvelope{
-			Type:         tx.Type(),
-			From:         tx.From,
-			Nonce:        tx.Nonce,
-			Ciphertext:   tx.Ciphertext,
-			EphemeralKey: tx.EphemeralKey,
-			TargetHeight: tx.TargetHeight,
-			BatchIndex:   tx.BatchIndex,
-			PuncturedKey: tx.PuncturedKey,
-		}, true
-	default:
+		n.Extra[k] = v
+	}
+	*w = TxEnvelope(n)
+	return nil
+}
+
+// MarshalJSON encodes the named fields followed by Extra.
+func (w TxEnvelope) MarshalJSON() ([]byte, error) {
+	type named TxEnvelope
+	b, err := json.Marshal(named(w))
+	if err != nil || len(w.Extra) == 0 {
+		return b, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	for k, v := range w.Extra {
+		if !envelopeKeys[strings.ToLower(k)] {
+			all[k] = v
+		}
+	}
+	return json.Marshal(all)
+}
+
+// TxFromInternal converts a payload of a registered type to a wire tx.
+func TxFromInternal(pl payload.Payload) (TxEnvelope, bool) {
+	if pl == nil {
 		return TxEnvelope{}, false
 	}
+	k, ok := payload.Lookup(pl.Type())
+	if !ok || reflect.TypeOf(pl) != reflect.TypeOf(k.New()) {
+		return TxEnvelope{}, false
+	}
+	b, err := json.Marshal(pl)
+	if err != nil {
+		return TxEnvelope{}, false
+	}
+	var env TxEnvelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return TxEnvelope{}, false
+	}
+	env.Type = pl.Type()
+	return env, true
 }
 
-// ToInternal converts the wire tx back to a payload instance.
+// ToInternal converts the wire tx back to a payload of its registered type,
+// or nil when the type is unknown or the fields do not decode.
 func (w TxEnvelope) ToInternal() payload.Payload {
 	if w.Type == "" {
 		w.Type = TypePlaintextV1
 	}
-	switch w.Type {
-	case TypePlaintextV1:
-		return &plaintext.PlaintextTx{
-			From:  w.From,
-			Nonce: w.Nonce,
-			Gas:   w.Gas,
-			Fee:   w.Fee,
-			Sig:   w.Sig,
-		}
-	case TypeAuctionBidV1:
-		return &auction.AuctionBidTx{
-			From:         w.From,
-			Nonce:        w.Nonce,
-			Gas:          w.Gas,
-			Bid:          w.Bid,
-			FeeRecipient: w.FeeRecipient,
-			Sig:          w.Sig,
-		}
-	case TypePrivateV1:
-		return &private.PrivateTx{
-			From:         w.From,
-			Nonce:        w.Nonce,
-			Ciphertext:   w.Ciphertext,
-			EphemeralKey: w.EphemeralKey,
-			TargetHeight: w.TargetHeight,
-			BatchIndex:   w.BatchIndex,
-			PuncturedKey: w.PuncturedKey,
-		}
-	default:
+	k, ok := payload.Lookup(w.Type)
+	if !ok {
+		return nil
+	}
+	b, err := json.Marshal(w)
+	if err != nil {
+		return nil
+	}
+	pl := k.New()
+	if err := json.Unmarshal(b, pl); err != nil {
 		return nil
 	}
+	return pl
 }
 
 // ParseTx decodes JSON into a payload.Payload (without calling Validate).
@@ -129,3 +167,17 @@ func ParseTx(b []byte) (payload.Payload, error) {
 	}
 	return env.ToInternal(), nil
 }
+
+// MarshalTx returns the canonical binary encoding of a payload (see
+// payload.CodecVersion), the form its hash and signature are computed over.
+func MarshalTx(pl payload.Payload) ([]byte, error) {
+	m, ok := pl.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%w: no binary encoding for %q", payload.ErrCodec, pl.Type())
+	}
+	return m.MarshalBinary()
+}
+
+// UnmarshalTx decodes a canonical binary encoding of any registered tx type
+// (without calling Validate).
+func UnmarshalTx(b []byte) (payload.Payload, error) { return payload.Decode(b) }
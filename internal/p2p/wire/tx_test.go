@@ -0,0 +1,131 @@
+package wire
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zmlAEQ/Aequa-network/internal/payload"
+)
+
+// memoTx is a payload type defined outside the tree's type packages: it
+// reaches the wire, the codec, the builder and block stats only through its
+// registered payload.Kind.
+type memoTx struct {
+	From  string `json:"from"`
+	Nonce uint64 `json:"nonce"`
+	Tip   uint64 `json:"tip"`
+	Memo  string `json:"memo,omitempty"`
+}
+
+func (t *memoTx) Type() string { return "memo_test_v1" }
+func (t *memoTx) body() *payload.Encoder {
+	return payload.NewEncoder(t.Type()).String(t.From).Uint64(t.Nonce).Uint64(t.Tip).String(t.Memo)
+}
+func (t *memoTx) Hash() []byte                   { return t.body().Hash() }
+func (t *memoTx) Validate() error                { return nil }
+func (t *memoTx) SortKey() uint64                { return t.Tip }
+func (t *memoTx) MarshalBinary() ([]byte, error) { return t.body().Out(), nil }
+func (t *memoTx) UnmarshalBinary(b []byte) error {
+	d := payload.NewDecoder(b, t.Type())
+	v := memoTx{From: d.String(), Nonce: d.Uint64(), Tip: d.Uint64(), Memo: d.String()}
+	if err := d.Finish(); err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
+// memoPool keeps memos in insertion order.
+type memoPool struct{ items []payload.Payload }
+
+func (p *memoPool) Add(pl payload.Payload) error { p.items = append(p.items, pl); return nil }
+func (p *memoPool) Get(n, _ int) []payload.Payload {
+	if n > len(p.items) {
+		n = len(p.items)
+	}
+	return p.items[:n]
+}
+func (p *memoPool) Len() int { return len(p.items) }
+
+var registerMemo sync.Once
+
+func memoKind() {
+	registerMemo.Do(func() {
+		payload.Register(payload.Kind{
+			Type: "memo_test_v1",
+			New:  func() payload.Payload { return &memoTx{} },
+			Verify: func(p payload.Payload, _ string) error {
+				if p.(*memoTx).From == "" {
+					return payload.ErrBadSignature
+				}
+				return nil
+			},
+			Pool: func(payload.Limits) payload.TypedMempool { return &memoPool{} },
+			Threshold: func(p payload.Payload, pol payload.BuilderPolicy) string {
+				if p.SortKey() < pol.MinFee {
+					return "below_min_tip"
+				}
+				return ""
+			},
+			Stats: func(p payload.Payload, s *payload.BlockStats) { s.TotalFees += p.(*memoTx).Tip },
+		})
+	})
+}
+
+func TestRegistry_CustomTypeOnWire(t *testing.T) {
+	memoKind()
+	tx := &memoTx{From: "alice", Nonce: 3, Tip: 9, Memo: "hi"}
+	env, ok := TxFromInternal(tx)
+	if !ok || env.Type != "memo_test_v1" || env.From != "alice" || string(env.Extra["memo"]) != `"hi"` {
+		t.Fatalf("envelope %+v ok=%v", env, ok)
+	}
+	raw, err := json.Marshal(env)
+	if err != nil || !strings.Contains(string(raw), `"tip":9`) {
+		t.Fatalf("json %s err=%v", raw, err)
+	}
+	got, err := ParseTx(raw)
+	if err != nil || !reflect.DeepEqual(got, tx) {
+		t.Fatalf("parsed %+v err=%v", got, err)
+	}
+	enc, _ := MarshalTx(tx)
+	if dec, err := UnmarshalTx(enc); err != nil || !reflect.DeepEqual(dec, tx) {
+		t.Fatalf("binary %+v err=%v", dec, err)
+	}
+	blk := payload.StandardBlock{Header: payload.BlockHeader{Height: 1}, Items: []payload.Payload{tx}}
+	b, err := EncodeBlock(blk)
+	if err != nil {
+		t.Fatalf("encode block: %v", err)
+	}
+	if dec, _ := DecodeBlock(b); len(dec.Items) != 1 || !reflect.DeepEqual(dec.Items[0], tx) {
+		t.Fatalf("block items %+v", dec.Items)
+	}
+	if err := payload.VerifySig(&memoTx{}, ""); !errors.Is(err, payload.ErrBadSignature) {
+		t.Fatalf("validator not applied: %v", err)
+	}
+}
+
+func TestRegistry_CustomTypeInBuilder(t *testing.T) {
+	memoKind()
+	pools := payload.NewPools(payload.Limits{}, "private_v1")
+	if _, ok := pools["memo_test_v1"]; !ok {
+		t.Fatalf("no pool for the custom type")
+	}
+	if _, ok := pools["private_v1"]; ok {
+		t.Fatalf("skipped type got a pool")
+	}
+	c := payload.NewContainer(pools)
+	_ = c.Add(&memoTx{From: "a", Tip: 1})
+	_ = c.Add(&memoTx{From: "b", Tip: 20})
+	pol := payload.BuilderPolicy{Order: []string{"memo_test_v1"}, MaxN: 4, MinFee: 10}
+	blk := payload.PrepareProposal(c, payload.BlockHeader{Height: 1}, pol)
+	if len(blk.Items) != 1 || blk.Items[0].SortKey() != 20 {
+		t.Fatalf("threshold not applied: %+v", blk.Items)
+	}
+	if st := payload.Summarize(blk.Items); st.TotalFees != 20 || st.Items != 1 {
+		t.Fatalf("stats %+v", st)
+	}
+}
@@ -0,0 +1,24 @@
+package auction_bid_v1
+
+import "github.com/zmlAEQ/Aequa-network/internal/payload"
+
+func init() {
+	payload.Register(payload.Kind{
+		Type:   "auction_bid_v1",
+		New:    func() payload.Payload { return &AuctionBidTx{} },
+		Verify: func(p payload.Payload, chainID string) error { return p.(*AuctionBidTx).VerifySig(chainID) },
+		Pool:   func(lim payload.Limits) payload.TypedMempool { return NewWithLimits(lim) },
+		// Threshold keys off SortKey (the bid) so any auction_bid_v1 payload qualifies.
+		Threshold: func(p payload.Payload, pol payload.BuilderPolicy) string {
+			if pol.MinBid > 0 && p.SortKey() < pol.MinBid {
+				return "below_min_bid"
+			}
+			return ""
+		},
+		Stats: func(p payload.Payload, s *payload.BlockStats) {
+			if tx, ok := p.(*AuctionBidTx); ok {
+				s.TotalBids += tx.Bid
+			}
+		},
+	})
+}
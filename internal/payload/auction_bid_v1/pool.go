@@ -1,37 +1,58 @@
 package auction_bid_v1
 
 import (
-	"crypto/sha256"
+	"bytes"
+	"crypto/ed25519"
 	"errors"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/zmlAEQ/Aequa-network/internal/payload"
 	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
 )
 
 // AuctionBidTx represents a bid-style transaction used by DFBA.
-// It sorts by Bid (descending) while keeping per-sender nonce order.
+// It sorts by Bid (descending) while keeping per-sender nonce order. Its
+// JSON form is the wire envelope without the type tag.
 type AuctionBidTx struct {
-	From         string
-	Nonce        uint64
-	Gas          uint64
-	Bid          uint64 // used as SortKey (higher first)
-	FeeRecipient string
-	Sig          []byte // shape-only validation in this stage
+	From         string `json:"from"`
+	Nonce        uint64 `json:"nonce"`
+	Gas          uint64 `json:"gas"`
+	Bid          uint64 `json:"bid,omitempty"` // used as SortKey (higher first)
+	FeeRecipient string `json:"fee_recipient,omitempty"`
+	Sig          []byte `json:"sig,omitempty"` // ed25519 signature by From over SigningPayload
 	h            []byte // cached hash
 }
 
 func (t *AuctionBidTx) Type() string { return "auction_bid_v1" }
 
+// body is the canonical encoding of every field but Sig.
+func (t *AuctionBidTx) body() *payload.Encoder {
+	return payload.NewEncoder(t.Type()).String(t.From).Uint64(t.Nonce).Uint64(t.Gas).Uint64(t.Bid).String(t.FeeRecipient)
+}
+
 func (t *AuctionBidTx) Hash() []byte {
 	if t.h == nil {
-		sum := sha256.Sum256(append(append([]byte(t.From), []byte(t.FeeRecipient)...), byte(t.Nonce), byte(t.Gas), byte(t.Bid)))
-		t.h = sum[:]
+		t.h = t.body().Hash()
 	}
 	return t.h
 }
 
+// MarshalBinary returns the canonical encoding (payload.CodecVersion).
+func (t *AuctionBidTx) MarshalBinary() ([]byte, error) { return t.body().Bytes(t.Sig).Out(), nil }
+
+// UnmarshalBinary decodes a canonical encoding.
+func (t *AuctionBidTx) UnmarshalBinary(b []byte) error {
+	d := payload.NewDecoder(b, "auction_bid_v1")
+	v := AuctionBidTx{From: d.String(), Nonce: d.Uint64(), Gas: d.Uint64(), Bid: d.Uint64(), FeeRecipient: d.String(), Sig: d.Bytes()}
+	if err := d.Finish(); err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
 func (t *AuctionBidTx) Validate() error {
 	if t.From == "" || t.FeeRecipient == "" || t.Gas == 0 || t.Bid == 0 || len(t.Sig) < 32 {
 		return errors.New("invalid")
@@ -41,71 +62,378 @@ func (t *AuctionBidTx) Validate() error {
 
 func (t *AuctionBidTx) SortKey() uint64 { return t.Bid }
 
-// Pool implements a minimal pending/future pool with bid-based ordering.
+// SenderNonce returns the nonce slot the bid uses (payload.Sequenced).
+func (t *AuctionBidTx) SenderNonce() (string, uint64) { return t.From, t.Nonce }
+
+// SigningPayload returns the canonical bytes From signs for chainID.
+func (t *AuctionBidTx) SigningPayload(chainID string) []byte {
+	return payload.SigningBytes(chainID, t.body().Out())
+}
+
+// VerifySig checks Sig against From, the hex ed25519 public key.
+func (t *AuctionBidTx) VerifySig(chainID string) error {
+	return payload.VerifyEd25519(t.From, t.SigningPayload(chainID), t.Sig)
+}
+
+// Sign sets From to the address of key and signs the tx for chainID.
+func (t *AuctionBidTx) Sign(chainID string, key ed25519.PrivateKey) {
+	t.From = payload.Address(key.Public().(ed25519.PublicKey))
+	t.h = nil
+	t.Sig = ed25519.Sign(key, t.SigningPayload(chainID))
+}
+
+// Pool implements a nonce-ordered pending/future pool with bid-based
+// ordering, bounded by payload.Limits: global pending and future caps with
+// lowest-bid eviction, a per-sender quota and nonce-gap bound, and
+// time/height expiry.
 type Pool struct {
 	mu           sync.Mutex
+	lim          payload.Limits
 	expect       map[string]uint64
 	pendBySender map[string][]*AuctionBidTx
 	future       map[string]map[uint64]*AuctionBidTx
+	// since stamps every pooled tx for expiry
+	since  map[*AuctionBidTx]stamp
+	height uint64 // last committed height passed to Expire
+	npend  int
+	nfut   int
+}
+
+type stamp struct {
+	at     time.Time
+	height uint64
 }
 
-func New() *Pool {
+func New() *Pool { return NewWithLimits(payload.Limits{}) }
+
+// NewWithLimits builds a pool bounded by lim (zero fields keep defaults).
+func NewWithLimits(lim payload.Limits) *Pool {
 	return &Pool{
+		lim:          lim.WithDefaults(),
 		expect:       map[string]uint64{},
 		pendBySender: map[string][]*AuctionBidTx{},
 		future:       map[string]map[uint64]*AuctionBidTx{},
+		since:        map[*AuctionBidTx]stamp{},
 	}
 }
 
+func reject(result, msg string) error {
+	metrics.Inc("mempool_in_total", map[string]string{"result": result})
+	return errors.New(msg)
+}
+
 // Add inserts a payload; only AuctionBidTx is accepted.
 func (p *Pool) Add(pl payload.Payload) error {
+	_, err := p.AddReplacing(pl)
+	return err
+}
+
+// AddReplacing is Add with replace-by-fee: a tx for a (from, nonce) slot
+// already pending or queued replaces the pooled one if its bid is at least
+// Limits.MinBumpPct percent higher. It returns the replaced tx, if any.
+func (p *Pool) AddReplacing(pl payload.Payload) (payload.Payload, error) {
 	tx, ok := pl.(*AuctionBidTx)
 	if !ok || tx.Type() != "auction_bid_v1" {
-		return nil
+		return nil, nil
 	}
 	if err := tx.Validate(); err != nil {
 		metrics.Inc("mempool_in_total", map[string]string{"result": "invalid"})
-		return err
+		return nil, err
 	}
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	defer p.gauge(p.npend)
 	exp := p.expect[tx.From]
 	switch {
 	case tx.Nonce < exp:
-		metrics.Inc("mempool_in_total", map[string]string{"result": "old"})
-		return errors.New("old nonce")
-	case tx.Nonce == exp:
+		ll := p.pendBySender[tx.From]
+		if len(ll) == 0 || tx.Nonce < ll[0].Nonce {
+			return nil, reject("old", "old nonce")
+		}
+		i := tx.Nonce - ll[0].Nonce
+		if err := p.bump(ll[i], tx); err != nil {
+			return nil, err
+		}
+		old := ll[i]
+		ll[i] = tx
+		p.replaced(old, tx, "pending")
+		return old, nil
+	case tx.Nonce > exp:
+		if old, exists := p.future[tx.From][tx.Nonce]; exists {
+			if err := p.bump(old, tx); err != nil {
+				return nil, err
+			}
+			p.future[tx.From][tx.Nonce] = tx
+			p.replaced(old, tx, "future")
+			return old, nil
+		}
+		if tx.Nonce-exp > p.lim.MaxNonceGap {
+			return nil, reject("nonce_gap", "nonce gap too large")
+		}
+	}
+	if len(p.pendBySender[tx.From])+len(p.future[tx.From]) >= p.lim.MaxPerSender {
+		return nil, reject("sender_quota", "sender quota exceeded")
+	}
+	p.since[tx] = stamp{at: time.Now(), height: p.height}
+	if tx.Nonce == exp {
+		if p.npend >= p.lim.MaxPending && !p.evictPending(tx) {
+			delete(p.since, tx)
+			return nil, reject("overflow", "mempool full")
+		}
 		p.pendBySender[tx.From] = append(p.pendBySender[tx.From], tx)
+		p.npend++
 		p.expect[tx.From] = exp + 1
-		for {
-			futs := p.future[tx.From]
-			nx := p.expect[tx.From]
-			if futs == nil {
-				break
-			}
-			if next, ok := futs[nx]; ok {
-				p.pendBySender[tx.From] = append(p.pendBySender[tx.From], next)
-				delete(futs, nx)
-				p.expect[tx.From] = nx + 1
-			} else {
+		p.promote(tx.From)
+		// Promotions may push pending past the cap: trim the cheapest tails.
+		for p.npend > p.lim.MaxPending {
+			if !p.evictPending(nil) {
 				break
 			}
 		}
 		metrics.Inc("mempool_in_total", map[string]string{"result": "ok"})
-		metrics.AddGauge("mempool_size", nil, 1)
-		return nil
-	default:
-		if p.future[tx.From] == nil {
-			p.future[tx.From] = map[uint64]*AuctionBidTx{}
+		return nil, nil
+	}
+	if p.nfut >= p.lim.MaxFuture && !p.evictFuture(tx) {
+		delete(p.since, tx)
+		return nil, reject("future_overflow", "future queue full")
+	}
+	if p.future[tx.From] == nil {
+		p.future[tx.From] = map[uint64]*AuctionBidTx{}
+	}
+	p.future[tx.From][tx.Nonce] = tx
+	p.nfut++
+	metrics.Inc("mempool_in_total", map[string]string{"result": "future"})
+	return nil, nil
+}
+
+// bump checks that tx may replace old: a different bid at least
+// MinBumpPct percent more.
+func (p *Pool) bump(old, tx *AuctionBidTx) error {
+	if bytes.Equal(old.Hash(), tx.Hash()) {
+		return reject("dup", "duplicate tx")
+	}
+	if tx.Bid <= old.Bid || tx.Bid-old.Bid < p.lim.Bump(old.Bid) {
+		return reject("underpriced", "replacement bid too low")
+	}
+	return nil
+}
+
+// replaced moves old's expiry stamp to the fresh tx and counts the
+// replacement. Callers hold p.mu.
+func (p *Pool) replaced(old, tx *AuctionBidTx, slot string) {
+	delete(p.since, old)
+	p.since[tx] = stamp{at: time.Now(), height: p.height}
+	metrics.Inc("mempool_in_total", map[string]string{"result": "replaced"})
+	metrics.Inc("mempool_replaced_total", map[string]string{"slot": slot})
+}
+
+// gauge moves mempool_size by the pending count change since before.
+// Callers hold p.mu.
+func (p *Pool) gauge(before int) {
+	if d := p.npend - before; d != 0 {
+		metrics.AddGauge("mempool_size", nil, int64(d))
+	}
+}
+
+// lower orders txs for eviction: lowest bid first, ties by (from, nonce).
+func lower(a, b *AuctionBidTx) bool {
+	if a.Bid != b.Bid {
+		return a.Bid < b.Bid
+	}
+	if a.From != b.From {
+		return a.From < b.From
+	}
+	return a.Nonce < b.Nonce
+}
+
+// evictPending drops the cheapest sender tail (the highest pending nonce of a
+// sender, so the rest stays contiguous) and rolls that sender's expected
+// nonce back to it. With an incoming tx only a tail of another sender paying
+// a lower bid is evicted. Callers hold p.mu.
+func (p *Pool) evictPending(in *AuctionBidTx) bool {
+	var victim *AuctionBidTx
+	for from, ll := range p.pendBySender {
+		if in != nil && from == in.From {
+			continue
 		}
-		if _, exists := p.future[tx.From][tx.Nonce]; exists {
-			metrics.Inc("mempool_in_total", map[string]string{"result": "dup"})
-			return errors.New("duplicate future")
+		if tail := ll[len(ll)-1]; victim == nil || lower(tail, victim) {
+			victim = tail
 		}
-		p.future[tx.From][tx.Nonce] = tx
-		metrics.Inc("mempool_in_total", map[string]string{"result": "future"})
-		return nil
 	}
+	if victim == nil || (in != nil && victim.Bid >= in.Bid) {
+		return false
+	}
+	ll := p.pendBySender[victim.From]
+	p.setPending(victim.From, ll[:len(ll)-1])
+	p.expect[victim.From] = victim.Nonce
+	p.npend--
+	delete(p.since, victim)
+	metrics.Inc("mempool_evicted_total", map[string]string{"reason": "fee"})
+	return true
+}
+
+// evictFuture drops the cheapest future if the incoming bid is higher.
+// Callers hold p.mu.
+func (p *Pool) evictFuture(in *AuctionBidTx) bool {
+	var victim *AuctionBidTx
+	for _, futs := range p.future {
+		for _, tx := range futs {
+			if victim == nil || lower(tx, victim) {
+				victim = tx
+			}
+		}
+	}
+	if victim == nil || victim.Bid >= in.Bid {
+		return false
+	}
+	p.dropFuture(victim)
+	metrics.Inc("mempool_evicted_total", map[string]string{"reason": "fee"})
+	return true
+}
+
+// setPending stores a sender's pending list, dropping empty ones.
+func (p *Pool) setPending(from string, ll []*AuctionBidTx) {
+	if len(ll) == 0 {
+		delete(p.pendBySender, from)
+		return
+	}
+	p.pendBySender[from] = ll
+}
+
+// dropFuture removes a future tx. Callers hold p.mu.
+func (p *Pool) dropFuture(tx *AuctionBidTx) {
+	futs := p.future[tx.From]
+	delete(futs, tx.Nonce)
+	if len(futs) == 0 {
+		delete(p.future, tx.From)
+	}
+	delete(p.since, tx)
+	p.nfut--
+}
+
+// promote moves futures that became ready to pending. Callers hold p.mu.
+func (p *Pool) promote(from string) {
+	futs := p.future[from]
+	for futs != nil {
+		nx := p.expect[from]
+		next, ok := futs[nx]
+		if !ok {
+			break
+		}
+		p.pendBySender[from] = append(p.pendBySender[from], next)
+		delete(futs, nx)
+		p.expect[from] = nx + 1
+		p.npend++
+		p.nfut--
+	}
+	if futs != nil && len(futs) == 0 {
+		delete(p.future, from)
+	}
+}
+
+// RemoveCommitted drops the committed txs together with every pending or
+// future tx of their senders at or below a committed nonce, advances the
+// senders' expected nonces past them and promotes futures that became ready.
+// Committed txs this pool never saw still advance their sender. It returns
+// the txs it dropped.
+func (p *Pool) RemoveCommitted(items []payload.Payload) []payload.Payload {
+	next := map[string]uint64{} // sender -> first nonce not committed
+	var senders []string
+	for _, it := range items {
+		tx, ok := it.(*AuctionBidTx)
+		if !ok {
+			continue
+		}
+		n, seen := next[tx.From]
+		if !seen {
+			senders = append(senders, tx.From)
+		}
+		if tx.Nonce+1 > n {
+			next[tx.From] = tx.Nonce + 1
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer p.gauge(p.npend)
+	var out []payload.Payload
+	for _, from := range senders {
+		n := next[from]
+		keep := make([]*AuctionBidTx, 0, len(p.pendBySender[from]))
+		for _, tx := range p.pendBySender[from] {
+			if tx.Nonce < n {
+				out = append(out, tx)
+				delete(p.since, tx)
+				p.npend--
+				continue
+			}
+			keep = append(keep, tx)
+		}
+		p.setPending(from, keep)
+		for nonce, tx := range p.future[from] {
+			if nonce < n {
+				out = append(out, tx)
+				p.dropFuture(tx)
+			}
+		}
+		if n > p.expect[from] {
+			p.expect[from] = n
+		}
+		p.promote(from)
+	}
+	return out
+}
+
+// Expire drops txs that outlived the pool's TTL as of now and the committed
+// height. When a pending tx expires its sender's later pending txs move back
+// to the future queue behind the reopened nonce.
+func (p *Pool) Expire(now time.Time, height uint64) []payload.Payload {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer p.gauge(p.npend)
+	if height > p.height {
+		p.height = height
+	}
+	expired := func(tx *AuctionBidTx) bool {
+		st := p.since[tx]
+		return p.lim.Expired(st.at, st.height, now, p.height)
+	}
+	var out []payload.Payload
+	for _, futs := range p.future {
+		for _, tx := range futs {
+			if expired(tx) {
+				out = append(out, tx)
+				p.dropFuture(tx)
+			}
+		}
+	}
+	for from, ll := range p.pendBySender {
+		i := 0
+		for i < len(ll) && !expired(ll[i]) {
+			i++
+		}
+		if i == len(ll) {
+			continue
+		}
+		p.setPending(from, ll[:i])
+		p.expect[from] = ll[i].Nonce
+		p.npend -= len(ll) - i
+		for _, tx := range ll[i:] {
+			if expired(tx) {
+				out = append(out, tx)
+				delete(p.since, tx)
+				continue
+			}
+			if p.future[from] == nil {
+				p.future[from] = map[uint64]*AuctionBidTx{}
+			}
+			p.future[from][tx.Nonce] = tx
+			p.nfut++
+		}
+	}
+	for range out {
+		metrics.Inc("mempool_evicted_total", map[string]string{"reason": "expired"})
+	}
+	return out
 }
 
 // Get returns up to n ready txs ordered by bid (desc), stable by (from,nonce).
@@ -130,9 +458,5 @@ func (p *Pool) Get(n int, _ int) []payload.Payload {
 func (p *Pool) Len() int {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	sum := 0
-	for _, ll := range p.pendBySender {
-		sum += len(ll)
-	}
-	return sum
+	return p.npend
 }
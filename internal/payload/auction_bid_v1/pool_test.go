@@ -1,7 +1,10 @@
 package auction_bid_v1
 
 import (
+	"crypto/ed25519"
 	"testing"
+
+	"github.com/zmlAEQ/Aequa-network/internal/payload"
 )
 
 func TestPool_AddAndGetOrdersByBid(t *testing.T) {
@@ -55,3 +58,71 @@ func TestPool_RejectInvalid(t *testing.T) {
 		t.Fatalf("expected error for short sig")
 	}
 }
+
+func TestPool_RemoveCommittedDropsStaleBids(t *testing.T) {
+	p := New()
+	bid := func(nonce, v uint64) *AuctionBidTx {
+		return &AuctionBidTx{From: "A", Nonce: nonce, Gas: 1, Bid: v, FeeRecipient: "r", Sig: make([]byte, 32)}
+	}
+	b0 := bid(0, 10)
+	_ = p.Add(b0)
+	_ = p.Add(bid(1, 10))
+	_ = p.Add(bid(2, 10))
+	dropped := p.RemoveCommitted([]payload.Payload{b0, bid(1, 3)})
+	if len(dropped) != 2 || p.Len() != 1 {
+		t.Fatalf("dropped %d, pending %d", len(dropped), p.Len())
+	}
+	if got := p.Get(1, 0); got[0].(*AuctionBidTx).Nonce != 2 {
+		t.Fatalf("left %+v", got[0])
+	}
+}
+
+func TestPool_FullPoolEvictsLowestBid(t *testing.T) {
+	p := NewWithLimits(payload.Limits{MaxPending: 2})
+	bid := func(from string, v uint64) *AuctionBidTx {
+		return &AuctionBidTx{From: from, Gas: 1, Bid: v, FeeRecipient: "r", Sig: make([]byte, 32)}
+	}
+	_ = p.Add(bid("A", 10))
+	_ = p.Add(bid("B", 20))
+	if err := p.Add(bid("C", 5)); err == nil {
+		t.Fatalf("lower bid admitted into a full pool")
+	}
+	if err := p.Add(bid("C", 15)); err != nil {
+		t.Fatalf("higher bid: %v", err)
+	}
+	got := p.Get(0, 0)
+	if len(got) != 2 || got[0].(*AuctionBidTx).From != "B" || got[1].(*AuctionBidTx).From != "C" {
+		t.Fatalf("pending %+v", got)
+	}
+}
+
+func TestPool_ReplaceByBid(t *testing.T) {
+	p := New()
+	bid := func(nonce, v uint64) *AuctionBidTx {
+		return &AuctionBidTx{From: "A", Nonce: nonce, Gas: 1, Bid: v, FeeRecipient: "r", Sig: make([]byte, 32)}
+	}
+	_ = p.Add(bid(0, 100))
+	if err := p.Add(bid(0, 105)); err == nil {
+		t.Fatalf("replacement below the default 10%% bump accepted")
+	}
+	old, err := p.AddReplacing(bid(0, 150))
+	if err != nil || old.(*AuctionBidTx).Bid != 100 {
+		t.Fatalf("replacement: old=%v err=%v", old, err)
+	}
+	if got := p.Get(0, 0); len(got) != 1 || got[0].(*AuctionBidTx).Bid != 150 {
+		t.Fatalf("pending %+v", got)
+	}
+}
+
+func TestAuctionBidTx_VerifySig(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	tx := &AuctionBidTx{Nonce: 1, Gas: 1, Bid: 9, FeeRecipient: "r"}
+	tx.Sign(payload.DefaultChainID, key)
+	if err := payload.VerifySig(tx, ""); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	tx.FeeRecipient = "attacker"
+	if err := payload.VerifySig(tx, ""); err != payload.ErrBadSignature {
+		t.Fatalf("redirected fee recipient accepted: %v", err)
+	}
+}
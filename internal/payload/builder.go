@@ -1,6 +1,7 @@
 package payload
 
 import (
+	"bytes"
 	"errors"
 	"os"
 	"sort"
@@ -40,7 +41,8 @@ func PrepareProposal(c *Container, hdr BlockHeader, pol BuilderPolicy) StandardB
 		window = max
 	}
 	res := make([]Payload, 0, max)
-	remain := max
+	remain := max // in txs: a bundle counts its size
+	sel := newSelection()
 	now := time.Now()
 	windowDur := time.Duration(pol.BatchTicks) * time.Millisecond
 	for _, typ := range pol.Order {
@@ -56,12 +58,12 @@ func PrepareProposal(c *Container, hdr BlockHeader, pol BuilderPolicy) StandardB
 		if typ == "private_v1" && os.Getenv("AEQUA_ENABLE_BEAST") == "1" {
 			filtered = decryptAndMapPrivate(hdr, filtered)
 		}
-		selected := takeDeterministic(filtered, need, max-len(res))
+		selected, n := takeFitting(sequence(filtered), need, sel)
 		res = append(res, selected...)
 		for i := 0; i < len(selected); i++ {
 			metrics.Inc("builder_selected_total", map[string]string{"type": typ})
 		}
-		remain = max - len(res)
+		remain -= n
 	}
 	return StandardBlock{Header: hdr, Items: res}
 }
@@ -120,9 +122,14 @@ func prepareProposalDFBA(c *Container, hdr BlockHeader, pol BuilderPolicy) Stand
 	for _, it := range out.Selected {
 		if plAny, ok := it.Payload.(Payload); ok && plAny != nil {
 			res = append(res, plAny)
-			metrics.Inc("builder_selected_total", map[string]string{"type": it.Type})
 		}
 	}
+	// DFBA caps items; senders' nonces are put back in order, bundles count
+	// their size and conflicts are dropped.
+	res, _ = takeFitting(sequenceRuns(res), max, newSelection())
+	for _, p := range res {
+		metrics.Inc("builder_selected_total", map[string]string{"type": p.Type()})
+	}
 	// mark DFBA-specific drops for observability; reuse existing builder_reject_total
 	for _, it := range all {
 		if _, ok := selectedSet[string(it.Hash)]; !ok {
@@ -136,7 +143,9 @@ func prepareProposalDFBA(c *Container, hdr BlockHeader, pol BuilderPolicy) Stand
 // Checks:
 // - Items only contain allowed types in policy
 // - Type ordering obeys policy (all of a type appear before lower priority types)
-// - Within the same type, SortKey is non-increasing
+// - Within the same type, SortKey is non-increasing (capped per sender, see sequence)
+// - Bundles decode, and no two items carry the same tx or nonce slot
+// - Each sender's nonces, bundle txs included, strictly ascend
 func ProcessProposal(b StandardBlock, pol BuilderPolicy) error {
 	if len(pol.Order) == 0 {
 		return nil
@@ -149,22 +158,46 @@ func ProcessProposal(b StandardBlock, pol BuilderPolicy) error {
 	lastPri := -1
 	// track last SortKey per type to enforce non-increasing order
 	lastKey := map[string]uint64{}
+	senderKey := map[string]uint64{} // effective key of each sender's last tx
+	next := map[string]uint64{}      // per sender: lowest nonce still allowed
+	claimed := map[string]bool{}
 	for _, it := range b.Items {
 		t := it.Type()
 		p, ok := pri[t]
 		if !ok {
 			return errors.New("unexpected payload type: " + t)
 		}
+		if bd, ok := it.(Bundle); ok && len(bd.Inner()) == 0 {
+			return errors.New("malformed bundle")
+		}
+		if !claim(claimed, ConflictKeys(it)) {
+			return errors.New("conflicting payloads in block: " + t)
+		}
+		for _, in := range parts(it) {
+			if sender, nonce, ok := seqKey(in); ok {
+				if n, seen := next[sender]; seen && nonce < n {
+					return errors.New("nonce out of order for sender: " + sender)
+				}
+				next[sender] = nonce + 1
+			}
+		}
 		if p < lastPri {
 			return errors.New("type priority violated")
 		}
+		key := it.SortKey()
+		if sender, _, ok := seqKey(it); ok {
+			if prev, seen := senderKey[sender]; seen && prev < key {
+				key = prev
+			}
+			senderKey[sender] = key
+		}
 		if prev, seen := lastKey[t]; seen {
 			// enforce non-increasing sort key per type (DFBA fairness)
-			if it.SortKey() > prev {
+			if key > prev {
 				return errors.New("sortkey not non-increasing for type: " + t)
 			}
 		}
-		lastKey[t] = it.SortKey()
+		lastKey[t] = key
 		if p > lastPri {
 			lastPri = p
 		}
@@ -174,43 +207,57 @@ func ProcessProposal(b StandardBlock, pol BuilderPolicy) error {
 
 // belowThreshold returns reason if payload should be rejected for DFBA thresholds.
 func belowThreshold(typ string, p Payload, pol BuilderPolicy) string {
-	switch typ {
-	case "auction_bid_v1":
-		if pol.MinBid > 0 {
-			if p.SortKey() < pol.MinBid {
-				return "below_min_bid"
-			}
-		}
-	case "plaintext_v1":
-		if pol.MinFee > 0 {
-			if p.SortKey() < pol.MinFee {
-				return "below_min_fee"
-			}
-		}
+	if k, ok := Lookup(typ); ok && k.Threshold != nil {
+		return k.Threshold(p, pol)
 	}
 	return ""
 }
 
-// filterByWindowAndThreshold applies window time check and thresholds.
+// filterByWindowAndThreshold applies window time check and thresholds. A
+// sender's txs above a nonce it rejects are dropped too: they could not
+// execute.
 func filterByWindowAndThreshold(c *Container, cands []Payload, typ string, pol BuilderPolicy, now time.Time, windowDur time.Duration) []Payload {
 	filtered := make([]Payload, 0, len(cands))
+	gap := map[string]uint64{} // per sender: lowest rejected nonce
+	rejected := func(p Payload) {
+		if sender, nonce, ok := seqKey(p); ok {
+			if n, seen := gap[sender]; !seen || nonce < n {
+				gap[sender] = nonce
+			}
+		}
+	}
 	for _, p := range cands {
 		// Time window check if configured
 		if windowDur > 0 {
 			if meta, ok := c.Arrival(p); ok {
 				if meta.TS.Before(now.Add(-windowDur)) {
 					metrics.Inc("builder_reject_total", map[string]string{"type": typ, "reason": "late"})
+					rejected(p)
 					continue
 				}
 			}
 		}
 		if reject := belowThreshold(typ, p, pol); reject != "" {
 			metrics.Inc("builder_reject_total", map[string]string{"type": typ, "reason": reject})
+			rejected(p)
 			continue
 		}
 		filtered = append(filtered, p)
 	}
-	return filtered
+	if len(gap) == 0 {
+		return filtered
+	}
+	kept := filtered[:0]
+	for _, p := range filtered {
+		if sender, nonce, ok := seqKey(p); ok {
+			if n, seen := gap[sender]; seen && nonce > n {
+				metrics.Inc("builder_reject_total", map[string]string{"type": typ, "reason": "nonce_gap"})
+				continue
+			}
+		}
+		kept = append(kept, p)
+	}
+	return kept
 }
 
 // decryptAndMapPrivate: BEAST decrypt + mapping into sortable payload, with basic
@@ -254,33 +301,166 @@ func decryptAndMapPrivate(hdr BlockHeader, cands []Payload) []Payload {
 	return out
 }
 
-// takeDeterministic sorts by arrival seq asc, then SortKey desc, and takes up to need, respecting total budget.
-func takeDeterministic(cands []Payload, need int, budget int) []Payload {
-	if need > budget {
-		need = budget
+// selection is what a block being built already holds.
+type selection struct {
+	claimed map[string]bool   // ConflictKeys of the taken payloads
+	next    map[string]uint64 // per sender: the nonce after its last taken tx
+	gapped  map[string]bool   // senders with a skipped nonce
+}
+
+func newSelection() *selection {
+	return &selection{claimed: map[string]bool{}, next: map[string]uint64{}, gapped: map[string]bool{}}
+}
+
+// inOrder reports whether taking p keeps every sender's nonces ascending
+// and does not follow a nonce the selection skipped.
+func (sel *selection) inOrder(p Payload) bool {
+	seen := map[string]uint64{}
+	for _, it := range parts(p) {
+		sender, nonce, ok := seqKey(it)
+		if !ok {
+			continue
+		}
+		n, has := seen[sender]
+		if !has {
+			n, has = sel.next[sender]
+		}
+		if sel.gapped[sender] || (has && nonce < n) {
+			return false
+		}
+		seen[sender] = nonce + 1
+	}
+	return true
+}
+
+func (sel *selection) take(p Payload) {
+	claim(sel.claimed, ConflictKeys(p))
+	for _, it := range parts(p) {
+		if sender, nonce, ok := seqKey(it); ok {
+			sel.next[sender] = nonce + 1
+		}
+	}
+}
+
+// skip records that p is left out. Its senders' later nonces could not
+// execute, unless the skipped nonce is already taken by another payload.
+func (sel *selection) skip(p Payload) {
+	for _, it := range parts(p) {
+		if sender, nonce, ok := seqKey(it); ok && !sel.claimed[slotKey(sender, nonce)] {
+			sel.gapped[sender] = true
+		}
 	}
-	sort.SliceStable(cands, func(i, j int) bool {
-		// Primary: SortKey desc; tie-breaker: Hash asc.
-		ki := cands[i].SortKey()
-		kj := cands[j].SortKey()
-		if ki != kj {
-			return ki > kj
-		}
-		ci := cands[i].Hash()
-		cj := cands[j].Hash()
-		n := len(ci)
-		if len(cj) < n {
-			n = len(cj)
-		}
-		for k := 0; k < n; k++ {
-			if ci[k] != cj[k] {
-				return ci[k] < cj[k]
+}
+
+// takeFitting takes cands in order while they fit in need txs. A bundle is
+// taken whole or skipped when too large for what is left, and a payload
+// conflicting with one already taken (see ConflictKeys) is skipped, so
+// whichever comes first in the policy order wins. A payload that would put
+// a sender's nonces out of order, or follow one of its skipped nonces, is
+// skipped as well. It returns the taken payloads and their size in txs.
+func takeFitting(cands []Payload, need int, sel *selection) ([]Payload, int) {
+	out := make([]Payload, 0, len(cands))
+	n := 0
+	for _, p := range cands {
+		if n >= need {
+			break
+		}
+		reason := ""
+		switch {
+		case Weight(p) > need-n:
+			reason = "bundle_too_large"
+		case conflicts(sel.claimed, ConflictKeys(p)):
+			reason = "conflict"
+		case !sel.inOrder(p):
+			reason = "nonce_order"
+		}
+		if reason != "" {
+			sel.skip(p)
+			metrics.Inc("builder_reject_total", map[string]string{"type": p.Type(), "reason": reason})
+			continue
+		}
+		sel.take(p)
+		out = append(out, p)
+		n += Weight(p)
+	}
+	return out, n
+}
+
+// sequenced is a candidate with its effective sort key.
+type sequenced struct {
+	p      Payload
+	key    uint64
+	sender string
+	nonce  uint64
+}
+
+// sequence orders candidates of one type for inclusion: by SortKey
+// (descending), then sender, nonce and hash. A Sequenced payload is keyed
+// no higher than its sender's previous nonce, so each sender's txs come in
+// ascending nonce order and a high fee cannot jump its own queue. A
+// sender's txs after a nonce missing from cands are dropped: they could
+// not execute.
+func sequence(cands []Payload) []Payload {
+	cs := make([]sequenced, 0, len(cands))
+	bySender := map[string][]int{}
+	for _, p := range cands {
+		c := sequenced{p: p, key: p.SortKey()}
+		if sender, nonce, ok := seqKey(p); ok {
+			c.sender, c.nonce = sender, nonce
+			bySender[sender] = append(bySender[sender], len(cs))
+		}
+		cs = append(cs, c)
+	}
+	drop := map[int]bool{}
+	for _, idx := range bySender {
+		sort.Slice(idx, func(i, j int) bool { return cs[idx[i]].nonce < cs[idx[j]].nonce })
+		for k := 1; k < len(idx); k++ {
+			prev, cur := cs[idx[k-1]], &cs[idx[k]]
+			if drop[idx[k-1]] || cur.nonce != prev.nonce+1 {
+				drop[idx[k]] = true
+				metrics.Inc("builder_reject_total", map[string]string{"type": cur.p.Type(), "reason": "nonce_gap"})
+				continue
+			}
+			if cur.key > prev.key {
+				cur.key = prev.key
 			}
 		}
-		return len(ci) < len(cj)
+	}
+	kept := make([]sequenced, 0, len(cs))
+	for i, c := range cs {
+		if !drop[i] {
+			kept = append(kept, c)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		a, b := kept[i], kept[j]
+		switch {
+		case a.key != b.key:
+			return a.key > b.key
+		case a.sender != b.sender:
+			return a.sender < b.sender
+		case a.nonce != b.nonce:
+			return a.nonce < b.nonce
+		}
+		return bytes.Compare(a.p.Hash(), b.p.Hash()) < 0
 	})
-	if need <= 0 || need > len(cands) {
-		need = len(cands)
+	out := make([]Payload, len(kept))
+	for i, c := range kept {
+		out[i] = c.p
+	}
+	return out
+}
+
+// sequenceRuns applies sequence to each run of same-type items.
+func sequenceRuns(items []Payload) []Payload {
+	out := make([]Payload, 0, len(items))
+	for i := 0; i < len(items); {
+		j := i + 1
+		for j < len(items) && items[j].Type() == items[i].Type() {
+			j++
+		}
+		out = append(out, sequence(items[i:j])...)
+		i = j
 	}
-	return cands[:need]
+	return out
 }
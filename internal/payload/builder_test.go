@@ -1,10 +1,16 @@
 package payload_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	payload "github.com/zmlAEQ/Aequa-network/internal/payload"
+	// The threshold rules are registered by the built-in types.
+	_ "github.com/zmlAEQ/Aequa-network/internal/payload/auction_bid_v1"
+	bundle "github.com/zmlAEQ/Aequa-network/internal/payload/bundle_v1"
+	pt "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
 )
 
 // dummyPayload implements payload.Payload for testing DFBA logic.
@@ -171,3 +177,101 @@ func TestPrepareProposal_DFBAFlagKeepsBehaviour(t *testing.T) {
 		}
 	}
 }
+
+func ptx(from string, nonce, fee uint64) *pt.PlaintextTx {
+	return &pt.PlaintextTx{From: from, Nonce: nonce, Gas: 1, Fee: fee, Sig: make([]byte, 32)}
+}
+
+func newBundle(t *testing.T, bid uint64, txs ...*pt.PlaintextTx) *bundle.BundleTx {
+	t.Helper()
+	b := &bundle.BundleTx{From: "S", Bid: bid, Sig: make([]byte, 32)}
+	for _, tx := range txs {
+		enc, _ := tx.MarshalBinary()
+		b.Txs = append(b.Txs, enc)
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("bundle: %v", err)
+	}
+	return b
+}
+
+// Bundles count their txs against MaxN and are never split: one too large
+// for what is left is skipped whole and smaller payloads fill the room.
+func TestPrepareProposal_BundlesNotSplitByMaxN(t *testing.T) {
+	c := payload.NewContainer(map[string]payload.TypedMempool{"bundle_v1": bundle.New(), "plaintext_v1": pt.New()})
+	big := newBundle(t, 9, ptx("A", 0, 1), ptx("B", 0, 1), ptx("C", 0, 1))
+	small := newBundle(t, 5, ptx("D", 0, 1), ptx("E", 0, 1))
+	_ = c.Add(big)
+	_ = c.Add(small)
+	_ = c.Add(ptx("F", 0, 3))
+	pol := payload.BuilderPolicy{Order: []string{"bundle_v1", "plaintext_v1"}, MaxN: 4}
+	blk := payload.PrepareProposal(c, payload.BlockHeader{Height: 1}, pol)
+	if len(blk.Items) != 2 || blk.Items[0] != big || blk.Items[1].Type() != "plaintext_v1" {
+		t.Fatalf("items %v", blk.Items)
+	}
+	if err := payload.ProcessProposal(blk, pol); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+}
+
+// A tx or nonce slot is claimed by whichever payload comes first in the
+// policy order: the higher bundle wins over an overlapping lower one and
+// over individually submitted txs of its slots.
+func TestPrepareProposal_BundleConflicts(t *testing.T) {
+	c := payload.NewContainer(map[string]payload.TypedMempool{"bundle_v1": bundle.New(), "plaintext_v1": pt.New()})
+	a0 := ptx("A", 0, 1)
+	win := newBundle(t, 9, a0, ptx("B", 0, 1))
+	lose := newBundle(t, 5, ptx("B", 0, 4), ptx("C", 0, 1)) // B's slot is taken
+	_ = c.Add(win)
+	_ = c.Add(lose)
+	_ = c.Add(ptx("A", 0, 50)) // same slot as a bundle tx, higher fee
+	_ = c.Add(ptx("A", 1, 2))
+	pol := payload.BuilderPolicy{Order: []string{"bundle_v1", "plaintext_v1"}, MaxN: 10}
+	blk := payload.PrepareProposal(c, payload.BlockHeader{Height: 1}, pol)
+	if len(blk.Items) != 2 || blk.Items[0] != win || blk.Items[1].(*pt.PlaintextTx).Nonce != 1 {
+		t.Fatalf("items %v", blk.Items)
+	}
+	if st := payload.Summarize(blk.Items); st.TotalBids != 9 || st.TotalFees != 4 {
+		t.Fatalf("stats %+v", st)
+	}
+	// A proposer that included both is rejected.
+	bad := payload.StandardBlock{Items: []payload.Payload{win, a0}}
+	if err := payload.ProcessProposal(bad, pol); err == nil {
+		t.Fatalf("conflicting block accepted")
+	}
+}
+
+// A sender's txs are proposed in ascending nonce order even when a later
+// nonce pays more, and nothing follows a nonce the builder left out.
+func TestPrepareProposal_SenderNonceOrder(t *testing.T) {
+	c := payload.NewContainer(map[string]payload.TypedMempool{"plaintext_v1": pt.New()})
+	for _, tx := range []*pt.PlaintextTx{ptx("A", 0, 10), ptx("A", 1, 50), ptx("A", 2, 30), ptx("B", 0, 20), ptx("C", 0, 1), ptx("C", 1, 90)} {
+		if err := c.Add(tx); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	pol := payload.BuilderPolicy{Order: []string{"plaintext_v1"}, MaxN: 10, MinFee: 5}
+	blk := payload.PrepareProposal(c, payload.BlockHeader{Height: 1}, pol)
+	var got []string
+	for _, it := range blk.Items {
+		tx := it.(*pt.PlaintextTx)
+		got = append(got, fmt.Sprintf("%s%d", tx.From, tx.Nonce))
+	}
+	// C0 is below MinFee, so C1 could not execute.
+	if strings.Join(got, ",") != "B0,A0,A1,A2" {
+		t.Fatalf("order %v", got)
+	}
+	if err := payload.ProcessProposal(blk, pol); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	swapped := payload.StandardBlock{Items: []payload.Payload{blk.Items[0], blk.Items[2], blk.Items[1]}}
+	if err := payload.ProcessProposal(swapped, pol); err == nil {
+		t.Fatalf("out-of-order nonces accepted")
+	}
+	// A bundle tx counts too: a later individual tx may not go back below it.
+	b := newBundle(t, 9, ptx("A", 1, 1))
+	pol.Order = []string{"bundle_v1", "plaintext_v1"}
+	if err := payload.ProcessProposal(payload.StandardBlock{Items: []payload.Payload{b, ptx("A", 0, 10)}}, pol); err == nil {
+		t.Fatalf("individual tx below a bundle nonce accepted")
+	}
+}
@@ -0,0 +1,91 @@
+package payload
+
+import (
+	"encoding/hex"
+	"strconv"
+)
+
+// Weight returns how many txs p puts in a block: the size of a bundle, one
+// otherwise. The builder counts MaxN in txs, so a bundle is never split.
+func Weight(p Payload) int {
+	if b, ok := p.(Bundle); ok && len(b.Inner()) > 1 {
+		return len(b.Inner())
+	}
+	return 1
+}
+
+// Expand returns items with each bundle followed by the payloads it
+// carries, so committed bundle txs reach the pools of their own types.
+func Expand(items []Payload) []Payload {
+	out := make([]Payload, 0, len(items))
+	for _, it := range items {
+		out = append(out, it)
+		if b, ok := it.(Bundle); ok {
+			out = append(out, b.Inner()...)
+		}
+	}
+	return out
+}
+
+// ConflictKeys returns the keys p claims in a block: the hash of p and of
+// each payload it carries, and the nonce slot of each Sequenced one. Two
+// payloads sharing a key cannot both be included.
+func ConflictKeys(p Payload) []string {
+	keys := []string{"tx:" + p.Type() + ":" + hex.EncodeToString(p.Hash())}
+	if sender, nonce, ok := seqKey(p); ok {
+		keys = append(keys, slotKey(sender, nonce))
+	}
+	if b, ok := p.(Bundle); ok {
+		for _, it := range b.Inner() {
+			keys = append(keys, ConflictKeys(it)...)
+		}
+	}
+	return keys
+}
+
+// seqKey returns the nonce space of a Sequenced payload (its type and
+// sender; each type keeps its own nonces) and its nonce.
+func seqKey(p Payload) (string, uint64, bool) {
+	s, ok := p.(Sequenced)
+	if !ok {
+		return "", 0, false
+	}
+	from, nonce := s.SenderNonce()
+	return p.Type() + ":" + from, nonce, true
+}
+
+// slotKey is the conflict key of nonce in sender's nonce space.
+func slotKey(sender string, nonce uint64) string {
+	return "slot:" + sender + ":" + strconv.FormatUint(nonce, 10)
+}
+
+// parts returns the payloads p puts in a block in execution order: the
+// txs of a bundle, p itself otherwise.
+func parts(p Payload) []Payload {
+	if b, ok := p.(Bundle); ok {
+		return b.Inner()
+	}
+	return []Payload{p}
+}
+
+// conflicts reports whether one of keys is already claimed.
+func conflicts(claimed map[string]bool, keys []string) bool {
+	for _, k := range keys {
+		if claimed[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// claim adds keys to claimed unless one of them is already there, and
+// reports whether it did.
+func claim(claimed map[string]bool, keys []string) bool {
+	if conflicts(claimed, keys) {
+		return false
+	}
+	for _, k := range keys {
+		claimed[k] = true
+	}
+	return true
+}
@@ -0,0 +1,32 @@
+package bundle_v1
+
+import "github.com/zmlAEQ/Aequa-network/internal/payload"
+
+func init() {
+	payload.Register(payload.Kind{
+		Type:   Type,
+		New:    func() payload.Payload { return &BundleTx{} },
+		Verify: func(p payload.Payload, chainID string) error { return p.(*BundleTx).VerifySig(chainID) },
+		Pool:   func(lim payload.Limits) payload.TypedMempool { return NewWithLimits(lim) },
+		// Bundles compete with auction bids, so MinBid applies.
+		Threshold: func(p payload.Payload, pol payload.BuilderPolicy) string {
+			if pol.MinBid > 0 && p.SortKey() < pol.MinBid {
+				return "below_min_bid"
+			}
+			return ""
+		},
+		// The bid and the contributions of the bundle txs.
+		Stats: func(p payload.Payload, s *payload.BlockStats) {
+			tx, ok := p.(*BundleTx)
+			if !ok {
+				return
+			}
+			s.TotalBids += tx.Bid
+			for _, in := range tx.Inner() {
+				if k, ok := payload.Lookup(in.Type()); ok && k.Stats != nil {
+					k.Stats(in, s)
+				}
+			}
+		},
+	})
+}
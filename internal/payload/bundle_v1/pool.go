@@ -0,0 +1,309 @@
+// Package bundle_v1 is the atomic bundle payload type: an ordered group of
+// signed txs a searcher wants included all-or-nothing, at one position of
+// the block, for a bid.
+package bundle_v1
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zmlAEQ/Aequa-network/internal/payload"
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// Type is the payload type of a bundle.
+const Type = "bundle_v1"
+
+// MaxTxs bounds the txs of one bundle.
+const MaxTxs = 16
+
+// BundleTx is a bundle: the canonical encodings of its txs in execution
+// order, bid for by From. It is one block item, so its txs stay contiguous;
+// the builder counts them against MaxN and never splits them, and execution
+// applies them all or none. Its JSON form is the wire envelope without the
+// type tag, the txs as base64 strings.
+type BundleTx struct {
+	From  string   `json:"from"`
+	Bid   uint64   `json:"bid,omitempty"` // used as SortKey (higher first)
+	Txs   [][]byte `json:"txs"`
+	Sig   []byte   `json:"sig,omitempty"` // ed25519 signature by From over SigningPayload
+	h     []byte   // cached hash
+	inner []payload.Payload
+}
+
+func (t *BundleTx) Type() string { return Type }
+
+// body is the canonical encoding of every field but Sig.
+func (t *BundleTx) body() *payload.Encoder {
+	e := payload.NewEncoder(Type).String(t.From).Uint64(t.Bid).Uint64(uint64(len(t.Txs)))
+	for _, tx := range t.Txs {
+		e.Bytes(tx)
+	}
+	return e
+}
+
+func (t *BundleTx) Hash() []byte {
+	if t.h == nil {
+		t.h = t.body().Hash()
+	}
+	return t.h
+}
+
+// MarshalBinary returns the canonical encoding (payload.CodecVersion).
+func (t *BundleTx) MarshalBinary() ([]byte, error) { return t.body().Bytes(t.Sig).Out(), nil }
+
+// UnmarshalBinary decodes a canonical encoding.
+func (t *BundleTx) UnmarshalBinary(b []byte) error {
+	d := payload.NewDecoder(b, Type)
+	v := BundleTx{From: d.String(), Bid: d.Uint64()}
+	n := d.Uint64()
+	if n > MaxTxs {
+		return fmt.Errorf("%w: %d bundle txs", payload.ErrCodec, n)
+	}
+	for i := uint64(0); i < n; i++ {
+		v.Txs = append(v.Txs, d.Bytes())
+	}
+	v.Sig = d.Bytes()
+	if err := d.Finish(); err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
+// decode returns the bundle txs, decoded once.
+func (t *BundleTx) decode() ([]payload.Payload, error) {
+	if t.inner != nil {
+		return t.inner, nil
+	}
+	out := make([]payload.Payload, 0, len(t.Txs))
+	for _, b := range t.Txs {
+		p, err := payload.Decode(b)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	t.inner = out
+	return out, nil
+}
+
+// Inner returns the bundle txs in execution order, or nil when one does not
+// decode (payload.Bundle).
+func (t *BundleTx) Inner() []payload.Payload {
+	in, _ := t.decode()
+	return in
+}
+
+// Validate checks the bundle and each of its txs: 1 to MaxTxs sequenced,
+// non-bundle txs, none of which shares a tx or nonce slot with another.
+func (t *BundleTx) Validate() error {
+	if t.From == "" || t.Bid == 0 || len(t.Sig) < 32 || len(t.Txs) == 0 || len(t.Txs) > MaxTxs {
+		return errors.New("invalid")
+	}
+	in, err := t.decode()
+	if err != nil {
+		return err
+	}
+	claimed := map[string]bool{}
+	for _, p := range in {
+		if _, ok := p.(payload.Sequenced); !ok {
+			return fmt.Errorf("invalid: %s in a bundle", p.Type())
+		}
+		if _, ok := p.(payload.Bundle); ok {
+			return errors.New("invalid: nested bundle")
+		}
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		for _, k := range payload.ConflictKeys(p) {
+			if claimed[k] {
+				return errors.New("invalid: bundle txs conflict")
+			}
+			claimed[k] = true
+		}
+	}
+	return nil
+}
+
+func (t *BundleTx) SortKey() uint64 { return t.Bid }
+
+// SigningPayload returns the canonical bytes From signs for chainID.
+func (t *BundleTx) SigningPayload(chainID string) []byte {
+	return payload.SigningBytes(chainID, t.body().Out())
+}
+
+// VerifySig checks Sig against From, the hex ed25519 public key, and the
+// signature of each bundle tx.
+func (t *BundleTx) VerifySig(chainID string) error {
+	if err := payload.VerifyEd25519(t.From, t.SigningPayload(chainID), t.Sig); err != nil {
+		return err
+	}
+	in, err := t.decode()
+	if err != nil {
+		return err
+	}
+	for _, p := range in {
+		if err := payload.VerifySig(p, chainID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sign sets From to the address of key and signs the bundle for chainID.
+// The bundle txs keep their own senders' signatures.
+func (t *BundleTx) Sign(chainID string, key ed25519.PrivateKey) {
+	t.From = payload.Address(key.Public().(ed25519.PublicKey))
+	t.h = nil
+	t.Sig = ed25519.Sign(key, t.SigningPayload(chainID))
+}
+
+// Pool holds bundles by hash, bounded by Limits.MaxPending with lowest-bid
+// eviction and expiring by Limits.TTL/TTLBlocks. Bundles may overlap one
+// another and pooled individual txs; the builder resolves conflicts at
+// selection and RemoveConflicting drops bundles a commit made stale.
+type Pool struct {
+	mu     sync.Mutex
+	lim    payload.Limits
+	items  map[string]*entry
+	height uint64 // last committed height passed to Expire
+}
+
+type entry struct {
+	tx     *BundleTx
+	at     time.Time
+	height uint64
+}
+
+func New() *Pool { return NewWithLimits(payload.Limits{}) }
+
+// NewWithLimits returns a pool bounded by lim (zero fields take defaults).
+func NewWithLimits(lim payload.Limits) *Pool {
+	return &Pool{lim: lim.WithDefaults(), items: map[string]*entry{}}
+}
+
+func (p *Pool) Add(pl payload.Payload) error {
+	tx, ok := pl.(*BundleTx)
+	if !ok {
+		return nil
+	}
+	if err := tx.Validate(); err != nil {
+		metrics.Inc("bundle_pool_in_total", map[string]string{"result": "invalid"})
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h := string(tx.Hash())
+	if _, dup := p.items[h]; dup {
+		metrics.Inc("bundle_pool_in_total", map[string]string{"result": "dup"})
+		return errors.New("duplicate bundle")
+	}
+	if len(p.items) >= p.lim.MaxPending {
+		low := p.lowest()
+		if low == nil || low.tx.Bid >= tx.Bid {
+			metrics.Inc("bundle_pool_in_total", map[string]string{"result": "overflow"})
+			return errors.New("bundle pool full")
+		}
+		delete(p.items, string(low.tx.Hash()))
+		metrics.Inc("mempool_evicted_total", map[string]string{"reason": "fee"})
+	}
+	p.items[h] = &entry{tx: tx, at: time.Now(), height: p.height}
+	metrics.Inc("bundle_pool_in_total", map[string]string{"result": "ok"})
+	metrics.SetGauge("bundle_pool_size", nil, int64(len(p.items)))
+	return nil
+}
+
+// lowest returns the entry evicted first: the lowest bid, then the highest
+// hash.
+func (p *Pool) lowest() *entry {
+	var low *entry
+	for _, e := range p.items {
+		if low == nil || e.tx.Bid < low.tx.Bid || (e.tx.Bid == low.tx.Bid && bytes.Compare(e.tx.Hash(), low.tx.Hash()) > 0) {
+			low = e
+		}
+	}
+	return low
+}
+
+// Get returns up to n bundles by bid (descending), then hash.
+func (p *Pool) Get(n int, _ int) []payload.Payload {
+	p.mu.Lock()
+	txs := make([]*BundleTx, 0, len(p.items))
+	for _, e := range p.items {
+		txs = append(txs, e.tx)
+	}
+	p.mu.Unlock()
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].Bid != txs[j].Bid {
+			return txs[i].Bid > txs[j].Bid
+		}
+		return bytes.Compare(txs[i].Hash(), txs[j].Hash()) < 0
+	})
+	if n <= 0 || n > len(txs) {
+		n = len(txs)
+	}
+	out := make([]payload.Payload, 0, n)
+	for _, tx := range txs[:n] {
+		out = append(out, tx)
+	}
+	return out
+}
+
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.items)
+}
+
+// RemoveConflicting drops the committed bundles and every bundle carrying a
+// committed tx or nonce slot (payload.ConflictRemover).
+func (p *Pool) RemoveConflicting(committed []payload.Payload) []payload.Payload {
+	used := map[string]bool{}
+	for _, it := range committed {
+		for _, k := range payload.ConflictKeys(it) {
+			used[k] = true
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []payload.Payload
+	for h, e := range p.items {
+		for _, k := range payload.ConflictKeys(e.tx) {
+			if used[k] {
+				delete(p.items, h)
+				out = append(out, e.tx)
+				break
+			}
+		}
+	}
+	if len(out) > 0 {
+		metrics.SetGauge("bundle_pool_size", nil, int64(len(p.items)))
+	}
+	return out
+}
+
+// Expire drops bundles that outlived the limits once height was committed
+// (payload.Expirer).
+func (p *Pool) Expire(now time.Time, height uint64) []payload.Payload {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.height = height
+	var out []payload.Payload
+	for h, e := range p.items {
+		if p.lim.Expired(e.at, e.height, now, height) {
+			delete(p.items, h)
+			out = append(out, e.tx)
+			metrics.Inc("mempool_evicted_total", map[string]string{"reason": "expired"})
+		}
+	}
+	if len(out) > 0 {
+		metrics.SetGauge("bundle_pool_size", nil, int64(len(p.items)))
+	}
+	return out
+}
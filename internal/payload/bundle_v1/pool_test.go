@@ -0,0 +1,117 @@
+package bundle_v1
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"github.com/zmlAEQ/Aequa-network/internal/payload"
+	plaintext "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+	private "github.com/zmlAEQ/Aequa-network/internal/payload/private_v1"
+)
+
+func enc(t *testing.T, p interface{ MarshalBinary() ([]byte, error) }) []byte {
+	t.Helper()
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return b
+}
+
+func tx(from string, nonce, fee uint64) *plaintext.PlaintextTx {
+	return &plaintext.PlaintextTx{From: from, Nonce: nonce, Gas: 1, Fee: fee, Sig: make([]byte, 32)}
+}
+
+func bundle(t *testing.T, bid uint64, txs ...*plaintext.PlaintextTx) *BundleTx {
+	b := &BundleTx{From: "S", Bid: bid, Sig: make([]byte, 32)}
+	for _, tx := range txs {
+		b.Txs = append(b.Txs, enc(t, tx))
+	}
+	return b
+}
+
+func TestBundle_Validate(t *testing.T) {
+	if err := bundle(t, 5, tx("A", 0, 1), tx("B", 0, 1)).Validate(); err != nil {
+		t.Fatalf("valid bundle: %v", err)
+	}
+	priv := &BundleTx{From: "S", Bid: 5, Sig: make([]byte, 32), Txs: [][]byte{enc(t, &private.PrivateTx{From: "A", Ciphertext: []byte{1}, TargetHeight: 1})}}
+	nested := &BundleTx{From: "S", Bid: 5, Sig: make([]byte, 32), Txs: [][]byte{enc(t, bundle(t, 1, tx("A", 0, 1)))}}
+	bad := map[string]*BundleTx{
+		"empty":         bundle(t, 5),
+		"no bid":        bundle(t, 0, tx("A", 0, 1)),
+		"same slot":     bundle(t, 5, tx("A", 0, 1), tx("A", 0, 2)),
+		"undecodable":   {From: "S", Bid: 5, Sig: make([]byte, 32), Txs: [][]byte{{1, 2, 3}}},
+		"unsequenced":   priv,
+		"nested":        nested,
+		"invalid inner": bundle(t, 5, &plaintext.PlaintextTx{From: "A", Gas: 1}),
+	}
+	for name, b := range bad {
+		if b.Validate() == nil {
+			t.Fatalf("%s: accepted", name)
+		}
+	}
+}
+
+func TestBundle_CodecAndSignature(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{3}, ed25519.SeedSize))
+	in := &plaintext.PlaintextTx{Gas: 1, Fee: 1}
+	in.Sign(payload.DefaultChainID, key)
+	b := &BundleTx{Bid: 9, Txs: [][]byte{enc(t, in)}}
+	b.Sign(payload.DefaultChainID, key)
+	var dec BundleTx
+	if err := dec.UnmarshalBinary(enc(t, b)); err != nil || !bytes.Equal(dec.Hash(), b.Hash()) {
+		t.Fatalf("round trip: %v", err)
+	}
+	if err := payload.VerifySig(&dec, ""); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	// A bundle tx with a bad signature fails the whole bundle.
+	forged := &BundleTx{Bid: 9, Txs: [][]byte{enc(t, tx("A", 0, 1))}}
+	forged.Sign(payload.DefaultChainID, key)
+	if err := payload.VerifySig(forged, ""); !errors.Is(err, payload.ErrBadSignature) {
+		t.Fatalf("forged inner tx: %v", err)
+	}
+}
+
+func TestPool_OrdersByBidAndEvictsLowest(t *testing.T) {
+	p := NewWithLimits(payload.Limits{MaxPending: 2})
+	low, mid, high := bundle(t, 1, tx("A", 0, 1)), bundle(t, 5, tx("B", 0, 1)), bundle(t, 9, tx("C", 0, 1))
+	_ = p.Add(low)
+	_ = p.Add(high)
+	if err := p.Add(high); err == nil {
+		t.Fatalf("duplicate admitted")
+	}
+	if err := p.Add(mid); err != nil {
+		t.Fatalf("add over capacity with a higher bid: %v", err)
+	}
+	got := p.Get(0, 0)
+	if len(got) != 2 || got[0] != high || got[1] != mid {
+		t.Fatalf("got %v", got)
+	}
+	if err := p.Add(bundle(t, 1, tx("D", 0, 1))); err == nil {
+		t.Fatalf("low bid admitted into a full pool")
+	}
+}
+
+// A committed tx makes every pooled bundle carrying it, or its nonce slot,
+// stale.
+func TestPool_RemoveConflicting(t *testing.T) {
+	p := New()
+	a := bundle(t, 5, tx("A", 0, 1), tx("B", 0, 1))
+	b := bundle(t, 4, tx("A", 0, 7)) // same slot as a's first tx
+	c := bundle(t, 3, tx("C", 0, 1))
+	for _, x := range []*BundleTx{a, b, c} {
+		if err := p.Add(x); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	dropped := p.RemoveConflicting(payload.Expand([]payload.Payload{tx("A", 0, 1)}))
+	if len(dropped) != 2 || p.Len() != 1 || p.Get(1, 0)[0] != c {
+		t.Fatalf("dropped %d, left %d", len(dropped), p.Len())
+	}
+	if d := p.RemoveConflicting(payload.Expand([]payload.Payload{c})); len(d) != 1 || p.Len() != 0 {
+		t.Fatalf("committed bundle not dropped")
+	}
+}
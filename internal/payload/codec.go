@@ -0,0 +1,138 @@
+package payload
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// CodecVersion is the version byte leading every canonical tx encoding.
+const CodecVersion byte = 1
+
+// Canonical encoding (version 1) of a tx, shared with the JS SDK:
+//
+//	tx    = version:u8 type:str field... [sig:bytes]
+//	str   = len:u32 utf-8 bytes
+//	bytes = len:u32 raw bytes
+//	u64   = 8 bytes
+//
+// Integers are big-endian. Each type fixes its field order; the body is the
+// encoding up to, and not including, the signature. Hash is sha256(body),
+// so it covers every field at full width and a re-signed tx keeps its hash.
+// Senders sign SigningBytes(chainID, body).
+
+// ErrCodec is wrapped by every decoding error.
+var ErrCodec = errors.New("codec")
+
+// Encoder appends canonical fields.
+type Encoder struct{ b []byte }
+
+// NewEncoder starts the body of a tx of type typ.
+func NewEncoder(typ string) *Encoder {
+	e := &Encoder{b: []byte{CodecVersion}}
+	return e.String(typ)
+}
+
+// String appends a length-prefixed string field.
+func (e *Encoder) String(v string) *Encoder {
+	e.b = binary.BigEndian.AppendUint32(e.b, uint32(len(v)))
+	e.b = append(e.b, v...)
+	return e
+}
+
+// Bytes appends a length-prefixed byte field.
+func (e *Encoder) Bytes(v []byte) *Encoder { return e.String(string(v)) }
+
+// Uint64 appends an integer field.
+func (e *Encoder) Uint64(v uint64) *Encoder {
+	e.b = binary.BigEndian.AppendUint64(e.b, v)
+	return e
+}
+
+// Out returns the encoding built so far.
+func (e *Encoder) Out() []byte { return e.b }
+
+// Hash returns sha256 of the encoding built so far.
+func (e *Encoder) Hash() []byte {
+	sum := sha256.Sum256(e.b)
+	return sum[:]
+}
+
+// Decoder reads canonical fields in order. The first error sticks and is
+// reported by Finish.
+type Decoder struct {
+	b   []byte
+	err error
+}
+
+// NewDecoder checks the version and type of an encoding and returns a
+// decoder positioned at its first field.
+func NewDecoder(b []byte, typ string) *Decoder {
+	d := &Decoder{b: b}
+	if len(b) == 0 || b[0] != CodecVersion {
+		d.err = fmt.Errorf("%w: unsupported version", ErrCodec)
+		return d
+	}
+	d.b = b[1:]
+	if got := d.String(); d.err == nil && got != typ {
+		d.err = fmt.Errorf("%w: type %q, want %q", ErrCodec, got, typ)
+	}
+	return d
+}
+
+// PeekType returns the type named by an encoding.
+func PeekType(b []byte) (string, error) {
+	if len(b) == 0 || b[0] != CodecVersion {
+		return "", fmt.Errorf("%w: unsupported version", ErrCodec)
+	}
+	d := &Decoder{b: b[1:]}
+	typ := d.String()
+	return typ, d.err
+}
+
+func (d *Decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.b) < n {
+		d.err = fmt.Errorf("%w: truncated", ErrCodec)
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+// String reads a length-prefixed string field.
+func (d *Decoder) String() string { return string(d.Bytes()) }
+
+// Bytes reads a length-prefixed byte field (nil when empty).
+func (d *Decoder) Bytes() []byte {
+	n := d.take(4)
+	if n == nil {
+		return nil
+	}
+	v := d.take(int(binary.BigEndian.Uint32(n)))
+	if len(v) == 0 {
+		return nil
+	}
+	return append([]byte(nil), v...)
+}
+
+// Uint64 reads an integer field.
+func (d *Decoder) Uint64() uint64 {
+	v := d.take(8)
+	if v == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(v)
+}
+
+// Finish returns the first decoding error, or an error if bytes are left.
+func (d *Decoder) Finish() error {
+	if d.err == nil && len(d.b) > 0 {
+		d.err = fmt.Errorf("%w: %d trailing bytes", ErrCodec, len(d.b))
+	}
+	return d.err
+}
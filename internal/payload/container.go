@@ -1,8 +1,11 @@
 package payload
 
 import (
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
 )
 
 // Container holds a set of typed mempools keyed by payload Type().
@@ -25,17 +28,154 @@ func NewContainer(pools map[string]TypedMempool) *Container {
 
 // Add routes a payload to its typed pool.
 func (c *Container) Add(p Payload) error {
+	_, err := c.AddReplacing(p)
+	return err
+}
+
+// AddReplacing is Add that also returns the payload p replaced in a pool
+// implementing Replacer; the replaced payload's arrival metadata is dropped.
+func (c *Container) AddReplacing(p Payload) (Payload, error) {
 	c.mu.Lock()
 	pool := c.impl[p.Type()]
 	if pool == nil {
 		c.mu.Unlock()
-		return nil
+		return nil, nil
 	}
-	c.seq++
 	key := string(p.Hash())
-	c.meta[key] = arrivalMeta{Seq: c.seq, TS: time.Now()}
+	_, known := c.meta[key]
+	if !known {
+		c.seq++
+		c.meta[key] = arrivalMeta{Seq: c.seq, TS: time.Now()}
+	}
+	c.mu.Unlock()
+	var replaced Payload
+	var err error
+	if r, ok := pool.(Replacer); ok {
+		replaced, err = r.AddReplacing(p)
+	} else {
+		err = pool.Add(p)
+	}
+	if err != nil && !known {
+		// The pool refused it: do not keep arrival metadata it will never use.
+		c.forget([]Payload{p})
+	}
+	if replaced != nil {
+		c.forget([]Payload{replaced})
+	}
+	return replaced, err
+}
+
+// RemoveCommitted hands the payloads of a committed block, bundles expanded,
+// to the typed pools that implement Remover or ConflictRemover and forgets
+// the arrival metadata of everything they dropped. It returns how many
+// pooled payloads were removed.
+func (c *Container) RemoveCommitted(items []Payload) int { return c.RemoveExecuted(items, nil) }
+
+// RemoveExecuted is RemoveCommitted for a block whose execution skipped the
+// payloads in skipped (by hash), bundle txs included. A skipped tx used no
+// nonce, so it is not treated as committed: its sender does not advance
+// past it, and it is added back when no pool holds it anymore. A skipped
+// bundle is dropped all the same, as it would fail again; its txs are not.
+func (c *Container) RemoveExecuted(items []Payload, skipped map[string]bool) int {
+	var committed, back []Payload
+	for _, it := range Expand(items) {
+		if _, isBundle := it.(Bundle); !isBundle && skipped[string(it.Hash())] {
+			back = append(back, it)
+			continue
+		}
+		committed = append(committed, it)
+	}
+	removed := c.remove(committed)
+	for _, p := range back {
+		if _, ok := c.Arrival(p); !ok {
+			_ = c.Add(p)
+		}
+	}
+	return removed
+}
+
+// remove drops the committed payloads (bundles already expanded) from the
+// pools.
+func (c *Container) remove(items []Payload) int {
+	byType := map[string][]Payload{}
+	var types []string
+	for _, it := range items {
+		t := it.Type()
+		if _, ok := byType[t]; !ok {
+			types = append(types, t)
+		}
+		byType[t] = append(byType[t], it)
+	}
+	removed := 0
+	for _, t := range types {
+		c.mu.RLock()
+		r, ok := c.impl[t].(Remover)
+		c.mu.RUnlock()
+		var dropped []Payload
+		if ok {
+			dropped = r.RemoveCommitted(byType[t])
+		}
+		c.forget(byType[t])
+		c.forget(dropped)
+		for range dropped {
+			metrics.Inc("mempool_removed_total", map[string]string{"type": t})
+		}
+		removed += len(dropped)
+	}
+	c.mu.RLock()
+	var cts []string
+	for t, p := range c.impl {
+		if _, ok := p.(ConflictRemover); ok {
+			cts = append(cts, t)
+		}
+	}
+	c.mu.RUnlock()
+	sort.Strings(cts)
+	for _, t := range cts {
+		c.mu.RLock()
+		r := c.impl[t].(ConflictRemover)
+		c.mu.RUnlock()
+		dropped := r.RemoveConflicting(items)
+		c.forget(dropped)
+		for range dropped {
+			metrics.Inc("mempool_removed_total", map[string]string{"type": t})
+		}
+		removed += len(dropped)
+	}
+	return removed
+}
+
+// Expire asks the typed pools that implement Expirer to drop entries that
+// outlived their limits once height was committed, and forgets their
+// arrival metadata. It returns how many payloads were dropped.
+func (c *Container) Expire(height uint64) int {
+	c.mu.RLock()
+	var ex []Expirer
+	for _, p := range c.impl {
+		if e, ok := p.(Expirer); ok {
+			ex = append(ex, e)
+		}
+	}
+	c.mu.RUnlock()
+	now, n := time.Now(), 0
+	for _, e := range ex {
+		dropped := e.Expire(now, height)
+		c.forget(dropped)
+		n += len(dropped)
+	}
+	return n
+}
+
+// forget drops the arrival metadata of items.
+func (c *Container) forget(items []Payload) {
+	if len(items) == 0 {
+		return
+	}
+	c.mu.Lock()
+	for _, it := range items {
+		delete(c.meta, string(it.Hash()))
+	}
 	c.mu.Unlock()
-	return pool.Add(p)
 }
 
 // GetN asks a specific typed pool for up to n payloads.
@@ -3,6 +3,7 @@ package payload_test
 import (
     "testing"
     payload "github.com/zmlAEQ/Aequa-network/internal/payload"
+    bundle "github.com/zmlAEQ/Aequa-network/internal/payload/bundle_v1"
     pt "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
 )
 
@@ -24,3 +25,57 @@ func TestContainer_RoutesToTypedPool(t *testing.T) {
     // unknown type is ignored
     _ = c.Add(dummy{t:"unknown"})
 }
+
+func TestContainer_RemoveCommittedForgetsArrival(t *testing.T) {
+    pool := pt.New()
+    c := payload.NewContainer(map[string]payload.TypedMempool{"plaintext_v1": pool})
+    a0 := &pt.PlaintextTx{From:"A", Nonce:0, Gas:1, Fee:1, Sig: make([]byte,32)}
+    a1 := &pt.PlaintextTx{From:"A", Nonce:1, Gas:1, Fee:1, Sig: make([]byte,32)}
+    _ = c.Add(a0); _ = c.Add(a1)
+    if n := c.RemoveCommitted([]payload.Payload{a0}); n != 1 { t.Fatalf("removed %d", n) }
+    if _, ok := c.Arrival(a0); ok { t.Fatalf("arrival of a committed tx kept") }
+    if _, ok := c.Arrival(a1); !ok || c.Len() != 1 { t.Fatalf("pending tx lost") }
+    // A refused payload leaves no arrival behind.
+    if err := c.Add(a0); err == nil { t.Fatalf("committed nonce admitted again") }
+    if _, ok := c.Arrival(a0); ok { t.Fatalf("arrival recorded for a refused tx") }
+}
+
+func TestContainer_ExpireForgetsArrival(t *testing.T) {
+    c := payload.NewContainer(map[string]payload.TypedMempool{"plaintext_v1": pt.NewWithLimits(payload.Limits{TTLBlocks: 2})})
+    a0 := &pt.PlaintextTx{From:"A", Nonce:0, Gas:1, Fee:1, Sig: make([]byte,32)}
+    _ = c.Add(a0)
+    if n := c.Expire(1); n != 0 { t.Fatalf("expired %d at height 1", n) }
+    if n := c.Expire(2); n != 1 || c.Len() != 0 { t.Fatalf("expired %d, len %d", n, c.Len()) }
+    if _, ok := c.Arrival(a0); ok { t.Fatalf("arrival of an expired tx kept") }
+}
+
+// A committed bundle removes its txs from their own pools and drops the
+// pooled bundles it made stale.
+func TestContainer_RemoveCommittedBundle(t *testing.T) {
+    c := payload.NewContainer(map[string]payload.TypedMempool{"plaintext_v1": pt.New(), "bundle_v1": bundle.New()})
+    a0 := &pt.PlaintextTx{From:"A", Nonce:0, Gas:1, Fee:1, Sig: make([]byte,32)}
+    enc, _ := a0.MarshalBinary()
+    b1 := &bundle.BundleTx{From:"S", Bid:5, Txs: [][]byte{enc}, Sig: make([]byte,32)}
+    b2 := &bundle.BundleTx{From:"T", Bid:4, Txs: [][]byte{enc}, Sig: make([]byte,32)}
+    for _, p := range []payload.Payload{a0, b1, b2} {
+        if err := c.Add(p); err != nil { t.Fatalf("add: %v", err) }
+    }
+    if n := c.RemoveCommitted([]payload.Payload{b1}); n != 3 || c.Len() != 0 { t.Fatalf("removed %d, left %d", n, c.Len()) }
+    if _, ok := c.Arrival(b2); ok { t.Fatalf("arrival of a stale bundle kept") }
+}
+
+// A bundle execution skipped is dropped, but its txs are not committed: they
+// stay pooled for a later block.
+func TestContainer_RemoveExecutedSkippedBundle(t *testing.T) {
+    c := payload.NewContainer(map[string]payload.TypedMempool{"plaintext_v1": pt.New(), "bundle_v1": bundle.New()})
+    a0 := &pt.PlaintextTx{From:"A", Nonce:0, Gas:1, Fee:1, Sig: make([]byte,32)}
+    enc, _ := a0.MarshalBinary()
+    b1 := &bundle.BundleTx{From:"S", Bid:5, Txs: [][]byte{enc}, Sig: make([]byte,32)}
+    for _, p := range []payload.Payload{a0, b1} {
+        if err := c.Add(p); err != nil { t.Fatalf("add: %v", err) }
+    }
+    skipped := map[string]bool{string(b1.Hash()): true, string(a0.Hash()): true}
+    c.RemoveExecuted([]payload.Payload{b1}, skipped)
+    if _, ok := c.Arrival(b1); ok { t.Fatalf("skipped bundle kept") }
+    if _, ok := c.Arrival(a0); !ok || c.Len() != 1 { t.Fatalf("skipped bundle tx pruned, left %d", c.Len()) }
+}
@@ -1,5 +1,7 @@
 package payload
 
+import "time"
+
 // Payload is the generic payload contract across different mempool plugins.
 // Implementations must be deterministic and stable across nodes.
 type Payload interface {
@@ -22,3 +24,47 @@ type TypedMempool interface {
     Len() int
 }
 
+
+// Remover is implemented by typed mempools that drop payloads once a block
+// including them is committed. RemoveCommitted receives the committed
+// payloads of the pool's type and returns every payload it dropped, the
+// committed ones it held as well as those they made stale.
+type Remover interface {
+    RemoveCommitted(items []Payload) []Payload
+}
+
+// Expirer is implemented by typed mempools whose entries expire. Expire is
+// called after each commit with the wall clock and the committed height and
+// returns the payloads it dropped.
+type Expirer interface {
+    Expire(now time.Time, height uint64) []Payload
+}
+
+// Replacer is implemented by typed mempools with replace-by-fee: a payload
+// for an occupied slot may replace the pooled one. AddReplacing is Add that
+// also returns the payload it replaced, if any.
+type Replacer interface {
+    AddReplacing(p Payload) (Payload, error)
+}
+
+// Sequenced is implemented by payloads that use one nonce of their sender
+// (plaintext_v1, auction_bid_v1). Two payloads of the same type, sender and
+// nonce cannot both execute.
+type Sequenced interface {
+    SenderNonce() (from string, nonce uint64)
+}
+
+// Bundle is implemented by payloads carrying an ordered group of other
+// payloads included all-or-nothing (bundle_v1). Inner returns them in
+// execution order, or nil when they do not decode.
+type Bundle interface {
+    Inner() []Payload
+}
+
+// ConflictRemover is implemented by typed mempools whose entries include
+// payloads of other types. RemoveConflicting receives every payload of a
+// committed block, bundles expanded, and returns the entries it dropped
+// because they include one of them.
+type ConflictRemover interface {
+    RemoveConflicting(committed []Payload) []Payload
+}
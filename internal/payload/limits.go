@@ -0,0 +1,64 @@
+package payload
+
+import "time"
+
+// Defaults for Limits.
+const (
+	DefaultMaxPending   = 8192
+	DefaultMaxFuture    = 2048
+	DefaultMaxPerSender = 64
+	DefaultMaxNonceGap  = 64
+	DefaultTxTTL        = time.Hour
+	DefaultMinBumpPct   = 10
+)
+
+// Limits bounds a nonce-ordered typed mempool (plaintext_v1,
+// auction_bid_v1). Zero fields keep the defaults; a negative TTL disables
+// time-based expiry.
+type Limits struct {
+	MaxPending   int           // ready txs across all senders
+	MaxFuture    int           // txs waiting for a nonce gap to close
+	MaxPerSender int           // pending plus future txs of one sender
+	MaxNonceGap  uint64        // how far past the expected nonce a future may be
+	TTL          time.Duration // age at which a tx expires
+	TTLBlocks    uint64        // committed heights after which a tx expires (0 never)
+	MinBumpPct   uint64        // fee/bid increase, in percent, a replacement must pay
+}
+
+// WithDefaults returns l with its zero fields set to the defaults.
+func (l Limits) WithDefaults() Limits {
+	if l.MaxPending <= 0 {
+		l.MaxPending = DefaultMaxPending
+	}
+	if l.MaxFuture <= 0 {
+		l.MaxFuture = DefaultMaxFuture
+	}
+	if l.MaxPerSender <= 0 {
+		l.MaxPerSender = DefaultMaxPerSender
+	}
+	if l.MaxNonceGap == 0 {
+		l.MaxNonceGap = DefaultMaxNonceGap
+	}
+	if l.TTL == 0 {
+		l.TTL = DefaultTxTTL
+	}
+	if l.MinBumpPct == 0 {
+		l.MinBumpPct = DefaultMinBumpPct
+	}
+	return l
+}
+
+// Expired reports whether an entry added at (at, height) has outlived the
+// limits at (now, committed).
+func (l Limits) Expired(at time.Time, height uint64, now time.Time, committed uint64) bool {
+	if l.TTL > 0 && now.Sub(at) >= l.TTL {
+		return true
+	}
+	return l.TTLBlocks > 0 && committed >= height+l.TTLBlocks
+}
+
+// Bump returns the least increase over a pooled fee or bid v that a
+// replacement of the same (from, nonce) slot must pay.
+func (l Limits) Bump(v uint64) uint64 {
+	return v/100*l.MinBumpPct + v%100*l.MinBumpPct/100
+}
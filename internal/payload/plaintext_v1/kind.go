@@ -0,0 +1,20 @@
+package plaintext_v1
+
+import "github.com/zmlAEQ/Aequa-network/internal/payload"
+
+func init() {
+    payload.Register(payload.Kind{
+        Type:   "plaintext_v1",
+        New:    func() payload.Payload { return &PlaintextTx{} },
+        Verify: func(p payload.Payload, chainID string) error { return p.(*PlaintextTx).VerifySig(chainID) },
+        Pool:   func(lim payload.Limits) payload.TypedMempool { return NewWithLimits(lim) },
+        // Threshold keys off SortKey (the fee) so any plaintext_v1 payload qualifies.
+        Threshold: func(p payload.Payload, pol payload.BuilderPolicy) string {
+            if pol.MinFee > 0 && p.SortKey() < pol.MinFee { return "below_min_fee" }
+            return ""
+        },
+        Stats: func(p payload.Payload, s *payload.BlockStats) {
+            if tx, ok := p.(*PlaintextTx); ok { s.TotalFees += tx.Fee }
+        },
+    })
+}
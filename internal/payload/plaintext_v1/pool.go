@@ -1,98 +1,349 @@
 package plaintext_v1
 
 import (
-	"crypto/sha256"
+	"bytes"
+	"crypto/ed25519"
 	"errors"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/zmlAEQ/Aequa-network/internal/payload"
 	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
 )
 
 // PlaintextTx is a minimal, nonce-ordered tx used for stage-1 mempool.
+// Its JSON form is the wire envelope without the type tag.
 type PlaintextTx struct{
-    From  string
-    Nonce uint64
-    Gas   uint64
-    Fee   uint64 // used as SortKey (higher first)
-    Sig   []byte // shape-only validation in this stage
+    From  string `json:"from"`
+    Nonce uint64 `json:"nonce"`
+    Gas   uint64 `json:"gas"`
+    Fee   uint64 `json:"fee,omitempty"`   // used as SortKey (higher first)
+    To    string `json:"to,omitempty"`    // transfer recipient (empty: fee-only tx)
+    Value uint64 `json:"value,omitempty"` // amount moved to To when executed
+    Sig   []byte `json:"sig,omitempty"`   // ed25519 signature by From over SigningPayload
     h     []byte // cached hash
 }
 
 func (t *PlaintextTx) Type() string { return "plaintext_v1" }
+// body is the canonical encoding of every field but Sig.
+func (t *PlaintextTx) body() *payload.Encoder {
+    return payload.NewEncoder(t.Type()).String(t.From).Uint64(t.Nonce).Uint64(t.Gas).Uint64(t.Fee).String(t.To).Uint64(t.Value)
+}
+
 func (t *PlaintextTx) Hash() []byte {
-    if t.h == nil {
-        sum := sha256.Sum256(append([]byte(t.From), byte(t.Nonce), byte(t.Gas), byte(t.Fee)))
-        t.h = sum[:]
-    }
+    if t.h == nil { t.h = t.body().Hash() }
     return t.h
 }
+
+// MarshalBinary returns the canonical encoding (payload.CodecVersion).
+func (t *PlaintextTx) MarshalBinary() ([]byte, error) { return t.body().Bytes(t.Sig).Out(), nil }
+
+// UnmarshalBinary decodes a canonical encoding.
+func (t *PlaintextTx) UnmarshalBinary(b []byte) error {
+    d := payload.NewDecoder(b, "plaintext_v1")
+    v := PlaintextTx{From: d.String(), Nonce: d.Uint64(), Gas: d.Uint64(), Fee: d.Uint64(), To: d.String(), Value: d.Uint64(), Sig: d.Bytes()}
+    if err := d.Finish(); err != nil { return err }
+    *t = v
+    return nil
+}
+
 func (t *PlaintextTx) Validate() error {
     if t.From == "" || t.Gas == 0 || len(t.Sig) < 32 { return errors.New("invalid") }
+    if t.Value > 0 && t.To == "" { return errors.New("invalid") }
     return nil
 }
 func (t *PlaintextTx) SortKey() uint64 { return t.Fee }
 
-// Pool implements a minimal pending/future nonce-ordered pool.
+// SenderNonce returns the nonce slot the tx uses (payload.Sequenced).
+func (t *PlaintextTx) SenderNonce() (string, uint64) { return t.From, t.Nonce }
+
+// SigningPayload returns the canonical bytes From signs for chainID.
+func (t *PlaintextTx) SigningPayload(chainID string) []byte {
+    return payload.SigningBytes(chainID, t.body().Out())
+}
+
+// VerifySig checks Sig against From, the hex ed25519 public key.
+func (t *PlaintextTx) VerifySig(chainID string) error {
+    return payload.VerifyEd25519(t.From, t.SigningPayload(chainID), t.Sig)
+}
+
+// Sign sets From to the address of key and signs the tx for chainID.
+func (t *PlaintextTx) Sign(chainID string, key ed25519.PrivateKey) {
+    t.From = payload.Address(key.Public().(ed25519.PublicKey))
+    t.h = nil
+    t.Sig = ed25519.Sign(key, t.SigningPayload(chainID))
+}
+
+// Pool implements a nonce-ordered pending/future pool bounded by
+// payload.Limits: global pending and future caps with lowest-fee eviction,
+// a per-sender quota and nonce-gap bound, and time/height expiry.
 type Pool struct{
     mu      sync.Mutex
+    lim     payload.Limits
     // expected nonce per sender
     expect  map[string]uint64
     // pending ready list (per sender FIFO) and a flat slice for Get ordering
     pendBySender map[string][]*PlaintextTx
     // future holds txs with nonce > expected
     future  map[string]map[uint64]*PlaintextTx
+    // since stamps every pooled tx for expiry
+    since   map[*PlaintextTx]stamp
+    height  uint64 // last committed height passed to Expire
+    npend   int
+    nfut    int
+}
+
+type stamp struct{ at time.Time; height uint64 }
+
+func New() *Pool { return NewWithLimits(payload.Limits{}) }
+
+// NewWithLimits builds a pool bounded by lim (zero fields keep defaults).
+func NewWithLimits(lim payload.Limits) *Pool {
+    return &Pool{lim: lim.WithDefaults(), expect: map[string]uint64{}, pendBySender: map[string][]*PlaintextTx{}, future: map[string]map[uint64]*PlaintextTx{}, since: map[*PlaintextTx]stamp{}}
 }
 
-func New() *Pool {
-    return &Pool{expect: map[string]uint64{}, pendBySender: map[string][]*PlaintextTx{}, future: map[string]map[uint64]*PlaintextTx{}}
+func reject(result, msg string) error {
+    metrics.Inc("mempool_in_total", map[string]string{"result":result})
+    return errors.New(msg)
 }
 
 // Add inserts a payload; only PlaintextTx is accepted.
 func (p *Pool) Add(pl payload.Payload) error {
+    _, err := p.AddReplacing(pl)
+    return err
+}
+
+// AddReplacing is Add with replace-by-fee: a tx for a (from, nonce) slot
+// already pending or queued replaces the pooled one if its fee is at least
+// Limits.MinBumpPct percent higher. It returns the replaced tx, if any.
+func (p *Pool) AddReplacing(pl payload.Payload) (payload.Payload, error) {
     tx, ok := pl.(*PlaintextTx)
     if !ok || tx.Type() != "plaintext_v1" {
-        return nil
+        return nil, nil
     }
     if err := tx.Validate(); err != nil {
         metrics.Inc("mempool_in_total", map[string]string{"result":"invalid"})
-        return err
+        return nil, err
     }
     p.mu.Lock(); defer p.mu.Unlock()
+    defer p.gauge(p.npend)
     exp := p.expect[tx.From]
     switch {
     case tx.Nonce < exp:
-        metrics.Inc("mempool_in_total", map[string]string{"result":"old"})
-        return errors.New("old nonce")
-    case tx.Nonce == exp:
+        ll := p.pendBySender[tx.From]
+        if len(ll) == 0 || tx.Nonce < ll[0].Nonce { return nil, reject("old", "old nonce") }
+        i := tx.Nonce - ll[0].Nonce
+        if err := p.bump(ll[i], tx); err != nil { return nil, err }
+        old := ll[i]
+        ll[i] = tx
+        p.replaced(old, tx, "pending")
+        return old, nil
+    case tx.Nonce > exp:
+        if old, exists := p.future[tx.From][tx.Nonce]; exists {
+            if err := p.bump(old, tx); err != nil { return nil, err }
+            p.future[tx.From][tx.Nonce] = tx
+            p.replaced(old, tx, "future")
+            return old, nil
+        }
+        if tx.Nonce-exp > p.lim.MaxNonceGap { return nil, reject("nonce_gap", "nonce gap too large") }
+    }
+    if len(p.pendBySender[tx.From])+len(p.future[tx.From]) >= p.lim.MaxPerSender {
+        return nil, reject("sender_quota", "sender quota exceeded")
+    }
+    p.since[tx] = stamp{at: time.Now(), height: p.height}
+    if tx.Nonce == exp {
+        if p.npend >= p.lim.MaxPending && !p.evictPending(tx) {
+            delete(p.since, tx)
+            return nil, reject("overflow", "mempool full")
+        }
         p.pendBySender[tx.From] = append(p.pendBySender[tx.From], tx)
+        p.npend++
         p.expect[tx.From] = exp + 1
         // If subsequent futures become ready, promote them
-        for {
-            futs := p.future[tx.From]
-            nx := p.expect[tx.From]
-            if futs == nil { break }
-            if next, ok := futs[nx]; ok {
-                p.pendBySender[tx.From] = append(p.pendBySender[tx.From], next)
-                delete(futs, nx)
-                p.expect[tx.From] = nx + 1
-            } else { break }
-        }
+        p.promote(tx.From)
+        // Promotions may push pending past the cap: trim the cheapest tails.
+        for p.npend > p.lim.MaxPending { if !p.evictPending(nil) { break } }
         metrics.Inc("mempool_in_total", map[string]string{"result":"ok"})
-        metrics.AddGauge("mempool_size", nil, 1)
-        return nil
-    default: // tx.Nonce > exp
-        if p.future[tx.From] == nil { p.future[tx.From] = map[uint64]*PlaintextTx{} }
-        // dedup future by (from, nonce)
-        if _, exists := p.future[tx.From][tx.Nonce]; exists {
-            metrics.Inc("mempool_in_total", map[string]string{"result":"dup"})
-            return errors.New("duplicate future")
+        return nil, nil
+    }
+    if p.nfut >= p.lim.MaxFuture && !p.evictFuture(tx) {
+        delete(p.since, tx)
+        return nil, reject("future_overflow", "future queue full")
+    }
+    if p.future[tx.From] == nil { p.future[tx.From] = map[uint64]*PlaintextTx{} }
+    p.future[tx.From][tx.Nonce] = tx
+    p.nfut++
+    metrics.Inc("mempool_in_total", map[string]string{"result":"future"})
+    return nil, nil
+}
+
+// bump checks that tx may replace old: a different tx paying at least
+// MinBumpPct percent more.
+func (p *Pool) bump(old, tx *PlaintextTx) error {
+    if bytes.Equal(old.Hash(), tx.Hash()) { return reject("dup", "duplicate tx") }
+    if tx.Fee <= old.Fee || tx.Fee-old.Fee < p.lim.Bump(old.Fee) {
+        return reject("underpriced", "replacement fee too low")
+    }
+    return nil
+}
+
+// replaced moves old's expiry stamp to the fresh tx and counts the
+// replacement. Callers hold p.mu.
+func (p *Pool) replaced(old, tx *PlaintextTx, slot string) {
+    delete(p.since, old)
+    p.since[tx] = stamp{at: time.Now(), height: p.height}
+    metrics.Inc("mempool_in_total", map[string]string{"result":"replaced"})
+    metrics.Inc("mempool_replaced_total", map[string]string{"slot":slot})
+}
+
+// gauge moves mempool_size by the pending count change since before.
+// Callers hold p.mu.
+func (p *Pool) gauge(before int) {
+    if d := p.npend - before; d != 0 { metrics.AddGauge("mempool_size", nil, int64(d)) }
+}
+
+// lower orders txs for eviction: lowest fee first, ties by (from, nonce).
+func lower(a, b *PlaintextTx) bool {
+    if a.Fee != b.Fee { return a.Fee < b.Fee }
+    if a.From != b.From { return a.From < b.From }
+    return a.Nonce < b.Nonce
+}
+
+// evictPending drops the cheapest sender tail (the highest pending nonce of a
+// sender, so the rest stays contiguous) and rolls that sender's expected
+// nonce back to it. With an incoming tx only a tail of another sender paying
+// a lower fee is evicted. Callers hold p.mu.
+func (p *Pool) evictPending(in *PlaintextTx) bool {
+    var victim *PlaintextTx
+    for from, ll := range p.pendBySender {
+        if in != nil && from == in.From { continue }
+        if tail := ll[len(ll)-1]; victim == nil || lower(tail, victim) { victim = tail }
+    }
+    if victim == nil || (in != nil && victim.Fee >= in.Fee) { return false }
+    ll := p.pendBySender[victim.From]
+    p.setPending(victim.From, ll[:len(ll)-1])
+    p.expect[victim.From] = victim.Nonce
+    p.npend--
+    delete(p.since, victim)
+    metrics.Inc("mempool_evicted_total", map[string]string{"reason":"fee"})
+    return true
+}
+
+// evictFuture drops the cheapest future if the incoming tx pays more.
+// Callers hold p.mu.
+func (p *Pool) evictFuture(in *PlaintextTx) bool {
+    var victim *PlaintextTx
+    for _, futs := range p.future {
+        for _, tx := range futs {
+            if victim == nil || lower(tx, victim) { victim = tx }
         }
-        p.future[tx.From][tx.Nonce] = tx
-        metrics.Inc("mempool_in_total", map[string]string{"result":"future"})
-        return nil
     }
+    if victim == nil || victim.Fee >= in.Fee { return false }
+    p.dropFuture(victim)
+    metrics.Inc("mempool_evicted_total", map[string]string{"reason":"fee"})
+    return true
+}
+
+// setPending stores a sender's pending list, dropping empty ones.
+func (p *Pool) setPending(from string, ll []*PlaintextTx) {
+    if len(ll) == 0 { delete(p.pendBySender, from); return }
+    p.pendBySender[from] = ll
+}
+
+// dropFuture removes a future tx. Callers hold p.mu.
+func (p *Pool) dropFuture(tx *PlaintextTx) {
+    futs := p.future[tx.From]
+    delete(futs, tx.Nonce)
+    if len(futs) == 0 { delete(p.future, tx.From) }
+    delete(p.since, tx)
+    p.nfut--
+}
+
+// promote moves futures that became ready to pending. Callers hold p.mu.
+func (p *Pool) promote(from string) {
+    futs := p.future[from]
+    for futs != nil {
+        nx := p.expect[from]
+        next, ok := futs[nx]
+        if !ok { break }
+        p.pendBySender[from] = append(p.pendBySender[from], next)
+        delete(futs, nx)
+        p.expect[from] = nx + 1
+        p.npend++
+        p.nfut--
+    }
+    if futs != nil && len(futs) == 0 { delete(p.future, from) }
+}
+
+// RemoveCommitted drops the committed txs together with every pending or
+// future tx of their senders at or below a committed nonce, advances the
+// senders' expected nonces past them and promotes futures that became ready.
+// Committed txs this pool never saw still advance their sender. It returns
+// the txs it dropped.
+func (p *Pool) RemoveCommitted(items []payload.Payload) []payload.Payload {
+    next := map[string]uint64{} // sender -> first nonce not committed
+    var senders []string
+    for _, it := range items {
+        tx, ok := it.(*PlaintextTx)
+        if !ok { continue }
+        n, seen := next[tx.From]
+        if !seen { senders = append(senders, tx.From) }
+        if tx.Nonce+1 > n { next[tx.From] = tx.Nonce + 1 }
+    }
+    p.mu.Lock(); defer p.mu.Unlock()
+    defer p.gauge(p.npend)
+    var out []payload.Payload
+    for _, from := range senders {
+        n := next[from]
+        keep := make([]*PlaintextTx, 0, len(p.pendBySender[from]))
+        for _, tx := range p.pendBySender[from] {
+            if tx.Nonce < n { out = append(out, tx); delete(p.since, tx); p.npend--; continue }
+            keep = append(keep, tx)
+        }
+        p.setPending(from, keep)
+        for nonce, tx := range p.future[from] {
+            if nonce < n { out = append(out, tx); p.dropFuture(tx) }
+        }
+        if n > p.expect[from] { p.expect[from] = n }
+        p.promote(from)
+    }
+    return out
+}
+
+// Expire drops txs that outlived the pool's TTL as of now and the committed
+// height. When a pending tx expires its sender's later pending txs move back
+// to the future queue behind the reopened nonce.
+func (p *Pool) Expire(now time.Time, height uint64) []payload.Payload {
+    p.mu.Lock(); defer p.mu.Unlock()
+    defer p.gauge(p.npend)
+    if height > p.height { p.height = height }
+    expired := func(tx *PlaintextTx) bool {
+        st := p.since[tx]
+        return p.lim.Expired(st.at, st.height, now, p.height)
+    }
+    var out []payload.Payload
+    for _, futs := range p.future {
+        for _, tx := range futs {
+            if expired(tx) { out = append(out, tx); p.dropFuture(tx) }
+        }
+    }
+    for from, ll := range p.pendBySender {
+        i := 0
+        for i < len(ll) && !expired(ll[i]) { i++ }
+        if i == len(ll) { continue }
+        p.setPending(from, ll[:i])
+        p.expect[from] = ll[i].Nonce
+        p.npend -= len(ll) - i
+        for _, tx := range ll[i:] {
+            if expired(tx) { out = append(out, tx); delete(p.since, tx); continue }
+            if p.future[from] == nil { p.future[from] = map[uint64]*PlaintextTx{} }
+            p.future[from][tx.Nonce] = tx
+            p.nfut++
+        }
+    }
+    for range out { metrics.Inc("mempool_evicted_total", map[string]string{"reason":"expired"}) }
+    return out
 }
 
 // Get returns up to n ready txs ordered by fee (desc), stable by (from,nonce).
@@ -113,7 +364,5 @@ func (p *Pool) Get(n int, _ int) []payload.Payload {
 
 func (p *Pool) Len() int {
     p.mu.Lock(); defer p.mu.Unlock()
-    sum := 0
-    for _, ll := range p.pendBySender { sum += len(ll) }
-    return sum
+    return p.npend
 }
@@ -1,7 +1,11 @@
 package plaintext_v1
 
 import (
+    "crypto/ed25519"
+    "strings"
     "testing"
+    "time"
+    "github.com/zmlAEQ/Aequa-network/internal/payload"
     "github.com/zmlAEQ/Aequa-network/pkg/metrics"
 )
 
@@ -23,12 +27,41 @@ func TestPool_Add_PendingAndFuturePromotion(t *testing.T) {
 func TestPool_Add_ReplayAndDup(t *testing.T) {
     metrics.Reset()
     p := New()
-    _ = p.Add(tx("B", 0, 1))
-    // old nonce rejected
-    if err := p.Add(tx("B", 0, 2)); err == nil { t.Fatalf("want old nonce error") }
-    // duplicate future rejected
+    b0 := tx("B", 0, 1)
+    _ = p.Add(b0)
+    // duplicate pending and future rejected
+    if err := p.Add(tx("B", 0, 1)); err == nil { t.Fatalf("want dup error") }
     if err := p.Add(tx("B", 2, 1)); err != nil { t.Fatalf("add future: %v", err) }
-    if err := p.Add(tx("B", 2, 3)); err == nil { t.Fatalf("want dup future error") }
+    if err := p.Add(tx("B", 2, 1)); err == nil { t.Fatalf("want dup future error") }
+    // old nonce rejected once committed
+    p.RemoveCommitted([]payload.Payload{b0})
+    if err := p.Add(tx("B", 0, 5)); err == nil { t.Fatalf("want old nonce error") }
+}
+
+func TestPool_ReplaceByFee(t *testing.T) {
+    metrics.Reset()
+    p := NewWithLimits(payload.Limits{MinBumpPct: 10})
+    _ = p.Add(tx("A", 0, 100))
+    _ = p.Add(tx("A", 1, 100))
+    _ = p.Add(tx("A", 3, 100))
+    if _, err := p.AddReplacing(tx("A", 1, 109)); err == nil { t.Fatalf("bump below 10%% accepted") }
+    old, err := p.AddReplacing(tx("A", 1, 110))
+    if err != nil || old.(*PlaintextTx).Fee != 100 { t.Fatalf("pending replacement: old=%v err=%v", old, err) }
+    if old, err := p.AddReplacing(tx("A", 3, 200)); err != nil || old == nil { t.Fatalf("future replacement: %v", err) }
+    got := p.Get(0, 0)
+    if len(got) != 2 || got[0].(*PlaintextTx).Fee != 110 || got[1].(*PlaintextTx).Nonce != 0 { t.Fatalf("pending %v", got) }
+    // The replacement keeps its slot: nonce 2 still promotes the bumped future.
+    _ = p.Add(tx("A", 2, 1))
+    if p.Len() != 4 { t.Fatalf("len %d", p.Len()) }
+    dump := metrics.DumpProm()
+    for _, want := range []string{
+        `mempool_in_total{result="underpriced"} 1`,
+        `mempool_replaced_total{slot="pending"} 1`,
+        `mempool_replaced_total{slot="future"} 1`,
+        `mempool_size 4`,
+    } {
+        if !strings.Contains(dump, want) { t.Fatalf("missing %s in %s", want, dump) }
+    }
 }
 
 func TestPool_Get_SortsByFee(t *testing.T) {
@@ -40,3 +73,78 @@ func TestPool_Get_SortsByFee(t *testing.T) {
     if out[0].(*PlaintextTx).Fee < out[1].(*PlaintextTx).Fee { t.Fatalf("order not by fee desc") }
 }
 
+
+func TestPool_RemoveCommitted_AdvancesNonceAndPromotes(t *testing.T) {
+    metrics.Reset()
+    p := New()
+    a0, a1 := tx("A", 0, 5), tx("A", 1, 5)
+    _ = p.Add(a0); _ = p.Add(a1)
+    _ = p.Add(tx("A", 3, 5)) // future until nonce 2 is committed
+    _ = p.Add(tx("B", 0, 9))
+    // A block includes A/0 and a nonce-2 tx this pool never saw.
+    dropped := p.RemoveCommitted([]payload.Payload{a0, tx("A", 2, 1)})
+    if len(dropped) != 2 { t.Fatalf("dropped %d, want A/0 and the stale A/1", len(dropped)) }
+    if p.Len() != 2 { t.Fatalf("pending %d, want B/0 and promoted A/3", p.Len()) }
+    if err := p.Add(tx("A", 1, 50)); err == nil { t.Fatalf("committed nonce admitted again") }
+    if err := p.Add(tx("A", 4, 5)); err != nil { t.Fatalf("next nonce: %v", err) }
+    if !strings.Contains(metrics.DumpProm(), "mempool_size 3") { t.Fatalf("gauge: %s", metrics.DumpProm()) }
+}
+
+func TestPool_Limits_EvictLowestFeeAndRejectReasons(t *testing.T) {
+    metrics.Reset()
+    p := NewWithLimits(payload.Limits{MaxPending: 2, MaxFuture: 2, MaxPerSender: 2, MaxNonceGap: 3})
+    _ = p.Add(tx("A", 0, 5))
+    _ = p.Add(tx("B", 0, 1))
+    // Full: a cheaper tx is refused, a better paying one evicts B/0.
+    if err := p.Add(tx("C", 0, 1)); err == nil { t.Fatalf("cheap tx admitted into a full pool") }
+    if err := p.Add(tx("C", 0, 9)); err != nil { t.Fatalf("better fee: %v", err) }
+    if got := p.Get(0, 0); len(got) != 2 || got[1].(*PlaintextTx).From != "A" { t.Fatalf("pending %v", got) }
+    // The eviction reopened B's nonce.
+    if err := p.Add(tx("B", 0, 10)); err != nil { t.Fatalf("evicted nonce not reopened: %v", err) }
+
+    if err := p.Add(tx("D", 9, 1)); err == nil { t.Fatalf("nonce gap admitted") }
+    _ = p.Add(tx("D", 2, 1))
+    _ = p.Add(tx("D", 3, 1))
+    if err := p.Add(tx("D", 1, 1)); err == nil { t.Fatalf("sender quota not enforced") }
+    if err := p.Add(tx("E", 1, 1)); err == nil { t.Fatalf("future queue overflow admitted") }
+    if err := p.Add(tx("E", 1, 5)); err != nil { t.Fatalf("better paying future: %v", err) }
+    dump := metrics.DumpProm()
+    for _, want := range []string{
+        `mempool_in_total{result="overflow"} 1`,
+        `mempool_in_total{result="nonce_gap"} 1`,
+        `mempool_in_total{result="sender_quota"} 1`,
+        `mempool_in_total{result="future_overflow"} 1`,
+        `mempool_evicted_total{reason="fee"} 3`,
+    } {
+        if !strings.Contains(dump, want) { t.Fatalf("missing %s in %s", want, dump) }
+    }
+}
+
+func TestPool_Expire_ByAgeAndHeight(t *testing.T) {
+    p := NewWithLimits(payload.Limits{TTL: time.Minute, TTLBlocks: 5})
+    a0, a1 := tx("A", 0, 1), tx("A", 1, 1)
+    _ = p.Add(a0); _ = p.Add(a1)
+    _ = p.Add(tx("B", 3, 1))
+    if got := p.Expire(time.Now(), 4); len(got) != 0 { t.Fatalf("expired early: %v", got) }
+    // Only a0 expires: a1 waits behind the reopened nonce 0.
+    p.since[a1] = stamp{at: time.Now().Add(time.Hour), height: 4}
+    got := p.Expire(time.Now().Add(2*time.Minute), 4)
+    if len(got) != 2 || p.Len() != 0 { t.Fatalf("expired %d, pending %d", len(got), p.Len()) }
+    if err := p.Add(tx("A", 0, 1)); err != nil || p.Len() != 2 { t.Fatalf("reopened nonce: %v len=%d", err, p.Len()) }
+    // Both were stamped at height 4: five commits later they expire without
+    // the wall clock advancing.
+    if got := p.Expire(time.Now(), 8); len(got) != 0 { t.Fatalf("height expiry too early: %d", len(got)) }
+    if got := p.Expire(time.Now(), 9); len(got) != 2 || p.Len() != 0 { t.Fatalf("height expiry dropped %d", len(got)) }
+}
+
+func TestPlaintextTx_VerifySig(t *testing.T) {
+    _, key, _ := ed25519.GenerateKey(nil)
+    tx := &PlaintextTx{Nonce: 3, Gas: 1, Fee: 5, To: "B", Value: 7}
+    tx.Sign("chain-a", key)
+    if err := tx.VerifySig("chain-a"); err != nil { t.Fatalf("valid signature rejected: %v", err) }
+    if err := payload.VerifySig(tx, "chain-b"); err != payload.ErrBadSignature { t.Fatalf("other chain: %v", err) }
+    tx.Value = 8
+    if err := tx.VerifySig("chain-a"); err == nil { t.Fatalf("tampered value accepted") }
+    forged := &PlaintextTx{From: "A", Gas: 1, Sig: make([]byte, 64)}
+    if err := forged.VerifySig("chain-a"); err == nil { t.Fatalf("non-key sender accepted") }
+}
@@ -0,0 +1,11 @@
+package private_v1
+
+import "github.com/zmlAEQ/Aequa-network/internal/payload"
+
+func init() {
+	payload.Register(payload.Kind{
+		Type: "private_v1",
+		New:  func() payload.Payload { return &PrivateTx{} },
+		Pool: func(payload.Limits) payload.TypedMempool { return New() },
+	})
+}
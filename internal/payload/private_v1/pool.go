@@ -1,7 +1,6 @@
 package private_v1
 
 import (
-	"crypto/sha256"
 	"errors"
 	"os"
 	"sync"
@@ -11,29 +10,49 @@ import (
 )
 
 // PrivateTx represents a BEAST-style encrypted transaction (stub).
-// Sorting is not used; SortKey returns 0.
+// Sorting is not used; SortKey returns 0. Its JSON form is the wire
+// envelope without the type tag.
 type PrivateTx struct {
-	From         string
-	Nonce        uint64
-	Ciphertext   []byte
-	EphemeralKey []byte
-	TargetHeight uint64
+	From         string `json:"from"`
+	Nonce        uint64 `json:"nonce"`
+	Ciphertext   []byte `json:"ciphertext,omitempty"`
+	EphemeralKey []byte `json:"ephemeral_key,omitempty"`
+	TargetHeight uint64 `json:"target_height,omitempty"`
 	// Optional batched BEAST fields (used when Mode=="batched").
-	BatchIndex   uint64
-	PuncturedKey []byte
+	BatchIndex   uint64 `json:"batch_index,omitempty"`
+	PuncturedKey []byte `json:"punctured_key,omitempty"`
 	h            []byte // cached hash
 }
 
 func (t *PrivateTx) Type() string { return "private_v1" }
 
+// body is the canonical encoding of the tx; private_v1 carries no signature.
+func (t *PrivateTx) body() *payload.Encoder {
+	return payload.NewEncoder(t.Type()).String(t.From).Uint64(t.Nonce).Bytes(t.Ciphertext).Bytes(t.EphemeralKey).
+		Uint64(t.TargetHeight).Uint64(t.BatchIndex).Bytes(t.PuncturedKey)
+}
+
 func (t *PrivateTx) Hash() []byte {
 	if t.h == nil {
-		sum := sha256.Sum256(append(append([]byte(t.From), byte(t.Nonce)), t.Ciphertext...))
-		t.h = sum[:]
+		t.h = t.body().Hash()
 	}
 	return t.h
 }
 
+// MarshalBinary returns the canonical encoding (payload.CodecVersion).
+func (t *PrivateTx) MarshalBinary() ([]byte, error) { return t.body().Out(), nil }
+
+// UnmarshalBinary decodes a canonical encoding.
+func (t *PrivateTx) UnmarshalBinary(b []byte) error {
+	d := payload.NewDecoder(b, "private_v1")
+	v := PrivateTx{From: d.String(), Nonce: d.Uint64(), Ciphertext: d.Bytes(), EphemeralKey: d.Bytes(), TargetHeight: d.Uint64(), BatchIndex: d.Uint64(), PuncturedKey: d.Bytes()}
+	if err := d.Finish(); err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
 func (t *PrivateTx) Validate() error {
 	if t.From == "" || len(t.Ciphertext) == 0 || len(t.EphemeralKey) == 0 {
 		return errors.New("invalid")
@@ -88,6 +107,36 @@ func (p *Pool) Add(pl payload.Payload) error {
 	return nil
 }
 
+// RemoveCommitted drops committed private txs by hash. Their hashes stay in
+// seen so a late gossip copy is not admitted again.
+func (p *Pool) RemoveCommitted(items []payload.Payload) []payload.Payload {
+	drop := map[string]struct{}{}
+	for _, it := range items {
+		if tx, ok := it.(*PrivateTx); ok {
+			drop[string(tx.Hash())] = struct{}{}
+		}
+	}
+	if len(drop) == 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []payload.Payload
+	keep := make([]payload.Payload, 0, len(p.items))
+	for _, it := range p.items {
+		if _, ok := drop[string(it.Hash())]; ok {
+			out = append(out, it)
+			continue
+		}
+		keep = append(keep, it)
+	}
+	if len(out) > 0 {
+		p.items = keep
+		metrics.SetGauge("private_pool_size", nil, int64(len(p.items)))
+	}
+	return out
+}
+
 func (p *Pool) Get(n int, _ int) []payload.Payload {
 	p.mu.Lock()
 	defer p.mu.Unlock()
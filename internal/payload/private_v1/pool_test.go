@@ -1,6 +1,10 @@
 package private_v1
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/zmlAEQ/Aequa-network/internal/payload"
+)
 
 func TestPool_AddGet(t *testing.T) {
 	p := New()
@@ -26,3 +30,16 @@ func TestPool_RejectInvalid(t *testing.T) {
 		t.Fatalf("expected error on invalid tx")
 	}
 }
+
+func TestPool_RemoveCommittedKeepsDedup(t *testing.T) {
+	p := New()
+	tx := &PrivateTx{From: "A", Ciphertext: []byte{1}, EphemeralKey: []byte{2}}
+	_ = p.Add(tx)
+	_ = p.Add(&PrivateTx{From: "B", Ciphertext: []byte{3}, EphemeralKey: []byte{4}})
+	if got := p.RemoveCommitted([]payload.Payload{tx}); len(got) != 1 || p.Len() != 1 {
+		t.Fatalf("removed %d, len %d", len(got), p.Len())
+	}
+	if err := p.Add(tx); err == nil {
+		t.Fatalf("committed private tx admitted again")
+	}
+}
@@ -0,0 +1,11 @@
+package reconfig_v1
+
+import "github.com/zmlAEQ/Aequa-network/internal/payload"
+
+// The pool follows the epoch schedule, so the node builds it (New).
+func init() {
+    payload.Register(payload.Kind{
+        Type: Type,
+        New:  func() payload.Payload { return &ReconfigTx{} },
+    })
+}
@@ -0,0 +1,217 @@
+package reconfig_v1
+
+import (
+    "crypto/ed25519"
+    "crypto/sha256"
+    "encoding/binary"
+    "errors"
+    "sync"
+
+    "github.com/zmlAEQ/Aequa-network/internal/payload"
+    "github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// Type is the payload type of validator set change endorsements.
+const Type = "reconfig_v1"
+
+// Operations (mirroring the qbft.Change ops).
+const (
+    OpAdd       = "add"
+    OpRemove    = "remove"
+    OpThreshold = "threshold"
+)
+
+// signDomain separates endorsement signatures from consensus messages signed
+// with the same node key.
+const signDomain = "aequa/reconfig/v1"
+
+// ReconfigTx is one operator's endorsement of a validator set change. A
+// change is agreed once a quorum of the operators in force endorsed it in
+// blocks of the named Epoch; endorsements committed in any other epoch are
+// ignored, so an old one cannot be replayed later. Its JSON form is the wire
+// envelope without the type tag.
+type ReconfigTx struct {
+    From      string `json:"from"`                // endorsing operator (cluster-lock peer_id)
+    Nonce     uint64 `json:"nonce"`
+    Epoch     uint64 `json:"epoch,omitempty"`
+    Op        string `json:"op,omitempty"`        // OpAdd, OpRemove or OpThreshold
+    Operator  string `json:"operator,omitempty"`  // operator added or removed
+    PubKey    []byte `json:"pubkey,omitempty"`    // ed25519 key of an added operator
+    Threshold int    `json:"threshold,omitempty"` // new quorum lower bound (OpThreshold)
+    Sig       []byte `json:"sig,omitempty"`       // ed25519 over SigningBytes by From's node key
+    h         []byte // cached hash
+}
+
+func (t *ReconfigTx) Type() string { return Type }
+
+// ChangeKey identifies the endorsed change; endorsements of one change by
+// different operators share it.
+func (t *ReconfigTx) ChangeKey() string {
+    h := sha256.New()
+    writeChange(h, t)
+    return string(h.Sum(nil))
+}
+
+// SigningBytes is what From signs: the change, the epoch and the nonce.
+func (t *ReconfigTx) SigningBytes() []byte {
+    h := sha256.New()
+    h.Write([]byte(signDomain))
+    writeChange(h, t)
+    var buf [8]byte
+    binary.BigEndian.PutUint64(buf[:], t.Epoch)
+    h.Write(buf[:])
+    binary.BigEndian.PutUint64(buf[:], t.Nonce)
+    h.Write(buf[:])
+    return h.Sum(nil)
+}
+
+func writeChange(h interface{ Write([]byte) (int, error) }, t *ReconfigTx) {
+    var buf [8]byte
+    for _, s := range []string{t.Op, t.Operator, string(t.PubKey)} {
+        binary.BigEndian.PutUint32(buf[:4], uint32(len(s)))
+        h.Write(buf[:4])
+        h.Write([]byte(s))
+    }
+    binary.BigEndian.PutUint64(buf[:], uint64(t.Threshold))
+    h.Write(buf[:])
+}
+
+// Sign sets Sig with the endorsing operator's node key.
+func (t *ReconfigTx) Sign(key ed25519.PrivateKey) {
+    t.Sig = ed25519.Sign(key, t.SigningBytes())
+    t.h = nil
+}
+
+// body is the canonical encoding of every field but Sig.
+func (t *ReconfigTx) body() *payload.Encoder {
+    return payload.NewEncoder(Type).String(t.From).Uint64(t.Nonce).Uint64(t.Epoch).String(t.Op).String(t.Operator).
+        Bytes(t.PubKey).Uint64(uint64(t.Threshold))
+}
+
+func (t *ReconfigTx) Hash() []byte {
+    if t.h == nil { t.h = t.body().Hash() }
+    return t.h
+}
+
+// MarshalBinary returns the canonical encoding (payload.CodecVersion).
+func (t *ReconfigTx) MarshalBinary() ([]byte, error) { return t.body().Bytes(t.Sig).Out(), nil }
+
+// UnmarshalBinary decodes a canonical encoding.
+func (t *ReconfigTx) UnmarshalBinary(b []byte) error {
+    d := payload.NewDecoder(b, Type)
+    v := ReconfigTx{From: d.String(), Nonce: d.Uint64(), Epoch: d.Uint64(), Op: d.String(), Operator: d.String(), PubKey: d.Bytes(), Threshold: int(d.Uint64()), Sig: d.Bytes()}
+    if err := d.Finish(); err != nil { return err }
+    *t = v
+    return nil
+}
+
+// Validate checks the shape; the endorser's membership and signature depend
+// on the validator set and are checked when the block is applied.
+func (t *ReconfigTx) Validate() error {
+    if t.From == "" || len(t.Sig) != ed25519.SignatureSize { return errors.New("invalid") }
+    switch t.Op {
+    case OpAdd:
+        if t.Operator == "" || (len(t.PubKey) != 0 && len(t.PubKey) != ed25519.PublicKeySize) { return errors.New("invalid add") }
+    case OpRemove:
+        if t.Operator == "" { return errors.New("invalid remove") }
+    case OpThreshold:
+        if t.Threshold <= 0 { return errors.New("invalid threshold") }
+    default:
+        return errors.New("unknown op")
+    }
+    return nil
+}
+
+// SortKey is constant: endorsements keep arrival order.
+func (t *ReconfigTx) SortKey() uint64 { return 0 }
+
+// Pool holds endorsements in arrival order, deduplicated by hash. With an
+// epoch clock, endorsements for epochs already over are dropped.
+type Pool struct {
+    mu      sync.Mutex
+    epoch   func() uint64
+    order   []*ReconfigTx
+    byHash  map[string]uint64 // hash -> epoch, also for committed ones
+}
+
+// New builds a pool; epoch returns the current epoch (nil keeps everything).
+func New(epoch func() uint64) *Pool {
+    return &Pool{epoch: epoch, byHash: map[string]uint64{}}
+}
+
+// Add inserts a payload; only ReconfigTx is accepted.
+func (p *Pool) Add(pl payload.Payload) error {
+    tx, ok := pl.(*ReconfigTx)
+    if !ok { return nil }
+    if err := tx.Validate(); err != nil {
+        metrics.Inc("mempool_in_total", map[string]string{"result":"invalid"})
+        return err
+    }
+    p.mu.Lock(); defer p.mu.Unlock()
+    if p.epoch != nil && tx.Epoch < p.epoch() {
+        metrics.Inc("mempool_in_total", map[string]string{"result":"old"})
+        return errors.New("epoch over")
+    }
+    k := string(tx.Hash())
+    if _, dup := p.byHash[k]; dup {
+        metrics.Inc("mempool_in_total", map[string]string{"result":"duplicate"})
+        return errors.New("duplicate")
+    }
+    p.byHash[k] = tx.Epoch
+    p.order = append(p.order, tx)
+    metrics.Inc("mempool_in_total", map[string]string{"result":"ok"})
+    return nil
+}
+
+// Get returns up to n endorsements of the current (or a later) epoch.
+func (p *Pool) Get(n int, _ int) []payload.Payload {
+    p.mu.Lock(); defer p.mu.Unlock()
+    p.expire()
+    if n <= 0 || n > len(p.order) { n = len(p.order) }
+    out := make([]payload.Payload, 0, n)
+    for _, tx := range p.order[:n] { out = append(out, tx) }
+    return out
+}
+
+func (p *Pool) Len() int {
+    p.mu.Lock(); defer p.mu.Unlock()
+    p.expire()
+    return len(p.order)
+}
+
+// RemoveCommitted drops committed endorsements. Their hashes are remembered
+// until their epoch is over so a late gossip copy is not included twice.
+func (p *Pool) RemoveCommitted(items []payload.Payload) []payload.Payload {
+    drop := map[string]uint64{}
+    for _, it := range items {
+        if tx, ok := it.(*ReconfigTx); ok { drop[string(tx.Hash())] = tx.Epoch }
+    }
+    if len(drop) == 0 { return nil }
+    p.mu.Lock(); defer p.mu.Unlock()
+    var out []payload.Payload
+    keep := p.order[:0]
+    for _, tx := range p.order {
+        if _, ok := drop[string(tx.Hash())]; ok { out = append(out, tx); continue }
+        keep = append(keep, tx)
+    }
+    for i := len(keep); i < len(p.order); i++ { p.order[i] = nil }
+    p.order = keep
+    for k, e := range drop { p.byHash[k] = e }
+    return out
+}
+
+// expire drops endorsements whose epoch is over. Callers hold p.mu.
+func (p *Pool) expire() {
+    if p.epoch == nil { return }
+    cur := p.epoch()
+    for k, e := range p.byHash {
+        if e < cur { delete(p.byHash, k) }
+    }
+    keep := p.order[:0]
+    for _, tx := range p.order {
+        if tx.Epoch < cur { continue }
+        keep = append(keep, tx)
+    }
+    for i := len(keep); i < len(p.order); i++ { p.order[i] = nil }
+    p.order = keep
+}
@@ -0,0 +1,69 @@
+package reconfig_v1
+
+import (
+    "crypto/ed25519"
+    "testing"
+
+    "github.com/zmlAEQ/Aequa-network/internal/payload"
+    "github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+func endorse(t *testing.T, from string, epoch uint64) *ReconfigTx {
+    t.Helper()
+    _, key, _ := ed25519.GenerateKey(nil)
+    tx := &ReconfigTx{From: from, Epoch: epoch, Op: OpRemove, Operator: "n3"}
+    tx.Sign(key)
+    return tx
+}
+
+func TestReconfigTx_ChangeKeySharedAcrossEndorsers(t *testing.T) {
+    a, b := endorse(t, "n0", 1), endorse(t, "n1", 1)
+    if a.ChangeKey() != b.ChangeKey() { t.Fatalf("same change, different keys") }
+    if string(a.Hash()) == string(b.Hash()) { t.Fatalf("different endorsers, same hash") }
+    c := endorse(t, "n0", 1)
+    c.Operator = "n2"
+    if c.ChangeKey() == a.ChangeKey() { t.Fatalf("different change, same key") }
+    // The epoch is signed but not part of the change.
+    d := endorse(t, "n0", 2)
+    if d.ChangeKey() != a.ChangeKey() || string(d.SigningBytes()) == string(a.SigningBytes()) { t.Fatalf("epoch binding") }
+}
+
+func TestReconfigTx_Validate(t *testing.T) {
+    bad := []*ReconfigTx{
+        {From: "n0", Op: OpAdd, Sig: make([]byte, 64)},
+        {From: "n0", Op: OpAdd, Operator: "n4", PubKey: []byte{1}, Sig: make([]byte, 64)},
+        {From: "n0", Op: OpRemove, Sig: make([]byte, 64)},
+        {From: "n0", Op: OpThreshold, Sig: make([]byte, 64)},
+        {From: "n0", Op: "rename", Operator: "n4", Sig: make([]byte, 64)},
+        {From: "n0", Op: OpRemove, Operator: "n3", Sig: make([]byte, 10)},
+        {Op: OpRemove, Operator: "n3", Sig: make([]byte, 64)},
+    }
+    for _, tx := range bad {
+        if tx.Validate() == nil { t.Fatalf("%+v accepted", tx) }
+    }
+}
+
+func TestPool_DedupAndEpochExpiry(t *testing.T) {
+    metrics.Reset()
+    epoch := uint64(1)
+    p := New(func() uint64 { return epoch })
+    a := endorse(t, "n0", 1)
+    if err := p.Add(a); err != nil { t.Fatalf("add: %v", err) }
+    if err := p.Add(a); err == nil { t.Fatalf("duplicate accepted") }
+    if err := p.Add(endorse(t, "n1", 0)); err == nil { t.Fatalf("endorsement of a past epoch accepted") }
+    if err := p.Add(endorse(t, "n1", 2)); err != nil { t.Fatalf("add next epoch: %v", err) }
+    if got := p.Get(10, 0); len(got) != 2 || got[0] != a { t.Fatalf("get %v", got) }
+
+    epoch = 2
+    if p.Len() != 1 { t.Fatalf("endorsements of epoch 1 kept: %d", p.Len()) }
+    // Expired hashes are forgotten with their entries.
+    if len(p.byHash) != 1 { t.Fatalf("hash index %d", len(p.byHash)) }
+}
+
+func TestPool_RemoveCommittedRemembersHash(t *testing.T) {
+    p := New(func() uint64 { return 1 })
+    a, b := endorse(t, "n0", 1), endorse(t, "n1", 1)
+    _ = p.Add(a); _ = p.Add(b)
+    if got := p.RemoveCommitted([]payload.Payload{a}); len(got) != 1 || p.Len() != 1 { t.Fatalf("removed %v, len %d", got, p.Len()) }
+    if err := p.Add(a); err == nil { t.Fatalf("committed endorsement admitted again") }
+}
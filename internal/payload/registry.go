@@ -0,0 +1,125 @@
+package payload
+
+import (
+	"encoding"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Kind describes a payload type to the node. Each type package registers
+// its Kind from init; the wire layer, API, builder and consensus dispatch
+// through the registry, so adding a type is a package plus an import.
+type Kind struct {
+	// Type is the identifier returned by Payload.Type.
+	Type string
+	// New returns an empty payload of the type. Its codecs are its JSON
+	// form (the flat fields of the wire envelope) and, when it implements
+	// encoding.BinaryMarshaler/BinaryUnmarshaler, its canonical encoding.
+	New func() Payload
+	// Verify is the validator run on API and gossip ingest after Validate,
+	// e.g. the sender signature for chainID. Optional.
+	Verify func(p Payload, chainID string) error
+	// Pool builds the type's mempool. Optional: pools that need more than
+	// limits (reconfig_v1 follows the epoch schedule) are wired by the node.
+	Pool func(lim Limits) TypedMempool
+	// Threshold returns the reason the builder rejects p under pol, or "".
+	// Optional.
+	Threshold func(p Payload, pol BuilderPolicy) string
+	// Stats adds p to the stats of a block including it. Optional.
+	Stats func(p Payload, s *BlockStats)
+}
+
+var (
+	kindsMu sync.RWMutex
+	kinds   = map[string]Kind{}
+)
+
+// Register adds a payload type. It panics on an empty type, a missing New
+// or a type registered twice.
+func Register(k Kind) {
+	if k.Type == "" || k.New == nil {
+		panic("payload: Register needs Type and New")
+	}
+	kindsMu.Lock()
+	defer kindsMu.Unlock()
+	if _, dup := kinds[k.Type]; dup {
+		panic(fmt.Sprintf("payload: type %q registered twice", k.Type))
+	}
+	kinds[k.Type] = k
+}
+
+// Lookup returns the registered Kind of typ.
+func Lookup(typ string) (Kind, bool) {
+	kindsMu.RLock()
+	defer kindsMu.RUnlock()
+	k, ok := kinds[typ]
+	return k, ok
+}
+
+// Kinds returns every registered Kind ordered by type.
+func Kinds() []Kind {
+	kindsMu.RLock()
+	out := make([]Kind, 0, len(kinds))
+	for _, k := range kinds {
+		out = append(out, k)
+	}
+	kindsMu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Type < out[j].Type })
+	return out
+}
+
+// Decode decodes the canonical encoding of a tx of any registered type
+// (without calling Validate).
+func Decode(b []byte) (Payload, error) {
+	typ, err := PeekType(b)
+	if err != nil {
+		return nil, err
+	}
+	k, ok := Lookup(typ)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown type %q", ErrCodec, typ)
+	}
+	p := k.New()
+	u, ok := p.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%w: no binary encoding for %q", ErrCodec, typ)
+	}
+	if err := u.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewPools builds the mempool of every registered type with a Pool
+// constructor, except the types in skip.
+func NewPools(lim Limits, skip ...string) map[string]TypedMempool {
+	pools := map[string]TypedMempool{}
+	for _, k := range Kinds() {
+		if k.Pool == nil || contains(skip, k.Type) {
+			continue
+		}
+		pools[k.Type] = k.Pool(lim)
+	}
+	return pools
+}
+
+// Summarize returns the stats of a block selecting items.
+func Summarize(items []Payload) BlockStats {
+	stats := BlockStats{Items: len(items)}
+	for _, it := range items {
+		if k, ok := Lookup(it.Type()); ok && k.Stats != nil {
+			k.Stats(it, &stats)
+		}
+	}
+	return stats
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
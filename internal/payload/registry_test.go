@@ -0,0 +1,46 @@
+package payload_test
+
+import (
+	"errors"
+	"testing"
+
+	payload "github.com/zmlAEQ/Aequa-network/internal/payload"
+	pt "github.com/zmlAEQ/Aequa-network/internal/payload/plaintext_v1"
+)
+
+func TestRegistry_BuiltinKinds(t *testing.T) {
+	k, ok := payload.Lookup("plaintext_v1")
+	if !ok || k.New().Type() != "plaintext_v1" {
+		t.Fatalf("plaintext_v1 not registered")
+	}
+	if _, ok := payload.Lookup("nope_v1"); ok {
+		t.Fatalf("unknown type found")
+	}
+	kinds := payload.Kinds()
+	for i := 1; i < len(kinds); i++ {
+		if kinds[i-1].Type >= kinds[i].Type {
+			t.Fatalf("kinds not ordered: %q before %q", kinds[i-1].Type, kinds[i].Type)
+		}
+	}
+	tx := &pt.PlaintextTx{From: "a", Gas: 1, Fee: 7, Sig: make([]byte, 64)}
+	enc, _ := tx.MarshalBinary()
+	dec, err := payload.Decode(enc)
+	if err != nil || string(dec.Hash()) != string(tx.Hash()) {
+		t.Fatalf("decode: %v", err)
+	}
+	if st := payload.Summarize([]payload.Payload{tx, tx}); st.TotalFees != 14 || st.Items != 2 {
+		t.Fatalf("stats %+v", st)
+	}
+}
+
+func TestRegistry_RejectsDuplicatesAndUnknown(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("duplicate registration did not panic")
+		}
+	}()
+	if _, err := payload.Decode(payload.NewEncoder("nope_v1").Out()); !errors.Is(err, payload.ErrCodec) {
+		t.Fatalf("unknown type decoded: %v", err)
+	}
+	payload.Register(payload.Kind{Type: "plaintext_v1", New: func() payload.Payload { return &pt.PlaintextTx{} }})
+}
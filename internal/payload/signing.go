@@ -0,0 +1,57 @@
+package payload
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+)
+
+// DefaultChainID is the chain id txs are signed for when none is configured.
+const DefaultChainID = "aequa-local"
+
+// ErrBadSignature is returned when a tx signature does not authenticate its
+// sender for the chain.
+var ErrBadSignature = errors.New("bad signature")
+
+// VerifySig runs the validator registered for p's type (Kind.Verify;
+// plaintext_v1 and auction_bid_v1 check the sender signature); other
+// payloads pass. An empty chain id means DefaultChainID.
+func VerifySig(p Payload, chainID string) error {
+	k, ok := Lookup(p.Type())
+	if !ok || k.Verify == nil {
+		return nil
+	}
+	if chainID == "" {
+		chainID = DefaultChainID
+	}
+	return k.Verify(p, chainID)
+}
+
+// sigDomain separates tx signatures from other data signed with the same
+// key.
+const sigDomain = "aequa/sig/v1"
+
+// SigningBytes returns what a sender signs for chainID: the signature
+// domain and the chain id, then the canonical body of the tx.
+func SigningBytes(chainID string, body []byte) []byte {
+	e := &Encoder{}
+	e.String(sigDomain).String(chainID)
+	return append(e.b, body...)
+}
+
+// VerifyEd25519 checks that sig is an ed25519 signature over msg by the
+// sender from, the hex encoding of its public key.
+func VerifyEd25519(from string, msg, sig []byte) error {
+	pub, err := hex.DecodeString(from)
+	if err != nil || len(pub) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return ErrBadSignature
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pub), msg, sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// Address returns the sender address of an ed25519 key: its public key in
+// hex.
+func Address(pub ed25519.PublicKey) string { return hex.EncodeToString(pub) }
@@ -1,9 +1,15 @@
 package payload
 
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
 // BlockHeader carries minimal coordinates for deterministic building.
 type BlockHeader struct {
-	Height uint64
-	Round  uint64
+	Height       uint64
+	Round        uint64
+	FeeRecipient string // credited with the fees of executed txs (empty burns them)
 }
 
 // BlockStats captures aggregate value for a block selection.
@@ -20,3 +26,34 @@ type StandardBlock struct {
 	Items  []Payload // selection result in deterministic order
 	Stats  BlockStats
 }
+
+// blockDomain separates block hashes from other hashed byte strings.
+const blockDomain = "aequa/block/v1"
+
+// Hash returns the block identity: sha256 over the header coordinates and the
+// ordered (type, hash) of each item. Stats are derived data and not covered.
+// The fee recipient is covered only when set, so blocks without one keep the
+// hash they had before it existed.
+func (b StandardBlock) Hash() []byte {
+	h := sha256.New()
+	var buf [8]byte
+	writeString := func(s string) {
+		binary.BigEndian.PutUint32(buf[:4], uint32(len(s)))
+		h.Write(buf[:4])
+		h.Write([]byte(s))
+	}
+	writeString(blockDomain)
+	binary.BigEndian.PutUint64(buf[:], b.Header.Height)
+	h.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], b.Header.Round)
+	h.Write(buf[:])
+	if b.Header.FeeRecipient != "" {
+		writeString("fee_recipient")
+		writeString(b.Header.FeeRecipient)
+	}
+	for _, it := range b.Items {
+		writeString(it.Type())
+		writeString(string(it.Hash()))
+	}
+	return h.Sum(nil)
+}
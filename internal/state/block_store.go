@@ -0,0 +1,130 @@
+package state
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "sync"
+)
+
+// CommitSig is one operator's commit vote for a block: the signature over the
+// QBFT commit message for (Height, Round, ID) of the enclosing record.
+type CommitSig struct {
+    From string `json:"from"`
+    Sig  []byte `json:"sig,omitempty"`
+}
+
+// AggSeal is a commit seal aggregated into one signature (HotStuff with the
+// BLS scheme): the signers of the QC certifying the block and their
+// aggregate signature over its vote digest.
+type AggSeal struct {
+    Signers []string `json:"signers"`
+    Sig     []byte   `json:"sig"`
+}
+
+// Receipt is the outcome of executing one tx of a committed block. Status is
+// "ok", "reverted" (fee charged and nonce used, transfer not applied) or
+// "skipped" (not executable, state untouched); Err names the reason.
+type Receipt struct {
+    Tx     []byte `json:"tx"` // payload hash
+    From   string `json:"from"`
+    Nonce  uint64 `json:"nonce"`
+    Status string `json:"status"`
+    Err    string `json:"err,omitempty"`
+    Fee    uint64 `json:"fee,omitempty"` // amount debited from From
+}
+
+// BlockRecord is a finalized block as persisted by the node: the encoded
+// block, its hash, the consensus value id it was committed under and the
+// commit seal (the quorum of commit signatures that finalized it, or AggSeal
+// when the engine aggregates them and Seal is empty). StateRoot
+// and Receipts are the result of executing the block on the state left by
+// the previous height; they are derived locally and not covered by the seal.
+type BlockRecord struct {
+    Height    uint64      `json:"height"`
+    Round     uint64      `json:"round"`
+    ID        string      `json:"id"`              // committed QBFT value id
+    Hash      []byte      `json:"hash"`            // payload.StandardBlock.Hash()
+    Block     []byte      `json:"block,omitempty"` // wire-encoded payload.StandardBlock
+    Seal      []CommitSig `json:"seal"`
+    AggSeal   *AggSeal    `json:"agg_seal,omitempty"`
+    StateRoot []byte      `json:"state_root,omitempty"` // post-state commitment
+    Receipts  []Receipt   `json:"receipts,omitempty"`
+}
+
+// ErrBlockConflict is returned when a different block is already stored at
+// the same height. Finalized heights are immutable.
+var ErrBlockConflict = errors.New("conflicting block at height")
+
+// BlockStore persists committed blocks indexed by height and by block hash.
+// Implementations should be concurrency-safe. Lookups return ErrNotFound for
+// unknown heights/hashes.
+type BlockStore interface {
+    SaveBlock(ctx context.Context, rec BlockRecord) error
+    BlockByHeight(ctx context.Context, height uint64) (BlockRecord, error)
+    BlockByHash(ctx context.Context, hash []byte) (BlockRecord, error)
+    // LastBlock returns the record with the highest height.
+    LastBlock(ctx context.Context) (BlockRecord, error)
+    Close() error
+}
+
+// checkReplace reports whether rec may be stored over prev at the same height:
+// the same hash is an idempotent re-save, a different one is a conflict.
+func checkReplace(prev, rec BlockRecord) error {
+    if !bytes.Equal(prev.Hash, rec.Hash) { return ErrBlockConflict }
+    return nil
+}
+
+// MemoryBlockStore is an in-memory BlockStore for wiring and tests. It is not
+// durable.
+type MemoryBlockStore struct {
+    mu     sync.RWMutex
+    byH    map[uint64]BlockRecord
+    byHash map[string]uint64
+    last   uint64
+}
+
+// NewMemoryBlockStore constructs an empty MemoryBlockStore.
+func NewMemoryBlockStore() *MemoryBlockStore {
+    return &MemoryBlockStore{byH: make(map[uint64]BlockRecord), byHash: make(map[string]uint64)}
+}
+
+// SaveBlock stores rec; re-saving the same block at a height is a no-op.
+func (m *MemoryBlockStore) SaveBlock(_ context.Context, rec BlockRecord) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if prev, ok := m.byH[rec.Height]; ok { return checkReplace(prev, rec) }
+    m.byH[rec.Height] = rec
+    m.byHash[string(rec.Hash)] = rec.Height
+    if rec.Height > m.last || len(m.byH) == 1 { m.last = rec.Height }
+    return nil
+}
+
+// BlockByHeight returns the block committed at height.
+func (m *MemoryBlockStore) BlockByHeight(_ context.Context, height uint64) (BlockRecord, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    rec, ok := m.byH[height]
+    if !ok { return BlockRecord{}, ErrNotFound }
+    return rec, nil
+}
+
+// BlockByHash returns the block with the given hash.
+func (m *MemoryBlockStore) BlockByHash(_ context.Context, hash []byte) (BlockRecord, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    h, ok := m.byHash[string(hash)]
+    if !ok { return BlockRecord{}, ErrNotFound }
+    return m.byH[h], nil
+}
+
+// LastBlock returns the highest stored block.
+func (m *MemoryBlockStore) LastBlock(_ context.Context) (BlockRecord, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    if len(m.byH) == 0 { return BlockRecord{}, ErrNotFound }
+    return m.byH[m.last], nil
+}
+
+// Close implements BlockStore. For MemoryBlockStore it is a no-op.
+func (m *MemoryBlockStore) Close() error { return nil }
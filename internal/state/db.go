@@ -24,12 +24,34 @@ type Store interface {
     Close() error
 }
 
+// ReplayEntry is one remembered consensus message key and the height it was
+// seen at.
+type ReplayEntry struct {
+    Key    string
+    Height uint64
+}
+
+// ReplayCheckpoint is the anti-replay cache saved alongside the last state so
+// a restarted node keeps rejecting messages it verified before.
+type ReplayCheckpoint struct {
+    Floor   uint64 // heights below are no longer remembered
+    Entries []ReplayEntry
+}
+
+// ReplayStore is implemented by stores that also persist the anti-replay
+// checkpoint (MemoryStore and FileStore do).
+type ReplayStore interface {
+    SaveReplay(ctx context.Context, c ReplayCheckpoint) error
+    LoadReplay(ctx context.Context) (ReplayCheckpoint, error)
+}
+
 // MemoryStore is a minimal in-memory implementation of Store.
 // It is intended as a stub for wiring and tests in M3 and is not durable.
 type MemoryStore struct {
-    mu   sync.RWMutex
-    have bool
-    last LastState
+    mu     sync.RWMutex
+    have   bool
+    last   LastState
+    replay *ReplayCheckpoint
 }
 
 // NewMemoryStore constructs a new empty MemoryStore.
@@ -53,6 +75,25 @@ func (m *MemoryStore) LoadLastState(_ context.Context) (LastState, error) {
     return s, nil
 }
 
+// SaveReplay keeps a copy of the replay checkpoint.
+func (m *MemoryStore) SaveReplay(_ context.Context, c ReplayCheckpoint) error {
+    c.Entries = append([]ReplayEntry(nil), c.Entries...)
+    m.mu.Lock()
+    m.replay = &c
+    m.mu.Unlock()
+    return nil
+}
+
+// LoadReplay returns the last saved replay checkpoint, or ErrNotFound.
+func (m *MemoryStore) LoadReplay(_ context.Context) (ReplayCheckpoint, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    if m.replay == nil { return ReplayCheckpoint{}, ErrNotFound }
+    c := *m.replay
+    c.Entries = append([]ReplayEntry(nil), c.Entries...)
+    return c, nil
+}
+
 // Close implements Store. For MemoryStore it is a no-op.
 func (m *MemoryStore) Close() error { return nil }
 
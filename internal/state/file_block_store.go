@@ -0,0 +1,190 @@
+package state
+
+import (
+    "context"
+    "encoding/binary"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/zmlAEQ/Aequa-network/pkg/logger"
+    "github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+// FileBlockStore is a durable BlockStore keeping one file per committed
+// height in a directory. File names carry both keys,
+// blk-<height, 20 digits>-<hex hash>.dat, so the height and hash indexes are
+// rebuilt from a directory listing on open without reading block contents.
+// Each file is written atomically (tmp write + fsync + rename).
+type FileBlockStore struct {
+    mu     sync.RWMutex
+    dir    string
+    byH    map[uint64]string // height -> file name
+    byHash map[string]uint64 // hex hash -> height
+    last   uint64
+}
+
+const (
+    blockMagic   uint32 = 0x424c4b53 // 'BLKS'
+    blockVersion uint16 = 1
+    blockPrefix         = "blk-"
+    blockSuffix         = ".dat"
+)
+
+// on-disk layout (same framing as FileStore):
+// [magic u32][version u16][reserved u16][length u32][crc32 u32][payload bytes...]
+// payload = JSON(BlockRecord)
+
+// OpenFileBlockStore opens (creating if needed) a block store in dir and
+// indexes the blocks already present. Leftover temp files are removed.
+func OpenFileBlockStore(dir string) (*FileBlockStore, error) {
+    if err := os.MkdirAll(dir, 0o700); err != nil { return nil, err }
+    ents, err := os.ReadDir(dir)
+    if err != nil { return nil, err }
+    fs := &FileBlockStore{dir: dir, byH: make(map[uint64]string), byHash: make(map[string]uint64)}
+    for _, e := range ents {
+        name := e.Name()
+        if strings.HasSuffix(name, ".tmp") { _ = os.Remove(filepath.Join(dir, name)); continue }
+        h, hashHex, ok := parseBlockName(name)
+        if !ok { continue }
+        fs.index(h, hashHex, name)
+    }
+    logger.InfoJ("block_store", map[string]any{"op": "open", "result": "ok", "blocks": len(fs.byH), "last": fs.last})
+    return fs, nil
+}
+
+func blockName(height uint64, hash []byte) string {
+    return fmt.Sprintf("%s%020d-%s%s", blockPrefix, height, hex.EncodeToString(hash), blockSuffix)
+}
+
+func parseBlockName(name string) (uint64, string, bool) {
+    if !strings.HasPrefix(name, blockPrefix) || !strings.HasSuffix(name, blockSuffix) { return 0, "", false }
+    parts := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(name, blockPrefix), blockSuffix), "-", 2)
+    if len(parts) != 2 { return 0, "", false }
+    h, err := strconv.ParseUint(parts[0], 10, 64)
+    if err != nil { return 0, "", false }
+    if _, err := hex.DecodeString(parts[1]); err != nil { return 0, "", false }
+    return h, parts[1], true
+}
+
+func (fs *FileBlockStore) index(height uint64, hashHex, name string) {
+    fs.byH[height] = name
+    fs.byHash[hashHex] = height
+    if height > fs.last || len(fs.byH) == 1 { fs.last = height }
+}
+
+func writeBlockFile(path string, rec BlockRecord) error {
+    payload, err := json.Marshal(rec)
+    if err != nil { return err }
+    var hdr [4 + 2 + 2 + 4 + 4]byte
+    off := 0
+    binary.BigEndian.PutUint32(hdr[off:], blockMagic); off += 4
+    binary.BigEndian.PutUint16(hdr[off:], blockVersion); off += 2
+    binary.BigEndian.PutUint16(hdr[off:], 0); off += 2 // reserved
+    binary.BigEndian.PutUint32(hdr[off:], uint32(len(payload))); off += 4
+    binary.BigEndian.PutUint32(hdr[off:], crc32.ChecksumIEEE(payload))
+
+    tmp := path + ".tmp"
+    f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+    if err != nil { return err }
+    if _, err = f.Write(hdr[:]); err != nil { _ = f.Close(); return err }
+    if _, err = f.Write(payload); err != nil { _ = f.Close(); return err }
+    if err = f.Sync(); err != nil { _ = f.Close(); return err }
+    if err = f.Close(); err != nil { return err }
+    if err = os.Rename(tmp, path); err != nil { return err }
+    if d, err2 := os.Open(filepath.Dir(path)); err2 == nil { _ = d.Sync(); _ = d.Close() }
+    return nil
+}
+
+func readBlockFile(path string) (BlockRecord, error) {
+    f, err := os.Open(path)
+    if err != nil { return BlockRecord{}, err }
+    defer f.Close()
+    var hdr [4 + 2 + 2 + 4 + 4]byte
+    if _, err = io.ReadFull(f, hdr[:]); err != nil { return BlockRecord{}, err }
+    if binary.BigEndian.Uint32(hdr[0:4]) != blockMagic { return BlockRecord{}, errors.New("bad magic") }
+    length := binary.BigEndian.Uint32(hdr[8:12])
+    wantCRC := binary.BigEndian.Uint32(hdr[12:16])
+    payload := make([]byte, length)
+    if _, err = io.ReadFull(f, payload); err != nil { return BlockRecord{}, err }
+    if crc32.ChecksumIEEE(payload) != wantCRC { return BlockRecord{}, errors.New("crc mismatch") }
+    var rec BlockRecord
+    if err = json.Unmarshal(payload, &rec); err != nil { return BlockRecord{}, err }
+    return rec, nil
+}
+
+// SaveBlock persists rec. Re-saving the same block at a height is a no-op;
+// a different block at a stored height returns ErrBlockConflict.
+func (fs *FileBlockStore) SaveBlock(_ context.Context, rec BlockRecord) error {
+    start := time.Now()
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if name, ok := fs.byH[rec.Height]; ok {
+        _, hashHex, _ := parseBlockName(name)
+        if hashHex == hex.EncodeToString(rec.Hash) { return nil }
+        metrics.Inc("block_store_total", map[string]string{"op": "save", "result": "conflict"})
+        logger.ErrorJ("block_store", map[string]any{"op": "save", "result": "conflict", "height": rec.Height})
+        return ErrBlockConflict
+    }
+    name := blockName(rec.Height, rec.Hash)
+    if err := writeBlockFile(filepath.Join(fs.dir, name), rec); err != nil {
+        metrics.Inc("block_store_total", map[string]string{"op": "save", "result": "error"})
+        logger.ErrorJ("block_store", map[string]any{"op": "save", "result": "error", "height": rec.Height, "err": err.Error()})
+        return err
+    }
+    fs.index(rec.Height, hex.EncodeToString(rec.Hash), name)
+    ms := float64(time.Since(start).Milliseconds())
+    metrics.Inc("block_store_total", map[string]string{"op": "save", "result": "ok"})
+    metrics.ObserveSummary("block_store_persist_ms", nil, ms)
+    logger.InfoJ("block_store", map[string]any{"op": "save", "result": "ok", "height": rec.Height, "seal": len(rec.Seal), "latency_ms": ms})
+    return nil
+}
+
+func (fs *FileBlockStore) load(name string) (BlockRecord, error) {
+    rec, err := readBlockFile(filepath.Join(fs.dir, name))
+    if err != nil {
+        metrics.Inc("block_store_total", map[string]string{"op": "load", "result": "error"})
+        logger.ErrorJ("block_store", map[string]any{"op": "load", "result": "error", "file": name, "err": err.Error()})
+        return BlockRecord{}, err
+    }
+    return rec, nil
+}
+
+// BlockByHeight returns the block committed at height.
+func (fs *FileBlockStore) BlockByHeight(_ context.Context, height uint64) (BlockRecord, error) {
+    fs.mu.RLock()
+    name, ok := fs.byH[height]
+    fs.mu.RUnlock()
+    if !ok { return BlockRecord{}, ErrNotFound }
+    return fs.load(name)
+}
+
+// BlockByHash returns the block with the given hash.
+func (fs *FileBlockStore) BlockByHash(ctx context.Context, hash []byte) (BlockRecord, error) {
+    fs.mu.RLock()
+    h, ok := fs.byHash[hex.EncodeToString(hash)]
+    fs.mu.RUnlock()
+    if !ok { return BlockRecord{}, ErrNotFound }
+    return fs.BlockByHeight(ctx, h)
+}
+
+// LastBlock returns the highest stored block.
+func (fs *FileBlockStore) LastBlock(ctx context.Context) (BlockRecord, error) {
+    fs.mu.RLock()
+    empty, last := len(fs.byH) == 0, fs.last
+    fs.mu.RUnlock()
+    if empty { return BlockRecord{}, ErrNotFound }
+    return fs.BlockByHeight(ctx, last)
+}
+
+// Close implements BlockStore. For FileBlockStore it is a no-op.
+func (fs *FileBlockStore) Close() error { return nil }
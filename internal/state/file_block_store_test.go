@@ -0,0 +1,68 @@
+package state
+
+import (
+    "context"
+    "errors"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func rec(h uint64, hash string) BlockRecord {
+    return BlockRecord{Height: h, Round: 1, ID: "v" + hash, Hash: []byte(hash), Block: []byte(`{"height":1}`),
+        Seal: []CommitSig{{From: "n0", Sig: []byte{1}}, {From: "n1", Sig: []byte{2}}, {From: "n2", Sig: []byte{3}}}}
+}
+
+func TestFileBlockStore_SaveReopenLookup(t *testing.T) {
+    ctx := context.Background()
+    dir := filepath.Join(t.TempDir(), "blocks")
+    fs, err := OpenFileBlockStore(dir)
+    if err != nil { t.Fatalf("open: %v", err) }
+    for h, hash := range map[uint64]string{1: "aa", 2: "bb", 5: "cc"} {
+        if err := fs.SaveBlock(ctx, rec(h, hash)); err != nil { t.Fatalf("save %d: %v", h, err) }
+    }
+    // Reopen: indexes are rebuilt from the directory.
+    fs, err = OpenFileBlockStore(dir)
+    if err != nil { t.Fatalf("reopen: %v", err) }
+    got, err := fs.BlockByHeight(ctx, 2)
+    if err != nil || got.ID != "vbb" || len(got.Seal) != 3 || got.Seal[1].From != "n1" {
+        t.Fatalf("by height: %+v err=%v", got, err)
+    }
+    got, err = fs.BlockByHash(ctx, []byte("cc"))
+    if err != nil || got.Height != 5 { t.Fatalf("by hash: %+v err=%v", got, err) }
+    last, err := fs.LastBlock(ctx)
+    if err != nil || last.Height != 5 { t.Fatalf("last: %+v err=%v", last, err) }
+    if _, err := fs.BlockByHeight(ctx, 3); !errors.Is(err, ErrNotFound) { t.Fatalf("want ErrNotFound, got %v", err) }
+}
+
+func TestFileBlockStore_ImmutableHeights(t *testing.T) {
+    ctx := context.Background()
+    fs, err := OpenFileBlockStore(t.TempDir())
+    if err != nil { t.Fatalf("open: %v", err) }
+    if err := fs.SaveBlock(ctx, rec(1, "aa")); err != nil { t.Fatalf("save: %v", err) }
+    if err := fs.SaveBlock(ctx, rec(1, "aa")); err != nil { t.Fatalf("idempotent re-save: %v", err) }
+    if err := fs.SaveBlock(ctx, rec(1, "bb")); !errors.Is(err, ErrBlockConflict) { t.Fatalf("want conflict, got %v", err) }
+}
+
+func TestFileBlockStore_CorruptFileDetected(t *testing.T) {
+    ctx := context.Background()
+    dir := t.TempDir()
+    fs, _ := OpenFileBlockStore(dir)
+    if err := fs.SaveBlock(ctx, rec(7, "dd")); err != nil { t.Fatalf("save: %v", err) }
+    path := filepath.Join(dir, blockName(7, []byte("dd")))
+    b, _ := os.ReadFile(path)
+    b[len(b)-2] ^= 0xff
+    if err := os.WriteFile(path, b, 0o600); err != nil { t.Fatalf("write: %v", err) }
+    if _, err := fs.BlockByHeight(ctx, 7); err == nil { t.Fatalf("want crc error") }
+}
+
+func TestMemoryBlockStore_Lookup(t *testing.T) {
+    ctx := context.Background()
+    m := NewMemoryBlockStore()
+    if _, err := m.LastBlock(ctx); !errors.Is(err, ErrNotFound) { t.Fatalf("want ErrNotFound") }
+    _ = m.SaveBlock(ctx, rec(3, "aa"))
+    _ = m.SaveBlock(ctx, rec(2, "bb"))
+    if err := m.SaveBlock(ctx, rec(3, "cc")); !errors.Is(err, ErrBlockConflict) { t.Fatalf("want conflict, got %v", err) }
+    if got, _ := m.BlockByHash(ctx, []byte("bb")); got.Height != 2 { t.Fatalf("by hash: %+v", got) }
+    if got, _ := m.LastBlock(ctx); got.Height != 3 { t.Fatalf("last: %+v", got) }
+}
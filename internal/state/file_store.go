@@ -27,37 +27,43 @@ type FileStore struct {
 func NewFileStore(path string) *FileStore { return &FileStore{path: path} }
 
 const (
-    magic   uint32 = 0x53544442 // 'STDB' (State-DB)
-    version uint16 = 1
+    magic       uint32 = 0x53544442 // 'STDB' (State-DB)
+    replayMagic uint32 = 0x53545250 // 'STRP' (replay checkpoint)
+    version     uint16 = 1
 )
 
 // on-disk layout:
 // [magic u32][version u16][reserved u16][length u32][crc32 u32][payload bytes...]
-// payload = Height u64 | Round u64 (big endian)
+// LastState payload = Height u64 | Round u64 (big endian)
 
 func writeFileAtomic(path string, s LastState) error {
+    var payload [16]byte
+    binary.BigEndian.PutUint64(payload[0:8], s.Height)
+    binary.BigEndian.PutUint64(payload[8:16], s.Round)
+    return writeFramed(path, magic, payload[:])
+}
+
+// writeFramed writes payload under the header above via tmp write + fsync +
+// rename, keeping the previous file as .bak.
+func writeFramed(path string, mg uint32, payload []byte) error {
     dir := filepath.Dir(path)
     tmp := path + ".tmp"
 
     f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
     if err != nil { return err }
-    // Build payload
-    var payload [16]byte
-    binary.BigEndian.PutUint64(payload[0:8], s.Height)
-    binary.BigEndian.PutUint64(payload[8:16], s.Round)
     // Header (with length + crc)
     length := uint32(len(payload))
-    crc := crc32.ChecksumIEEE(payload[:])
+    crc := crc32.ChecksumIEEE(payload)
     var hdr [4 + 2 + 2 + 4 + 4]byte
     off := 0
-    binary.BigEndian.PutUint32(hdr[off:], magic); off += 4
+    binary.BigEndian.PutUint32(hdr[off:], mg); off += 4
     binary.BigEndian.PutUint16(hdr[off:], version); off += 2
     binary.BigEndian.PutUint16(hdr[off:], 0); off += 2 // reserved
     binary.BigEndian.PutUint32(hdr[off:], length); off += 4
     binary.BigEndian.PutUint32(hdr[off:], crc)
 
     if _, err = f.Write(hdr[:]); err != nil { _ = f.Close(); return err }
-    if _, err = f.Write(payload[:]); err != nil { _ = f.Close(); return err }
+    if _, err = f.Write(payload); err != nil { _ = f.Close(); return err }
     if err = f.Sync(); err != nil { _ = f.Close(); return err }
     if err = f.Close(); err != nil { return err }
 
@@ -78,31 +84,42 @@ func writeFileAtomic(path string, s LastState) error {
 }
 
 func readFile(path string) (LastState, error) {
-    f, err := os.Open(path)
+    payload, err := readFramed(path, magic)
     if err != nil { return LastState{}, err }
+    if len(payload) != 16 { return LastState{}, errors.New("bad length") }
+    s := LastState{
+        Height: binary.BigEndian.Uint64(payload[0:8]),
+        Round:  binary.BigEndian.Uint64(payload[8:16]),
+    }
+    return s, nil
+}
+
+// readFramed returns the payload of a file written by writeFramed after
+// checking its magic and checksum.
+func readFramed(path string, mg uint32) ([]byte, error) {
+    f, err := os.Open(path)
+    if err != nil { return nil, err }
     defer f.Close()
     var hdr [4 + 2 + 2 + 4 + 4]byte
-    if _, err = io.ReadFull(f, hdr[:]); err != nil { return LastState{}, err }
+    if _, err = io.ReadFull(f, hdr[:]); err != nil { return nil, err }
     off := 0
-    mg := binary.BigEndian.Uint32(hdr[off:]); off += 4
-    if mg != magic { return LastState{}, errors.New("bad magic") }
+    if binary.BigEndian.Uint32(hdr[off:]) != mg { return nil, errors.New("bad magic") }
+    off += 4
     ver := binary.BigEndian.Uint16(hdr[off:]); off += 2
     _ = ver // reserved for future use
     off += 2 // reserved
     length := binary.BigEndian.Uint32(hdr[off:]); off += 4
     wantCRC := binary.BigEndian.Uint32(hdr[off:])
-    if length != 16 { return LastState{}, errors.New("bad length") }
-    var payload [16]byte
-    if _, err = io.ReadFull(f, payload[:]); err != nil { return LastState{}, err }
-    got := crc32.ChecksumIEEE(payload[:])
-    if got != wantCRC { return LastState{}, errors.New("crc mismatch") }
-    s := LastState{
-        Height: binary.BigEndian.Uint64(payload[0:8]),
-        Round:  binary.BigEndian.Uint64(payload[8:16]),
-    }
-    return s, nil
+    if length > maxFramedPayload { return nil, errors.New("bad length") }
+    payload := make([]byte, length)
+    if _, err = io.ReadFull(f, payload); err != nil { return nil, err }
+    if crc32.ChecksumIEEE(payload) != wantCRC { return nil, errors.New("crc mismatch") }
+    return payload, nil
 }
 
+// maxFramedPayload bounds what readFramed allocates for a corrupt length.
+const maxFramedPayload = 64 << 20
+
 // SaveLastState persists the last state using atomic file replace.
 func (fs *FileStore) SaveLastState(_ context.Context, s LastState) error {
     start := time.Now()
@@ -142,5 +159,61 @@ func (fs *FileStore) LoadLastState(_ context.Context) (LastState, error) {
     return LastState{}, ErrNotFound
 }
 
+// replayPath is where the replay checkpoint lives next to the last state.
+func (fs *FileStore) replayPath() string { return fs.path + ".replay" }
+
+// SaveReplay persists the replay checkpoint next to the last state file,
+// with the same atomic write protocol.
+// Payload: Floor u64 | count u32 | count x (Height u64 | len u16 | key).
+func (fs *FileStore) SaveReplay(_ context.Context, c ReplayCheckpoint) error {
+    payload := make([]byte, 12, 12+len(c.Entries)*24)
+    binary.BigEndian.PutUint64(payload[0:8], c.Floor)
+    binary.BigEndian.PutUint32(payload[8:12], uint32(len(c.Entries)))
+    var buf [10]byte
+    for _, e := range c.Entries {
+        if len(e.Key) > 0xffff { return errors.New("replay key too long") }
+        binary.BigEndian.PutUint64(buf[0:8], e.Height)
+        binary.BigEndian.PutUint16(buf[8:10], uint16(len(e.Key)))
+        payload = append(append(payload, buf[:]...), e.Key...)
+    }
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if err := writeFramed(fs.replayPath(), replayMagic, payload); err != nil {
+        metrics.Inc("state_persist_errors_total", nil)
+        logger.ErrorJ("consensus_state", map[string]any{"op":"persist_replay", "result":"error", "err": err.Error(), "trace_id": ""})
+        return err
+    }
+    return nil
+}
+
+// LoadReplay loads the replay checkpoint, falling back to its .bak copy.
+func (fs *FileStore) LoadReplay(_ context.Context) (ReplayCheckpoint, error) {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    for _, p := range []string{fs.replayPath(), fs.replayPath() + ".bak"} {
+        payload, err := readFramed(p, replayMagic)
+        if err != nil { continue }
+        if c, err := decodeReplay(payload); err == nil { return c, nil }
+    }
+    return ReplayCheckpoint{}, ErrNotFound
+}
+
+func decodeReplay(b []byte) (ReplayCheckpoint, error) {
+    bad := errors.New("bad replay checkpoint")
+    if len(b) < 12 { return ReplayCheckpoint{}, bad }
+    c := ReplayCheckpoint{Floor: binary.BigEndian.Uint64(b[0:8])}
+    n := int(binary.BigEndian.Uint32(b[8:12]))
+    b = b[12:]
+    for i := 0; i < n; i++ {
+        if len(b) < 10 { return ReplayCheckpoint{}, bad }
+        h, l := binary.BigEndian.Uint64(b[0:8]), int(binary.BigEndian.Uint16(b[8:10]))
+        if len(b) < 10+l { return ReplayCheckpoint{}, bad }
+        c.Entries = append(c.Entries, ReplayEntry{Key: string(b[10 : 10+l]), Height: h})
+        b = b[10+l:]
+    }
+    if len(b) != 0 { return ReplayCheckpoint{}, bad }
+    return c, nil
+}
+
 // Close implements Store. For FileStore it is a no-op.
 func (fs *FileStore) Close() error { return nil }
@@ -49,3 +49,21 @@ func TestFileStore_Load_NotFound(t *testing.T) {
     }
 }
 
+
+func TestFileStore_ReplayCheckpoint_RoundTripAndFallback(t *testing.T) {
+    ctx := context.Background()
+    fs := NewFileStore(filepath.Join(t.TempDir(), "laststate.dat"))
+    if _, err := fs.LoadReplay(ctx); err != ErrNotFound { t.Fatalf("want ErrNotFound, got %v", err) }
+    v1 := ReplayCheckpoint{Floor: 3, Entries: []ReplayEntry{{Key: "prepare|n1|0|b", Height: 4}}}
+    v2 := ReplayCheckpoint{Floor: 5, Entries: []ReplayEntry{{Key: "commit|n2|1|c", Height: 6}, {Key: "", Height: 7}}}
+    if err := fs.SaveReplay(ctx, v1); err != nil { t.Fatalf("save1: %v", err) }
+    if err := fs.SaveReplay(ctx, v2); err != nil { t.Fatalf("save2: %v", err) }
+    got, err := fs.LoadReplay(ctx)
+    if err != nil || got.Floor != 5 || len(got.Entries) != 2 || got.Entries[0] != v2.Entries[0] { t.Fatalf("load: %+v %v", got, err) }
+    // The last state file is untouched by checkpoints.
+    if _, err := fs.LoadLastState(ctx); err != ErrNotFound { t.Fatalf("last state: %v", err) }
+
+    if err := os.Truncate(fs.replayPath(), 20); err != nil { t.Fatalf("truncate: %v", err) }
+    got, err = fs.LoadReplay(ctx)
+    if err != nil || got.Floor != 3 || got.Entries[0] != v1.Entries[0] { t.Fatalf("fallback: %+v %v", got, err) }
+}
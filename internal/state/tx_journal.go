@@ -0,0 +1,131 @@
+package state
+
+import (
+    "bufio"
+    "encoding/binary"
+    "hash/crc32"
+    "io"
+    "os"
+    "path/filepath"
+    "sync"
+
+    "github.com/zmlAEQ/Aequa-network/pkg/logger"
+    "github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+const (
+    journalHeaderSize = 8
+    maxJournalRecord  = 1 << 20
+)
+
+// TxJournal is an append-only file of opaque mempool records, each framed as
+// [length u32][crc32 u32][body]. Appends are not fsynced: the journal is
+// meant to survive process restarts, where the page cache is kept, not power
+// loss. Load truncates a torn or corrupt tail; Compact rewrites the file
+// atomically (tmp write + fsync + rename) with the records still wanted.
+type TxJournal struct {
+    mu   sync.Mutex
+    path string
+    f    *os.File // opened lazily for append
+}
+
+// NewTxJournal returns a journal stored at path. Nothing is created on disk
+// until the first record is appended.
+func NewTxJournal(path string) *TxJournal { return &TxJournal{path: path} }
+
+// Load returns the valid records in append order.
+func (j *TxJournal) Load() ([][]byte, error) {
+    j.mu.Lock(); defer j.mu.Unlock()
+    return j.load()
+}
+
+func (j *TxJournal) load() ([][]byte, error) {
+    f, err := os.Open(j.path)
+    if os.IsNotExist(err) { return nil, nil }
+    if err != nil { return nil, err }
+    defer f.Close()
+    var recs [][]byte
+    var off int64
+    r := bufio.NewReader(f)
+    var hdr [journalHeaderSize]byte
+    for {
+        if _, err := io.ReadFull(r, hdr[:]); err != nil { break }
+        n := binary.BigEndian.Uint32(hdr[0:4])
+        if n > maxJournalRecord { break }
+        body := make([]byte, n)
+        if _, err := io.ReadFull(r, body); err != nil { break }
+        if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(hdr[4:8]) { break }
+        recs = append(recs, body)
+        off += journalHeaderSize + int64(n)
+    }
+    if st, err := f.Stat(); err == nil && st.Size() > off {
+        if err := os.Truncate(j.path, off); err != nil { return recs, err }
+        metrics.Inc("mempool_journal_recover_total", map[string]string{"result":"truncated"})
+        logger.ErrorJ("mempool_journal", map[string]any{"op":"recover", "result":"truncated", "from": st.Size(), "to": off})
+    }
+    return recs, nil
+}
+
+// Append adds one record at the end of the journal.
+func (j *TxJournal) Append(body []byte) error {
+    j.mu.Lock(); defer j.mu.Unlock()
+    if j.f == nil {
+        if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil { return err }
+        f, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+        if err != nil { return err }
+        j.f = f
+    }
+    _, err := j.f.Write(frameRecord(body))
+    return err
+}
+
+// Compact rewrites the journal with the records keep accepts, in order, and
+// returns how many it dropped. Appends wait until it is done.
+func (j *TxJournal) Compact(keep func(body []byte) bool) (int, error) {
+    j.mu.Lock(); defer j.mu.Unlock()
+    recs, err := j.load()
+    if err != nil { return 0, err }
+    kept := recs[:0]
+    for _, body := range recs {
+        if keep(body) { kept = append(kept, body) }
+    }
+    dropped := len(recs) - len(kept)
+    if dropped == 0 { return 0, nil }
+    return dropped, j.rewrite(kept)
+}
+
+// rewrite atomically replaces the journal with recs. Callers hold j.mu.
+func (j *TxJournal) rewrite(recs [][]byte) error {
+    if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil { return err }
+    tmp := j.path + ".tmp"
+    f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+    if err != nil { return err }
+    w := bufio.NewWriter(f)
+    for _, body := range recs {
+        if _, err := w.Write(frameRecord(body)); err != nil { _ = f.Close(); return err }
+    }
+    if err := w.Flush(); err != nil { _ = f.Close(); return err }
+    if err := f.Sync(); err != nil { _ = f.Close(); return err }
+    if err := f.Close(); err != nil { return err }
+    if j.f != nil {
+        _ = j.f.Close()
+        j.f = nil
+    }
+    return os.Rename(tmp, j.path)
+}
+
+// Close releases the append handle.
+func (j *TxJournal) Close() error {
+    j.mu.Lock(); defer j.mu.Unlock()
+    if j.f == nil { return nil }
+    err := j.f.Close()
+    j.f = nil
+    return err
+}
+
+func frameRecord(body []byte) []byte {
+    rec := make([]byte, journalHeaderSize, journalHeaderSize+len(body))
+    binary.BigEndian.PutUint32(rec[0:4], uint32(len(body)))
+    binary.BigEndian.PutUint32(rec[4:8], crc32.ChecksumIEEE(body))
+    return append(rec, body...)
+}
@@ -0,0 +1,38 @@
+package state
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestTxJournal_AppendLoadAndTornTail(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "sub", "mempool.journal")
+    j := NewTxJournal(path)
+    if recs, err := j.Load(); err != nil || len(recs) != 0 { t.Fatalf("missing file: %v %v", recs, err) }
+    for _, r := range []string{"a", "bb", "ccc"} {
+        if err := j.Append([]byte(r)); err != nil { t.Fatalf("append: %v", err) }
+    }
+    _ = j.Close()
+    // A crash mid-append leaves a partial record behind.
+    f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+    _, _ = f.Write([]byte{0, 0, 0, 9, 1, 2})
+    _ = f.Close()
+
+    recs, err := NewTxJournal(path).Load()
+    if err != nil || len(recs) != 3 || string(recs[2]) != "ccc" { t.Fatalf("load: %q %v", recs, err) }
+    st, _ := os.Stat(path)
+    if st.Size() != 3*journalHeaderSize+6 { t.Fatalf("torn tail not truncated: %d", st.Size()) }
+}
+
+func TestTxJournal_Compact(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "mempool.journal")
+    j := NewTxJournal(path)
+    for _, r := range []string{"keep-1", "drop", "keep-2"} { _ = j.Append([]byte(r)) }
+    dropped, err := j.Compact(func(b []byte) bool { return string(b) != "drop" })
+    if err != nil || dropped != 1 { t.Fatalf("compact: %d %v", dropped, err) }
+    // Appends after a compaction go to the rewritten file.
+    _ = j.Append([]byte("keep-3"))
+    recs, _ := j.Load()
+    if len(recs) != 3 || string(recs[0]) != "keep-1" || string(recs[2]) != "keep-3" { t.Fatalf("after compact: %q", recs) }
+}
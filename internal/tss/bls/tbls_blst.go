@@ -13,12 +13,12 @@ func PartialSign(sk PrivateKey, msg []byte, dst string) (PartialSignature, error
 	if len(sk) == 0 {
 		return nil, ErrNotImplemented
 	}
-	sec := blst.KeyGen([]byte(sk), nil)
+	sec := blst.KeyGen([]byte(sk))
 	if sec == nil {
 		return nil, ErrNotImplemented
 	}
 	var sig blst.P2Affine
-	if sig.Sign(sec, msg, []byte(dst), nil) == nil {
+	if sig.Sign(sec, msg, []byte(dst)) == nil {
 		return nil, ErrNotImplemented
 	}
 	out := sig.Compress()
@@ -40,22 +40,15 @@ func Combine(shares []PartialSignature) (AggregateSignature, error) {
 	if len(shares) == 0 {
 		return nil, ErrNotImplemented
 	}
-	agg := new(blst.P2Aggregate)
+	sigs := make([]bls381.Signature, 0, len(shares))
 	for _, s := range shares {
-		var aff blst.P2Affine
-		if aff.Uncompress(s) == nil {
-			return nil, ErrNotImplemented
-		}
-		if err := agg.Aggregate(&aff, true); err != blst.SUCCESS {
-			return nil, ErrNotImplemented
-		}
+		sigs = append(sigs, bls381.Signature(s))
 	}
-	var outAff blst.P2Affine
-	outAff.FromAggregate(agg)
-	out := outAff.Compress()
-	cp := make([]byte, len(out))
-	copy(cp, out)
-	return AggregateSignature(cp), nil
+	agg, err := bls381.Aggregate(sigs...)
+	if err != nil {
+		return nil, ErrNotImplemented
+	}
+	return AggregateSignature(agg), nil
 }
 
 // VerifyAgg keeps the single-key verify path; TBLS final verify against group key
@@ -0,0 +1,23 @@
+//go:build !blst
+
+package bls381
+
+// HashToG2 maps msg to a point in G2 under the provided DST.
+func HashToG2(msg, dst []byte) (G2Point, error) { return nil, ErrNotImplemented }
+
+// Sign signs msg under DST with the secret scalar sk (big-endian, 32 bytes).
+func Sign(sk Scalar, msg, dst []byte) (Signature, error) { return nil, ErrNotImplemented }
+
+// Verify checks a BLS signature against a pubkey and message under DST.
+func Verify(pk PubKey, sig Signature, msg, dst []byte) (bool, error) {
+    return false, ErrNotImplemented
+}
+
+// Aggregate combines multiple signatures into a single signature.
+func Aggregate(sigs ...Signature) (Signature, error) { return nil, ErrNotImplemented }
+
+// VerifyAggregate verifies an aggregate signature for messages (same msg model).
+func VerifyAggregate(pks []PubKey, sig Signature, msg, dst []byte) (bool, error) {
+    return false, ErrNotImplemented
+}
+
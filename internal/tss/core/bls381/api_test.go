@@ -1,3 +1,5 @@
+//go:build !blst
+
 package bls381
 
 import "testing"
@@ -15,6 +15,19 @@ func HashToG2(msg, dst []byte) (G2Point, error) {
 	return G2Point(cp), nil
 }
 
+// Sign signs msg under DST with the secret scalar sk (big-endian, 32 bytes)
+// and returns the compressed G2 signature.
+func Sign(sk Scalar, msg, dst []byte) (Signature, error) {
+	key := new(blst.SecretKey).Deserialize(sk)
+	if key == nil {
+		return nil, ErrInvalidInput
+	}
+	out := new(blst.P2Affine).Sign(key, msg, dst).Compress()
+	cp := make([]byte, len(out))
+	copy(cp, out)
+	return Signature(cp), nil
+}
+
 // Verify checks a BLS signature against a pubkey and message under DST.
 // pk is compressed G1 (48 bytes); sig is compressed G2 (96 bytes).
 func Verify(pk PubKey, sig Signature, msg, dst []byte) (bool, error) {
@@ -29,8 +42,7 @@ func Verify(pk PubKey, sig Signature, msg, dst []byte) (bool, error) {
 	if sigAff.Uncompress(sig) == nil {
 		return false, ErrInvalidInput
 	}
-	ok := sigAff.Verify(true, &pkAff, true, msg, dst, nil) == blst.SUCCESS
-	return ok, nil
+	return sigAff.Verify(true, &pkAff, true, msg, dst), nil
 }
 
 // Aggregate combines multiple signatures (compressed G2) into one.
@@ -38,19 +50,19 @@ func Aggregate(sigs ...Signature) (Signature, error) {
 	if len(sigs) == 0 {
 		return nil, ErrInvalidInput
 	}
-	agg := new(blst.P2Aggregate)
+	affs := make([]*blst.P2Affine, 0, len(sigs))
 	for _, s := range sigs {
-		var aff blst.P2Affine
+		aff := new(blst.P2Affine)
 		if aff.Uncompress(s) == nil {
 			return nil, ErrInvalidInput
 		}
-		if err := agg.Aggregate(&aff, true); err != blst.SUCCESS {
-			return nil, ErrInvalidInput
-		}
+		affs = append(affs, aff)
+	}
+	agg := new(blst.P2Aggregate)
+	if !agg.Aggregate(affs, true) {
+		return nil, ErrInvalidInput
 	}
-	var outAff blst.P2Affine
-	outAff.FromAggregate(agg)
-	out := outAff.Compress()
+	out := agg.ToAffine().Compress()
 	cp := make([]byte, len(out))
 	copy(cp, out)
 	return Signature(cp), nil
@@ -73,6 +85,5 @@ func VerifyAggregate(pks []PubKey, sig Signature, msg, dst []byte) (bool, error)
 		}
 		arr = append(arr, &a)
 	}
-	ok := blst.FastAggregateVerify(&sigAff, arr, msg, dst) == blst.SUCCESS
-	return ok, nil
+	return sigAff.FastAggregateVerify(true, arr, msg, dst), nil
 }
@@ -11,20 +11,15 @@ import (
 func TestVerify_SignRoundtrip(t *testing.T) {
     // Generate a keypair
     ikm := []byte("ikm-32-bytes-minimum-length-012345")
-    var sk blst.SecretKey
-    sk.KeyGen(ikm, nil)
-
-    var pkAff blst.P1Affine
-    pkAff.From(&sk)
-    pk := pkAff.Serialize()
+    sk := blst.KeyGen(ikm)
+    pk := new(blst.P1Affine).From(sk).Compress()
 
     msg := []byte("hello")
     dst := []byte("EQS/TSS/v1/SIG")
 
     // Sign
-    var sigAff blst.P2Affine
-    sigAff.Sign(&sk, msg, dst, nil)
-    sig := sigAff.Serialize()
+    sig, err := Sign(Scalar(sk.Serialize()), msg, dst)
+    if err != nil { t.Fatalf("sign: %v", err) }
 
     ok, err := Verify(PubKey(pk), Signature(sig), msg, dst)
     if err != nil || !ok { t.Fatalf("verify err=%v ok=%v", err, ok) }
@@ -33,21 +28,18 @@ func TestVerify_SignRoundtrip(t *testing.T) {
 func TestAggregate_FastVerify(t *testing.T) {
     ikm1 := []byte("ikm-1-abcdefghijklmnopqrstuvwxyz0123")
     ikm2 := []byte("ikm-2-abcdefghijklmnopqrstuvwxyz0123")
-    var sk1, sk2 blst.SecretKey
-    sk1.KeyGen(ikm1, nil)
-    sk2.KeyGen(ikm2, nil)
+    sk1, sk2 := blst.KeyGen(ikm1), blst.KeyGen(ikm2)
 
     msg := []byte("m")
     dst := []byte("EQS/TSS/v1/SIG")
 
-    var pk1, pk2 blst.P1Affine
-    pk1.From(&sk1); pk2.From(&sk2)
-    var sig1, sig2 blst.P2Affine
-    sig1.Sign(&sk1, msg, dst, nil)
-    sig2.Sign(&sk2, msg, dst, nil)
+    pk1, pk2 := new(blst.P1Affine).From(sk1).Compress(), new(blst.P1Affine).From(sk2).Compress()
+    sig1, _ := Sign(Scalar(sk1.Serialize()), msg, dst)
+    sig2, _ := Sign(Scalar(sk2.Serialize()), msg, dst)
 
-    agg, err := Aggregate(Signature(sig1.Serialize()), Signature(sig2.Serialize()))
+    agg, err := Aggregate(sig1, sig2)
     if err != nil { t.Fatalf("agg: %v", err) }
-    ok, err := VerifyAggregate([]PubKey{PubKey(pk1.Serialize()), PubKey(pk2.Serialize())}, agg, msg, dst)
+    ok, err := VerifyAggregate([]PubKey{PubKey(pk1), PubKey(pk2)}, agg, msg, dst)
     if err != nil || !ok { t.Fatalf("verify agg err=%v ok=%v", err, ok) }
+    if ok, _ := VerifyAggregate([]PubKey{PubKey(pk1)}, agg, msg, dst); ok { t.Fatalf("aggregate verified against a missing signer") }
 }
\ No newline at end of file
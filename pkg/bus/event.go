@@ -2,6 +2,8 @@ package bus
 
 import (
 	"context"
+
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
 )
 
 type Kind string
@@ -11,7 +13,8 @@ const (
     // KindConsensus represents an inbound consensus (QBFT) message delivered
     // from the network transport into the internal bus.
     KindConsensus Kind = "consensus"
-    // KindTx is reserved for future use (transaction gossip to mempool).
+    // KindTx carries submitted or gossiped transactions to the mempool on
+    // the bus's separate tx lane.
     KindTx Kind = "tx"
 )
 
@@ -21,21 +24,40 @@ type Event struct {
 	Round   uint64
 	Body    any
 	TraceID string
+	// Local marks a KindTx event submitted to this node rather than
+	// received over gossip.
+	Local bool
 }
 
 type Subscriber chan Event
 
+// Bus has two lanes of the same size: consensus (and duty) events, and tx
+// events. Keeping txs apart means a tx flood can fill only its own lane and
+// never crowds out QBFT messages.
 type Bus struct {
 	pub chan Event
+	tx  chan Event
 }
 
 func New(size int) *Bus {
 	if size <= 0 { size = 128 }
-	return &Bus{pub: make(chan Event, size)}
+	return &Bus{pub: make(chan Event, size), tx: make(chan Event, size)}
 }
 
+// Publish enqueues ev on its lane without blocking. When the lane is full the
+// event is dropped and counted in bus_dropped_total{kind}.
 func (b *Bus) Publish(_ context.Context, ev Event) {
-	select { case b.pub <- ev: default: /* drop on backpressure */ }
+	lane := b.pub
+	if ev.Kind == KindTx { lane = b.tx }
+	select {
+	case lane <- ev:
+	default:
+		metrics.Inc("bus_dropped_total", map[string]string{"kind": string(ev.Kind)})
+	}
 }
 
+// Subscribe returns the consensus lane (all kinds except KindTx).
 func (b *Bus) Subscribe() Subscriber { return b.pub }
+
+// SubscribeTx returns the tx lane (KindTx).
+func (b *Bus) SubscribeTx() Subscriber { return b.tx }
@@ -0,0 +1,28 @@
+package bus
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/zmlAEQ/Aequa-network/pkg/metrics"
+)
+
+func TestBus_TxFloodDoesNotCrowdOutConsensus(t *testing.T) {
+	metrics.Reset()
+	b := New(2)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		b.Publish(ctx, Event{Kind: KindTx})
+	}
+	b.Publish(ctx, Event{Kind: KindConsensus, Height: 7})
+	if ev := <-b.Subscribe(); ev.Kind != KindConsensus || ev.Height != 7 {
+		t.Fatalf("consensus event lost behind txs: %+v", ev)
+	}
+	if n := len(b.SubscribeTx()); n != 2 {
+		t.Fatalf("tx lane holds %d, want 2", n)
+	}
+	if dump := metrics.DumpProm(); !strings.Contains(dump, `bus_dropped_total{kind="tx"} 3`) {
+		t.Fatalf("drops not counted:\n%s", dump)
+	}
+}
@@ -5,12 +5,25 @@ import (
     "os"
 )
 
-type Operator struct { Index int `json:"index"`; PeerID string `json:"peer_id"` }
+type Operator struct {
+    Index  int    `json:"index"`
+    PeerID string `json:"peer_id"`
+    // PubKey is the operator's hex-encoded ed25519 key for signing consensus
+    // messages (optional; when set for any operator, signatures are enforced).
+    PubKey string `json:"pubkey,omitempty"`
+    // BLSPubKey is the operator's hex-encoded compressed BLS12-381 key for
+    // aggregated HotStuff certificates (optional).
+    BLSPubKey string `json:"bls_pubkey,omitempty"`
+}
 
 type ClusterLock struct {
     Name      string     `json:"name"`
     Threshold int        `json:"threshold"`
     Operators []Operator `json:"operators"`
+    // EpochLength is the number of heights per epoch; membership and
+    // threshold changes agreed on-chain take effect at epoch boundaries.
+    // 0 keeps the operator set fixed.
+    EpochLength uint64 `json:"epoch_length,omitempty"`
 }
 
 func LoadClusterLock(path string) (ClusterLock, error) {
@@ -21,3 +34,16 @@ func LoadClusterLock(path string) (ClusterLock, error) {
     return c, err
 }
 
+
+// Genesis holds the account balances the state machine starts from.
+type Genesis struct {
+    Alloc map[string]uint64 `json:"alloc"` // address -> balance
+}
+
+func LoadGenesis(path string) (Genesis, error) {
+    var g Genesis
+    b, err := os.ReadFile(path)
+    if err != nil { return g, err }
+    err = json.Unmarshal(b, &g)
+    return g, err
+}
@@ -0,0 +1,123 @@
+import { createHash, createPrivateKey, createPublicKey, sign } from "crypto";
+import { TxEnvelope } from "./provider";
+
+// Canonical binary tx encoding, version 1 (mirrors internal/payload/codec.go):
+//   tx    = version:u8 type:str field... [sig:bytes]
+//   str   = len:u32 utf-8 bytes, bytes = len:u32 raw bytes, u64 = 8 bytes
+// Integers are big-endian. The body is everything before the signature;
+// hash = sha256(body) and senders sign signingBytes(chainId, body).
+// Golden vectors shared with the Go node: vectors/tx_codec_v1.json.
+
+export const CODEC_VERSION = 1;
+export const DEFAULT_CHAIN_ID = "aequa-local";
+const SIG_DOMAIN = "aequa/sig/v1";
+
+type U64 = number | bigint | undefined;
+
+class Encoder {
+  private parts: Buffer[] = [];
+
+  str(v: string | undefined): this {
+    return this.bytes(Buffer.from(v ?? "", "utf8"));
+  }
+
+  bytes(v: Uint8Array | undefined): this {
+    const b = Buffer.from(v ?? []);
+    const len = Buffer.alloc(4);
+    len.writeUInt32BE(b.length);
+    this.parts.push(len, b);
+    return this;
+  }
+
+  u64(v: U64): this {
+    const b = Buffer.alloc(8);
+    b.writeBigUInt64BE(BigInt(v ?? 0));
+    this.parts.push(b);
+    return this;
+  }
+
+  raw(v: Uint8Array | number[]): this {
+    this.parts.push(Buffer.from(v));
+    return this;
+  }
+
+  out(): Buffer {
+    return Buffer.concat(this.parts);
+  }
+}
+
+function b64(v: string | undefined): Buffer {
+  return Buffer.from(v ?? "", "base64");
+}
+
+// txBody returns the canonical encoding of every field but the signature.
+export function txBody(tx: TxEnvelope): Buffer {
+  const type = tx.type ?? "plaintext_v1";
+  const e = new Encoder().raw([CODEC_VERSION]).str(type);
+  switch (type) {
+    case "plaintext_v1":
+      return e.str(tx.from).u64(tx.nonce).u64(tx.gas).u64(tx.fee).str(tx.to).u64(tx.value).out();
+    case "auction_bid_v1":
+      return e.str(tx.from).u64(tx.nonce).u64(tx.gas).u64(tx.bid).str(tx.fee_recipient).out();
+    case "private_v1":
+      return e
+        .str(tx.from)
+        .u64(tx.nonce)
+        .bytes(b64(tx.ciphertext))
+        .bytes(b64(tx.ephemeral_key))
+        .u64(tx.target_height)
+        .u64(tx.batch_index)
+        .bytes(b64(tx.punctured_key))
+        .out();
+    case "reconfig_v1":
+      return e
+        .str(tx.from)
+        .u64(tx.nonce)
+        .u64(tx.epoch)
+        .str(tx.op)
+        .str(tx.operator)
+        .bytes(b64(tx.pubkey))
+        .u64(tx.threshold)
+        .out();
+    case "bundle_v1": {
+      const txs = tx.txs ?? [];
+      e.str(tx.from).u64(tx.bid).u64(txs.length);
+      for (const t of txs) e.bytes(b64(t));
+      return e.out();
+    }
+    default:
+      throw new Error(`unsupported tx type ${type}`);
+  }
+}
+
+// encodeTx returns the full canonical encoding (body, then the signature for
+// signed types).
+export function encodeTx(tx: TxEnvelope): Buffer {
+  const body = txBody(tx);
+  if (tx.type === "private_v1") return body;
+  return new Encoder().raw(body).bytes(b64(tx.sig)).out();
+}
+
+// txHash returns the hex tx hash the node deduplicates by.
+export function txHash(tx: TxEnvelope): string {
+  return createHash("sha256").update(txBody(tx)).digest("hex");
+}
+
+// signingBytes returns what the sender signs for chainId.
+export function signingBytes(tx: TxEnvelope, chainId: string = DEFAULT_CHAIN_ID): Buffer {
+  return new Encoder().str(SIG_DOMAIN).str(chainId).raw(txBody(tx)).out();
+}
+
+// PKCS#8 DER prefix of an ed25519 private key; the 32-byte seed follows.
+const ED25519_PKCS8_PREFIX = Buffer.from("302e020100300506032b657004220420", "hex");
+
+// signTx sets `from` to the hex ed25519 public key of seed and `sig` to the
+// signature over signingBytes (plaintext_v1, auction_bid_v1 and bundle_v1;
+// the txs of a bundle are signed by their own senders first).
+export function signTx(tx: TxEnvelope, seed: Uint8Array, chainId: string = DEFAULT_CHAIN_ID): TxEnvelope {
+  const key = createPrivateKey({ key: Buffer.concat([ED25519_PKCS8_PREFIX, Buffer.from(seed)]), format: "der", type: "pkcs8" });
+  const spki = createPublicKey(key).export({ format: "der", type: "spki" }) as Buffer;
+  const signed: TxEnvelope = { ...tx, type: tx.type ?? "plaintext_v1", from: spki.subarray(spki.length - 32).toString("hex") };
+  signed.sig = sign(null, signingBytes(signed, chainId), key).toString("base64");
+  return signed;
+}
@@ -1,4 +1,6 @@
 import { AequaProvider, TxEnvelope, TxType } from "./provider";
 import { encryptPrivateTx } from "./beast";
+import { CODEC_VERSION, DEFAULT_CHAIN_ID, encodeTx, signTx, signingBytes, txBody, txHash } from "./codec";
 
 export { AequaProvider, TxEnvelope, TxType, encryptPrivateTx };
+export { CODEC_VERSION, DEFAULT_CHAIN_ID, encodeTx, signTx, signingBytes, txBody, txHash };
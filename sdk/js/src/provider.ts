@@ -1,6 +1,6 @@
 import fetch from "node-fetch";
 
-export type TxType = "plaintext_v1" | "auction_bid_v1" | "private_v1";
+export type TxType = "plaintext_v1" | "auction_bid_v1" | "private_v1" | "reconfig_v1" | "bundle_v1";
 
 export interface TxEnvelope {
   type?: TxType; // defaults to plaintext_v1
@@ -8,6 +8,8 @@ export interface TxEnvelope {
   nonce: number;
   gas?: number;
   fee?: number;
+  to?: string; // plaintext_v1 transfer recipient
+  value?: number; // amount moved to `to`
   bid?: number;
   fee_recipient?: string;
   ciphertext?: string; // base64
@@ -16,6 +18,14 @@ export interface TxEnvelope {
    batch_index?: number;
    punctured_key?: string; // base64
   sig?: string; // base64
+  // reconfig_v1 fields
+  epoch?: number;
+  op?: string;
+  operator?: string;
+  pubkey?: string; // base64
+  threshold?: number;
+  // bundle_v1: the bundled txs in execution order, each its encodeTx bytes in base64
+  txs?: string[];
 }
 
 export class AequaProvider {
@@ -0,0 +1,28 @@
+// Checks the SDK codec against the golden vectors the Go node is tested
+// with (internal/p2p/wire/codec_test.go). Run after `npm run build`.
+import { test } from "node:test";
+import assert from "node:assert/strict";
+import { readFileSync } from "node:fs";
+import { encodeTx, signingBytes, signTx, txHash } from "../dist/codec.js";
+
+const golden = JSON.parse(readFileSync(new URL("../vectors/tx_codec_v1.json", import.meta.url), "utf8"));
+
+for (const v of golden.vectors) {
+  test(v.name, () => {
+    assert.equal(encodeTx(v.tx).toString("hex"), v.encoding);
+    assert.equal(txHash(v.tx), v.hash);
+    if (v.signing_bytes) {
+      assert.equal(signingBytes(v.tx, golden.chain_id).toString("hex"), v.signing_bytes);
+    }
+  });
+}
+
+test("signTx reproduces the signed vectors", () => {
+  const seed = Buffer.from(golden.seed, "hex");
+  for (const v of golden.vectors.filter((v) => v.signing_bytes && v.tx.sig)) {
+    const { from, sig, ...unsigned } = v.tx;
+    const signed = signTx(unsigned, seed, golden.chain_id);
+    assert.equal(signed.from, from);
+    assert.equal(signed.sig, sig);
+  }
+});
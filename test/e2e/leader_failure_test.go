@@ -1,6 +1,37 @@
 package e2e
 
-import "testing"
+import (
+    "testing"
 
-func TestLeaderFailurePlaceholder(t *testing.T) { t.Skip("placeholder") }
+    qbft "github.com/zmlAEQ/Aequa-network/internal/consensus/qbft"
+)
 
+// A crashed round-0 proposer is replaced after a round-change quorum: the
+// next round has a different deterministic proposer, who proposes with the
+// round-change quorum as justification, and the failed leader can no longer
+// propose.
+func TestLeaderFailure_RotatesProposer(t *testing.T) {
+    vs := qbft.NewValidatorSet([]string{"node0", "node1", "node2", "node3"}, 3)
+    const h = 5
+    crashed := vs.Proposer(h, 0)
+    st := &qbft.State{Validators: vs}
+    // The remaining three members time out and vote for round 1.
+    var rcs []qbft.Message
+    for _, id := range vs.IDs() {
+        if id == crashed { continue }
+        rc := qbft.Message{ID: "vc-" + id, From: id, Type: qbft.MsgViewChange, Height: h, Round: 1}
+        rcs = append(rcs, rc)
+        if err := st.Process(rc); err != nil {
+            t.Fatalf("view-change from %s: %v", id, err)
+        }
+    }
+    if st.Round != 1 { t.Fatalf("want round 1 after round-change quorum, got %d", st.Round) }
+    next := vs.Proposer(h, 1)
+    if next == crashed { t.Fatalf("proposer did not rotate away from %s", crashed) }
+    if err := st.Process(qbft.Message{ID: "blk", From: crashed, Type: qbft.MsgPreprepare, Height: h, Round: 1, Justification: rcs}); err == nil {
+        t.Fatalf("crashed leader must not propose in round 1")
+    }
+    if err := st.Process(qbft.Message{ID: "blk", From: next, Type: qbft.MsgPreprepare, Height: h, Round: 1, Justification: rcs}); err != nil {
+        t.Fatalf("round-1 proposer: %v", err)
+    }
+}